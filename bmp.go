@@ -0,0 +1,152 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// bmpFileHeaderSize is the on-disk size of BITMAPFILEHEADER.
+const bmpFileHeaderSize = 14
+
+// maxBMPSize bounds the files ReadBMP will buffer.
+const maxBMPSize = 1 << 30
+
+func init() {
+	image.RegisterFormat("bmp", "BM", DecodeBMP, DecodeBMPConfig)
+}
+
+// ReadBMP reads a .bmp file: a BITMAPFILEHEADER followed by a packed DIB
+// with any header version, compression or color profile that ParseDIB
+// accepts.
+func ReadBMP(r io.Reader) (*DIB, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxBMPSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBMPSize {
+		return nil, errors.New("bmp: file is too large")
+	}
+	return parseBMP(data)
+}
+
+func parseBMP(data []byte) (*DIB, error) {
+	if len(data) < bmpFileHeaderSize {
+		return nil, errors.New("bmp: file header is truncated")
+	}
+	var fh BITMAPFILEHEADER
+	binary.Read(bytes.NewReader(data), binary.LittleEndian, &fh)
+	if fh.BfType != 0x4D42 {
+		return nil, errors.New("bmp: missing BM signature")
+	}
+
+	dib := data[bmpFileHeaderSize:]
+	d, off, err := parseDIBHeader(dib)
+	if err != nil {
+		return nil, fmt.Errorf("bmp: %v", err)
+	}
+	// BfOffBits wins over the end of the color table; some writers leave
+	// a gap between the two.
+	if bits := int(fh.BfOffBits) - bmpFileHeaderSize; bits >= off && bits <= len(dib) {
+		off = bits
+	}
+	if err := d.readProfile(dib); err != nil {
+		return nil, fmt.Errorf("bmp: %v", err)
+	}
+	if err := d.setBits(dib[off:]); err != nil {
+		return nil, fmt.Errorf("bmp: %v", err)
+	}
+	return d, nil
+}
+
+// DecodeBMP decodes a .bmp file as DIB.Image does.
+func DecodeBMP(r io.Reader) (image.Image, error) {
+	d, err := ReadBMP(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.Image()
+}
+
+// DecodeBMPConfig returns the dimensions and color model of a .bmp file
+// without decoding the bits.
+func DecodeBMPConfig(r io.Reader) (image.Config, error) {
+	var fh BITMAPFILEHEADER
+	if err := binary.Read(r, binary.LittleEndian, &fh); err != nil {
+		return image.Config{}, err
+	}
+	if fh.BfType != 0x4D42 {
+		return image.Config{}, errors.New("bmp: missing BM signature")
+	}
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return image.Config{}, err
+	}
+	if size < 12 || size > 1<<10 {
+		return image.Config{}, fmt.Errorf("bmp: bad DIB header size %d", size)
+	}
+
+	// Read the header and a color table of up to 256 entries.
+	hdr := make([]byte, int(size)+4*256)
+	binary.LittleEndian.PutUint32(hdr, size)
+	n, err := io.ReadFull(r, hdr[4:])
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return image.Config{}, err
+	}
+	d, _, err := parseDIBHeader(hdr[:4+n])
+	if err != nil {
+		return image.Config{}, fmt.Errorf("bmp: %v", err)
+	}
+
+	cfg := image.Config{Width: d.Width(), Height: d.Height(), ColorModel: color.NRGBAModel}
+	if d.Header.BiBitCount <= 8 {
+		pal := make(color.Palette, 1<<d.Header.BiBitCount)
+		for i := range pal {
+			c := color.RGBA{A: 0xFF}
+			if i < len(d.Palette) {
+				q := d.Palette[i]
+				c.R, c.G, c.B = q.RgbRed, q.RgbGreen, q.RgbBlue
+			}
+			pal[i] = c
+		}
+		cfg.ColorModel = pal
+	}
+	return cfg, nil
+}
+
+// EncodeBMP writes img as a .bmp file in the format selected by opts, which
+// may be nil as for NewDIB.
+func EncodeBMP(w io.Writer, img image.Image, opts *DIBOptions) error {
+	d, err := NewDIB(img, opts)
+	if err != nil {
+		return err
+	}
+	return d.WriteBMP(w)
+}
+
+// WriteBMP writes d as a .bmp file.
+func (d *DIB) WriteBMP(w io.Writer) error {
+	info := d.InfoBytes()
+	fh := BITMAPFILEHEADER{
+		BfType:    0x4D42,
+		BfSize:    uint32(bmpFileHeaderSize + len(info) + len(d.Bits) + len(d.Profile)),
+		BfOffBits: uint32(bmpFileHeaderSize + len(info)),
+	}
+	if err := binary.Write(w, binary.LittleEndian, fh); err != nil {
+		return err
+	}
+	for _, b := range [][]byte{info, d.Bits, d.Profile} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readBMPFile(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "bmp", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// The fixtures are written by hand, not by WriteBMP.
+func TestReadBMP(t *testing.T) {
+	tests := []struct {
+		name  string
+		want  *image.NRGBA
+		check func(d *DIB) error
+	}{
+		// 32 bpp BI_BITFIELDS with an alpha mask, in RGBA byte order.
+		{"v4-rgba.bmp", dibRows("RGB", "Wh."), func(d *DIB) error {
+			if d.Header.BiSize != 108 || d.Masks != [4]uint32{0xFF, 0xFF00, 0xFF0000, 0xFF000000} ||
+				d.ColorSpace != LCS_sRGB || d.TopDown() {
+				return fmt.Errorf("header %+v, masks %#x, color space %#x", d.Header, d.Masks, d.ColorSpace)
+			}
+			return nil
+		}},
+		// Top-down 24 bpp with a rendering intent, an embedded profile
+		// and a gap before the bits.
+		{"v5-profile.bmp", dibRows("RGB", "WKB"), func(d *DIB) error {
+			if d.Header.BiSize != 124 || d.ColorSpace != PROFILE_EMBEDDED || d.Intent != LCS_GM_IMAGES ||
+				string(d.Profile) != "fake ICC profile" || !d.TopDown() || len(d.Bits) != 24 {
+				return fmt.Errorf("header %+v, color space %#x, intent %d, profile %q",
+					d.Header, d.ColorSpace, d.Intent, d.Profile)
+			}
+			return nil
+		}},
+		// An OS/2 BITMAPCOREHEADER with an RGBTRIPLE color table.
+		{"core-4bpp.bmp", dibRows("RGB", "WKR"), func(d *DIB) error {
+			if d.Header.BiBitCount != 4 || len(d.Palette) != 16 || d.Palette[4] != (RGBQUAD{0xFF, 0xFF, 0xFF, 0}) {
+				return fmt.Errorf("header %+v, palette %v", d.Header, d.Palette)
+			}
+			return nil
+		}},
+	}
+	for _, tt := range tests {
+		data := readBMPFile(t, tt.name)
+		d, err := ReadBMP(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if err := tt.check(d); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+
+		img, err := DecodeBMP(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		checkSameImage(t, tt.name, img, tt.want)
+
+		// Through the image package, by the BM signature.
+		img, format, err := image.Decode(bytes.NewReader(data))
+		if err != nil || format != "bmp" {
+			t.Errorf("%s: image.Decode gave format %q, %v", tt.name, format, err)
+			continue
+		}
+		checkSameImage(t, tt.name+" via image.Decode", img, tt.want)
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || format != "bmp" || cfg.Width != 3 || cfg.Height != 2 {
+			t.Errorf("%s: image.DecodeConfig gave %+v, %q, %v", tt.name, cfg, format, err)
+			continue
+		}
+		pal, paletted := cfg.ColorModel.(color.Palette)
+		if _, ok := img.(*image.Paletted); ok != paletted ||
+			paletted && (len(pal) != 16 || pal[4] != color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}) {
+			t.Errorf("%s: color model %v for a %T", tt.name, cfg.ColorModel, img)
+		}
+	}
+
+	// Writing a fixture back reproduces it, but for the gap before the
+	// bits.
+	for _, name := range []string{"v4-rgba.bmp", "v5-profile.bmp"} {
+		data := readBMPFile(t, name)
+		d, _ := ReadBMP(bytes.NewReader(data))
+		var b bytes.Buffer
+		if err := d.WriteBMP(&b); err != nil {
+			t.Fatal(err)
+		}
+		if name == "v5-profile.bmp" {
+			var fh BITMAPFILEHEADER
+			binary.Read(bytes.NewReader(data), binary.LittleEndian, &fh)
+			fh.BfSize -= 4
+			fh.BfOffBits -= 4
+			var want bytes.Buffer
+			binary.Write(&want, binary.LittleEndian, fh)
+			hdr := append([]byte(nil), data[bmpFileHeaderSize:bmpFileHeaderSize+124]...)
+			binary.LittleEndian.PutUint32(hdr[112:], binary.LittleEndian.Uint32(hdr[112:])-4)
+			want.Write(hdr)
+			want.Write(data[bmpFileHeaderSize+124+4:])
+			data = want.Bytes()
+		}
+		if !bytes.Equal(b.Bytes(), data) {
+			t.Errorf("%s: WriteBMP gave\n%x\nwant\n%x", name, b.Bytes(), data)
+		}
+	}
+}
+
+func TestBMPErrors(t *testing.T) {
+	v4 := readBMPFile(t, "v4-rgba.bmp")
+	patch := func(off int, v uint32) []byte {
+		b := append([]byte(nil), v4...)
+		binary.LittleEndian.PutUint32(b[off:], v)
+		return b
+	}
+	for _, tt := range []struct {
+		name    string
+		data    []byte
+		err     string
+		cfgErrs bool // DecodeBMPConfig fails too
+	}{
+		{"empty", nil, "bmp: file header is truncated", true},
+		{"no signature", append([]byte("MB"), v4[2:]...), "bmp: missing BM signature", true},
+		{"bad header size", patch(14, 20), "bmp: bad DIB header size 20", true},
+		{"truncated header", v4[:14+60], "bmp: bad DIB header size 108", true},
+		{"truncated bits", v4[:len(v4)-1], "bmp: DIB bits are truncated", false},
+		{"too wide", patch(18, 1<<16+1), "bmp: bad DIB size 65537x2", false},
+	} {
+		if _, err := ReadBMP(bytes.NewReader(tt.data)); err == nil || err.Error() != tt.err {
+			t.Errorf("%s: ReadBMP error is %v, want %q", tt.name, err, tt.err)
+		}
+		if _, err := DecodeBMPConfig(bytes.NewReader(tt.data)); (err != nil) != tt.cfgErrs {
+			t.Errorf("%s: DecodeBMPConfig error is %v", tt.name, err)
+		}
+	}
+
+	// A BfOffBits inside the headers is ignored.
+	if d, err := ReadBMP(bytes.NewReader(patch(10, 14))); err != nil || len(d.Bits) != 24 || d.Bits[0] != 0xFF {
+		t.Errorf("bad BfOffBits: %v", err)
+	}
+
+	if err := EncodeBMP(new(bytes.Buffer), dibPrimaries(2, 2), &DIBOptions{BitCount: 3}); err == nil {
+		t.Error("EncodeBMP wrote a 3 bpp bitmap")
+	}
+	d, _ := NewDIB(dibPrimaries(2, 2), nil)
+	for n := 0; n < 3; n++ {
+		if err := d.WriteBMP(&failingWriter{n}); err != errWriteFailed {
+			t.Errorf("WriteBMP failing after %d writes: %v", n, err)
+		}
+	}
+}
+
+var errWriteFailed = errors.New("write failed")
+
+// failingWriter fails after n writes.
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errWriteFailed
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestEncodeBMP(t *testing.T) {
+	alpha := dibTestImage(7, 5, true)
+	paletted := dibPaletted(7, 5, 16)
+	tests := []struct {
+		name string
+		src  image.Image
+		opts *DIBOptions
+	}{
+		{"default", alpha, nil},
+		{"top-down", alpha, &DIBOptions{TopDown: true}},
+		{"24bpp", dibTestImage(7, 5, false), &DIBOptions{BitCount: 24}},
+		{"16bpp 5-6-5", dibPrimaries(7, 5), &DIBOptions{BitCount: 16, Masks: [4]uint32{0xF800, 0x07E0, 0x001F}}},
+		{"32bpp alpha mask", alpha, &DIBOptions{Masks: [4]uint32{0xFF0000, 0xFF00, 0xFF, 0xFF000000}}},
+		{"1bpp", dibPaletted(7, 5, 2), &DIBOptions{BitCount: 1}},
+		{"4bpp", paletted, &DIBOptions{BitCount: 4}},
+		{"8bpp", paletted, &DIBOptions{BitCount: 8}},
+		{"RLE4", paletted, &DIBOptions{BitCount: 4, RLE: true}},
+		{"RLE8", dibPaletted(7, 5, 200), &DIBOptions{BitCount: 8, RLE: true}},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := EncodeBMP(&b, tt.src, tt.opts); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		data := b.Bytes()
+		var fh BITMAPFILEHEADER
+		binary.Read(bytes.NewReader(data), binary.LittleEndian, &fh)
+		d, _ := NewDIB(tt.src, tt.opts)
+		if fh.BfType != 0x4D42 || int(fh.BfSize) != len(data) ||
+			int(fh.BfOffBits) != bmpFileHeaderSize+len(d.InfoBytes()) {
+			t.Errorf("%s: file header %+v for %d bytes", tt.name, fh, len(data))
+		}
+		img, err := DecodeBMP(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		checkSameImage(t, tt.name, img, tt.src)
+		cfg, err := DecodeBMPConfig(bytes.NewReader(data))
+		if err != nil || cfg.Width != 7 || cfg.Height != 5 {
+			t.Errorf("%s: config %+v, %v", tt.name, cfg, err)
+		}
+	}
+}
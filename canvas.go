@@ -0,0 +1,380 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"image"
+	"math"
+)
+
+// Canvas is a drawing surface with the GDI drawing calls of gdi32.go,
+// minus the HDC argument. HDCCanvas draws through GDI on Windows and
+// SoftwareCanvas into an image.RGBA anywhere, so drawing code written
+// against Canvas can be tested on any platform.
+//
+// The methods follow their GDI counterparts, except that they report
+// failure through their results instead of panicking. Objects belong to
+// the canvas that created them and must be deleted with it.
+type Canvas interface {
+	CreatePen(iStyle, cWidth int, color COLORREF) HPEN
+	CreateSolidBrush(color COLORREF) HBRUSH
+	CreateHatchBrush(iHatch int, color COLORREF) HBRUSH
+	CreateBrushIndirect(lplb *LOGBRUSH) HBRUSH
+	GetStockObject(fnObject int) HGDIOBJ
+	SelectObject(hgdiobj HGDIOBJ) HGDIOBJ
+	DeleteObject(hObject HGDIOBJ) bool
+
+	SetTextColor(crColor COLORREF) COLORREF
+	SetBkColor(crColor COLORREF) COLORREF
+	SetBkMode(iBkMode int) int
+	SetROP2(rop2 int) int
+	SetBrushOrgEx(nXOrg, nYOrg int, lppt *POINT) bool
+
+	MoveToEx(x, y int, lpPoint *POINT) bool
+	LineTo(nXEnd, nYEnd int) bool
+	Rectangle(nLeftRect, nTopRect, nRightRect, nBottomRect int) bool
+	Ellipse(nLeftRect, nTopRect, nRightRect, nBottomRect int) bool
+	FillRect(lprc *RECT, hbr HBRUSH) bool
+	SetPixel(x, y int, crColor COLORREF) COLORREF
+	GetPixel(x, y int) COLORREF
+
+	// BitBlt copies from src, which must be a canvas of the same kind, or
+	// nil for raster operations without a source.
+	BitBlt(nXDest, nYDest, nWidth, nHeight int, src Canvas, nXSrc, nYSrc int, dwRop uint) bool
+}
+
+// SoftwareCanvas is a Canvas that paints into an image.RGBA in the
+// MM_TEXT mapping mode, with GDI's pen, brush, background mode and raster
+// operation semantics. It hits the same pixels as GDI for lines,
+// rectangles and fills, and the same to within a pixel for ellipses. Text
+// is not drawn: stock fonts can be selected, but no text calls exist.
+type SoftwareCanvas struct {
+	dc       *softDC
+	pos      POINT
+	penWidth int // logical width of the selected pen
+	objects  map[HGDIOBJ]interface{}
+	next     HGDIOBJ
+	pen      HGDIOBJ
+	brush    HGDIOBJ
+	font     HGDIOBJ
+}
+
+// softCanvasStock marks the handles of stock objects on a SoftwareCanvas;
+// the low bits hold the stock object index.
+const softCanvasStock HGDIOBJ = 0x80000000
+
+// softCanvasPen is a pen created on a SoftwareCanvas.
+type softCanvasPen struct {
+	pen   softPen
+	width int
+}
+
+// softCanvasFont is a stock font; fonts only take part in selection.
+type softCanvasFont struct{}
+
+// NewSoftwareCanvas returns a canvas that draws into img, with the
+// defaults of a new GDI DC: the black pen, the white brush and the system
+// font selected, black text on an opaque white background.
+func NewSoftwareCanvas(img *image.RGBA) *SoftwareCanvas {
+	return &SoftwareCanvas{
+		dc:      newSoftDC(img),
+		objects: make(map[HGDIOBJ]interface{}),
+		next:    0x100,
+		pen:     softCanvasStock | BLACK_PEN,
+		brush:   softCanvasStock | WHITE_BRUSH,
+		font:    softCanvasStock | SYSTEM_FONT,
+	}
+}
+
+// Image returns the image the canvas draws into.
+func (c *SoftwareCanvas) Image() *image.RGBA {
+	return c.dc.dst
+}
+
+func newCanvasPen(style uint32, width int, color COLORREF) softCanvasPen {
+	return softCanvasPen{
+		pen: softPen{
+			style:      createPenStyle(style, float64(width)),
+			width:      float64(width),
+			color:      color,
+			miterLimit: 10,
+		},
+		width: width,
+	}
+}
+
+// object returns the pen, brush or font behind h, or nil.
+func (c *SoftwareCanvas) object(h HGDIOBJ) interface{} {
+	if h&softCanvasStock == 0 {
+		return c.objects[h]
+	}
+	switch i := int(h &^ softCanvasStock); {
+	case i == NULL_BRUSH:
+		return softBrush{style: BS_NULL}
+	case i == WHITE_PEN:
+		return newCanvasPen(PS_SOLID, 0, 0xFFFFFF)
+	case i == BLACK_PEN || i == DC_PEN:
+		return newCanvasPen(PS_SOLID, 0, 0)
+	case i == NULL_PEN:
+		return newCanvasPen(PS_NULL, 0, 0)
+	case i >= OEM_FIXED_FONT && i <= DEFAULT_GUI_FONT && i != DEFAULT_PALETTE:
+		return softCanvasFont{}
+	default:
+		if color, ok := emfStockBrushes[i]; ok {
+			return softBrush{style: BS_SOLID, color: color}
+		}
+	}
+	return nil
+}
+
+func (c *SoftwareCanvas) add(o interface{}) HGDIOBJ {
+	h := c.next
+	c.next += 4
+	c.objects[h] = o
+	return h
+}
+
+func (c *SoftwareCanvas) CreatePen(iStyle, cWidth int, color COLORREF) HPEN {
+	if cWidth < 0 {
+		cWidth = 0
+	}
+	return HPEN(c.add(newCanvasPen(uint32(iStyle), cWidth, color)))
+}
+
+func (c *SoftwareCanvas) CreateSolidBrush(color COLORREF) HBRUSH {
+	return HBRUSH(c.add(softBrush{style: BS_SOLID, color: color}))
+}
+
+func (c *SoftwareCanvas) CreateHatchBrush(iHatch int, color COLORREF) HBRUSH {
+	if iHatch < HS_HORIZONTAL || iHatch > HS_DIAGCROSS {
+		return 0
+	}
+	return HBRUSH(c.add(softBrush{style: BS_HATCHED, color: color, hatch: uint32(iHatch)}))
+}
+
+// CreateBrushIndirect supports solid, hatched and null brushes.
+func (c *SoftwareCanvas) CreateBrushIndirect(lplb *LOGBRUSH) HBRUSH {
+	switch lplb.LbStyle {
+	case BS_SOLID:
+		return c.CreateSolidBrush(lplb.LbColor)
+	case BS_HATCHED:
+		return c.CreateHatchBrush(int(lplb.LbHatch), lplb.LbColor)
+	case BS_NULL:
+		return HBRUSH(c.add(softBrush{style: BS_NULL}))
+	}
+	return 0
+}
+
+func (c *SoftwareCanvas) GetStockObject(fnObject int) HGDIOBJ {
+	h := softCanvasStock | HGDIOBJ(fnObject)
+	if fnObject < 0 || c.object(h) == nil {
+		return 0
+	}
+	return h
+}
+
+// SelectObject returns the previously selected object of the same kind,
+// or 0 if hgdiobj is not a pen, brush or font of this canvas.
+func (c *SoftwareCanvas) SelectObject(hgdiobj HGDIOBJ) HGDIOBJ {
+	var old HGDIOBJ
+	switch o := c.object(hgdiobj).(type) {
+	case softCanvasPen:
+		old, c.pen = c.pen, hgdiobj
+		c.dc.pen, c.penWidth = o.pen, o.width
+	case softBrush:
+		old, c.brush = c.brush, hgdiobj
+		c.dc.brush = o
+	case softCanvasFont:
+		old, c.font = c.font, hgdiobj
+	}
+	return old
+}
+
+// DeleteObject fails for objects that are selected. Deleting a stock
+// object does nothing.
+func (c *SoftwareCanvas) DeleteObject(hObject HGDIOBJ) bool {
+	if hObject&softCanvasStock != 0 {
+		return c.object(hObject) != nil
+	}
+	if _, ok := c.objects[hObject]; !ok || hObject == c.pen || hObject == c.brush {
+		return false
+	}
+	delete(c.objects, hObject)
+	return true
+}
+
+func (c *SoftwareCanvas) SetTextColor(crColor COLORREF) COLORREF {
+	old := c.dc.textColor
+	c.dc.textColor = crColor
+	return old
+}
+
+func (c *SoftwareCanvas) SetBkColor(crColor COLORREF) COLORREF {
+	old := c.dc.bkColor
+	c.dc.bkColor = crColor
+	return old
+}
+
+func (c *SoftwareCanvas) SetBkMode(iBkMode int) int {
+	if iBkMode != TRANSPARENT && iBkMode != OPAQUE {
+		return 0
+	}
+	old := c.dc.bkMode
+	c.dc.bkMode = iBkMode
+	return old
+}
+
+func (c *SoftwareCanvas) SetROP2(rop2 int) int {
+	if rop2 < R2_BLACK || rop2 > R2_WHITE {
+		return 0
+	}
+	old := c.dc.rop2
+	c.dc.rop2 = rop2
+	return old
+}
+
+func (c *SoftwareCanvas) SetBrushOrgEx(nXOrg, nYOrg int, lppt *POINT) bool {
+	if lppt != nil {
+		lppt.X, lppt.Y = int32(c.dc.brushOrg.X), int32(c.dc.brushOrg.Y)
+	}
+	c.dc.brushOrg = image.Point{nXOrg, nYOrg}
+	return true
+}
+
+func (c *SoftwareCanvas) MoveToEx(x, y int, lpPoint *POINT) bool {
+	if lpPoint != nil {
+		*lpPoint = c.pos
+	}
+	c.pos = POINT{X: int32(x), Y: int32(y)}
+	return true
+}
+
+// LineTo draws from the current position up to, but not including,
+// (nXEnd, nYEnd), and moves the current position there.
+func (c *SoftwareCanvas) LineTo(nXEnd, nYEnd int) bool {
+	start := canvasPt(int(c.pos.X), int(c.pos.Y))
+	c.pos = POINT{X: int32(nXEnd), Y: int32(nYEnd)}
+	c.dc.stroke([]rasterFigure{{pts: []rasterPt{start, canvasPt(nXEnd, nYEnd)}}})
+	return true
+}
+
+// canvasPt returns the raster space center of pixel (x, y).
+func canvasPt(x, y int) rasterPt {
+	return rasterPt{float64(x) + 0.5, float64(y) + 0.5}
+}
+
+// box returns the pixel centers bounding a shape drawn in the given
+// rectangle, whose right and bottom edges are excluded. Inside-frame pens
+// move the edges in by half their width.
+func (c *SoftwareCanvas) box(left, top, right, bottom int) (l, t, r, b float64) {
+	l, r = float64(left), float64(right)
+	if l > r {
+		l, r = r, l
+	}
+	t, b = float64(top), float64(bottom)
+	if t > b {
+		t, b = b, t
+	}
+	l, t, r, b = l+0.5, t+0.5, r-0.5, b-0.5
+	if hw := float64(c.penWidth) / 2; c.dc.pen.style&PS_STYLE_MASK == PS_INSIDEFRAME && hw > 0.75 {
+		l, t, r, b = l+hw, t+hw, r-hw, b-hw
+	}
+	return l, t, math.Max(l, r), math.Max(t, b)
+}
+
+// Rectangle outlines the rectangle with the pen and fills it with the
+// brush. With the null pen the filled area is a pixel smaller in each
+// direction, as in GDI.
+func (c *SoftwareCanvas) Rectangle(nLeftRect, nTopRect, nRightRect, nBottomRect int) bool {
+	l, t, r, b := c.box(nLeftRect, nTopRect, nRightRect, nBottomRect)
+	figs := []rasterFigure{{pts: []rasterPt{{l, t}, {r, t}, {r, b}, {l, b}}, closed: true}}
+	c.dc.fill(figs)
+	c.dc.stroke(figs)
+	return true
+}
+
+func (c *SoftwareCanvas) Ellipse(nLeftRect, nTopRect, nRightRect, nBottomRect int) bool {
+	l, t, r, b := c.box(nLeftRect, nTopRect, nRightRect, nBottomRect)
+	pts := ellipseArc(nil, identityAffine, rasterPt{(l + r) / 2, (t + b) / 2}, (r-l)/2, (b-t)/2, 0, 2*math.Pi)
+	figs := []rasterFigure{{pts: pts[:len(pts)-1], closed: true}}
+	c.dc.fill(figs)
+	c.dc.stroke(figs)
+	return true
+}
+
+// FillRect fills lprc, excluding its right and bottom edges, with hbr
+// regardless of the selected brush and binary raster operation. As in
+// GDI, hbr may be a COLOR_* index plus one, which paints the default
+// system color.
+func (c *SoftwareCanvas) FillRect(lprc *RECT, hbr HBRUSH) bool {
+	brush, ok := c.object(HGDIOBJ(hbr)).(softBrush)
+	if color, sys := DefaultSysColor(int(hbr) - 1); hbr != 0 && sys {
+		brush, ok = softBrush{style: BS_SOLID, color: color}, true
+	}
+	if !ok {
+		return false
+	}
+	if lprc.Right <= lprc.Left || lprc.Bottom <= lprc.Top {
+		return true
+	}
+	l, t := float64(lprc.Left), float64(lprc.Top)
+	r, b := float64(lprc.Right), float64(lprc.Bottom)
+	saved, rop := c.dc.brush, c.dc.rop2
+	c.dc.brush, c.dc.rop2 = brush, R2_COPYPEN
+	c.dc.fill([]rasterFigure{{pts: []rasterPt{{l, t}, {r, t}, {r, b}, {l, b}}, closed: true}})
+	c.dc.brush, c.dc.rop2 = saved, rop
+	return true
+}
+
+// SetPixel ignores the binary raster operation, as GDI does. It returns
+// CLR_INVALID for pixels outside the image.
+func (c *SoftwareCanvas) SetPixel(x, y int, crColor COLORREF) COLORREF {
+	if c.dc.clipped(x, y) {
+		return CLR_INVALID
+	}
+	crColor &= 0xFFFFFF
+	r, g, b := colorrefRGB(crColor)
+	c.dc.putRaw(x, y, r, g, b, 0xFF)
+	return crColor
+}
+
+// GetPixel returns CLR_INVALID for pixels outside the image.
+func (c *SoftwareCanvas) GetPixel(x, y int) COLORREF {
+	if c.dc.clipped(x, y) {
+		return CLR_INVALID
+	}
+	return c.dc.get(x, y)
+}
+
+// BitBlt copies from another SoftwareCanvas, or from the canvas itself;
+// overlapping copies behave as if the source were read first. The brush
+// serves as the pattern.
+func (c *SoftwareCanvas) BitBlt(nXDest, nYDest, nWidth, nHeight int, src Canvas, nXSrc, nYSrc int, dwRop uint) bool {
+	var img *image.RGBA
+	switch s := src.(type) {
+	case nil:
+		if _, useSrc, _ := ROP3Operands(uint32(dwRop)); useSrc {
+			return false
+		}
+	case *SoftwareCanvas:
+		img = s.dc.dst
+		if s == c {
+			copied := *img
+			copied.Pix = append([]byte(nil), img.Pix...)
+			img = &copied
+		}
+	default:
+		return false
+	}
+	box := image.Rect(nXDest, nYDest, nXDest+nWidth, nYDest+nHeight)
+	toSrc := translateAffine(float64(nXSrc-nXDest), float64(nYSrc-nYDest))
+	if img == nil {
+		c.dc.blit(box, toSrc, nil, image.Rectangle{}, uint32(dwRop))
+		return true
+	}
+	srcRect := image.Rect(nXSrc, nYSrc, nXSrc+nWidth, nYSrc+nHeight)
+	c.dc.blit(box, toSrc, img, srcRect, uint32(dwRop))
+	return true
+}
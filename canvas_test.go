@@ -0,0 +1,263 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"image"
+	"testing"
+)
+
+// newTestCanvas returns a w x h canvas cleared to white.
+func newTestCanvas(w, h int) *SoftwareCanvas {
+	c := NewSoftwareCanvas(image.NewRGBA(image.Rect(0, 0, w, h)))
+	c.FillRect(&RECT{0, 0, int32(w), int32(h)}, HBRUSH(c.GetStockObject(WHITE_BRUSH)))
+	return c
+}
+
+// canvasRows renders the canvas as text: '.' for white, '#' for black,
+// 'r' for red and '?' for anything else.
+func canvasRows(c *SoftwareCanvas) []string {
+	b := c.Image().Bounds()
+	var rows []string
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := make([]byte, b.Dx())
+		for x := range row {
+			switch c.GetPixel(b.Min.X+x, y) {
+			case 0xFFFFFF:
+				row[x] = '.'
+			case 0:
+				row[x] = '#'
+			case RGB(255, 0, 0):
+				row[x] = 'r'
+			default:
+				row[x] = '?'
+			}
+		}
+		rows = append(rows, string(row))
+	}
+	return rows
+}
+
+func checkCanvasRows(t *testing.T, name string, c *SoftwareCanvas, want []string) {
+	t.Helper()
+	got := canvasRows(c)
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Errorf("%s: got\n%q\nwant\n%q", name, got, want)
+			return
+		}
+	}
+}
+
+// Small scenes checked pixel by pixel against what GDI draws.
+func TestSoftwareCanvasPixels(t *testing.T) {
+	c := newTestCanvas(8, 6)
+	red := c.CreateSolidBrush(RGB(255, 0, 0))
+	old := c.SelectObject(HGDIOBJ(red))
+	if old != c.GetStockObject(WHITE_BRUSH) {
+		t.Error("SelectObject did not return the default brush")
+	}
+	c.Rectangle(1, 1, 6, 5)
+	checkCanvasRows(t, "rectangle excludes its right and bottom edges", c, []string{
+		"........",
+		".#####..",
+		".#rrr#..",
+		".#rrr#..",
+		".#####..",
+		"........",
+	})
+	if c.DeleteObject(HGDIOBJ(red)) {
+		t.Error("deleted the selected brush")
+	}
+	c.SelectObject(old)
+	if !c.DeleteObject(HGDIOBJ(red)) {
+		t.Error("cannot delete a deselected brush")
+	}
+
+	c = newTestCanvas(8, 3)
+	c.MoveToEx(1, 1, nil)
+	c.LineTo(6, 1)
+	c.SetROP2(R2_NOT)
+	c.MoveToEx(0, 2, nil)
+	c.LineTo(3, 2)
+	checkCanvasRows(t, "lines exclude their last pixel", c, []string{
+		"........",
+		".#####..",
+		"###.....",
+	})
+
+	c = newTestCanvas(6, 6)
+	c.SelectObject(c.GetStockObject(NULL_PEN))
+	c.SelectObject(c.GetStockObject(BLACK_BRUSH))
+	c.Rectangle(1, 1, 5, 5)
+	checkCanvasRows(t, "a rectangle without pen is a pixel smaller", c, []string{
+		"......",
+		".###..",
+		".###..",
+		".###..",
+		"......",
+		"......",
+	})
+
+	c = newTestCanvas(8, 12)
+	hatch := c.CreateHatchBrush(HS_HORIZONTAL, 0)
+	c.SetBkMode(TRANSPARENT)
+	c.FillRect(&RECT{0, 0, 8, 4}, hatch)
+	c.SetBkMode(OPAQUE)
+	c.SetBkColor(RGB(255, 0, 0))
+	c.FillRect(&RECT{0, 4, 8, 12}, hatch)
+	checkCanvasRows(t, "hatch background", c, []string{
+		"........",
+		"........",
+		"........",
+		"########",
+		"rrrrrrrr",
+		"rrrrrrrr",
+		"rrrrrrrr",
+		"rrrrrrrr",
+		"rrrrrrrr",
+		"rrrrrrrr",
+		"rrrrrrrr",
+		"########",
+	})
+
+	c = newTestCanvas(8, 1)
+	c.SetPixel(0, 0, 0)
+	c.SetPixel(1, 0, RGB(255, 0, 0))
+	if !c.BitBlt(1, 0, 4, 1, c, 0, 0, SRCCOPY) {
+		t.Error("BitBlt within a canvas failed")
+	}
+	checkCanvasRows(t, "overlapping BitBlt", c, []string{"##r....."})
+	if c.BitBlt(0, 0, 1, 1, nil, 0, 0, SRCCOPY) {
+		t.Error("SRCCOPY without a source succeeded")
+	}
+}
+
+func TestSoftwareCanvasGoldenLines(t *testing.T) {
+	c := newTestCanvas(64, 64)
+	pens := []HPEN{
+		c.CreatePen(PS_SOLID, 0, 0),
+		c.CreatePen(PS_SOLID, 3, RGB(255, 0, 0)),
+		c.CreatePen(PS_DASH, 0, RGB(0, 0, 255)),
+		c.CreatePen(PS_DOT, 0, RGB(0, 128, 0)),
+		c.CreatePen(PS_DASHDOT, 0, 0),
+		c.CreatePen(PS_SOLID, 6, RGB(0, 128, 128)),
+	}
+	for i, pen := range pens {
+		c.SelectObject(HGDIOBJ(pen))
+		y := 4 + 10*i
+		c.MoveToEx(2, y, nil)
+		c.LineTo(40, y+4)
+	}
+	c.SelectObject(HGDIOBJ(pens[0]))
+	for i := 0; i < 8; i++ {
+		// A fan of lines in every octant.
+		c.MoveToEx(52, 20, nil)
+		dx := []int{10, 10, 4, -4, -10, -10, -4, 4}[i]
+		dy := []int{4, -4, -10, -10, -4, 4, 10, 10}[i]
+		c.LineTo(52+dx, 20+dy)
+	}
+	c.SelectObject(HGDIOBJ(pens[5]))
+	c.SetROP2(R2_NOT)
+	c.MoveToEx(44, 36, nil)
+	c.LineTo(62, 62)
+	checkGoldenImage(t, "canvas/lines.png", c.Image())
+}
+
+func TestSoftwareCanvasGoldenRectangles(t *testing.T) {
+	c := newTestCanvas(64, 64)
+	c.Rectangle(2, 2, 20, 14)
+
+	c.SelectObject(HGDIOBJ(c.CreatePen(PS_SOLID, 3, RGB(0, 0, 255))))
+	c.SelectObject(HGDIOBJ(c.CreateSolidBrush(RGB(255, 255, 0))))
+	c.Rectangle(24, 2, 60, 20)
+
+	c.SelectObject(c.GetStockObject(NULL_PEN))
+	c.SelectObject(HGDIOBJ(c.CreateHatchBrush(HS_DIAGCROSS, RGB(255, 0, 0))))
+	c.Rectangle(2, 18, 20, 40)
+
+	c.SelectObject(c.GetStockObject(BLACK_PEN))
+	c.SelectObject(c.GetStockObject(NULL_BRUSH))
+	c.Rectangle(24, 24, 60, 40)
+	// Reversed coordinates draw the same rectangle.
+	c.Rectangle(58, 38, 26, 26)
+
+	c.SelectObject(c.GetStockObject(GRAY_BRUSH))
+	c.SetROP2(R2_XORPEN)
+	c.Rectangle(10, 30, 40, 60)
+	checkGoldenImage(t, "canvas/rectangles.png", c.Image())
+}
+
+func TestSoftwareCanvasGoldenEllipses(t *testing.T) {
+	c := newTestCanvas(64, 64)
+	c.Ellipse(2, 2, 30, 30)
+
+	c.SelectObject(HGDIOBJ(c.CreateSolidBrush(RGB(0, 160, 0))))
+	c.Ellipse(34, 2, 62, 16)
+	c.Ellipse(34, 20, 42, 62)
+
+	c.SelectObject(HGDIOBJ(c.CreatePen(PS_SOLID, 4, RGB(255, 0, 0))))
+	c.SelectObject(c.GetStockObject(NULL_BRUSH))
+	c.Ellipse(4, 34, 30, 60)
+
+	c.SelectObject(c.GetStockObject(NULL_PEN))
+	c.SelectObject(HGDIOBJ(c.CreateHatchBrush(HS_CROSS, RGB(0, 0, 255))))
+	c.Ellipse(46, 22, 62, 62)
+	// Degenerate ellipses.
+	c.SelectObject(c.GetStockObject(BLACK_PEN))
+	c.Ellipse(44, 18, 45, 19)
+	c.Ellipse(32, 60, 60, 61)
+	checkGoldenImage(t, "canvas/ellipses.png", c.Image())
+}
+
+func TestSoftwareCanvasGoldenFills(t *testing.T) {
+	c := newTestCanvas(64, 64)
+	hatches := []int{HS_HORIZONTAL, HS_VERTICAL, HS_FDIAGONAL, HS_BDIAGONAL, HS_CROSS, HS_DIAGCROSS}
+	for i, h := range hatches {
+		x := int32(2 + 10*i)
+		brush := c.CreateHatchBrush(h, RGB(0, 0, 128))
+		c.SetBkMode(OPAQUE)
+		c.SetBkColor(RGB(255, 255, 0))
+		c.FillRect(&RECT{x, 2, x + 9, 20}, brush)
+		c.SetBkMode(TRANSPARENT)
+		c.FillRect(&RECT{x, 22, x + 9, 40}, brush)
+	}
+	c.FillRect(&RECT{2, 42, 30, 62}, HBRUSH(c.GetStockObject(DKGRAY_BRUSH)))
+	c.FillRect(&RECT{6, 46, 26, 58}, c.CreateSolidBrush(RGB(200, 100, 50)))
+	// The brush origin shifts the hatch pattern.
+	brush := c.CreateHatchBrush(HS_CROSS, 0)
+	c.FillRect(&RECT{34, 42, 46, 62}, brush)
+	c.SetBrushOrgEx(3, 3, nil)
+	c.FillRect(&RECT{48, 42, 62, 62}, brush)
+	checkGoldenImage(t, "canvas/fills.png", c.Image())
+}
+
+func TestSoftwareCanvasGoldenBitBlt(t *testing.T) {
+	src := newTestCanvas(16, 16)
+	src.SelectObject(HGDIOBJ(src.CreateSolidBrush(RGB(255, 0, 0))))
+	src.Ellipse(0, 0, 16, 16)
+	src.MoveToEx(0, 0, nil)
+	src.LineTo(16, 16)
+
+	c := newTestCanvas(64, 64)
+	c.FillRect(&RECT{0, 32, 64, 64}, c.CreateHatchBrush(HS_BDIAGONAL, RGB(0, 0, 255)))
+	rops := []uint{SRCCOPY, SRCINVERT, SRCAND, NOTSRCCOPY}
+	for i, rop := range rops {
+		if !c.BitBlt(2+16*i, 34, 14, 14, src, 1, 1, rop) {
+			t.Errorf("BitBlt with ROP %#x failed", rop)
+		}
+	}
+	c.SelectObject(HGDIOBJ(c.CreateHatchBrush(HS_CROSS, RGB(0, 128, 0))))
+	for i, rop := range []uint{PATCOPY, PATINVERT, DSTINVERT, BLACKNESS} {
+		if !c.BitBlt(2+16*i, 50, 14, 12, nil, 0, 0, rop) {
+			t.Errorf("BitBlt with ROP %#x and no source failed", rop)
+		}
+	}
+	// Copies within the canvas, overlapping the source.
+	c.BitBlt(2, 2, 16, 16, src, 0, 0, SRCCOPY)
+	c.BitBlt(10, 10, 16, 16, c, 2, 2, SRCCOPY)
+	c.BitBlt(40, 2, 20, 20, c, 34, 34, SRCCOPY)
+	checkGoldenImage(t, "canvas/bitblt.png", c.Image())
+}
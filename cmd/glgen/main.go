@@ -0,0 +1,68 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command glgen writes typed Go function tables for OpenGL and WGL from a
+// local copy of the Khronos registry (gl.xml or wgl.xml). It runs on any
+// platform; the output builds on Windows only, and only for 386 and amd64
+// if any command takes floating point arguments. For example:
+//
+//	glgen -registry gl.xml -api gl -version 3.3 -profile core \
+//		-ext GL_ARB_debug_output -pkg gl -o gl33.go
+//	glgen -registry wgl.xml -api wgl -type WGLFuncs \
+//		-ext WGL_ARB_create_context,WGL_EXT_swap_control -pkg gl -o wgl.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AllenDang/w32"
+)
+
+func main() {
+	registry := flag.String("registry", "gl.xml", "registry XML file")
+	out := flag.String("o", "", "output file (default standard output)")
+	var opts w32.GLGenOptions
+	flag.StringVar(&opts.Package, "pkg", "gl", "package name of the output")
+	flag.StringVar(&opts.TypeName, "type", "Funcs", "name of the function table type")
+	flag.StringVar(&opts.API, "api", "gl", "API to follow: gl, gles2, wgl, ...")
+	flag.StringVar(&opts.Version, "version", "", "highest API version to include, such as 3.3")
+	flag.StringVar(&opts.Profile, "profile", "", "core or compatibility")
+	exts := flag.String("ext", "", "comma separated extensions to include")
+	flag.Parse()
+	if *exts != "" {
+		opts.Extensions = strings.Split(*exts, ",")
+	}
+	opts.Source = filepath.Base(*registry)
+
+	if err := run(*registry, *out, opts); err != nil {
+		fmt.Fprintln(os.Stderr, "glgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(registry, out string, opts w32.GLGenOptions) error {
+	f, err := os.Open(registry)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reg, err := w32.ParseGLRegistry(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", registry, err)
+	}
+	var b bytes.Buffer
+	if err := w32.GenerateGLLoader(&b, reg, opts); err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(b.Bytes())
+		return err
+	}
+	return os.WriteFile(out, b.Bytes(), 0644)
+}
@@ -0,0 +1,216 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"image/color"
+)
+
+// A COLORREF holds red, green and blue in its low three bytes. The high
+// byte marks palette-relative colors: PALETTERGB sets it to 2, and
+// PALETTEINDEX to 1 with a palette index in place of the color.
+
+func RGB(r, g, b byte) COLORREF {
+	return COLORREF(r) | COLORREF(g)<<8 | COLORREF(b)<<16
+}
+
+func GetRValue(rgb COLORREF) byte {
+	return byte(rgb)
+}
+
+func GetGValue(rgb COLORREF) byte {
+	return byte(rgb >> 8)
+}
+
+func GetBValue(rgb COLORREF) byte {
+	return byte(rgb >> 16)
+}
+
+// PALETTERGB returns a color that GDI matches against the selected
+// palette on palette devices, and treats as RGB otherwise.
+func PALETTERGB(r, g, b byte) COLORREF {
+	return 0x02000000 | RGB(r, g, b)
+}
+
+// PALETTEINDEX returns a color that names an entry of the selected
+// palette.
+func PALETTEINDEX(i uint16) COLORREF {
+	return 0x01000000 | COLORREF(i)
+}
+
+// IsPaletteIndex reports whether c was made by PALETTEINDEX.
+func (c COLORREF) IsPaletteIndex() bool {
+	return c>>24 == 1
+}
+
+// RGBA implements color.Color. Palette indices have no color of their
+// own and come out black; PALETTERGB colors come out as their RGB value.
+func (c COLORREF) RGBA() (r, g, b, a uint32) {
+	return c.Color().RGBA()
+}
+
+// Color returns c as an opaque color.RGBA.
+func (c COLORREF) Color() color.RGBA {
+	if c.IsPaletteIndex() {
+		return color.RGBA{A: 0xFF}
+	}
+	return color.RGBA{GetRValue(c), GetGValue(c), GetBValue(c), 0xFF}
+}
+
+// COLORREFModel converts colors to COLORREF, dropping alpha after
+// undoing its premultiplication.
+var COLORREFModel color.Model = color.ModelFunc(func(c color.Color) color.Color {
+	return ColorToCOLORREF(c)
+})
+
+// ColorToCOLORREF returns the COLORREF closest to c, ignoring its alpha.
+// Palette indices become black, the color they report.
+func ColorToCOLORREF(c color.Color) COLORREF {
+	if cr, ok := c.(COLORREF); ok {
+		if cr.IsPaletteIndex() {
+			return 0
+		}
+		return cr &^ 0xFF000000
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return RGB(n.R, n.G, n.B)
+}
+
+// The hue, luminance and saturation of ColorRGBToHLS and ColorHLSToRGB
+// range from 0 to 240, as in the Windows color dialog.
+const hlsMax = 240
+
+// ColorRGBToHLS converts a color to hue, luminance and saturation with
+// the integer arithmetic of the shlwapi function, so results match
+// Windows exactly. Grays have a hue of 160.
+func ColorRGBToHLS(rgb COLORREF) (hue, luminance, saturation uint16) {
+	r, g, b := int(GetRValue(rgb)), int(GetGValue(rgb)), int(GetBValue(rgb))
+	hi, lo := r, r
+	for _, v := range [2]int{g, b} {
+		if v > hi {
+			hi = v
+		}
+		if v < lo {
+			lo = v
+		}
+	}
+
+	l := ((hi+lo)*hlsMax + 255) / 510
+	if hi == lo {
+		return hlsMax * 2 / 3, uint16(l), 0
+	}
+
+	delta := hi - lo
+	var s int
+	if l <= hlsMax/2 {
+		s = ((hi+lo)/2 + delta*hlsMax) / (hi + lo)
+	} else {
+		s = ((510-hi-lo)/2 + delta*hlsMax) / (510 - hi - lo)
+	}
+
+	norm := func(v int) int {
+		return (delta/2 + (hi-v)*(hlsMax/6)) / delta
+	}
+	var h int
+	switch {
+	case r == hi:
+		h = norm(b) - norm(g)
+	case g == hi:
+		h = hlsMax/3 + norm(r) - norm(b)
+	default:
+		h = hlsMax*2/3 + norm(g) - norm(r)
+	}
+	if h < 0 {
+		h += hlsMax
+	} else if h > hlsMax {
+		h -= hlsMax
+	}
+	return uint16(h), uint16(l), uint16(s)
+}
+
+// ColorHLSToRGB is the inverse of ColorRGBToHLS, again with the integer
+// arithmetic of the shlwapi function.
+func ColorHLSToRGB(hue, luminance, saturation uint16) COLORREF {
+	h, l, s := int(hue), int(luminance), int(saturation)
+	if s == 0 {
+		v := byte(l * 255 / hlsMax)
+		return RGB(v, v, v)
+	}
+
+	var mid2 int
+	if l > hlsMax/2 {
+		mid2 = s + l - (s*l+hlsMax/2)/hlsMax
+	} else {
+		mid2 = ((s+hlsMax)*l + hlsMax/2) / hlsMax
+	}
+	mid1 := l*2 - mid2
+
+	channel := func(h int) byte {
+		if h > hlsMax {
+			h -= hlsMax
+		} else if h < 0 {
+			h += hlsMax
+		}
+		var v int
+		switch {
+		case h < hlsMax/6:
+			v = (h*(mid2-mid1)+hlsMax/12)/(hlsMax/6) + mid1
+		case h < hlsMax/2:
+			v = mid2
+		case h < hlsMax*2/3:
+			v = ((hlsMax*2/3-h)*(mid2-mid1)+hlsMax/12)/(hlsMax/6) + mid1
+		default:
+			v = mid1
+		}
+		return byte((int(byte(v))*255 + hlsMax/2) / hlsMax)
+	}
+	return RGB(channel(h+hlsMax/3), channel(h), channel(h-hlsMax/3))
+}
+
+// sysColorDefaults holds the GetSysColor values of a default Windows 10
+// installation, by COLOR_* index.
+var sysColorDefaults = [...]COLORREF{
+	COLOR_SCROLLBAR:               RGB(200, 200, 200),
+	COLOR_BACKGROUND:              RGB(0, 0, 0),
+	COLOR_ACTIVECAPTION:           RGB(153, 180, 209),
+	COLOR_INACTIVECAPTION:         RGB(191, 205, 219),
+	COLOR_MENU:                    RGB(240, 240, 240),
+	COLOR_WINDOW:                  RGB(255, 255, 255),
+	COLOR_WINDOWFRAME:             RGB(100, 100, 100),
+	COLOR_MENUTEXT:                RGB(0, 0, 0),
+	COLOR_WINDOWTEXT:              RGB(0, 0, 0),
+	COLOR_CAPTIONTEXT:             RGB(0, 0, 0),
+	COLOR_ACTIVEBORDER:            RGB(180, 180, 180),
+	COLOR_INACTIVEBORDER:          RGB(244, 247, 252),
+	COLOR_APPWORKSPACE:            RGB(171, 171, 171),
+	COLOR_HIGHLIGHT:               RGB(0, 120, 215),
+	COLOR_HIGHLIGHTTEXT:           RGB(255, 255, 255),
+	COLOR_BTNFACE:                 RGB(240, 240, 240),
+	COLOR_BTNSHADOW:               RGB(160, 160, 160),
+	COLOR_GRAYTEXT:                RGB(109, 109, 109),
+	COLOR_BTNTEXT:                 RGB(0, 0, 0),
+	COLOR_INACTIVECAPTIONTEXT:     RGB(0, 0, 0),
+	COLOR_BTNHIGHLIGHT:            RGB(255, 255, 255),
+	COLOR_3DDKSHADOW:              RGB(105, 105, 105),
+	COLOR_3DLIGHT:                 RGB(227, 227, 227),
+	COLOR_INFOTEXT:                RGB(0, 0, 0),
+	COLOR_INFOBK:                  RGB(255, 255, 225),
+	25:                            RGB(181, 181, 181), // the unnamed alternate button face
+	COLOR_HOTLIGHT:                RGB(0, 102, 204),
+	COLOR_GRADIENTACTIVECAPTION:   RGB(185, 209, 234),
+	COLOR_GRADIENTINACTIVECAPTION: RGB(215, 228, 242),
+	COLOR_MENUHILIGHT:             RGB(51, 153, 255),
+	COLOR_MENUBAR:                 RGB(240, 240, 240),
+}
+
+// DefaultSysColor returns the color GetSysColor reports for a COLOR_*
+// index on a default Windows 10 installation, for rendering without a
+// live system. It returns false for unknown indices.
+func DefaultSysColor(index int) (COLORREF, bool) {
+	if index < 0 || index >= len(sysColorDefaults) {
+		return 0, false
+	}
+	return sysColorDefaults[index], true
+}
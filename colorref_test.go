@@ -0,0 +1,141 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"image/color"
+	"testing"
+)
+
+// hlsTests are the values ColorRGBToHLS returns on Windows, as shown by
+// the color dialog for its basic colors.
+var hlsTests = []struct {
+	rgb     COLORREF
+	h, l, s uint16
+}{
+	{RGB(255, 0, 0), 0, 120, 240},
+	{RGB(255, 128, 0), 20, 120, 240},
+	{RGB(255, 255, 0), 40, 120, 240},
+	{RGB(0, 255, 0), 80, 120, 240},
+	{RGB(0, 255, 255), 120, 120, 240},
+	{RGB(0, 0, 255), 160, 120, 240},
+	{RGB(255, 0, 255), 200, 120, 240},
+	// Grays have hue 160 and no saturation.
+	{RGB(0, 0, 0), 160, 0, 0},
+	{RGB(128, 128, 128), 160, 120, 0},
+	{RGB(192, 192, 192), 160, 181, 0},
+	{RGB(255, 255, 255), 160, 240, 0},
+	// Half and three-quarter luminance.
+	{RGB(128, 0, 0), 0, 60, 240},
+	{RGB(0, 128, 0), 80, 60, 240},
+	{RGB(0, 0, 128), 160, 60, 240},
+	{RGB(128, 128, 0), 40, 60, 240},
+	{RGB(0, 128, 128), 120, 60, 240},
+	{RGB(128, 0, 128), 200, 60, 240},
+	{RGB(255, 128, 128), 0, 180, 240},
+	{RGB(128, 255, 128), 80, 180, 240},
+}
+
+func TestColorRGBToHLS(t *testing.T) {
+	for _, tt := range hlsTests {
+		if h, l, s := ColorRGBToHLS(tt.rgb); h != tt.h || l != tt.l || s != tt.s {
+			t.Errorf("ColorRGBToHLS(%#06x) = %d, %d, %d; want %d, %d, %d", tt.rgb, h, l, s, tt.h, tt.l, tt.s)
+		}
+	}
+	// The high byte is not part of the color.
+	if h, l, s := ColorRGBToHLS(PALETTERGB(255, 0, 0)); h != 0 || l != 120 || s != 240 {
+		t.Errorf("ColorRGBToHLS(PALETTERGB(255, 0, 0)) = %d, %d, %d", h, l, s)
+	}
+}
+
+func TestColorHLSToRGB(t *testing.T) {
+	for _, tt := range hlsTests {
+		want := tt.rgb
+		if tt.s == 0 && tt.l == 120 {
+			// Windows truncates gray levels: 120 * 255 / 240 is 127.
+			want = RGB(127, 127, 127)
+		}
+		if got := ColorHLSToRGB(tt.h, tt.l, tt.s); got != want {
+			t.Errorf("ColorHLSToRGB(%d, %d, %d) = %#06x, want %#06x", tt.h, tt.l, tt.s, got, want)
+		}
+	}
+	// The hue of a gray does not matter, and hue 240 is hue 0.
+	for _, tt := range []struct {
+		h, l, s uint16
+		want    COLORREF
+	}{
+		{0, 240, 0, RGB(255, 255, 255)},
+		{80, 181, 0, RGB(192, 192, 192)},
+		{240, 120, 240, RGB(255, 0, 0)},
+		{100, 120, 120, RGB(64, 191, 128)},
+	} {
+		if got := ColorHLSToRGB(tt.h, tt.l, tt.s); got != tt.want {
+			t.Errorf("ColorHLSToRGB(%d, %d, %d) = %#06x, want %#06x", tt.h, tt.l, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestCOLORREF(t *testing.T) {
+	c := RGB(0x12, 0x34, 0x56)
+	if c != 0x563412 || GetRValue(c) != 0x12 || GetGValue(c) != 0x34 || GetBValue(c) != 0x56 {
+		t.Errorf("RGB(0x12, 0x34, 0x56) = %#x", c)
+	}
+	if c.Color() != (color.RGBA{0x12, 0x34, 0x56, 0xFF}) {
+		t.Errorf("Color() = %v", c.Color())
+	}
+	if p := PALETTERGB(0x12, 0x34, 0x56); p != 0x02563412 || p.IsPaletteIndex() || p.Color() != c.Color() {
+		t.Errorf("PALETTERGB(0x12, 0x34, 0x56) = %#x, %v", p, p.Color())
+	}
+
+	// Palette indices have no color of their own.
+	i := PALETTEINDEX(5)
+	if i != 0x01000005 || !i.IsPaletteIndex() {
+		t.Errorf("PALETTEINDEX(5) = %#x", i)
+	}
+	if r, g, b, a := i.RGBA(); r != 0 || g != 0 || b != 0 || a != 0xFFFF {
+		t.Errorf("PALETTEINDEX(5).RGBA() = %d, %d, %d, %d", r, g, b, a)
+	}
+	if got := ColorToCOLORREF(i); got != 0 {
+		t.Errorf("ColorToCOLORREF(PALETTEINDEX(5)) = %#x", got)
+	}
+}
+
+func TestColorToCOLORREF(t *testing.T) {
+	for _, tt := range []struct {
+		c    color.Color
+		want COLORREF
+	}{
+		{color.NRGBA{200, 100, 50, 0xFF}, RGB(200, 100, 50)},
+		// Straight alpha is dropped, premultiplied alpha undone first.
+		{color.NRGBA{200, 100, 50, 10}, RGB(200, 100, 50)},
+		{color.RGBA{0x40, 0x00, 0x20, 0x80}, RGB(0x7F, 0x00, 0x3F)},
+		{color.RGBA64{0x8000, 0x4000, 0x0000, 0x8000}, RGB(0xFF, 0x7F, 0x00)},
+		{color.RGBA{}, RGB(0, 0, 0)},
+		{color.Gray{0x80}, RGB(0x80, 0x80, 0x80)},
+		{PALETTERGB(1, 2, 3), RGB(1, 2, 3)},
+		{COLORREF(0xFF010203), RGB(3, 2, 1)},
+	} {
+		if got := ColorToCOLORREF(tt.c); got != tt.want {
+			t.Errorf("ColorToCOLORREF(%v) = %#06x, want %#06x", tt.c, got, tt.want)
+		}
+		if got := COLORREFModel.Convert(tt.c); got != tt.want {
+			t.Errorf("COLORREFModel.Convert(%v) = %v, want %#06x", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultSysColor(t *testing.T) {
+	if c, ok := DefaultSysColor(COLOR_HIGHLIGHT); !ok || c != RGB(0, 120, 215) {
+		t.Errorf("COLOR_HIGHLIGHT is %#06x, %v", c, ok)
+	}
+	if c, ok := DefaultSysColor(25); !ok || c != RGB(181, 181, 181) {
+		t.Errorf("color 25 is %#06x, %v", c, ok)
+	}
+	for _, i := range []int{-1, COLOR_MENUBAR + 1} {
+		if c, ok := DefaultSysColor(i); ok {
+			t.Errorf("DefaultSysColor(%d) = %#06x", i, c)
+		}
+	}
+}
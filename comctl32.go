@@ -2,6 +2,8 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+//go:build windows
+
 package w32
 
 import (
@@ -42,13 +44,20 @@ func ImageList_Create(cx, cy int, flags uint, cInitial, cGrow int) HIMAGELIST {
 		panic("Create image list failed")
 	}
 
+	trackHandle(HandleKindImageList, "ImageList_Create", ret)
+
 	return HIMAGELIST(ret)
 }
 
 func ImageList_Destroy(himl HIMAGELIST) bool {
+	r := untrackHandle("ImageList_Destroy", uintptr(himl))
 	ret, _, _ := procImageList_Destroy.Call(
 		uintptr(himl))
 
+	if ret == 0 {
+		restoreHandle(r)
+	}
+
 	return ret != 0
 }
 
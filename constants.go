@@ -124,6 +124,31 @@ const (
 	IDI_INFORMATION = IDI_ASTERISK
 )
 
+// Predefined resource types
+const (
+	RT_CURSOR       = 1
+	RT_BITMAP       = 2
+	RT_ICON         = 3
+	RT_MENU         = 4
+	RT_DIALOG       = 5
+	RT_STRING       = 6
+	RT_FONTDIR      = 7
+	RT_FONT         = 8
+	RT_ACCELERATOR  = 9
+	RT_RCDATA       = 10
+	RT_MESSAGETABLE = 11
+	RT_GROUP_CURSOR = 12
+	RT_GROUP_ICON   = 14
+	RT_VERSION      = 16
+	RT_DLGINCLUDE   = 17
+	RT_PLUGPLAY     = 19
+	RT_VXD          = 20
+	RT_ANICURSOR    = 21
+	RT_ANIICON      = 22
+	RT_HTML         = 23
+	RT_MANIFEST     = 24
+)
+
 // Button style constants
 const (
 	BS_3STATE          = 5
@@ -199,6 +224,8 @@ const (
 	COLOR_HOTLIGHT                = 26
 	COLOR_GRADIENTACTIVECAPTION   = 27
 	COLOR_GRADIENTINACTIVECAPTION = 28
+	COLOR_MENUHILIGHT             = 29
+	COLOR_MENUBAR                 = 30
 )
 
 // Button message constants
@@ -346,6 +373,7 @@ const (
 	WM_DRAWCLIPBOARD          = 776
 	WM_DRAWITEM               = 43
 	WM_DROPFILES              = 563
+	WM_DWMCOMPOSITIONCHANGED  = 798
 	WM_ENABLE                 = 10
 	WM_ENDSESSION             = 22
 	WM_ENTERIDLE              = 289
@@ -1630,6 +1658,57 @@ const (
 	DLGC_BUTTON          = 0x2000
 )
 
+// Dialog styles
+const (
+	DS_ABSALIGN      = 0x0001
+	DS_SYSMODAL      = 0x0002
+	DS_LOCALEDIT     = 0x0020
+	DS_SETFONT       = 0x0040
+	DS_MODALFRAME    = 0x0080
+	DS_NOIDLEMSG     = 0x0100
+	DS_SETFOREGROUND = 0x0200
+	DS_3DLOOK        = 0x0004
+	DS_FIXEDSYS      = 0x0008
+	DS_NOFAILCREATE  = 0x0010
+	DS_CONTROL       = 0x0400
+	DS_CENTER        = 0x0800
+	DS_CENTERMOUSE   = 0x1000
+	DS_CONTEXTHELP   = 0x2000
+	DS_SHELLFONT     = DS_SETFONT | DS_FIXEDSYS
+)
+
+// Menu flags
+const (
+	MF_INSERT          = 0x00000000
+	MF_CHANGE          = 0x00000080
+	MF_APPEND          = 0x00000100
+	MF_DELETE          = 0x00000200
+	MF_REMOVE          = 0x00001000
+	MF_BYCOMMAND       = 0x00000000
+	MF_BYPOSITION      = 0x00000400
+	MF_SEPARATOR       = 0x00000800
+	MF_ENABLED         = 0x00000000
+	MF_GRAYED          = 0x00000001
+	MF_DISABLED        = 0x00000002
+	MF_UNCHECKED       = 0x00000000
+	MF_CHECKED         = 0x00000008
+	MF_USECHECKBITMAPS = 0x00000200
+	MF_STRING          = 0x00000000
+	MF_BITMAP          = 0x00000004
+	MF_OWNERDRAW       = 0x00000100
+	MF_POPUP           = 0x00000010
+	MF_MENUBARBREAK    = 0x00000020
+	MF_MENUBREAK       = 0x00000040
+	MF_UNHILITE        = 0x00000000
+	MF_HILITE          = 0x00000080
+	MF_DEFAULT         = 0x00001000
+	MF_SYSMENU         = 0x00002000
+	MF_HELP            = 0x00004000
+	MF_RIGHTJUSTIFY    = 0x00004000
+	MF_MOUSESELECT     = 0x00008000
+	MF_END             = 0x00000080
+)
+
 // Get/SetWindowWord/Long offsets for use with WC_DIALOG windows
 const (
 	DWL_MSGRESULT = 0
@@ -2260,6 +2339,23 @@ const (
 	BI_PNG       = 5
 )
 
+// Logical color space types
+const (
+	LCS_CALIBRATED_RGB      = 0x00000000
+	LCS_sRGB                = 0x73524742
+	LCS_WINDOWS_COLOR_SPACE = 0x57696E20
+	PROFILE_LINKED          = 0x4C494E4B
+	PROFILE_EMBEDDED        = 0x4D424544
+)
+
+// Color space rendering intents
+const (
+	LCS_GM_BUSINESS         = 0x00000001
+	LCS_GM_GRAPHICS         = 0x00000002
+	LCS_GM_IMAGES           = 0x00000004
+	LCS_GM_ABS_COLORIMETRIC = 0x00000008
+)
+
 // SetDIBitsToDevice fuColorUse
 const (
 	DIB_PAL_COLORS = 1
@@ -2448,11 +2544,10 @@ const (
 // enum-lite implementation for the following constant structure
 type DWMFLIP3DWINDOWPOLICY int32
 
-// TODO: need to verify this construction
 // Flags used by the DwmSetWindowAttribute function
 // to specify the Flip3D window policy
 const (
-	DWMFLIP3D_DEFAULT = iota + 1
+	DWMFLIP3D_DEFAULT = iota
 	DWMFLIP3D_EXCLUDEBELOW
 	DWMFLIP3D_EXCLUDEABOVE
 	DWMFLIP3D_LAST
@@ -2461,11 +2556,10 @@ const (
 // enum-lite implementation for the following constant structure
 type DWMNCRENDERINGPOLICY int32
 
-// TODO: need to verify this construction
 // Flags used by the DwmSetWindowAttribute function
 // to specify the non-client area rendering policy
 const (
-	DWMNCRP_USEWINDOWSTYLE = iota + 1
+	DWMNCRP_USEWINDOWSTYLE = iota
 	DWMNCRP_DISABLED
 	DWMNCRP_ENABLED
 	DWMNCRP_LAST
@@ -2501,7 +2595,59 @@ const (
 	DWMWA_CLOAK
 	DWMWA_CLOAKED
 	DWMWA_FREEZE_REPRESENTATION
-	DWMWA_LAST
+	DWMWA_PASSIVE_UPDATE_MODE
+	DWMWA_USE_HOSTBACKDROPBRUSH
+)
+
+// Window attributes added in Windows 10 and Windows 11. Their values are
+// not contiguous with the ones above.
+const (
+	DWMWA_USE_IMMERSIVE_DARK_MODE_BEFORE_20H1 = 19 // undocumented; Windows 10 1809 to 1909
+	DWMWA_USE_IMMERSIVE_DARK_MODE             = 20
+	DWMWA_WINDOW_CORNER_PREFERENCE            = 33
+	DWMWA_BORDER_COLOR                        = 34
+	DWMWA_CAPTION_COLOR                       = 35
+	DWMWA_TEXT_COLOR                          = 36
+	DWMWA_VISIBLE_FRAME_BORDER_THICKNESS      = 37
+	DWMWA_SYSTEMBACKDROP_TYPE                 = 38
+	DWMWA_LAST                                = 39
+)
+
+// Special COLORREF values for DWMWA_BORDER_COLOR, DWMWA_CAPTION_COLOR and
+// DWMWA_TEXT_COLOR
+const (
+	DWMWA_COLOR_DEFAULT = 0xFFFFFFFF // use the system color
+	DWMWA_COLOR_NONE    = 0xFFFFFFFE // draw no border, for DWMWA_BORDER_COLOR only
+)
+
+// Flags returned for DWMWA_CLOAKED
+const (
+	DWM_CLOAKED_APP       = 0x00000001 // cloaked by its owner application
+	DWM_CLOAKED_SHELL     = 0x00000002 // cloaked by the shell
+	DWM_CLOAKED_INHERITED = 0x00000004 // cloak value inherited from its owner window
+)
+
+// enum-lite implementation for the following constant structure
+type DWM_WINDOW_CORNER_PREFERENCE int32
+
+// Values for DWMWA_WINDOW_CORNER_PREFERENCE
+const (
+	DWMWCP_DEFAULT = iota
+	DWMWCP_DONOTROUND
+	DWMWCP_ROUND
+	DWMWCP_ROUNDSMALL
+)
+
+// enum-lite implementation for the following constant structure
+type DWM_SYSTEMBACKDROP_TYPE int32
+
+// Values for DWMWA_SYSTEMBACKDROP_TYPE
+const (
+	DWMSBT_AUTO = iota
+	DWMSBT_NONE
+	DWMSBT_MAINWINDOW      // Mica
+	DWMSBT_TRANSIENTWINDOW // Desktop Acrylic
+	DWMSBT_TABBEDWINDOW    // tabbed Mica
 )
 
 // enum-lite implementation for the following constant structure
@@ -2659,3 +2805,634 @@ const (
 	MOUSEEVENTF_XDOWN           = 0x0080
 	MOUSEEVENTF_XUP             = 0x0100
 )
+
+// VS_FIXEDFILEINFO signature and version
+const (
+	VS_FFI_SIGNATURE     = 0xFEEF04BD
+	VS_FFI_STRUCVERSION  = 0x00010000
+	VS_FFI_FILEFLAGSMASK = 0x0000003F
+)
+
+// VS_FIXEDFILEINFO file flags
+const (
+	VS_FF_DEBUG        = 0x00000001
+	VS_FF_PRERELEASE   = 0x00000002
+	VS_FF_PATCHED      = 0x00000004
+	VS_FF_PRIVATEBUILD = 0x00000008
+	VS_FF_INFOINFERRED = 0x00000010
+	VS_FF_SPECIALBUILD = 0x00000020
+)
+
+// VS_FIXEDFILEINFO file operating systems
+const (
+	VOS_UNKNOWN       = 0x00000000
+	VOS_DOS           = 0x00010000
+	VOS_OS216         = 0x00020000
+	VOS_OS232         = 0x00030000
+	VOS_NT            = 0x00040000
+	VOS__WINDOWS16    = 0x00000001
+	VOS__PM16         = 0x00000002
+	VOS__PM32         = 0x00000003
+	VOS__WINDOWS32    = 0x00000004
+	VOS_DOS_WINDOWS16 = 0x00010001
+	VOS_DOS_WINDOWS32 = 0x00010004
+	VOS_NT_WINDOWS32  = 0x00040004
+)
+
+// VS_FIXEDFILEINFO file types and subtypes
+const (
+	VFT_UNKNOWN    = 0x00000000
+	VFT_APP        = 0x00000001
+	VFT_DLL        = 0x00000002
+	VFT_DRV        = 0x00000003
+	VFT_FONT       = 0x00000004
+	VFT_VXD        = 0x00000005
+	VFT_STATIC_LIB = 0x00000007
+
+	VFT2_UNKNOWN               = 0x00000000
+	VFT2_DRV_PRINTER           = 0x00000001
+	VFT2_DRV_KEYBOARD          = 0x00000002
+	VFT2_DRV_LANGUAGE          = 0x00000003
+	VFT2_DRV_DISPLAY           = 0x00000004
+	VFT2_DRV_MOUSE             = 0x00000005
+	VFT2_DRV_NETWORK           = 0x00000006
+	VFT2_DRV_SYSTEM            = 0x00000007
+	VFT2_DRV_INSTALLABLE       = 0x00000008
+	VFT2_DRV_SOUND             = 0x00000009
+	VFT2_DRV_COMM              = 0x0000000A
+	VFT2_DRV_VERSIONED_PRINTER = 0x0000000C
+	VFT2_FONT_RASTER           = 0x00000001
+	VFT2_FONT_VECTOR           = 0x00000002
+	VFT2_FONT_TRUETYPE         = 0x00000003
+)
+
+const INVALID_HANDLE_VALUE = ^HANDLE(0)
+
+// CreateActCtx flags
+const (
+	ACTCTX_FLAG_PROCESSOR_ARCHITECTURE_VALID = 0x00000001
+	ACTCTX_FLAG_LANGID_VALID                 = 0x00000002
+	ACTCTX_FLAG_ASSEMBLY_DIRECTORY_VALID     = 0x00000004
+	ACTCTX_FLAG_RESOURCE_NAME_VALID          = 0x00000008
+	ACTCTX_FLAG_SET_PROCESS_DEFAULT          = 0x00000010
+	ACTCTX_FLAG_APPLICATION_NAME_VALID       = 0x00000020
+	ACTCTX_FLAG_HMODULE_VALID                = 0x00000080
+)
+
+// DeactivateActCtx flags
+const (
+	DEACTIVATE_ACTCTX_FLAG_FORCE_EARLY_DEACTIVATION = 0x00000001
+)
+
+// Manifest resource IDs
+const (
+	CREATEPROCESS_MANIFEST_RESOURCE_ID                 = 1
+	ISOLATIONAWARE_MANIFEST_RESOURCE_ID                = 2
+	ISOLATIONAWARE_NOSTATICIMPORT_MANIFEST_RESOURCE_ID = 3
+)
+
+// Enhanced metafile record types
+const (
+	EMR_HEADER                  = 1
+	EMR_POLYBEZIER              = 2
+	EMR_POLYGON                 = 3
+	EMR_POLYLINE                = 4
+	EMR_POLYBEZIERTO            = 5
+	EMR_POLYLINETO              = 6
+	EMR_POLYPOLYLINE            = 7
+	EMR_POLYPOLYGON             = 8
+	EMR_SETWINDOWEXTEX          = 9
+	EMR_SETWINDOWORGEX          = 10
+	EMR_SETVIEWPORTEXTEX        = 11
+	EMR_SETVIEWPORTORGEX        = 12
+	EMR_SETBRUSHORGEX           = 13
+	EMR_EOF                     = 14
+	EMR_SETPIXELV               = 15
+	EMR_SETMAPPERFLAGS          = 16
+	EMR_SETMAPMODE              = 17
+	EMR_SETBKMODE               = 18
+	EMR_SETPOLYFILLMODE         = 19
+	EMR_SETROP2                 = 20
+	EMR_SETSTRETCHBLTMODE       = 21
+	EMR_SETTEXTALIGN            = 22
+	EMR_SETCOLORADJUSTMENT      = 23
+	EMR_SETTEXTCOLOR            = 24
+	EMR_SETBKCOLOR              = 25
+	EMR_OFFSETCLIPRGN           = 26
+	EMR_MOVETOEX                = 27
+	EMR_SETMETARGN              = 28
+	EMR_EXCLUDECLIPRECT         = 29
+	EMR_INTERSECTCLIPRECT       = 30
+	EMR_SCALEVIEWPORTEXTEX      = 31
+	EMR_SCALEWINDOWEXTEX        = 32
+	EMR_SAVEDC                  = 33
+	EMR_RESTOREDC               = 34
+	EMR_SETWORLDTRANSFORM       = 35
+	EMR_MODIFYWORLDTRANSFORM    = 36
+	EMR_SELECTOBJECT            = 37
+	EMR_CREATEPEN               = 38
+	EMR_CREATEBRUSHINDIRECT     = 39
+	EMR_DELETEOBJECT            = 40
+	EMR_ANGLEARC                = 41
+	EMR_ELLIPSE                 = 42
+	EMR_RECTANGLE               = 43
+	EMR_ROUNDRECT               = 44
+	EMR_ARC                     = 45
+	EMR_CHORD                   = 46
+	EMR_PIE                     = 47
+	EMR_SELECTPALETTE           = 48
+	EMR_CREATEPALETTE           = 49
+	EMR_SETPALETTEENTRIES       = 50
+	EMR_RESIZEPALETTE           = 51
+	EMR_REALIZEPALETTE          = 52
+	EMR_EXTFLOODFILL            = 53
+	EMR_LINETO                  = 54
+	EMR_ARCTO                   = 55
+	EMR_POLYDRAW                = 56
+	EMR_SETARCDIRECTION         = 57
+	EMR_SETMITERLIMIT           = 58
+	EMR_BEGINPATH               = 59
+	EMR_ENDPATH                 = 60
+	EMR_CLOSEFIGURE             = 61
+	EMR_FILLPATH                = 62
+	EMR_STROKEANDFILLPATH       = 63
+	EMR_STROKEPATH              = 64
+	EMR_FLATTENPATH             = 65
+	EMR_WIDENPATH               = 66
+	EMR_SELECTCLIPPATH          = 67
+	EMR_ABORTPATH               = 68
+	EMR_GDICOMMENT              = 70
+	EMR_FILLRGN                 = 71
+	EMR_FRAMERGN                = 72
+	EMR_INVERTRGN               = 73
+	EMR_PAINTRGN                = 74
+	EMR_EXTSELECTCLIPRGN        = 75
+	EMR_BITBLT                  = 76
+	EMR_STRETCHBLT              = 77
+	EMR_MASKBLT                 = 78
+	EMR_PLGBLT                  = 79
+	EMR_SETDIBITSTODEVICE       = 80
+	EMR_STRETCHDIBITS           = 81
+	EMR_EXTCREATEFONTINDIRECTW  = 82
+	EMR_EXTTEXTOUTA             = 83
+	EMR_EXTTEXTOUTW             = 84
+	EMR_POLYBEZIER16            = 85
+	EMR_POLYGON16               = 86
+	EMR_POLYLINE16              = 87
+	EMR_POLYBEZIERTO16          = 88
+	EMR_POLYLINETO16            = 89
+	EMR_POLYPOLYLINE16          = 90
+	EMR_POLYPOLYGON16           = 91
+	EMR_POLYDRAW16              = 92
+	EMR_CREATEMONOBRUSH         = 93
+	EMR_CREATEDIBPATTERNBRUSHPT = 94
+	EMR_EXTCREATEPEN            = 95
+	EMR_POLYTEXTOUTA            = 96
+	EMR_POLYTEXTOUTW            = 97
+	EMR_SETICMMODE              = 98
+	EMR_CREATECOLORSPACE        = 99
+	EMR_SETCOLORSPACE           = 100
+	EMR_DELETECOLORSPACE        = 101
+	EMR_GLSRECORD               = 102
+	EMR_GLSBOUNDEDRECORD        = 103
+	EMR_PIXELFORMAT             = 104
+	EMR_DRAWESCAPE              = 105
+	EMR_EXTESCAPE               = 106
+	EMR_SMALLTEXTOUT            = 108
+	EMR_FORCEUFIMAPPING         = 109
+	EMR_NAMEDESCAPE             = 110
+	EMR_COLORCORRECTPALETTE     = 111
+	EMR_SETICMPROFILEA          = 112
+	EMR_SETICMPROFILEW          = 113
+	EMR_ALPHABLEND              = 114
+	EMR_SETLAYOUT               = 115
+	EMR_TRANSPARENTBLT          = 116
+	EMR_GRADIENTFILL            = 118
+	EMR_SETLINKEDUFIS           = 119
+	EMR_SETTEXTJUSTIFICATION    = 120
+	EMR_COLORMATCHTOTARGETW     = 121
+	EMR_CREATECOLORSPACEW       = 122
+
+	EMR_MIN = 1
+	EMR_MAX = 122
+)
+
+const (
+	ENHMETA_SIGNATURE    = 0x464D4520
+	ENHMETA_STAMP        = 0x01000000
+	ENHMETA_STOCK_OBJECT = 0x80000000
+)
+
+// ExtTextOut options
+const (
+	ETO_OPAQUE            = 0x0002
+	ETO_CLIPPED           = 0x0004
+	ETO_GLYPH_INDEX       = 0x0010
+	ETO_RTLREADING        = 0x0080
+	ETO_NUMERICSLOCAL     = 0x0400
+	ETO_NUMERICSLATIN     = 0x0800
+	ETO_IGNORELANGUAGE    = 0x1000
+	ETO_PDY               = 0x2000
+	ETO_REVERSE_INDEX_MAP = 0x10000
+)
+
+// SetTextAlign flags
+const (
+	TA_NOUPDATECP = 0
+	TA_UPDATECP   = 1
+	TA_LEFT       = 0
+	TA_RIGHT      = 2
+	TA_CENTER     = 6
+	TA_TOP        = 0
+	TA_BOTTOM     = 8
+	TA_BASELINE   = 24
+	TA_RTLREADING = 256
+	TA_MASK       = TA_BASELINE + TA_CENTER + TA_UPDATECP + TA_RTLREADING
+)
+
+// SetGraphicsMode modes
+const (
+	GM_COMPATIBLE = 1
+	GM_ADVANCED   = 2
+)
+
+// ModifyWorldTransform modes
+const (
+	MWT_IDENTITY      = 1
+	MWT_LEFTMULTIPLY  = 2
+	MWT_RIGHTMULTIPLY = 3
+)
+
+// SetPolyFillMode modes
+const (
+	ALTERNATE = 1
+	WINDING   = 2
+)
+
+// Mapping modes
+const (
+	MM_TEXT        = 1
+	MM_LOMETRIC    = 2
+	MM_HIMETRIC    = 3
+	MM_LOENGLISH   = 4
+	MM_HIENGLISH   = 5
+	MM_TWIPS       = 6
+	MM_ISOTROPIC   = 7
+	MM_ANISOTROPIC = 8
+)
+
+// Binary raster operations
+const (
+	R2_BLACK       = 1
+	R2_NOTMERGEPEN = 2
+	R2_MASKNOTPEN  = 3
+	R2_NOTCOPYPEN  = 4
+	R2_MASKPENNOT  = 5
+	R2_NOT         = 6
+	R2_XORPEN      = 7
+	R2_NOTMASKPEN  = 8
+	R2_MASKPEN     = 9
+	R2_NOTXORPEN   = 10
+	R2_NOP         = 11
+	R2_MERGENOTPEN = 12
+	R2_COPYPEN     = 13
+	R2_MERGEPENNOT = 14
+	R2_MERGEPEN    = 15
+	R2_WHITE       = 16
+)
+
+// CombineRgn modes
+const (
+	RGN_AND  = 1
+	RGN_OR   = 2
+	RGN_XOR  = 3
+	RGN_DIFF = 4
+	RGN_COPY = 5
+)
+
+// SetArcDirection directions
+const (
+	AD_COUNTERCLOCKWISE = 1
+	AD_CLOCKWISE        = 2
+)
+
+// SetStretchBltMode modes
+const (
+	BLACKONWHITE = 1
+	WHITEONBLACK = 2
+	COLORONCOLOR = 3
+	HALFTONE     = 4
+)
+
+// AlphaBlend BLENDFUNCTION values
+const (
+	AC_SRC_OVER  = 0x00
+	AC_SRC_ALPHA = 0x01
+)
+
+const LF_FULLFACESIZE = 64
+
+// EnumFontFamiliesEx font types
+const (
+	RASTER_FONTTYPE   = 0x0001
+	DEVICE_FONTTYPE   = 0x0002
+	TRUETYPE_FONTTYPE = 0x0004
+)
+
+// NEWTEXTMETRIC flags
+const (
+	NTM_ITALIC         = 0x00000001
+	NTM_BOLD           = 0x00000020
+	NTM_REGULAR        = 0x00000040
+	NTM_NONNEGATIVE_AC = 0x00010000
+	NTM_PS_OPENTYPE    = 0x00020000
+	NTM_TT_OPENTYPE    = 0x00040000
+	NTM_MULTIPLEMASTER = 0x00080000
+	NTM_TYPE1          = 0x00100000
+	NTM_DSIG           = 0x00200000
+)
+
+// GetPath point types
+const (
+	PT_CLOSEFIGURE = 0x01
+	PT_LINETO      = 0x02
+	PT_BEZIERTO    = 0x04
+	PT_MOVETO      = 0x06
+)
+
+// WGL_ARB_create_context and WGL_ARB_create_context_profile
+const (
+	WGL_CONTEXT_MAJOR_VERSION_ARB             = 0x2091
+	WGL_CONTEXT_MINOR_VERSION_ARB             = 0x2092
+	WGL_CONTEXT_LAYER_PLANE_ARB               = 0x2093
+	WGL_CONTEXT_FLAGS_ARB                     = 0x2094
+	WGL_CONTEXT_PROFILE_MASK_ARB              = 0x9126
+	WGL_CONTEXT_DEBUG_BIT_ARB                 = 0x0001
+	WGL_CONTEXT_FORWARD_COMPATIBLE_BIT_ARB    = 0x0002
+	WGL_CONTEXT_CORE_PROFILE_BIT_ARB          = 0x0001
+	WGL_CONTEXT_COMPATIBILITY_PROFILE_BIT_ARB = 0x0002
+	ERROR_INVALID_VERSION_ARB                 = 0x2095
+	ERROR_INVALID_PROFILE_ARB                 = 0x2096
+)
+
+// WGL_ARB_pixel_format
+const (
+	WGL_NUMBER_PIXEL_FORMATS_ARB     = 0x2000
+	WGL_DRAW_TO_WINDOW_ARB           = 0x2001
+	WGL_DRAW_TO_BITMAP_ARB           = 0x2002
+	WGL_ACCELERATION_ARB             = 0x2003
+	WGL_NEED_PALETTE_ARB             = 0x2004
+	WGL_NEED_SYSTEM_PALETTE_ARB      = 0x2005
+	WGL_SWAP_LAYER_BUFFERS_ARB       = 0x2006
+	WGL_SWAP_METHOD_ARB              = 0x2007
+	WGL_NUMBER_OVERLAYS_ARB          = 0x2008
+	WGL_NUMBER_UNDERLAYS_ARB         = 0x2009
+	WGL_TRANSPARENT_ARB              = 0x200A
+	WGL_SHARE_DEPTH_ARB              = 0x200C
+	WGL_SHARE_STENCIL_ARB            = 0x200D
+	WGL_SHARE_ACCUM_ARB              = 0x200E
+	WGL_SUPPORT_GDI_ARB              = 0x200F
+	WGL_SUPPORT_OPENGL_ARB           = 0x2010
+	WGL_DOUBLE_BUFFER_ARB            = 0x2011
+	WGL_STEREO_ARB                   = 0x2012
+	WGL_PIXEL_TYPE_ARB               = 0x2013
+	WGL_COLOR_BITS_ARB               = 0x2014
+	WGL_RED_BITS_ARB                 = 0x2015
+	WGL_RED_SHIFT_ARB                = 0x2016
+	WGL_GREEN_BITS_ARB               = 0x2017
+	WGL_GREEN_SHIFT_ARB              = 0x2018
+	WGL_BLUE_BITS_ARB                = 0x2019
+	WGL_BLUE_SHIFT_ARB               = 0x201A
+	WGL_ALPHA_BITS_ARB               = 0x201B
+	WGL_ALPHA_SHIFT_ARB              = 0x201C
+	WGL_ACCUM_BITS_ARB               = 0x201D
+	WGL_ACCUM_RED_BITS_ARB           = 0x201E
+	WGL_ACCUM_GREEN_BITS_ARB         = 0x201F
+	WGL_ACCUM_BLUE_BITS_ARB          = 0x2020
+	WGL_ACCUM_ALPHA_BITS_ARB         = 0x2021
+	WGL_DEPTH_BITS_ARB               = 0x2022
+	WGL_STENCIL_BITS_ARB             = 0x2023
+	WGL_AUX_BUFFERS_ARB              = 0x2024
+	WGL_NO_ACCELERATION_ARB          = 0x2025
+	WGL_GENERIC_ACCELERATION_ARB     = 0x2026
+	WGL_FULL_ACCELERATION_ARB        = 0x2027
+	WGL_SWAP_EXCHANGE_ARB            = 0x2028
+	WGL_SWAP_COPY_ARB                = 0x2029
+	WGL_SWAP_UNDEFINED_ARB           = 0x202A
+	WGL_TYPE_RGBA_ARB                = 0x202B
+	WGL_TYPE_COLORINDEX_ARB          = 0x202C
+	WGL_SAMPLE_BUFFERS_ARB           = 0x2041
+	WGL_SAMPLES_ARB                  = 0x2042
+	WGL_FRAMEBUFFER_SRGB_CAPABLE_ARB = 0x20A9
+)
+
+// GDI+ SmoothingMode
+const (
+	SmoothingModeInvalid      = -1
+	SmoothingModeDefault      = 0
+	SmoothingModeHighSpeed    = 1
+	SmoothingModeHighQuality  = 2
+	SmoothingModeNone         = 3
+	SmoothingModeAntiAlias    = 4
+	SmoothingModeAntiAlias8x4 = SmoothingModeAntiAlias
+	SmoothingModeAntiAlias8x8 = 5
+)
+
+// GDI+ TextRenderingHint
+const (
+	TextRenderingHintSystemDefault            = 0
+	TextRenderingHintSingleBitPerPixelGridFit = 1
+	TextRenderingHintSingleBitPerPixel        = 2
+	TextRenderingHintAntiAliasGridFit         = 3
+	TextRenderingHintAntiAlias                = 4
+	TextRenderingHintClearTypeGridFit         = 5
+)
+
+// GDI+ PixelOffsetMode
+const (
+	PixelOffsetModeInvalid     = -1
+	PixelOffsetModeDefault     = 0
+	PixelOffsetModeHighSpeed   = 1
+	PixelOffsetModeHighQuality = 2
+	PixelOffsetModeNone        = 3
+	PixelOffsetModeHalf        = 4
+)
+
+// GDI+ InterpolationMode
+const (
+	InterpolationModeInvalid             = -1
+	InterpolationModeDefault             = 0
+	InterpolationModeLowQuality          = 1
+	InterpolationModeHighQuality         = 2
+	InterpolationModeBilinear            = 3
+	InterpolationModeBicubic             = 4
+	InterpolationModeNearestNeighbor     = 5
+	InterpolationModeHighQualityBilinear = 6
+	InterpolationModeHighQualityBicubic  = 7
+)
+
+// GDI+ CompositingQuality
+const (
+	CompositingQualityInvalid        = -1
+	CompositingQualityDefault        = 0
+	CompositingQualityHighSpeed      = 1
+	CompositingQualityHighQuality    = 2
+	CompositingQualityGammaCorrected = 3
+	CompositingQualityAssumeLinear   = 4
+)
+
+// GDI+ Unit
+const (
+	UnitWorld      = 0
+	UnitDisplay    = 1
+	UnitPixel      = 2
+	UnitPoint      = 3
+	UnitInch       = 4
+	UnitDocument   = 5
+	UnitMillimeter = 6
+)
+
+// GDI+ FillMode
+const (
+	FillModeAlternate = 0
+	FillModeWinding   = 1
+)
+
+// GDI+ MatrixOrder
+const (
+	MatrixOrderPrepend = 0
+	MatrixOrderAppend  = 1
+)
+
+// GDI+ DashStyle
+const (
+	DashStyleSolid      = 0
+	DashStyleDash       = 1
+	DashStyleDot        = 2
+	DashStyleDashDot    = 3
+	DashStyleDashDotDot = 4
+	DashStyleCustom     = 5
+)
+
+// GDI+ LineCap and DashCap
+const (
+	LineCapFlat          = 0
+	LineCapSquare        = 1
+	LineCapRound         = 2
+	LineCapTriangle      = 3
+	LineCapNoAnchor      = 0x10
+	LineCapSquareAnchor  = 0x11
+	LineCapRoundAnchor   = 0x12
+	LineCapDiamondAnchor = 0x13
+	LineCapArrowAnchor   = 0x14
+	LineCapCustom        = 0xFF
+	DashCapFlat          = 0
+	DashCapRound         = 2
+	DashCapTriangle      = 3
+)
+
+// GDI+ LineJoin
+const (
+	LineJoinMiter        = 0
+	LineJoinBevel        = 1
+	LineJoinRound        = 2
+	LineJoinMiterClipped = 3
+)
+
+// GDI+ FontStyle
+const (
+	FontStyleRegular    = 0
+	FontStyleBold       = 1
+	FontStyleItalic     = 2
+	FontStyleBoldItalic = 3
+	FontStyleUnderline  = 4
+	FontStyleStrikeout  = 8
+)
+
+// GDI+ StringAlignment
+const (
+	StringAlignmentNear   = 0
+	StringAlignmentCenter = 1
+	StringAlignmentFar    = 2
+)
+
+// GDI+ WrapMode
+const (
+	WrapModeTile       = 0
+	WrapModeTileFlipX  = 1
+	WrapModeTileFlipY  = 2
+	WrapModeTileFlipXY = 3
+	WrapModeClamp      = 4
+)
+
+// GDI+ HatchStyle, the first six of 53
+const (
+	HatchStyleHorizontal       = 0
+	HatchStyleVertical         = 1
+	HatchStyleForwardDiagonal  = 2
+	HatchStyleBackwardDiagonal = 3
+	HatchStyleCross            = 4
+	HatchStyleDiagonalCross    = 5
+)
+
+// GDI+ PixelFormat
+const (
+	PixelFormat24bppRGB   = 0x00021808
+	PixelFormat32bppRGB   = 0x00022009
+	PixelFormat32bppARGB  = 0x0026200A
+	PixelFormat32bppPARGB = 0x000E200B
+)
+
+// GDI+ EncoderParameterValueType
+const (
+	EncoderParameterValueTypeByte          = 1
+	EncoderParameterValueTypeASCII         = 2
+	EncoderParameterValueTypeShort         = 3
+	EncoderParameterValueTypeLong          = 4
+	EncoderParameterValueTypeRational      = 5
+	EncoderParameterValueTypeLongRange     = 6
+	EncoderParameterValueTypeUndefined     = 7
+	EncoderParameterValueTypeRationalRange = 8
+	EncoderParameterValueTypePointer       = 9
+)
+
+// GDI+ EncoderValue
+const (
+	EncoderValueColorTypeCMYK            = 0
+	EncoderValueColorTypeYCCK            = 1
+	EncoderValueCompressionLZW           = 2
+	EncoderValueCompressionCCITT3        = 3
+	EncoderValueCompressionCCITT4        = 4
+	EncoderValueCompressionRle           = 5
+	EncoderValueCompressionNone          = 6
+	EncoderValueScanMethodInterlaced     = 7
+	EncoderValueScanMethodNonInterlaced  = 8
+	EncoderValueVersionGif87             = 9
+	EncoderValueVersionGif89             = 10
+	EncoderValueRenderProgressive        = 11
+	EncoderValueRenderNonProgressive     = 12
+	EncoderValueTransformRotate90        = 13
+	EncoderValueTransformRotate180       = 14
+	EncoderValueTransformRotate270       = 15
+	EncoderValueTransformFlipHorizontal  = 16
+	EncoderValueTransformFlipVertical    = 17
+	EncoderValueMultiFrame               = 18
+	EncoderValueLastFrame                = 19
+	EncoderValueFlush                    = 20
+	EncoderValueFrameDimensionTime       = 21
+	EncoderValueFrameDimensionResolution = 22
+	EncoderValueFrameDimensionPage       = 23
+)
+
+// GDI+ ImageCodecFlags
+const (
+	ImageCodecFlagsEncoder        = 0x00000001
+	ImageCodecFlagsDecoder        = 0x00000002
+	ImageCodecFlagsSupportBitmap  = 0x00000004
+	ImageCodecFlagsSupportVector  = 0x00000008
+	ImageCodecFlagsSeekableEncode = 0x00000010
+	ImageCodecFlagsBlockingDecode = 0x00000020
+	ImageCodecFlagsBuiltin        = 0x00010000
+	ImageCodecFlagsSystem         = 0x00020000
+	ImageCodecFlagsUser           = 0x00040000
+)
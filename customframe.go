@@ -0,0 +1,71 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+// CaptionButton is a button drawn by the application in its caption.
+type CaptionButton struct {
+	Rect RECT // relative to the top left corner of the window
+	Hit  int  // the WM_NCHITTEST result, such as HTCLOSE, or HTCLIENT for a control that takes clicks
+}
+
+// FrameLayout describes the non-client regions of a window that draws
+// its own frame.
+type FrameLayout struct {
+	Border        int32 // width of the resize borders, 0 for a window that cannot be resized
+	CaptionHeight int32 // height of the caption, including the top border
+	Buttons       []CaptionButton
+}
+
+// HitTest returns the WM_NCHITTEST result for a point in screen
+// coordinates. See FrameHitTest.
+func (l *FrameLayout) HitTest(pt POINT, window RECT) int {
+	return FrameHitTest(pt, window, l.Border, l.CaptionHeight, l.Buttons)
+}
+
+// FrameHitTest returns the WM_NCHITTEST result for a point in screen
+// coordinates in a window with the given screen rectangle: HTNOWHERE
+// outside the window, one of the HTLEFT to HTBOTTOMRIGHT codes within
+// border of its edges, the Hit of a button under the point, HTCAPTION
+// within captionHeight of the top and HTCLIENT elsewhere. The resize
+// borders take precedence over the buttons, as in standard frames.
+func FrameHitTest(pt POINT, window RECT, border, captionHeight int32, buttons []CaptionButton) int {
+	if pt.X < window.Left || pt.X >= window.Right || pt.Y < window.Top || pt.Y >= window.Bottom {
+		return HTNOWHERE
+	}
+	if border > 0 {
+		left := pt.X < window.Left+border
+		right := pt.X >= window.Right-border
+		top := pt.Y < window.Top+border
+		bottom := pt.Y >= window.Bottom-border
+		switch {
+		case top && left:
+			return HTTOPLEFT
+		case top && right:
+			return HTTOPRIGHT
+		case bottom && left:
+			return HTBOTTOMLEFT
+		case bottom && right:
+			return HTBOTTOMRIGHT
+		case left:
+			return HTLEFT
+		case right:
+			return HTRIGHT
+		case top:
+			return HTTOP
+		case bottom:
+			return HTBOTTOM
+		}
+	}
+	x, y := pt.X-window.Left, pt.Y-window.Top
+	for _, b := range buttons {
+		if x >= b.Rect.Left && x < b.Rect.Right && y >= b.Rect.Top && y < b.Rect.Bottom {
+			return b.Hit
+		}
+	}
+	if y < captionHeight {
+		return HTCAPTION
+	}
+	return HTCLIENT
+}
@@ -0,0 +1,159 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"testing"
+)
+
+// The window is 400x300 at (100, 200), with 8 pixel borders, a 40 pixel
+// caption and three buttons in the top right corner that reach up into
+// the top border.
+var (
+	testFrameWindow  = RECT{100, 200, 500, 500}
+	testFrameButtons = []CaptionButton{
+		{RECT{354, 0, 400, 30}, HTCLOSE},
+		{RECT{308, 0, 354, 30}, HTMAXBUTTON},
+		{RECT{262, 0, 308, 30}, HTMINBUTTON},
+		{RECT{10, 10, 30, 30}, HTSYSMENU},
+		{RECT{200, 10, 240, 30}, HTCLIENT},
+	}
+)
+
+func TestFrameHitTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		x, y    int32 // relative to the window
+		border  int32
+		caption int32
+		want    int
+	}{
+		{"outside left", -1, 100, 8, 40, HTNOWHERE},
+		{"outside above", 100, -1, 8, 40, HTNOWHERE},
+		{"on Right", 400, 100, 8, 40, HTNOWHERE},
+		{"on Bottom", 100, 300, 8, 40, HTNOWHERE},
+		{"on Right and Bottom", 400, 300, 8, 40, HTNOWHERE},
+		{"far outside", -1000, 1000, 8, 40, HTNOWHERE},
+
+		{"top left corner", 0, 0, 8, 40, HTTOPLEFT},
+		{"top left corner inner", 7, 7, 8, 40, HTTOPLEFT},
+		{"top right corner", 399, 0, 8, 40, HTTOPRIGHT},
+		{"top right corner inner", 392, 7, 8, 40, HTTOPRIGHT},
+		{"bottom left corner", 0, 299, 8, 40, HTBOTTOMLEFT},
+		{"bottom left corner inner", 7, 292, 8, 40, HTBOTTOMLEFT},
+		{"bottom right corner", 399, 299, 8, 40, HTBOTTOMRIGHT},
+		{"bottom right corner inner", 392, 292, 8, 40, HTBOTTOMRIGHT},
+
+		{"left edge", 0, 150, 8, 40, HTLEFT},
+		{"left edge inner", 7, 8, 8, 40, HTLEFT},
+		{"right edge", 399, 150, 8, 40, HTRIGHT},
+		{"right edge inner", 392, 291, 8, 40, HTRIGHT},
+		{"top edge", 100, 0, 8, 40, HTTOP},
+		{"top edge inner", 8, 7, 8, 40, HTTOP},
+		{"bottom edge", 100, 299, 8, 40, HTBOTTOM},
+		{"bottom edge inner", 391, 292, 8, 40, HTBOTTOM},
+		{"inside left edge", 8, 150, 8, 40, HTCLIENT},
+		{"inside bottom edge", 100, 291, 8, 40, HTCLIENT},
+
+		{"close button", 370, 15, 8, 40, HTCLOSE},
+		{"close button under top border", 370, 8, 8, 40, HTCLOSE},
+		{"top border over close button", 370, 7, 8, 40, HTTOP},
+		{"right border over close button", 395, 15, 8, 40, HTRIGHT},
+		{"maximize button", 308, 29, 8, 40, HTMAXBUTTON},
+		{"minimize button", 262, 8, 8, 40, HTMINBUTTON},
+		{"system menu", 10, 10, 8, 40, HTSYSMENU},
+		{"below system menu", 10, 30, 8, 40, HTCAPTION},
+		{"control in caption", 220, 20, 8, 40, HTCLIENT},
+
+		{"caption", 100, 8, 8, 40, HTCAPTION},
+		{"last caption row", 100, 39, 8, 40, HTCAPTION},
+		{"first client row", 100, 40, 8, 40, HTCLIENT},
+		{"no caption", 100, 8, 8, 0, HTCLIENT},
+		{"caption within border", 100, 7, 8, 5, HTTOP},
+
+		{"no border top left", 0, 0, 0, 40, HTCAPTION},
+		{"no border top right", 399, 0, 0, 40, HTCLOSE},
+		{"no border bottom left", 0, 299, 0, 40, HTCLIENT},
+		{"no border bottom right", 399, 299, 0, 40, HTCLIENT},
+		{"no border on Right", 400, 0, 0, 40, HTNOWHERE},
+	}
+	for _, tt := range tests {
+		pt := POINT{testFrameWindow.Left + tt.x, testFrameWindow.Top + tt.y}
+		if got := FrameHitTest(pt, testFrameWindow, tt.border, tt.caption, testFrameButtons); got != tt.want {
+			t.Errorf("%s: FrameHitTest(%d, %d) = %d, want %d", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+// TestFrameHitTestAllPoints checks every point in and around the window
+// against a straightforward model of the layout.
+func TestFrameHitTestAllPoints(t *testing.T) {
+	model := func(x, y int32) int {
+		if x < 0 || x >= 400 || y < 0 || y >= 300 {
+			return HTNOWHERE
+		}
+		left, right, top, bottom := x < 8, x >= 392, y < 8, y >= 292
+		switch {
+		case top && left:
+			return HTTOPLEFT
+		case top && right:
+			return HTTOPRIGHT
+		case bottom && left:
+			return HTBOTTOMLEFT
+		case bottom && right:
+			return HTBOTTOMRIGHT
+		case left:
+			return HTLEFT
+		case right:
+			return HTRIGHT
+		case top:
+			return HTTOP
+		case bottom:
+			return HTBOTTOM
+		}
+		if y < 30 {
+			switch {
+			case x >= 354:
+				return HTCLOSE
+			case x >= 308:
+				return HTMAXBUTTON
+			case x >= 262:
+				return HTMINBUTTON
+			case x >= 200 && x < 240 && y >= 10:
+				return HTCLIENT
+			case x >= 10 && x < 30 && y >= 10:
+				return HTSYSMENU
+			}
+		}
+		if y < 40 {
+			return HTCAPTION
+		}
+		return HTCLIENT
+	}
+	layout := FrameLayout{Border: 8, CaptionHeight: 40, Buttons: testFrameButtons}
+	seen := make(map[int]int)
+	for y := int32(-5); y < 305; y++ {
+		for x := int32(-5); x < 405; x++ {
+			pt := POINT{testFrameWindow.Left + x, testFrameWindow.Top + y}
+			got := layout.HitTest(pt, testFrameWindow)
+			if want := model(x, y); got != want {
+				t.Fatalf("HitTest(%d, %d) = %d, want %d", x, y, got, want)
+			}
+			seen[got]++
+		}
+	}
+	codes := []int{
+		HTNOWHERE, HTCLIENT, HTCAPTION, HTSYSMENU, HTMINBUTTON, HTMAXBUTTON, HTCLOSE,
+		HTLEFT, HTRIGHT, HTTOP, HTBOTTOM, HTTOPLEFT, HTTOPRIGHT, HTBOTTOMLEFT, HTBOTTOMRIGHT,
+	}
+	for _, c := range codes {
+		if seen[c] == 0 {
+			t.Errorf("no point gave %d", c)
+		}
+	}
+	if seen[HTTOPLEFT] != 8*8 {
+		t.Errorf("top left corner has %d points, want 64", seen[HTTOPLEFT])
+	}
+}
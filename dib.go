@@ -0,0 +1,946 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"math/bits"
+	"unsafe"
+)
+
+// DIB is a device-independent bitmap: a BITMAPINFOHEADER, the optional
+// BI_BITFIELDS masks and color table, and the pixel rows, each padded to a
+// DWORD boundary. Rows are stored bottom-up unless Header.BiHeight is
+// negative.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd183562.aspx
+type DIB struct {
+	Header BITMAPINFOHEADER
+
+	// Masks holds the red, green, blue and alpha masks of a BI_BITFIELDS
+	// bitmap. Only the first three are part of a BITMAPINFO; the alpha
+	// mask comes from a BITMAPV4HEADER or later.
+	Masks   [4]uint32
+	Palette []RGBQUAD
+	Bits    []byte
+
+	// Premultiplied marks 32 bpp color as premultiplied by alpha, the way
+	// AlphaBlend and UpdateLayeredWindow expect it.
+	Premultiplied bool
+
+	// ColorSpace, Endpoints and Gamma are the BITMAPV4HEADER color space
+	// fields; ColorSpace is an LCS_* or PROFILE_* value. Intent and
+	// Profile come from a BITMAPV5HEADER: Profile is the embedded ICC
+	// profile, or the file name of a linked one.
+	ColorSpace uint32
+	Endpoints  CIEXYZTRIPLE
+	Gamma      [3]uint32
+	Intent     uint32
+	Profile    []byte
+}
+
+// DIBOptions selects the format NewDIB produces.
+type DIBOptions struct {
+	// BitCount is 1, 4, 8, 16, 24 or 32; zero means 32.
+	BitCount int
+	TopDown  bool
+
+	// Premultiplied stores 32 bpp color premultiplied by alpha.
+	Premultiplied bool
+
+	// Masks, when non-zero, makes a 16 or 32 bpp bitmap BI_BITFIELDS
+	// with these red, green, blue and alpha masks. Otherwise 16 bpp is
+	// 5-5-5 and 32 bpp is BGRA.
+	Masks [4]uint32
+
+	// Palette is the color table for 1, 4 and 8 bpp. When nil, the
+	// palette of an *image.Paletted source is used if it fits, then
+	// black and white, the 16 VGA colors or the web-safe palette.
+	Palette color.Palette
+
+	// RLE compresses 4 and 8 bpp bitmaps as BI_RLE4 and BI_RLE8. RLE
+	// bitmaps are always bottom-up.
+	RLE bool
+}
+
+// DIBStride returns the size in bytes of one DWORD-aligned row.
+func DIBStride(width, bitCount int) int {
+	return (width*bitCount + 31) / 32 * 4
+}
+
+// Width returns the bitmap width in pixels.
+func (d *DIB) Width() int {
+	return int(d.Header.BiWidth)
+}
+
+// Height returns the bitmap height in pixels, whatever the row order.
+func (d *DIB) Height() int {
+	if d.Header.BiHeight < 0 {
+		return -int(d.Header.BiHeight)
+	}
+	return int(d.Header.BiHeight)
+}
+
+// TopDown reports whether the first row in Bits is the top of the image.
+func (d *DIB) TopDown() bool {
+	return d.Header.BiHeight < 0
+}
+
+// Stride returns the size in bytes of one row of Bits.
+func (d *DIB) Stride() int {
+	return DIBStride(d.Width(), int(d.Header.BiBitCount))
+}
+
+// row returns the bits of image row y, counted from the top.
+func (d *DIB) row(y int) []byte {
+	if !d.TopDown() {
+		y = d.Height() - 1 - y
+	}
+	s := d.Stride()
+	return d.Bits[y*s : (y+1)*s]
+}
+
+// masks returns the channel masks in effect for 16 and 32 bpp bitmaps.
+func (d *DIB) masks() [4]uint32 {
+	if d.Header.BiCompression == BI_BITFIELDS {
+		return d.Masks
+	}
+	if d.Header.BiBitCount == 16 {
+		return [4]uint32{0x7C00, 0x03E0, 0x001F, 0}
+	}
+	return [4]uint32{0x00FF0000, 0x0000FF00, 0x000000FF, 0xFF000000}
+}
+
+// hasAlpha reports whether any pixel of a 32 bpp BI_RGB bitmap has a
+// non-zero fourth byte. Such bitmaps traditionally leave it unused, so an
+// all-zero channel means opaque.
+func (d *DIB) hasAlpha() bool {
+	w, h := d.Width(), d.Height()
+	for y := 0; y < h; y++ {
+		row := d.row(y)
+		for x := 0; x < w; x++ {
+			if row[4*x+3] != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maxDIBPixels bounds the images decoded from untrusted bitmaps.
+const maxDIBPixels = 1 << 26
+
+// RLE data can describe far more pixels than it takes bytes, so an RLE
+// bitmap may only claim maxRLEPixelsPerByte pixels per byte of data, or
+// minRLEPixels if that is more. The densest code, a run of 255 pixels,
+// takes 2 bytes; the limit leaves room for end-of-line and delta codes.
+const (
+	maxRLEPixelsPerByte = 256
+	minRLEPixels        = 1 << 16
+)
+
+func (d *DIB) check() error {
+	w, h := d.Width(), d.Height()
+	if w <= 0 || h <= 0 || w > 1<<16 || h > 1<<16 || w*h > maxDIBPixels {
+		return fmt.Errorf("bad DIB size %dx%d", w, d.Header.BiHeight)
+	}
+	bpp := int(d.Header.BiBitCount)
+	switch d.Header.BiCompression {
+	case BI_RGB:
+		switch bpp {
+		case 1, 4, 8, 16, 24, 32:
+		default:
+			return fmt.Errorf("unsupported DIB bit depth %d", bpp)
+		}
+	case BI_BITFIELDS:
+		if bpp != 16 && bpp != 32 {
+			return fmt.Errorf("BI_BITFIELDS with %d bpp", bpp)
+		}
+	case BI_RLE8, BI_RLE4:
+		if d.rleBitCount() != bpp || d.TopDown() {
+			return fmt.Errorf("bad RLE bitmap with %d bpp", bpp)
+		}
+		if w*h > minRLEPixels && w*h/maxRLEPixelsPerByte > len(d.Bits) {
+			return fmt.Errorf("%d bytes of RLE data for %dx%d pixels", len(d.Bits), w, h)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported DIB compression %d", d.Header.BiCompression)
+	}
+	if len(d.Bits) < d.Stride()*h {
+		return errors.New("DIB bits are truncated")
+	}
+	return nil
+}
+
+// Image converts the bitmap. 1, 4 and 8 bpp bitmaps become *image.Paletted,
+// premultiplied 32 bpp bitmaps *image.RGBA and the rest *image.NRGBA. The
+// bits are copied.
+func (d *DIB) Image() (image.Image, error) {
+	if err := d.check(); err != nil {
+		return nil, err
+	}
+	if d.rleBitCount() != 0 {
+		u, err := d.Decompress()
+		if err != nil {
+			return nil, err
+		}
+		return u.Image()
+	}
+	w, h := d.Width(), d.Height()
+	bpp := int(d.Header.BiBitCount)
+
+	if bpp <= 8 {
+		pal := make(color.Palette, 1<<uint(bpp))
+		for i := range pal {
+			c := color.RGBA{A: 0xFF}
+			if i < len(d.Palette) {
+				q := d.Palette[i]
+				c.R, c.G, c.B = q.RgbRed, q.RgbGreen, q.RgbBlue
+			}
+			pal[i] = c
+		}
+		m := image.NewPaletted(image.Rect(0, 0, w, h), pal)
+		mask := byte(1<<uint(bpp) - 1)
+		for y := 0; y < h; y++ {
+			row := d.row(y)
+			dst := m.Pix[y*m.Stride:]
+			for x := 0; x < w; x++ {
+				bit := x * bpp
+				dst[x] = row[bit/8] >> uint(8-bpp-bit%8) & mask
+			}
+		}
+		return m, nil
+	}
+
+	rect := image.Rect(0, 0, w, h)
+	var pix []byte
+	var stride int
+	var out image.Image
+	if d.Premultiplied && bpp == 32 {
+		m := image.NewRGBA(rect)
+		pix, stride, out = m.Pix, m.Stride, m
+	} else {
+		m := image.NewNRGBA(rect)
+		pix, stride, out = m.Pix, m.Stride, m
+	}
+
+	if bpp == 24 {
+		for y := 0; y < h; y++ {
+			row, dst := d.row(y), pix[y*stride:]
+			for x := 0; x < w; x++ {
+				dst[4*x+0] = row[3*x+2]
+				dst[4*x+1] = row[3*x+1]
+				dst[4*x+2] = row[3*x+0]
+				dst[4*x+3] = 0xFF
+			}
+		}
+		return out, nil
+	}
+
+	masks := d.masks()
+	if bpp == 32 && d.Header.BiCompression == BI_RGB {
+		opaque := !d.hasAlpha()
+		for y := 0; y < h; y++ {
+			row, dst := d.row(y), pix[y*stride:]
+			for x := 0; x < w; x++ {
+				a := row[4*x+3]
+				if opaque {
+					a = 0xFF
+				}
+				dst[4*x+0] = row[4*x+2]
+				dst[4*x+1] = row[4*x+1]
+				dst[4*x+2] = row[4*x+0]
+				dst[4*x+3] = a
+			}
+		}
+	} else {
+		var ch [4]maskChannel
+		for i, m := range masks {
+			ch[i] = newMaskChannel(m)
+		}
+		for y := 0; y < h; y++ {
+			row, dst := d.row(y), pix[y*stride:]
+			for x := 0; x < w; x++ {
+				var v uint32
+				if bpp == 16 {
+					v = uint32(binary.LittleEndian.Uint16(row[2*x:]))
+				} else {
+					v = binary.LittleEndian.Uint32(row[4*x:])
+				}
+				dst[4*x+0] = ch[0].get(v)
+				dst[4*x+1] = ch[1].get(v)
+				dst[4*x+2] = ch[2].get(v)
+				dst[4*x+3] = 0xFF
+				if masks[3] != 0 {
+					dst[4*x+3] = ch[3].get(v)
+				}
+			}
+		}
+	}
+
+	if m, ok := out.(*image.RGBA); ok {
+		// Keep the result a valid premultiplied image even if the
+		// source was not.
+		for i := 0; i < len(m.Pix); i += 4 {
+			a := m.Pix[i+3]
+			for j := 0; j < 3; j++ {
+				if m.Pix[i+j] > a {
+					m.Pix[i+j] = a
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// maskChannel extracts one BI_BITFIELDS channel and scales it to 8 bits.
+type maskChannel struct {
+	mask  uint32
+	shift uint
+	max   uint64
+}
+
+func newMaskChannel(mask uint32) maskChannel {
+	if mask == 0 {
+		return maskChannel{}
+	}
+	shift := uint(bits.TrailingZeros32(mask))
+	return maskChannel{mask, shift, uint64(mask >> shift)}
+}
+
+func (c maskChannel) get(v uint32) uint8 {
+	if c.max == 0 {
+		return 0
+	}
+	return uint8((uint64((v&c.mask)>>c.shift)*255 + c.max/2) / c.max)
+}
+
+func (c maskChannel) put(v uint8) uint32 {
+	return uint32((uint64(v)*c.max+127)/255) << c.shift & c.mask
+}
+
+// NewDIB converts img to a bitmap in the format selected by opts, which may
+// be nil for a bottom-up 32 bpp BI_RGB bitmap with straight alpha.
+func NewDIB(img image.Image, opts *DIBOptions) (*DIB, error) {
+	var o DIBOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.BitCount == 0 {
+		o.BitCount = 32
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("bad DIB size %dx%d", w, h)
+	}
+	switch o.BitCount {
+	case 1, 4, 8, 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("unsupported DIB bit depth %d", o.BitCount)
+	}
+	if o.Masks != [4]uint32{} && o.BitCount != 16 && o.BitCount != 32 {
+		return nil, fmt.Errorf("BI_BITFIELDS with %d bpp", o.BitCount)
+	}
+	if o.RLE && (o.BitCount != 4 && o.BitCount != 8 || o.TopDown) {
+		return nil, errors.New("RLE needs a bottom-up 4 or 8 bpp bitmap")
+	}
+
+	d := &DIB{
+		Header: BITMAPINFOHEADER{
+			BiSize:      40,
+			BiWidth:     int32(w),
+			BiHeight:    int32(h),
+			BiPlanes:    1,
+			BiBitCount:  uint16(o.BitCount),
+			BiSizeImage: uint32(DIBStride(w, o.BitCount) * h),
+		},
+		Premultiplied: o.Premultiplied && o.BitCount == 32,
+	}
+	if o.TopDown {
+		d.Header.BiHeight = -d.Header.BiHeight
+	}
+	if o.Masks != [4]uint32{} {
+		d.Header.BiCompression = BI_BITFIELDS
+		d.Masks = o.Masks
+	}
+	d.Bits = make([]byte, d.Header.BiSizeImage)
+
+	if o.BitCount <= 8 {
+		d.encodePaletted(img, o.Palette)
+		if o.RLE {
+			d.compressRLE()
+		}
+		return d, nil
+	}
+
+	var pix []byte
+	var stride int
+	if d.Premultiplied {
+		m := toRGBA(img)
+		pix, stride = m.Pix, m.Stride
+	} else {
+		m := toNRGBA(img)
+		pix, stride = m.Pix, m.Stride
+	}
+
+	if o.BitCount == 24 {
+		for y := 0; y < h; y++ {
+			src, row := pix[y*stride:], d.row(y)
+			for x := 0; x < w; x++ {
+				row[3*x+0] = src[4*x+2]
+				row[3*x+1] = src[4*x+1]
+				row[3*x+2] = src[4*x+0]
+			}
+		}
+		return d, nil
+	}
+
+	if o.BitCount == 32 && d.Header.BiCompression == BI_RGB {
+		for y := 0; y < h; y++ {
+			src, row := pix[y*stride:], d.row(y)
+			for x := 0; x < w; x++ {
+				row[4*x+0] = src[4*x+2]
+				row[4*x+1] = src[4*x+1]
+				row[4*x+2] = src[4*x+0]
+				row[4*x+3] = src[4*x+3]
+			}
+		}
+		return d, nil
+	}
+
+	var ch [4]maskChannel
+	for i, m := range d.masks() {
+		ch[i] = newMaskChannel(m)
+	}
+	for y := 0; y < h; y++ {
+		src, row := pix[y*stride:], d.row(y)
+		for x := 0; x < w; x++ {
+			p := src[4*x : 4*x+4]
+			v := ch[0].put(p[0]) | ch[1].put(p[1]) | ch[2].put(p[2]) | ch[3].put(p[3])
+			if o.BitCount == 16 {
+				binary.LittleEndian.PutUint16(row[2*x:], uint16(v))
+			} else {
+				binary.LittleEndian.PutUint32(row[4*x:], v)
+			}
+		}
+	}
+	return d, nil
+}
+
+// vgaPalette is the 16-color palette of 4 bpp bitmaps.
+var vgaPalette = color.Palette{
+	color.RGBA{0x00, 0x00, 0x00, 0xFF}, color.RGBA{0x80, 0x00, 0x00, 0xFF},
+	color.RGBA{0x00, 0x80, 0x00, 0xFF}, color.RGBA{0x80, 0x80, 0x00, 0xFF},
+	color.RGBA{0x00, 0x00, 0x80, 0xFF}, color.RGBA{0x80, 0x00, 0x80, 0xFF},
+	color.RGBA{0x00, 0x80, 0x80, 0xFF}, color.RGBA{0xC0, 0xC0, 0xC0, 0xFF},
+	color.RGBA{0x80, 0x80, 0x80, 0xFF}, color.RGBA{0xFF, 0x00, 0x00, 0xFF},
+	color.RGBA{0x00, 0xFF, 0x00, 0xFF}, color.RGBA{0xFF, 0xFF, 0x00, 0xFF},
+	color.RGBA{0x00, 0x00, 0xFF, 0xFF}, color.RGBA{0xFF, 0x00, 0xFF, 0xFF},
+	color.RGBA{0x00, 0xFF, 0xFF, 0xFF}, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+}
+
+func (d *DIB) encodePaletted(img image.Image, pal color.Palette) {
+	bpp := int(d.Header.BiBitCount)
+	src, _ := img.(*image.Paletted)
+	if pal == nil {
+		switch {
+		case src != nil && len(src.Palette) <= 1<<uint(bpp):
+			pal = src.Palette
+		case bpp == 1:
+			pal = color.Palette{color.Black, color.White}
+		case bpp == 4:
+			pal = vgaPalette
+		default:
+			pal = palette.WebSafe
+		}
+	}
+	if len(pal) > 1<<uint(bpp) {
+		pal = pal[:1<<uint(bpp)]
+	}
+
+	d.Palette = make([]RGBQUAD, len(pal))
+	for i, c := range pal {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		d.Palette[i] = RGBQUAD{n.B, n.G, n.R, 0}
+	}
+	d.Header.BiClrUsed = uint32(len(pal))
+
+	direct := src != nil && sameColors(src.Palette, pal)
+	cache := make(map[color.RGBA]uint8)
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	for y := 0; y < h; y++ {
+		row := d.row(y)
+		for x := 0; x < w; x++ {
+			var idx uint8
+			if direct {
+				idx = src.Pix[src.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)]
+			} else {
+				r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+				c := color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+				i, ok := cache[c]
+				if !ok {
+					i = uint8(pal.Index(c))
+					cache[c] = i
+				}
+				idx = i
+			}
+			bit := x * bpp
+			row[bit/8] |= idx << uint(8-bpp-bit%8)
+		}
+	}
+}
+
+func sameColors(a, b color.Palette) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		r1, g1, b1, a1 := a[i].RGBA()
+		r2, g2, b2, a2 := b[i].RGBA()
+		if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+			return false
+		}
+	}
+	return true
+}
+
+// parseDIBHeader reads the header, masks and color table of a packed DIB and
+// returns the offset just past the color table.
+func parseDIBHeader(data []byte) (*DIB, int, error) {
+	if len(data) < 12 {
+		return nil, 0, errors.New("DIB header is truncated")
+	}
+	d := new(DIB)
+	size := int(binary.LittleEndian.Uint32(data))
+	if size == 12 {
+		return parseCoreHeader(data)
+	}
+	if size < 40 || size > len(data) {
+		return nil, 0, fmt.Errorf("bad DIB header size %d", size)
+	}
+	binary.Read(bytes.NewReader(data), binary.LittleEndian, &d.Header)
+
+	r := resReader{data: data, pos: size}
+	if d.Header.BiCompression == BI_BITFIELDS {
+		if size >= 52 {
+			// BITMAPV2INFOHEADER and later carry the masks.
+			m := resReader{data: data, pos: 40}
+			d.Masks[0], d.Masks[1], d.Masks[2] = m.u32(), m.u32(), m.u32()
+			if size >= 56 {
+				d.Masks[3] = m.u32()
+			}
+		} else {
+			d.Masks[0], d.Masks[1], d.Masks[2] = r.u32(), r.u32(), r.u32()
+		}
+	}
+	if size >= 108 {
+		// A BITMAPV4HEADER is a truncated BITMAPV5HEADER.
+		var v5 BITMAPV5HEADER
+		buf := make([]byte, binary.Size(v5))
+		copy(buf, data[:size])
+		binary.Read(bytes.NewReader(buf), binary.LittleEndian, &v5)
+		d.ColorSpace = v5.BV5CSType
+		d.Endpoints = v5.BV5Endpoints
+		d.Gamma = [3]uint32{v5.BV5GammaRed, v5.BV5GammaGreen, v5.BV5GammaBlue}
+		if size >= 124 {
+			d.Intent = v5.BV5Intent
+		}
+	}
+
+	n := int(d.Header.BiClrUsed)
+	if bpp := d.Header.BiBitCount; bpp <= 8 && (n == 0 || n > 1<<bpp) {
+		n = 1 << bpp
+	}
+	if n > 1<<16 {
+		return nil, 0, errors.New("DIB color table is too large")
+	}
+	for i := 0; i < n; i++ {
+		q := r.bytes(4)
+		if q == nil {
+			break
+		}
+		d.Palette = append(d.Palette, RGBQUAD{q[0], q[1], q[2], q[3]})
+	}
+	if r.err != nil {
+		return nil, 0, errors.New("DIB color table is truncated")
+	}
+	return d, r.pos, nil
+}
+
+// parseCoreHeader reads an OS/2-style BITMAPCOREHEADER and its RGBTRIPLE
+// color table.
+func parseCoreHeader(data []byte) (*DIB, int, error) {
+	var core BITMAPCOREHEADER
+	binary.Read(bytes.NewReader(data), binary.LittleEndian, &core)
+	d := &DIB{Header: BITMAPINFOHEADER{
+		BiSize:     40,
+		BiWidth:    int32(core.BcWidth),
+		BiHeight:   int32(core.BcHeight),
+		BiPlanes:   core.BcPlanes,
+		BiBitCount: core.BcBitCount,
+	}}
+	r := resReader{data: data, pos: 12}
+	if core.BcBitCount <= 8 {
+		for i := 0; i < 1<<core.BcBitCount; i++ {
+			q := r.bytes(3)
+			if q == nil {
+				return nil, 0, errors.New("DIB color table is truncated")
+			}
+			d.Palette = append(d.Palette, RGBQUAD{q[0], q[1], q[2], 0})
+		}
+	}
+	return d, r.pos, nil
+}
+
+// ParseDIB decodes a packed DIB, the BITMAPINFO followed by the bits, as
+// found in CF_DIB and CF_DIBV5 clipboard data and bitmap resources. The bits
+// are not copied.
+func ParseDIB(data []byte) (*DIB, error) {
+	d, off, err := parseDIBHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.readProfile(data); err != nil {
+		return nil, err
+	}
+	if err := d.setBits(data[off:]); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// readProfile points d.Profile at the color profile of a BITMAPV5HEADER.
+// Its offset is relative to the start of the header, which is data[0].
+func (d *DIB) readProfile(data []byte) error {
+	if d.ColorSpace != PROFILE_EMBEDDED && d.ColorSpace != PROFILE_LINKED {
+		return nil
+	}
+	if len(data) < 124 || binary.LittleEndian.Uint32(data) < 124 {
+		return nil
+	}
+	start := int(binary.LittleEndian.Uint32(data[112:]))
+	n := int(binary.LittleEndian.Uint32(data[116:]))
+	if start < 124 || start > len(data) || n > len(data)-start {
+		return errors.New("DIB color profile is out of range")
+	}
+	d.Profile = data[start : start+n]
+	return nil
+}
+
+// setBits points d at bits, trimmed to the size the header implies.
+func (d *DIB) setBits(bits []byte) error {
+	d.Bits = bits
+	if err := d.check(); err != nil {
+		return err
+	}
+	n := d.Stride() * d.Height()
+	if d.rleBitCount() != 0 {
+		n = int(d.Header.BiSizeImage)
+		if n == 0 || n > len(bits) {
+			n = len(bits)
+		}
+	}
+	d.Bits = bits[:n]
+	return nil
+}
+
+// headerSize picks the smallest header that holds d: a BITMAPV5HEADER for
+// a color profile or rendering intent, a BITMAPV4HEADER for an alpha mask or
+// a color space other than sRGB, and a BITMAPINFOHEADER otherwise.
+func (d *DIB) headerSize() int {
+	switch {
+	case d.Profile != nil || d.Intent != 0:
+		return 124
+	case d.Header.BiCompression == BI_BITFIELDS && d.Masks[3] != 0,
+		d.ColorSpace != LCS_CALIBRATED_RGB && d.ColorSpace != LCS_sRGB,
+		d.Endpoints != CIEXYZTRIPLE{}, d.Gamma != [3]uint32{}:
+		return 108
+	}
+	return 40
+}
+
+// InfoBytes encodes the BITMAPINFO: the header, the three BI_BITFIELDS masks
+// and the color table. A BITMAPV4HEADER or BITMAPV5HEADER is used when d
+// needs one; the profile then follows the bits, as Bytes writes them.
+func (d *DIB) InfoBytes() []byte {
+	size := d.headerSize()
+	var b bytes.Buffer
+	if size == 40 {
+		hdr := d.Header
+		hdr.BiSize = 40
+		binary.Write(&b, binary.LittleEndian, hdr)
+		if hdr.BiCompression == BI_BITFIELDS {
+			binary.Write(&b, binary.LittleEndian, d.Masks[:3])
+		}
+		binary.Write(&b, binary.LittleEndian, d.Palette)
+		return b.Bytes()
+	}
+
+	h := d.Header
+	v5 := BITMAPV5HEADER{
+		BV5Size:          uint32(size),
+		BV5Width:         h.BiWidth,
+		BV5Height:        h.BiHeight,
+		BV5Planes:        h.BiPlanes,
+		BV5BitCount:      h.BiBitCount,
+		BV5Compression:   h.BiCompression,
+		BV5SizeImage:     h.BiSizeImage,
+		BV5XPelsPerMeter: h.BiXPelsPerMeter,
+		BV5YPelsPerMeter: h.BiYPelsPerMeter,
+		BV5ClrUsed:       h.BiClrUsed,
+		BV5ClrImportant:  h.BiClrImportant,
+		BV5CSType:        d.ColorSpace,
+		BV5Endpoints:     d.Endpoints,
+		BV5GammaRed:      d.Gamma[0],
+		BV5GammaGreen:    d.Gamma[1],
+		BV5GammaBlue:     d.Gamma[2],
+		BV5Intent:        d.Intent,
+	}
+	if h.BiCompression == BI_BITFIELDS {
+		v5.BV5RedMask, v5.BV5GreenMask, v5.BV5BlueMask, v5.BV5AlphaMask = d.Masks[0], d.Masks[1], d.Masks[2], d.Masks[3]
+	}
+	if v5.BV5CSType == LCS_CALIBRATED_RGB && d.Endpoints == (CIEXYZTRIPLE{}) {
+		v5.BV5CSType = LCS_sRGB
+	}
+	if d.Profile != nil {
+		if v5.BV5CSType != PROFILE_LINKED {
+			v5.BV5CSType = PROFILE_EMBEDDED
+		}
+		v5.BV5ProfileData = uint32(size + 4*len(d.Palette) + len(d.Bits))
+		v5.BV5ProfileSize = uint32(len(d.Profile))
+	}
+	binary.Write(&b, binary.LittleEndian, v5)
+	b.Truncate(size)
+	binary.Write(&b, binary.LittleEndian, d.Palette)
+	return b.Bytes()
+}
+
+// BitmapInfo returns a pointer to the BITMAPINFO for CreateDIBSection,
+// SetDIBitsToDevice and friends: the bytes of InfoBytes, with the masks
+// and color table following the header as those functions expect.
+//
+// Convert it to *BITMAPINFO only in the call itself, and never copy the
+// struct it points to: BITMAPINFO declares BmiColors as a pointer, but
+// here that field holds color table bytes, which the garbage collector
+// must not find in a copy.
+func (d *DIB) BitmapInfo() unsafe.Pointer {
+	info := d.InfoBytes()
+	if n := int(unsafe.Sizeof(BITMAPINFO{})); len(info) < n {
+		info = append(info, make([]byte, n-len(info))...)
+	}
+	return unsafe.Pointer(&info[0])
+}
+
+// Bytes encodes d as a packed DIB, followed by the color profile if there
+// is one.
+func (d *DIB) Bytes() []byte {
+	b := append(d.InfoBytes(), d.Bits...)
+	return append(b, d.Profile...)
+}
+
+// rleBitCount returns 8 for BI_RLE8, 4 for BI_RLE4 and 0 otherwise.
+func (d *DIB) rleBitCount() int {
+	switch d.Header.BiCompression {
+	case BI_RLE8:
+		return 8
+	case BI_RLE4:
+		return 4
+	}
+	return 0
+}
+
+// Decompress returns a BI_RGB copy of a BI_RLE4 or BI_RLE8 bitmap. Pixels
+// that the delta and end-of-line codes skip get color index 0. Other
+// bitmaps are returned as they are.
+func (d *DIB) Decompress() (*DIB, error) {
+	bpp := d.rleBitCount()
+	if bpp == 0 {
+		return d, nil
+	}
+	if err := d.check(); err != nil {
+		return nil, err
+	}
+
+	u := *d
+	u.Header.BiCompression = BI_RGB
+	u.Header.BiSizeImage = uint32(u.Stride() * u.Height())
+	u.Bits = make([]byte, u.Header.BiSizeImage)
+	w, h, stride := u.Width(), u.Height(), u.Stride()
+
+	// Rows are numbered from the bottom, the order they are stored in.
+	x, y := 0, 0
+	put := func(idx byte) {
+		if x < w && y < h {
+			bit := x * bpp
+			u.Bits[y*stride+bit/8] |= idx << uint(8-bpp-bit%8)
+		}
+		x++
+	}
+	src := d.Bits
+	for i := 0; i+1 < len(src); {
+		n, v := int(src[i]), src[i+1]
+		i += 2
+		if n > 0 {
+			for j := 0; j < n; j++ {
+				if bpp == 8 {
+					put(v)
+				} else if j%2 == 0 {
+					put(v >> 4)
+				} else {
+					put(v & 0x0F)
+				}
+			}
+			continue
+		}
+		switch v {
+		case 0:
+			x, y = 0, y+1
+		case 1:
+			return &u, nil
+		case 2:
+			if i+1 >= len(src) {
+				return nil, errors.New("RLE delta is truncated")
+			}
+			x, y = x+int(src[i]), y+int(src[i+1])
+			i += 2
+		default:
+			count := int(v)
+			nbytes := count
+			if bpp == 4 {
+				nbytes = (count + 1) / 2
+			}
+			if i+nbytes > len(src) {
+				return nil, errors.New("RLE literal run is truncated")
+			}
+			for j := 0; j < count; j++ {
+				if bpp == 8 {
+					put(src[i+j])
+				} else if j%2 == 0 {
+					put(src[i+j/2] >> 4)
+				} else {
+					put(src[i+j/2] & 0x0F)
+				}
+			}
+			// Literal runs are padded to a WORD boundary.
+			i += nbytes + nbytes%2
+		}
+		if y >= h {
+			break
+		}
+	}
+	return &u, nil
+}
+
+// compressRLE replaces the BI_RGB bits of a bottom-up 4 or 8 bpp bitmap with
+// BI_RLE4 or BI_RLE8 data.
+func (d *DIB) compressRLE() {
+	bpp := int(d.Header.BiBitCount)
+	w, h := d.Width(), d.Height()
+	idx := make([]byte, w)
+	var out []byte
+	for y := 0; y < h; y++ {
+		row := d.Bits[y*d.Stride():]
+		for x := range idx {
+			bit := x * bpp
+			idx[x] = row[bit/8] >> uint(8-bpp-bit%8) & byte(1<<uint(bpp)-1)
+		}
+		out = appendRLERow(out, idx, bpp)
+		if y == h-1 {
+			out = append(out, 0, 1)
+		} else {
+			out = append(out, 0, 0)
+		}
+	}
+
+	d.Bits = out
+	d.Header.BiSizeImage = uint32(len(out))
+	if bpp == 8 {
+		d.Header.BiCompression = BI_RLE8
+	} else {
+		d.Header.BiCompression = BI_RLE4
+	}
+}
+
+// appendRLERow encodes one row of color indexes: runs of two or more equal
+// pixels in encoded mode, other stretches of three or more pixels in
+// absolute mode.
+func appendRLERow(out, idx []byte, bpp int) []byte {
+	const maxRun = 255
+	for x := 0; x < len(idx); {
+		run := 1
+		for x+run < len(idx) && run < maxRun && idx[x+run] == idx[x] {
+			run++
+		}
+		if run >= 2 {
+			v := idx[x]
+			if bpp == 4 {
+				v |= v << 4
+			}
+			out = append(out, byte(run), v)
+			x += run
+			continue
+		}
+
+		// Collect pixels up to the next run of two.
+		lit := 1
+		for x+lit < len(idx) && lit < maxRun-1 {
+			if x+lit+1 < len(idx) && idx[x+lit] == idx[x+lit+1] {
+				break
+			}
+			lit++
+		}
+		if lit < 3 {
+			for _, v := range idx[x : x+lit] {
+				if bpp == 4 {
+					v |= v << 4
+				}
+				out = append(out, 1, v)
+			}
+			x += lit
+			continue
+		}
+		out = append(out, 0, byte(lit))
+		n := 0
+		if bpp == 8 {
+			out = append(out, idx[x:x+lit]...)
+			n = lit
+		} else {
+			for j := 0; j < lit; j += 2 {
+				v := idx[x+j] << 4
+				if j+1 < lit {
+					v |= idx[x+j+1]
+				}
+				out = append(out, v)
+				n++
+			}
+		}
+		if n%2 != 0 {
+			out = append(out, 0)
+		}
+		x += lit
+	}
+	return out
+}
+
+// toRGBA returns img as an *image.RGBA whose bounds start at the origin.
+func toRGBA(img image.Image) *image.RGBA {
+	if m, ok := img.(*image.RGBA); ok && m.Rect.Min == (image.Point{}) {
+		return m
+	}
+	b := img.Bounds()
+	m := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(m, m.Rect, img, b.Min, draw.Src)
+	return m
+}
@@ -0,0 +1,425 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"reflect"
+	"testing"
+)
+
+// benchDIBFormats are the formats benchmarked at 4K: the source image and
+// the options NewDIB converts it with.
+var benchDIBFormats = []struct {
+	name string
+	img  func() image.Image
+	opts DIBOptions
+}{
+	{"32bpp", benchNRGBA, DIBOptions{BitCount: 32}},
+	{"24bpp", benchNRGBA, DIBOptions{BitCount: 24}},
+	{"8bpp-paletted", benchPaletted, DIBOptions{BitCount: 8}},
+}
+
+const benchDIBWidth, benchDIBHeight = 3840, 2160
+
+func benchNRGBA() image.Image {
+	m := image.NewNRGBA(image.Rect(0, 0, benchDIBWidth, benchDIBHeight))
+	for y := 0; y < benchDIBHeight; y++ {
+		for x := 0; x < benchDIBWidth; x++ {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(y), uint8(x ^ y), uint8(x + y)})
+		}
+	}
+	return m
+}
+
+func benchPaletted() image.Image {
+	m := image.NewPaletted(image.Rect(0, 0, benchDIBWidth, benchDIBHeight), palette.WebSafe)
+	for y := 0; y < benchDIBHeight; y++ {
+		for x := 0; x < benchDIBWidth; x++ {
+			m.SetColorIndex(x, y, uint8((x+y)%len(palette.WebSafe)))
+		}
+	}
+	return m
+}
+
+func BenchmarkDIBImage(b *testing.B) {
+	for _, f := range benchDIBFormats {
+		opts := f.opts
+		d, err := NewDIB(f.img(), &opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(f.name, func(b *testing.B) {
+			b.SetBytes(int64(len(d.Bits)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := d.Image(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkImageToDIB(b *testing.B) {
+	for _, f := range benchDIBFormats {
+		img := f.img()
+		opts := f.opts
+		b.Run(f.name, func(b *testing.B) {
+			b.SetBytes(int64(benchDIBWidth * benchDIBHeight * 4))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := NewDIB(img, &opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// dibColors maps the letters of the rows in the tests below to colors.
+var dibColors = map[byte]color.NRGBA{
+	'K': {0x00, 0x00, 0x00, 0xFF},
+	'R': {0xFF, 0x00, 0x00, 0xFF},
+	'G': {0x00, 0xFF, 0x00, 0xFF},
+	'B': {0x00, 0x00, 0xFF, 0xFF},
+	'W': {0xFF, 0xFF, 0xFF, 0xFF},
+	'h': {0xFF, 0x00, 0x00, 0x80},
+	'.': {},
+}
+
+// dibRows returns an image with one pixel per letter, rows top to bottom.
+func dibRows(rows ...string) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x := 0; x < len(row); x++ {
+			m.SetNRGBA(x, y, dibColors[row[x]])
+		}
+	}
+	return m
+}
+
+// testDIBPalette holds black, red, green, blue and white.
+var testDIBPalette = []RGBQUAD{{0, 0, 0, 0}, {0, 0, 0xFF, 0}, {0, 0xFF, 0, 0}, {0xFF, 0, 0, 0}, {0xFF, 0xFF, 0xFF, 0}}
+
+// packDIB lays out a packed DIB with a BITMAPINFOHEADER. table is the
+// masks or color table.
+func packDIB(w, h int32, bpp uint16, compression uint32, table interface{}, bits ...byte) []byte {
+	var b bytes.Buffer
+	hdr := BITMAPINFOHEADER{
+		BiSize: 40, BiWidth: w, BiHeight: h, BiPlanes: 1, BiBitCount: bpp,
+		BiCompression: compression, BiSizeImage: uint32(len(bits)),
+	}
+	if pal, ok := table.([]RGBQUAD); ok {
+		hdr.BiClrUsed = uint32(len(pal))
+	}
+	binary.Write(&b, binary.LittleEndian, hdr)
+	if table != nil {
+		binary.Write(&b, binary.LittleEndian, table)
+	}
+	b.Write(bits)
+	return b.Bytes()
+}
+
+func TestDIBKnownPixels(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		want     *image.NRGBA
+		paletted bool
+	}{
+		{"1bpp", packDIB(3, 2, 1, BI_RGB, []RGBQUAD{{0, 0, 0, 0}, {0xFF, 0xFF, 0xFF, 0}},
+			0x40, 0, 0, 0, // bottom row
+			0xA0, 0, 0, 0),
+			dibRows("WKW", "KWK"), true},
+		{"4bpp top-down", packDIB(3, -2, 4, BI_RGB, testDIBPalette[:4],
+			0x12, 0x30, 0, 0,
+			0x30, 0x10, 0, 0),
+			dibRows("RGB", "BKR"), true},
+		{"8bpp", packDIB(3, 2, 8, BI_RGB, testDIBPalette,
+			3, 4, 3, 0,
+			0, 1, 2, 0),
+			dibRows("KRG", "BWB"), true},
+		{"16bpp 5-5-5", packDIB(2, 2, 16, BI_RGB, nil,
+			0x1F, 0x00, 0xFF, 0x7F,
+			0x00, 0x7C, 0xE0, 0x03),
+			dibRows("RG", "BW"), false},
+		{"24bpp", packDIB(3, 2, 24, BI_RGB, nil,
+			0xFF, 0xFF, 0xFF, 0, 0, 0, 0, 0, 0xFF, 0, 0, 0,
+			0, 0, 0xFF, 0, 0xFF, 0, 0xFF, 0, 0, 0, 0, 0),
+			dibRows("RGB", "WKR"), false},
+		// An alpha byte that is 0 throughout means opaque.
+		{"32bpp no alpha", packDIB(2, 1, 32, BI_RGB, nil,
+			0, 0, 0xFF, 0, 0xFF, 0, 0, 0),
+			dibRows("RB"), false},
+		{"32bpp alpha", packDIB(2, 1, 32, BI_RGB, nil,
+			0, 0, 0xFF, 0x80, 0, 0, 0, 0),
+			dibRows("h."), false},
+		{"16bpp 5-6-5", packDIB(2, -1, 16, BI_BITFIELDS, []uint32{0xF800, 0x07E0, 0x001F},
+			0xE0, 0x07, 0xFF, 0xFF),
+			dibRows("GW"), false},
+		{"32bpp RGBX", packDIB(2, 1, 32, BI_BITFIELDS, []uint32{0x0000FF, 0x00FF00, 0xFF0000},
+			0xFF, 0, 0, 0, 0, 0, 0xFF, 0),
+			dibRows("RB"), false},
+		// Encoded, absolute and delta codes; the pixel skipped by the
+		// delta gets index 0.
+		{"RLE8", packDIB(3, 3, 8, BI_RLE8, testDIBPalette,
+			3, 1, 0, 0,
+			0, 3, 2, 3, 4, 0, 0, 0,
+			1, 3, 0, 2, 1, 0, 1, 4, 0, 1),
+			dibRows("BKW", "GBW", "RRR"), true},
+		{"RLE4", packDIB(5, 2, 4, BI_RLE4, testDIBPalette,
+			5, 0x12, 0, 0,
+			0, 5, 0x34, 0x01, 0x20, 0, 0, 1),
+			dibRows("BWKRG", "RGRGR"), true},
+	}
+	for _, tt := range tests {
+		d, err := ParseDIB(tt.data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		img, err := d.Image()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if _, ok := img.(*image.Paletted); ok != tt.paletted {
+			t.Errorf("%s: image is a %T", tt.name, img)
+		}
+		checkSameImage(t, tt.name, img, tt.want)
+	}
+}
+
+// dibTestImage returns a w x h image with every channel varying, and
+// alpha too if alpha is set.
+func dibTestImage(w, h int, alpha bool) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a := uint8(0xFF)
+			if alpha {
+				a = uint8(x*40 + y)
+			}
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x * 37), uint8(y * 53), uint8(x*y + 9), a})
+		}
+	}
+	return m
+}
+
+// dibPrimaries returns a w x h opaque image of colors whose channels are
+// 0 or 0xFF, which survive 5 and 6 bit channels.
+func dibPrimaries(w, h int) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := x + 3*y
+			m.SetNRGBA(x, y, color.NRGBA{uint8(i&1) * 0xFF, uint8(i>>1&1) * 0xFF, uint8(i>>2&1) * 0xFF, 0xFF})
+		}
+	}
+	return m
+}
+
+// dibPaletted returns a w x h image using n colors, with runs on the
+// left and single pixels on the right.
+func dibPaletted(w, h, n int) *image.Paletted {
+	pal := make(color.Palette, n)
+	for i := range pal {
+		pal[i] = color.RGBA{uint8(i * 255 / n), uint8(255 - i), uint8(i * 7), 0xFF}
+	}
+	m := image.NewPaletted(image.Rect(0, 0, w, h), pal)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := (x + 2*y) % n
+			if x < w/2 {
+				i = y % n
+			}
+			m.SetColorIndex(x, y, uint8(i))
+		}
+	}
+	return m
+}
+
+func TestDIBRoundTrip(t *testing.T) {
+	opaque := dibTestImage(7, 5, false)
+	tests := []struct {
+		name string
+		src  image.Image
+		opts DIBOptions
+		want image.Image // src if nil
+	}{
+		{"1bpp", dibPaletted(7, 5, 2), DIBOptions{BitCount: 1}, nil},
+		{"4bpp", dibPaletted(7, 5, 16), DIBOptions{BitCount: 4}, nil},
+		{"8bpp", dibPaletted(7, 5, 200), DIBOptions{BitCount: 8}, nil},
+		{"16bpp", dibPrimaries(7, 5), DIBOptions{BitCount: 16}, nil},
+		{"24bpp", opaque, DIBOptions{BitCount: 24}, nil},
+		{"24bpp drops alpha", dibTestImage(7, 5, true), DIBOptions{BitCount: 24}, opaque},
+		{"32bpp", dibTestImage(7, 5, true), DIBOptions{}, nil},
+		{"32bpp premultiplied", dibTestImage(7, 5, true), DIBOptions{Premultiplied: true}, nil},
+		{"16bpp 5-6-5", dibPrimaries(7, 5), DIBOptions{BitCount: 16, Masks: [4]uint32{0xF800, 0x07E0, 0x001F}}, nil},
+		{"32bpp RGBA masks", dibTestImage(7, 5, true),
+			DIBOptions{BitCount: 32, Masks: [4]uint32{0xFF, 0xFF00, 0xFF0000, 0xFF000000}}, nil},
+	}
+	for _, tt := range tests {
+		want := tt.want
+		if want == nil {
+			want = tt.src
+		}
+		if m, ok := tt.src.(*image.NRGBA); ok && tt.opts.Premultiplied {
+			// Premultiplying loses precision; compare with what it
+			// keeps.
+			want = toRGBA(m)
+		}
+		for _, topDown := range []bool{false, true} {
+			name := fmt.Sprintf("%s, top-down %v", tt.name, topDown)
+			opts := tt.opts
+			opts.TopDown = topDown
+			d, err := NewDIB(tt.src, &opts)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			if d.TopDown() != topDown {
+				t.Errorf("%s: height is %d", name, d.Header.BiHeight)
+			}
+			got, err := ParseDIB(d.Bytes())
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			// An alpha mask takes a BITMAPV4HEADER, and a packed DIB does
+			// not record premultiplied alpha.
+			if int(got.Header.BiSize) != d.headerSize() {
+				t.Errorf("%s: header size is %d, want %d", name, got.Header.BiSize, d.headerSize())
+			}
+			got.Header.BiSize = d.Header.BiSize
+			got.Premultiplied = d.Premultiplied
+			if got.Header != d.Header || got.Masks != d.Masks || !reflect.DeepEqual(got.Palette, d.Palette) ||
+				!bytes.Equal(got.Bits, d.Bits) {
+				t.Errorf("%s: parsed DIB differs", name)
+			}
+			img, err := got.Image()
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			checkSameImage(t, name, img, want)
+		}
+	}
+
+	// A paletted source keeps its palette, entry for entry.
+	src := dibPaletted(7, 5, 16)
+	d, _ := NewDIB(src, &DIBOptions{BitCount: 8})
+	if len(d.Palette) != 16 || d.Header.BiClrUsed != 16 {
+		t.Errorf("8bpp DIB of 16 colors has %d palette entries, BiClrUsed %d", len(d.Palette), d.Header.BiClrUsed)
+	}
+	img, _ := d.Image()
+	if m, ok := img.(*image.Paletted); !ok || !bytes.Equal(m.Pix[:m.Stride*5], src.Pix) {
+		t.Error("8bpp DIB does not keep the color indexes")
+	}
+}
+
+func TestDIBRLE(t *testing.T) {
+	for _, tt := range []struct {
+		bpp, w, h, n int
+	}{
+		{8, 1, 3, 2},
+		{8, 7, 5, 200},
+		{8, 13, 4, 3},
+		{8, 600, 2, 5}, // runs longer than 255
+		{4, 1, 3, 2},
+		{4, 7, 5, 16},
+		{4, 13, 4, 3},
+		{4, 600, 2, 5},
+	} {
+		name := fmt.Sprintf("RLE%d %dx%d", tt.bpp, tt.w, tt.h)
+		src := dibPaletted(tt.w, tt.h, tt.n)
+		d, err := NewDIB(src, &DIBOptions{BitCount: tt.bpp, RLE: true})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if d.rleBitCount() != tt.bpp || int(d.Header.BiSizeImage) != len(d.Bits) {
+			t.Errorf("%s: compression %d with %d of %d bytes", name, d.Header.BiCompression, d.Header.BiSizeImage, len(d.Bits))
+		}
+		got, err := ParseDIB(d.Bytes())
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		img, err := got.Image()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		checkSameImage(t, name, img, src)
+	}
+
+	if _, err := NewDIB(dibPaletted(4, 4, 2), &DIBOptions{BitCount: 8, RLE: true, TopDown: true}); err == nil {
+		t.Error("NewDIB made a top-down RLE bitmap")
+	}
+	if _, err := NewDIB(dibPaletted(4, 4, 2), &DIBOptions{BitCount: 24, RLE: true}); err == nil {
+		t.Error("NewDIB made a 24bpp RLE bitmap")
+	}
+	for _, bad := range [][]byte{
+		packDIB(3, 1, 8, BI_RLE8, testDIBPalette, 0, 2, 1),          // truncated delta
+		packDIB(3, 1, 8, BI_RLE8, testDIBPalette, 0, 3, 1, 2),       // truncated literal run
+		packDIB(3, 1, 4, BI_RLE4, testDIBPalette, 0, 5, 0x12, 0x34), // truncated literal run
+	} {
+		d, err := ParseDIB(bad)
+		if err != nil {
+			t.Errorf("ParseDIB(%x): %v", bad, err)
+			continue
+		}
+		if _, err := d.Image(); err == nil {
+			t.Errorf("Image of %x succeeded", bad)
+		}
+	}
+}
+
+func TestDIBLimits(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"too many pixels", packDIB(8193, 8192, 1, BI_RGB, testDIBPalette[:2]), "bad DIB size 8193x8192"},
+		{"too wide", packDIB(1<<16+1, 1, 1, BI_RGB, testDIBPalette[:2]), "bad DIB size 65537x1"},
+		{"too tall", packDIB(1, -(1<<16 + 1), 1, BI_RGB, testDIBPalette[:2]), "bad DIB size 1x-65537"},
+		{"empty", packDIB(0, 1, 1, BI_RGB, testDIBPalette[:2]), "bad DIB size 0x1"},
+		{"largest", packDIB(8192, 8192, 1, BI_RGB, testDIBPalette[:2]), "DIB bits are truncated"},
+		{"truncated", packDIB(3, 2, 24, BI_RGB, nil, make([]byte, 23)...), "DIB bits are truncated"},
+		{"2bpp", packDIB(3, 2, 2, BI_RGB, testDIBPalette[:4], make([]byte, 8)...), "unsupported DIB bit depth 2"},
+		{"24bpp masks", packDIB(1, 1, 24, BI_BITFIELDS, []uint32{1, 2, 4}, 0, 0, 0, 0), "BI_BITFIELDS with 24 bpp"},
+		{"RLE top-down", packDIB(3, -1, 8, BI_RLE8, testDIBPalette, 0, 1), "bad RLE bitmap with 8 bpp"},
+		{"RLE8 at 4bpp", packDIB(3, 1, 4, BI_RLE8, testDIBPalette, 0, 1), "bad RLE bitmap with 4 bpp"},
+		{"JPEG", packDIB(3, 1, 24, BI_JPEG, nil, 0, 1), "unsupported DIB compression 4"},
+		{"RLE bomb", packDIB(8192, 8192, 8, BI_RLE8, testDIBPalette, 255, 1, 255, 1, 0, 1),
+			"6 bytes of RLE data for 8192x8192 pixels"},
+		{"RLE bomb just over", packDIB(257, 256, 8, BI_RLE8, testDIBPalette, 0, 1),
+			"2 bytes of RLE data for 257x256 pixels"},
+	} {
+		_, err := ParseDIB(tt.data)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: error is %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	// Small RLE bitmaps need not have much data, and large ones may
+	// claim 256 pixels per byte.
+	if _, err := ParseDIB(packDIB(256, 256, 8, BI_RLE8, testDIBPalette, 0, 1)); err != nil {
+		t.Errorf("256x256 RLE8: %v", err)
+	}
+	d := &DIB{Header: BITMAPINFOHEADER{BiWidth: 8192, BiHeight: 8192, BiBitCount: 4, BiCompression: BI_RLE4}}
+	d.Bits = make([]byte, 8192*8192/maxRLEPixelsPerByte)
+	if err := d.check(); err != nil {
+		t.Errorf("8192x8192 RLE4 with %d bytes: %v", len(d.Bits), err)
+	}
+}
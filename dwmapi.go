@@ -2,11 +2,15 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+//go:build windows
+
 package w32
 
 import (
 	"fmt"
+	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
@@ -90,10 +94,19 @@ func DwmGetColorizationColor(pcrColorization *uint32, pfOpaqueBlend *BOOL) HRESU
 	return HRESULT(ret)
 }
 
+// DwmGetCompositionTimingInfo fills pTimingInfo. Since Windows 8.1, hWnd
+// must be 0.
 func DwmGetCompositionTimingInfo(hWnd HWND, pTimingInfo *DWM_TIMING_INFO) HRESULT {
+	// The C struct is packed, so it is passed as bytes and decoded.
+	var buf [dwmTimingInfoSize]byte
+	info := DWM_TIMING_INFO{cbSize: dwmTimingInfoSize}
+	info.marshal(buf[:])
 	ret, _, _ := procDwmGetCompositionTimingInfo.Call(
 		uintptr(hWnd),
-		uintptr(unsafe.Pointer(pTimingInfo)))
+		uintptr(unsafe.Pointer(&buf[0])))
+	if HRESULT(ret) >= 0 {
+		pTimingInfo.unmarshal(buf[:])
+	}
 	return HRESULT(ret)
 }
 
@@ -105,35 +118,200 @@ func DwmGetTransportAttributes(pfIsRemoting *BOOL, pfIsConnected *BOOL, pDwGener
 	return HRESULT(ret)
 }
 
-// TODO: verify handling of variable arguments
+// DwmGetWindowAttribute reads any attribute that can be read and returns a
+// *BOOL, *RECT or *uint32 depending on its kind. Other attributes fail with
+// E_INVALIDARG; the typed functions below report why.
 func DwmGetWindowAttribute(hWnd HWND, dwAttribute uint32) (pAttribute interface{}, result HRESULT) {
-	var pvAttribute, pvAttrSize uintptr
-	switch dwAttribute {
-	case DWMWA_NCRENDERING_ENABLED:
+	a, ok := dwmAttributes[dwAttribute]
+	if !ok || !a.Get {
+		invalid := uint32(E_INVALIDARG)
+		return nil, HRESULT(invalid)
+	}
+	var pvAttribute unsafe.Pointer
+	switch a.Kind {
+	case DwmAttrBool:
 		v := new(BOOL)
-		pAttribute = v
-		pvAttribute = uintptr(unsafe.Pointer(v))
-		pvAttrSize = unsafe.Sizeof(*v)
-	case DWMWA_CAPTION_BUTTON_BOUNDS, DWMWA_EXTENDED_FRAME_BOUNDS:
+		pAttribute, pvAttribute = v, unsafe.Pointer(v)
+	case DwmAttrRect:
 		v := new(RECT)
-		pAttribute = v
-		pvAttribute = uintptr(unsafe.Pointer(v))
-		pvAttrSize = unsafe.Sizeof(*v)
-	case DWMWA_CLOAKED:
-		panic(fmt.Sprintf("DwmGetWindowAttribute(%d) is not currently supported.", dwAttribute))
+		pAttribute, pvAttribute = v, unsafe.Pointer(v)
 	default:
-		panic(fmt.Sprintf("DwmGetWindowAttribute(%d) is not valid.", dwAttribute))
+		v := new(uint32)
+		pAttribute, pvAttribute = v, unsafe.Pointer(v)
 	}
 
 	ret, _, _ := procDwmGetWindowAttribute.Call(
 		uintptr(hWnd),
 		uintptr(dwAttribute),
-		pvAttribute,
-		pvAttrSize)
+		uintptr(pvAttribute),
+		uintptr(a.Size()))
 	result = HRESULT(ret)
 	return
 }
 
+func dwmGetWindowAttribute(hWnd HWND, attr uint32, pv unsafe.Pointer, size uint32) error {
+	ret, _, _ := procDwmGetWindowAttribute.Call(
+		uintptr(hWnd),
+		uintptr(attr),
+		uintptr(pv),
+		uintptr(size))
+	if HRESULT(ret) < 0 {
+		return &DwmError{"DwmGetWindowAttribute", attr, HRESULT(ret)}
+	}
+	return nil
+}
+
+func dwmSetWindowAttribute(hWnd HWND, attr uint32, pv unsafe.Pointer, size uint32) error {
+	ret, _, _ := procDwmSetWindowAttribute.Call(
+		uintptr(hWnd),
+		uintptr(attr),
+		uintptr(pv),
+		uintptr(size))
+	if HRESULT(ret) < 0 {
+		return &DwmError{"DwmSetWindowAttribute", attr, HRESULT(ret)}
+	}
+	return nil
+}
+
+// DwmGetWindowAttributeBool reads a BOOL attribute.
+func DwmGetWindowAttributeBool(hWnd HWND, attr uint32) (bool, error) {
+	a, err := checkDwmAttribute(attr, false, DwmAttrBool)
+	if err != nil {
+		return false, err
+	}
+	var v BOOL
+	err = dwmGetWindowAttribute(hWnd, attr, unsafe.Pointer(&v), a.Size())
+	return v != 0, err
+}
+
+// DwmSetWindowAttributeBool writes a BOOL attribute.
+func DwmSetWindowAttributeBool(hWnd HWND, attr uint32, value bool) error {
+	a, err := checkDwmAttribute(attr, true, DwmAttrBool)
+	if err != nil {
+		return err
+	}
+	v := BoolToBOOL(value)
+	return dwmSetWindowAttribute(hWnd, attr, unsafe.Pointer(&v), a.Size())
+}
+
+// DwmGetWindowAttributeUint32 reads a DWORD, enumeration or COLORREF
+// attribute.
+func DwmGetWindowAttributeUint32(hWnd HWND, attr uint32) (uint32, error) {
+	a, err := checkDwmAttribute(attr, false, DwmAttrDword, DwmAttrEnum, DwmAttrColor)
+	if err != nil {
+		return 0, err
+	}
+	var v uint32
+	err = dwmGetWindowAttribute(hWnd, attr, unsafe.Pointer(&v), a.Size())
+	return v, err
+}
+
+// DwmSetWindowAttributeUint32 writes a DWORD, enumeration or COLORREF
+// attribute.
+func DwmSetWindowAttributeUint32(hWnd HWND, attr uint32, value uint32) error {
+	a, err := checkDwmAttribute(attr, true, DwmAttrDword, DwmAttrEnum, DwmAttrColor)
+	if err != nil {
+		return err
+	}
+	return dwmSetWindowAttribute(hWnd, attr, unsafe.Pointer(&value), a.Size())
+}
+
+// DwmGetWindowAttributeRect reads a RECT attribute.
+func DwmGetWindowAttributeRect(hWnd HWND, attr uint32) (RECT, error) {
+	var v RECT
+	a, err := checkDwmAttribute(attr, false, DwmAttrRect)
+	if err != nil {
+		return v, err
+	}
+	err = dwmGetWindowAttribute(hWnd, attr, unsafe.Pointer(&v), a.Size())
+	return v, err
+}
+
+// DwmGetCloaked returns the DWM_CLOAKED_* flags of a window, 0 if it is
+// not cloaked.
+func DwmGetCloaked(hWnd HWND) (uint32, error) {
+	return DwmGetWindowAttributeUint32(hWnd, DWMWA_CLOAKED)
+}
+
+// DwmSetCloak cloaks or uncloaks a window.
+func DwmSetCloak(hWnd HWND, cloak bool) error {
+	return DwmSetWindowAttributeBool(hWnd, DWMWA_CLOAK, cloak)
+}
+
+// DwmGetExtendedFrameBounds returns the window bounds in screen
+// coordinates, without the invisible resize borders.
+func DwmGetExtendedFrameBounds(hWnd HWND) (RECT, error) {
+	return DwmGetWindowAttributeRect(hWnd, DWMWA_EXTENDED_FRAME_BOUNDS)
+}
+
+// DwmGetCaptionButtonBounds returns the bounds of the caption buttons in
+// window coordinates.
+func DwmGetCaptionButtonBounds(hWnd HWND) (RECT, error) {
+	return DwmGetWindowAttributeRect(hWnd, DWMWA_CAPTION_BUTTON_BOUNDS)
+}
+
+func DwmSetNCRenderingPolicy(hWnd HWND, policy DWMNCRENDERINGPOLICY) error {
+	return DwmSetWindowAttributeUint32(hWnd, DWMWA_NCRENDERING_POLICY, uint32(policy))
+}
+
+func DwmSetFlip3DPolicy(hWnd HWND, policy DWMFLIP3DWINDOWPOLICY) error {
+	return DwmSetWindowAttributeUint32(hWnd, DWMWA_FLIP3D_POLICY, uint32(policy))
+}
+
+// DwmSetImmersiveDarkMode draws the title bar of a window in dark mode
+// colors. Windows 10 builds before 20H1 use an older attribute, which is
+// tried when the current one is rejected.
+func DwmSetImmersiveDarkMode(hWnd HWND, dark bool) error {
+	err := DwmSetWindowAttributeBool(hWnd, DWMWA_USE_IMMERSIVE_DARK_MODE, dark)
+	if err != nil {
+		if DwmSetWindowAttributeBool(hWnd, DWMWA_USE_IMMERSIVE_DARK_MODE_BEFORE_20H1, dark) == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// DwmSetWindowCornerPreference sets how Windows 11 rounds the corners of
+// a window.
+func DwmSetWindowCornerPreference(hWnd HWND, pref DWM_WINDOW_CORNER_PREFERENCE) error {
+	return DwmSetWindowAttributeUint32(hWnd, DWMWA_WINDOW_CORNER_PREFERENCE, uint32(pref))
+}
+
+// DwmSetBorderColor sets the window border color on Windows 11. color may
+// also be DWMWA_COLOR_DEFAULT or DWMWA_COLOR_NONE.
+func DwmSetBorderColor(hWnd HWND, color COLORREF) error {
+	return DwmSetWindowAttributeUint32(hWnd, DWMWA_BORDER_COLOR, uint32(color))
+}
+
+// DwmSetCaptionColor sets the title bar color on Windows 11. color may
+// also be DWMWA_COLOR_DEFAULT.
+func DwmSetCaptionColor(hWnd HWND, color COLORREF) error {
+	return DwmSetWindowAttributeUint32(hWnd, DWMWA_CAPTION_COLOR, uint32(color))
+}
+
+// DwmSetTextColor sets the title bar text color on Windows 11. color may
+// also be DWMWA_COLOR_DEFAULT.
+func DwmSetTextColor(hWnd HWND, color COLORREF) error {
+	return DwmSetWindowAttributeUint32(hWnd, DWMWA_TEXT_COLOR, uint32(color))
+}
+
+// DwmGetVisibleFrameBorderThickness returns the width of the visible
+// window border on Windows 11.
+func DwmGetVisibleFrameBorderThickness(hWnd HWND) (uint32, error) {
+	return DwmGetWindowAttributeUint32(hWnd, DWMWA_VISIBLE_FRAME_BORDER_THICKNESS)
+}
+
+func DwmGetSystemBackdropType(hWnd HWND) (DWM_SYSTEMBACKDROP_TYPE, error) {
+	v, err := DwmGetWindowAttributeUint32(hWnd, DWMWA_SYSTEMBACKDROP_TYPE)
+	return DWM_SYSTEMBACKDROP_TYPE(v), err
+}
+
+// DwmSetSystemBackdropType sets the Windows 11 material drawn behind a
+// window, such as DWMSBT_MAINWINDOW for Mica.
+func DwmSetSystemBackdropType(hWnd HWND, backdrop DWM_SYSTEMBACKDROP_TYPE) error {
+	return DwmSetWindowAttributeUint32(hWnd, DWMWA_SYSTEMBACKDROP_TYPE, uint32(backdrop))
+}
+
 func DwmInvalidateIconicBitmaps(hWnd HWND) HRESULT {
 	ret, _, _ := procDwmInvalidateIconicBitmaps.Call(
 		uintptr(hWnd))
@@ -247,8 +425,244 @@ func DwmUnregisterThumbnail(hThumbnailId HTHUMBNAIL) HRESULT {
 }
 
 func DwmUpdateThumbnailProperties(hThumbnailId HTHUMBNAIL, ptnProperties *DWM_THUMBNAIL_PROPERTIES) HRESULT {
+	// The C struct is packed, so it is passed as bytes.
+	var buf [dwmThumbnailPropertiesSize]byte
+	ptnProperties.marshal(buf[:])
 	ret, _, _ := procDwmUpdateThumbnailProperties.Call(
 		uintptr(hThumbnailId),
-		uintptr(unsafe.Pointer(ptnProperties)))
+		uintptr(unsafe.Pointer(&buf[0])))
 	return HRESULT(ret)
 }
+
+// FramePacingSampler feeds DWM timing samples to a FramePacingAnalyzer on
+// a ticker.
+type FramePacingSampler struct {
+	hWnd     HWND
+	mu       sync.Mutex
+	analyzer *FramePacingAnalyzer
+	err      error
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// StartFramePacingSampler takes a sample every interval until Stop is
+// called. hWnd is passed to DwmGetCompositionTimingInfo. The first sample
+// is taken before it returns, so that an unavailable compositor is
+// reported at once.
+func StartFramePacingSampler(hWnd HWND, interval time.Duration) (*FramePacingSampler, error) {
+	analyzer, err := NewFramePacingAnalyzer(QueryPerformanceFrequency())
+	if err != nil {
+		return nil, err
+	}
+	s := &FramePacingSampler{
+		hWnd:     hWnd,
+		analyzer: analyzer,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := s.sample(); err != nil {
+		return nil, err
+	}
+	go s.run(interval)
+	return s, nil
+}
+
+func (s *FramePacingSampler) run(interval time.Duration) {
+	defer close(s.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			s.sample()
+		}
+	}
+}
+
+func (s *FramePacingSampler) sample() error {
+	var info DWM_TIMING_INFO
+	var err error
+	if hr := DwmGetCompositionTimingInfo(s.hWnd, &info); hr < 0 {
+		err = fmt.Errorf("DwmGetCompositionTimingInfo failed with HRESULT 0x%08X", uint32(hr))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+	if err == nil {
+		s.analyzer.Add(info.Sample())
+	}
+	return err
+}
+
+// Report returns the statistics of the samples taken so far.
+func (s *FramePacingSampler) Report() FramePacingReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.analyzer.Report()
+}
+
+// Reset forgets the samples taken so far.
+func (s *FramePacingSampler) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.analyzer.Reset()
+}
+
+// Err returns the error of the last sample, if it failed.
+func (s *FramePacingSampler) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Stop stops sampling and waits for the sampling goroutine to exit. It
+// must be called once.
+func (s *FramePacingSampler) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// Thumbnail is a live DWM thumbnail of a source window drawn in a
+// destination window. Its properties are changed with the setters of
+// ThumbnailProperties and sent by Update.
+type Thumbnail struct {
+	ThumbnailProperties
+	handle HTHUMBNAIL
+}
+
+// RegisterThumbnail creates a thumbnail of src in dest. It is not shown
+// until a destination is set and Update is called.
+func RegisterThumbnail(dest, src HWND) (*Thumbnail, error) {
+	var h HTHUMBNAIL
+	if hr := DwmRegisterThumbnail(dest, src, &h); hr < 0 {
+		return nil, fmt.Errorf("DwmRegisterThumbnail failed with HRESULT 0x%08X", uint32(hr))
+	}
+	return &Thumbnail{handle: h}, nil
+}
+
+func (t *Thumbnail) Handle() HTHUMBNAIL {
+	return t.handle
+}
+
+// SourceSize returns the size of the source window.
+func (t *Thumbnail) SourceSize() (SIZE, error) {
+	var size SIZE
+	if hr := DwmQueryThumbnailSourceSize(t.handle, &size); hr < 0 {
+		return size, fmt.Errorf("DwmQueryThumbnailSourceSize failed with HRESULT 0x%08X", uint32(hr))
+	}
+	return size, nil
+}
+
+// Fit sets the destination to the largest rectangle with the aspect ratio
+// of the source, or of the source rectangle if one was set, that fits in
+// bounds. See FitThumbnailRect.
+func (t *Thumbnail) Fit(bounds RECT, upscale bool) error {
+	var size SIZE
+	if (t.dirty|t.applied)&DWM_TNP_RECTSOURCE != 0 {
+		src := t.Source()
+		size = SIZE{src.Right - src.Left, src.Bottom - src.Top}
+	} else {
+		var err error
+		if size, err = t.SourceSize(); err != nil {
+			return err
+		}
+	}
+	t.SetDestination(FitThumbnailRect(size, bounds, upscale))
+	return nil
+}
+
+// Update sends the properties changed since the last update.
+func (t *Thumbnail) Update() error {
+	if t.Dirty() == 0 {
+		return nil
+	}
+	props := t.pending()
+	if hr := DwmUpdateThumbnailProperties(t.handle, &props); hr < 0 {
+		return fmt.Errorf("DwmUpdateThumbnailProperties failed with HRESULT 0x%08X", uint32(hr))
+	}
+	t.markApplied()
+	return nil
+}
+
+// Close unregisters the thumbnail.
+func (t *Thumbnail) Close() error {
+	if t.handle == 0 {
+		return nil
+	}
+	hr := DwmUnregisterThumbnail(t.handle)
+	t.handle = 0
+	if hr < 0 {
+		return fmt.Errorf("DwmUnregisterThumbnail failed with HRESULT 0x%08X", uint32(hr))
+	}
+	return nil
+}
+
+// CustomFrame lets a window draw its own caption. It extends the DWM frame
+// into the client area by Margins, makes the whole window client area,
+// lets DwmDefWindowProc handle the caption buttons drawn by DWM and
+// answers the other WM_NCHITTEST messages from the FrameLayout. The
+// window procedure passes messages to WndProc first:
+//
+//	if ret, ok := frame.WndProc(msg, wParam, lParam); ok {
+//		return ret
+//	}
+//
+// The layout may be changed between messages; set Border to 0 while the
+// window is maximized.
+type CustomFrame struct {
+	FrameLayout
+	Margins MARGINS
+	hWnd    HWND
+}
+
+// NewCustomFrame extends the frame of hWnd and has its non-client area
+// recalculated.
+func NewCustomFrame(hWnd HWND, layout FrameLayout, margins MARGINS) (*CustomFrame, error) {
+	f := &CustomFrame{FrameLayout: layout, Margins: margins, hWnd: hWnd}
+	if err := f.Extend(); err != nil {
+		return nil, err
+	}
+	setWindowPos(hWnd, 0, 0, 0, 0, 0, SWP_FRAMECHANGED|SWP_NOMOVE|SWP_NOSIZE|SWP_NOZORDER|SWP_NOACTIVATE)
+	return f, nil
+}
+
+// Extend extends the frame into the client area by Margins. WndProc calls
+// it again on activation and when composition changes.
+func (f *CustomFrame) Extend() error {
+	m := f.Margins
+	if hr := DwmExtendFrameIntoClientArea(f.hWnd, &m); hr < 0 {
+		return fmt.Errorf("DwmExtendFrameIntoClientArea failed with HRESULT 0x%08X", uint32(hr))
+	}
+	return nil
+}
+
+// WndProc handles the messages of the window that concern its frame. It
+// returns false for messages the window procedure should handle itself.
+func (f *CustomFrame) WndProc(msg uint32, wParam, lParam uintptr) (uintptr, bool) {
+	if handled, ret := DwmDefWindowProc(f.hWnd, uint(msg), wParam, lParam); handled {
+		return uintptr(ret), true
+	}
+	switch msg {
+	case WM_ACTIVATE, WM_DWMCOMPOSITIONCHANGED:
+		f.Extend()
+	case WM_NCCALCSIZE:
+		if wParam != 0 {
+			// Leaving the proposed rectangle as it is removes the
+			// standard frame.
+			return 0, true
+		}
+	case WM_NCHITTEST:
+		pt := POINT{
+			X: int32(int16(LOWORD(uint32(lParam)))),
+			Y: int32(int16(HIWORD(uint32(lParam)))),
+		}
+		window, ok := getWindowRect(f.hWnd)
+		if !ok {
+			return 0, false
+		}
+		return uintptr(f.HitTest(pt, window)), true
+	}
+	return 0, false
+}
@@ -0,0 +1,136 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"fmt"
+)
+
+// DwmAttrKind is the type of the value of a DWMWA_* window attribute.
+type DwmAttrKind int
+
+const (
+	DwmAttrBool  DwmAttrKind = iota + 1 // BOOL
+	DwmAttrDword                        // DWORD, such as the DWM_CLOAKED_* flags or a thickness
+	DwmAttrEnum                         // one of the DWM enumerations, stored as a DWORD
+	DwmAttrColor                        // COLORREF
+	DwmAttrRect                         // RECT
+)
+
+// Size returns the size in bytes of a value of kind k, or 0 if k is not
+// a valid kind.
+func (k DwmAttrKind) Size() uint32 {
+	switch k {
+	case DwmAttrBool, DwmAttrDword, DwmAttrEnum, DwmAttrColor:
+		return 4
+	case DwmAttrRect:
+		return 16
+	}
+	return 0
+}
+
+func (k DwmAttrKind) String() string {
+	switch k {
+	case DwmAttrBool:
+		return "BOOL"
+	case DwmAttrDword:
+		return "DWORD"
+	case DwmAttrEnum:
+		return "enum"
+	case DwmAttrColor:
+		return "COLORREF"
+	case DwmAttrRect:
+		return "RECT"
+	}
+	return fmt.Sprintf("DwmAttrKind(%d)", int(k))
+}
+
+// DwmAttribute describes a DWMWA_* window attribute.
+type DwmAttribute struct {
+	Name string
+	Kind DwmAttrKind
+	Get  bool // documented for DwmGetWindowAttribute
+	Set  bool // documented for DwmSetWindowAttribute
+}
+
+// Size returns the size of the attribute value in bytes.
+func (a DwmAttribute) Size() uint32 {
+	return a.Kind.Size()
+}
+
+var dwmAttributes = map[uint32]DwmAttribute{
+	DWMWA_NCRENDERING_ENABLED:                 {"DWMWA_NCRENDERING_ENABLED", DwmAttrBool, true, false},
+	DWMWA_NCRENDERING_POLICY:                  {"DWMWA_NCRENDERING_POLICY", DwmAttrEnum, false, true},
+	DWMWA_TRANSITIONS_FORCEDISABLED:           {"DWMWA_TRANSITIONS_FORCEDISABLED", DwmAttrBool, false, true},
+	DWMWA_ALLOW_NCPAINT:                       {"DWMWA_ALLOW_NCPAINT", DwmAttrBool, false, true},
+	DWMWA_CAPTION_BUTTON_BOUNDS:               {"DWMWA_CAPTION_BUTTON_BOUNDS", DwmAttrRect, true, false},
+	DWMWA_NONCLIENT_RTL_LAYOUT:                {"DWMWA_NONCLIENT_RTL_LAYOUT", DwmAttrBool, false, true},
+	DWMWA_FORCE_ICONIC_REPRESENTATION:         {"DWMWA_FORCE_ICONIC_REPRESENTATION", DwmAttrBool, false, true},
+	DWMWA_FLIP3D_POLICY:                       {"DWMWA_FLIP3D_POLICY", DwmAttrEnum, false, true},
+	DWMWA_EXTENDED_FRAME_BOUNDS:               {"DWMWA_EXTENDED_FRAME_BOUNDS", DwmAttrRect, true, false},
+	DWMWA_HAS_ICONIC_BITMAP:                   {"DWMWA_HAS_ICONIC_BITMAP", DwmAttrBool, false, true},
+	DWMWA_DISALLOW_PEEK:                       {"DWMWA_DISALLOW_PEEK", DwmAttrBool, false, true},
+	DWMWA_EXCLUDED_FROM_PEEK:                  {"DWMWA_EXCLUDED_FROM_PEEK", DwmAttrBool, false, true},
+	DWMWA_CLOAK:                               {"DWMWA_CLOAK", DwmAttrBool, false, true},
+	DWMWA_CLOAKED:                             {"DWMWA_CLOAKED", DwmAttrDword, true, false},
+	DWMWA_FREEZE_REPRESENTATION:               {"DWMWA_FREEZE_REPRESENTATION", DwmAttrBool, false, true},
+	DWMWA_PASSIVE_UPDATE_MODE:                 {"DWMWA_PASSIVE_UPDATE_MODE", DwmAttrBool, false, true},
+	DWMWA_USE_HOSTBACKDROPBRUSH:               {"DWMWA_USE_HOSTBACKDROPBRUSH", DwmAttrBool, false, true},
+	DWMWA_USE_IMMERSIVE_DARK_MODE_BEFORE_20H1: {"DWMWA_USE_IMMERSIVE_DARK_MODE_BEFORE_20H1", DwmAttrBool, false, true},
+	DWMWA_USE_IMMERSIVE_DARK_MODE:             {"DWMWA_USE_IMMERSIVE_DARK_MODE", DwmAttrBool, false, true},
+	DWMWA_WINDOW_CORNER_PREFERENCE:            {"DWMWA_WINDOW_CORNER_PREFERENCE", DwmAttrEnum, false, true},
+	DWMWA_BORDER_COLOR:                        {"DWMWA_BORDER_COLOR", DwmAttrColor, false, true},
+	DWMWA_CAPTION_COLOR:                       {"DWMWA_CAPTION_COLOR", DwmAttrColor, false, true},
+	DWMWA_TEXT_COLOR:                          {"DWMWA_TEXT_COLOR", DwmAttrColor, false, true},
+	DWMWA_VISIBLE_FRAME_BORDER_THICKNESS:      {"DWMWA_VISIBLE_FRAME_BORDER_THICKNESS", DwmAttrDword, true, false},
+	DWMWA_SYSTEMBACKDROP_TYPE:                 {"DWMWA_SYSTEMBACKDROP_TYPE", DwmAttrEnum, true, true},
+}
+
+// GetDwmAttribute returns the description of a DWMWA_* attribute.
+func GetDwmAttribute(attr uint32) (DwmAttribute, bool) {
+	a, ok := dwmAttributes[attr]
+	return a, ok
+}
+
+// dwmAttrName returns the name of attr for error messages.
+func dwmAttrName(attr uint32) string {
+	if a, ok := dwmAttributes[attr]; ok {
+		return a.Name
+	}
+	return fmt.Sprintf("DWMWA(%d)", attr)
+}
+
+// checkDwmAttribute returns an error unless attr can be read (or written,
+// if set is true) as a value of one of the kinds.
+func checkDwmAttribute(attr uint32, set bool, kinds ...DwmAttrKind) (DwmAttribute, error) {
+	a, ok := dwmAttributes[attr]
+	if !ok {
+		return a, fmt.Errorf("unknown window attribute %d", attr)
+	}
+	if set && !a.Set {
+		return a, fmt.Errorf("%s cannot be set", a.Name)
+	}
+	if !set && !a.Get {
+		return a, fmt.Errorf("%s cannot be read", a.Name)
+	}
+	for _, k := range kinds {
+		if a.Kind == k {
+			return a, nil
+		}
+	}
+	return a, fmt.Errorf("%s is a %v, not a %v", a.Name, a.Kind, kinds[0])
+}
+
+// DwmError is a failed HRESULT returned by a DWM function for a window
+// attribute.
+type DwmError struct {
+	Func      string
+	Attribute uint32
+	Result    HRESULT
+}
+
+func (e *DwmError) Error() string {
+	return fmt.Sprintf("%s(%s) failed with HRESULT 0x%08X", e.Func, dwmAttrName(e.Attribute), uint32(e.Result))
+}
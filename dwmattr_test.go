@@ -0,0 +1,140 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"testing"
+)
+
+// The DWMWA_* values, value sizes and directions as documented for
+// DwmGetWindowAttribute and DwmSetWindowAttribute.
+func TestDwmAttributes(t *testing.T) {
+	want := []struct {
+		attr     uint32
+		name     string
+		size     uint32
+		get, set bool
+	}{
+		{1, "DWMWA_NCRENDERING_ENABLED", 4, true, false},
+		{2, "DWMWA_NCRENDERING_POLICY", 4, false, true},
+		{3, "DWMWA_TRANSITIONS_FORCEDISABLED", 4, false, true},
+		{4, "DWMWA_ALLOW_NCPAINT", 4, false, true},
+		{5, "DWMWA_CAPTION_BUTTON_BOUNDS", 16, true, false},
+		{6, "DWMWA_NONCLIENT_RTL_LAYOUT", 4, false, true},
+		{7, "DWMWA_FORCE_ICONIC_REPRESENTATION", 4, false, true},
+		{8, "DWMWA_FLIP3D_POLICY", 4, false, true},
+		{9, "DWMWA_EXTENDED_FRAME_BOUNDS", 16, true, false},
+		{10, "DWMWA_HAS_ICONIC_BITMAP", 4, false, true},
+		{11, "DWMWA_DISALLOW_PEEK", 4, false, true},
+		{12, "DWMWA_EXCLUDED_FROM_PEEK", 4, false, true},
+		{13, "DWMWA_CLOAK", 4, false, true},
+		{14, "DWMWA_CLOAKED", 4, true, false},
+		{15, "DWMWA_FREEZE_REPRESENTATION", 4, false, true},
+		{16, "DWMWA_PASSIVE_UPDATE_MODE", 4, false, true},
+		{17, "DWMWA_USE_HOSTBACKDROPBRUSH", 4, false, true},
+		{19, "DWMWA_USE_IMMERSIVE_DARK_MODE_BEFORE_20H1", 4, false, true},
+		{20, "DWMWA_USE_IMMERSIVE_DARK_MODE", 4, false, true},
+		{33, "DWMWA_WINDOW_CORNER_PREFERENCE", 4, false, true},
+		{34, "DWMWA_BORDER_COLOR", 4, false, true},
+		{35, "DWMWA_CAPTION_COLOR", 4, false, true},
+		{36, "DWMWA_TEXT_COLOR", 4, false, true},
+		{37, "DWMWA_VISIBLE_FRAME_BORDER_THICKNESS", 4, true, false},
+		{38, "DWMWA_SYSTEMBACKDROP_TYPE", 4, true, true},
+	}
+	if len(dwmAttributes) != len(want) {
+		t.Errorf("dwmAttributes has %d entries, want %d", len(dwmAttributes), len(want))
+	}
+	for _, w := range want {
+		a, ok := GetDwmAttribute(w.attr)
+		if !ok {
+			t.Errorf("attribute %d (%s) is missing", w.attr, w.name)
+			continue
+		}
+		if a.Name != w.name || a.Size() != w.size || a.Get != w.get || a.Set != w.set {
+			t.Errorf("attribute %d is %s, %d bytes, get %v, set %v; want %s, %d bytes, get %v, set %v",
+				w.attr, a.Name, a.Size(), a.Get, a.Set, w.name, w.size, w.get, w.set)
+		}
+		if !a.Get && !a.Set {
+			t.Errorf("%s can be neither read nor set", a.Name)
+		}
+	}
+	if _, ok := GetDwmAttribute(DWMWA_LAST); ok {
+		t.Error("DWMWA_LAST is described")
+	}
+}
+
+func TestDwmAttrKind(t *testing.T) {
+	for _, tt := range []struct {
+		kind DwmAttrKind
+		size uint32
+		name string
+	}{
+		{DwmAttrBool, 4, "BOOL"},
+		{DwmAttrDword, 4, "DWORD"},
+		{DwmAttrEnum, 4, "enum"},
+		{DwmAttrColor, 4, "COLORREF"},
+		{DwmAttrRect, 16, "RECT"},
+		{0, 0, "DwmAttrKind(0)"},
+		{99, 0, "DwmAttrKind(99)"},
+	} {
+		if tt.kind.Size() != tt.size || tt.kind.String() != tt.name {
+			t.Errorf("kind %d is %q of %d bytes, want %q of %d bytes",
+				int(tt.kind), tt.kind.String(), tt.kind.Size(), tt.name, tt.size)
+		}
+	}
+}
+
+func TestCheckDwmAttribute(t *testing.T) {
+	tests := []struct {
+		attr  uint32
+		set   bool
+		kinds []DwmAttrKind
+		err   string
+	}{
+		{DWMWA_CLOAKED, false, []DwmAttrKind{DwmAttrDword, DwmAttrEnum}, ""},
+		{DWMWA_CAPTION_COLOR, true, []DwmAttrKind{DwmAttrColor}, ""},
+		{DWMWA_SYSTEMBACKDROP_TYPE, false, []DwmAttrKind{DwmAttrEnum}, ""},
+		{DWMWA_SYSTEMBACKDROP_TYPE, true, []DwmAttrKind{DwmAttrEnum}, ""},
+
+		// Unknown attributes.
+		{0, false, []DwmAttrKind{DwmAttrBool}, "unknown window attribute 0"},
+		{18, true, []DwmAttrKind{DwmAttrBool}, "unknown window attribute 18"},
+		{DWMWA_LAST, false, []DwmAttrKind{DwmAttrBool}, "unknown window attribute 39"},
+
+		// Wrong direction.
+		{DWMWA_EXTENDED_FRAME_BOUNDS, true, []DwmAttrKind{DwmAttrRect}, "DWMWA_EXTENDED_FRAME_BOUNDS cannot be set"},
+		{DWMWA_NCRENDERING_ENABLED, true, []DwmAttrKind{DwmAttrBool}, "DWMWA_NCRENDERING_ENABLED cannot be set"},
+		{DWMWA_USE_IMMERSIVE_DARK_MODE, false, []DwmAttrKind{DwmAttrBool}, "DWMWA_USE_IMMERSIVE_DARK_MODE cannot be read"},
+		{DWMWA_BORDER_COLOR, false, []DwmAttrKind{DwmAttrColor}, "DWMWA_BORDER_COLOR cannot be read"},
+
+		// Wrong kind.
+		{DWMWA_CLOAKED, false, []DwmAttrKind{DwmAttrBool}, "DWMWA_CLOAKED is a DWORD, not a BOOL"},
+		{DWMWA_CAPTION_BUTTON_BOUNDS, false, []DwmAttrKind{DwmAttrDword, DwmAttrEnum}, "DWMWA_CAPTION_BUTTON_BOUNDS is a RECT, not a DWORD"},
+		{DWMWA_CAPTION_COLOR, true, []DwmAttrKind{DwmAttrBool}, "DWMWA_CAPTION_COLOR is a COLORREF, not a BOOL"},
+	}
+	for _, tt := range tests {
+		a, err := checkDwmAttribute(tt.attr, tt.set, tt.kinds...)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("checkDwmAttribute(%d, %v) failed: %v", tt.attr, tt.set, err)
+		case tt.err == "" && a.Name != dwmAttrName(tt.attr):
+			t.Errorf("checkDwmAttribute(%d, %v) returned %s", tt.attr, tt.set, a.Name)
+		case tt.err != "" && (err == nil || err.Error() != tt.err):
+			t.Errorf("checkDwmAttribute(%d, %v) error is %v, want %q", tt.attr, tt.set, err, tt.err)
+		}
+	}
+}
+
+func TestDwmError(t *testing.T) {
+	invalid := uint32(E_INVALIDARG)
+	err := &DwmError{"DwmSetWindowAttribute", DWMWA_CAPTION_COLOR, HRESULT(invalid)}
+	if got, want := err.Error(), "DwmSetWindowAttribute(DWMWA_CAPTION_COLOR) failed with HRESULT 0x80070057"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	err.Attribute = 18
+	if got, want := err.Error(), "DwmSetWindowAttribute(DWMWA(18)) failed with HRESULT 0x80070057"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
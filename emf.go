@@ -0,0 +1,900 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// EnhMetaFile is a decoded enhanced metafile: its header and its records in
+// playback order, from the EMR_HEADER record to EMR_EOF.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd162601.aspx
+type EnhMetaFile struct {
+	Header  ENHMETAHEADER
+	Records []EMFRecord
+
+	description string
+}
+
+// EMFRecord is one metafile record. Records with a known layout are
+// decoded into the EMR* types of this file; the rest are *EMRRaw.
+type EMFRecord interface {
+	RecordType() uint32
+}
+
+// RecordType returns IType.
+func (e EMR) RecordType() uint32 {
+	return e.IType
+}
+
+// EMRRaw is a record without parameters, such as EMR_SAVEDC, or one this
+// package does not decode. Data holds the bytes after the EMR header.
+type EMRRaw struct {
+	EMR
+	Data []byte
+}
+
+// EMRHeader is the EMR_HEADER record that starts every metafile.
+type EMRHeader struct {
+	EMR
+	Header ENHMETAHEADER
+}
+
+// EMRPoly is EMR_POLYBEZIER, EMR_POLYGON, EMR_POLYLINE, EMR_POLYBEZIERTO,
+// EMR_POLYLINETO or one of their 16-bit variants.
+type EMRPoly struct {
+	EMR
+	Bounds RECT
+	Points []POINT
+}
+
+// EMRPolyPoly is EMR_POLYPOLYLINE, EMR_POLYPOLYGON or one of their 16-bit
+// variants. Counts holds the number of points of each figure.
+type EMRPolyPoly struct {
+	EMR
+	Bounds RECT
+	Counts []uint32
+	Points []POINT
+}
+
+// EMRExtTextOut is EMR_EXTTEXTOUTW or EMR_EXTTEXTOUTA. Dx holds the
+// character advances, two per character with ETO_PDY.
+//
+// The text of EMR_EXTTEXTOUTA is in the code page of the charset of the
+// selected font, which the record does not carry. It is decoded as
+// Windows-1252 and encoded back the same way, so that every byte value
+// survives the round trip; text in other code pages decodes to the wrong
+// characters.
+type EMRExtTextOut struct {
+	EMR
+	Bounds       RECT
+	GraphicsMode uint32
+	XScale       float32
+	YScale       float32
+	Reference    POINT
+	Options      uint32
+	Rect         RECT
+	Text         string
+	Dx           []int32
+}
+
+// EMRBitBlt is EMR_BITBLT, EMR_STRETCHBLT, EMR_ALPHABLEND or
+// EMR_TRANSPARENTBLT. Rop is the BLENDFUNCTION of EMR_ALPHABLEND and the
+// transparent color of EMR_TRANSPARENTBLT. Source is nil for raster
+// operations without a source; CxSrc and CySrc equal the destination size
+// for EMR_BITBLT.
+type EMRBitBlt struct {
+	EMR
+	Bounds     RECT
+	XDest      int32
+	YDest      int32
+	CxDest     int32
+	CyDest     int32
+	Rop        uint32
+	XSrc       int32
+	YSrc       int32
+	XformSrc   XFORM
+	BkColorSrc COLORREF
+	UsageSrc   uint32
+	CxSrc      int32
+	CySrc      int32
+	Source     *DIB
+}
+
+// EMRStretchDIBits is EMR_STRETCHDIBITS.
+type EMRStretchDIBits struct {
+	EMR
+	Bounds   RECT
+	XDest    int32
+	YDest    int32
+	XSrc     int32
+	YSrc     int32
+	CxSrc    int32
+	CySrc    int32
+	UsageSrc uint32
+	Rop      uint32
+	CxDest   int32
+	CyDest   int32
+	Source   *DIB
+}
+
+// EMRObject is EMR_SELECTOBJECT, EMR_DELETEOBJECT or EMR_SELECTPALETTE.
+// Index is a slot of the object table or ENHMETA_STOCK_OBJECT ORed with a
+// GetStockObject index.
+type EMRObject struct {
+	EMR
+	Index uint32
+}
+
+// EMRCreatePen is EMR_CREATEPEN.
+type EMRCreatePen struct {
+	EMR
+	Index uint32
+	Pen   LOGPEN
+}
+
+// EMRExtCreatePen is EMR_EXTCREATEPEN. Pattern is the brush bitmap of a
+// BS_DIBPATTERN pen.
+type EMRExtCreatePen struct {
+	EMR
+	Index        uint32
+	Style        uint32
+	Width        uint32
+	BrushStyle   uint32
+	Color        COLORREF
+	Hatch        uint32
+	StyleEntries []uint32
+	Pattern      *DIB
+}
+
+// EMRCreateBrushIndirect is EMR_CREATEBRUSHINDIRECT.
+type EMRCreateBrushIndirect struct {
+	EMR
+	Index uint32
+	Brush LOGBRUSH32
+}
+
+// EMRCreateDIBPatternBrush is EMR_CREATEDIBPATTERNBRUSHPT or
+// EMR_CREATEMONOBRUSH.
+type EMRCreateDIBPatternBrush struct {
+	EMR
+	Index   uint32
+	Usage   uint32
+	Pattern *DIB
+}
+
+// EMRExtCreateFontIndirect is EMR_EXTCREATEFONTINDIRECTW. Only the LOGFONT
+// part of the record is decoded.
+type EMRExtCreateFontIndirect struct {
+	EMR
+	Index uint32
+	Font  LOGFONT
+}
+
+// EMRCreatePalette is EMR_CREATEPALETTE.
+type EMRCreatePalette struct {
+	EMR
+	Index   uint32
+	Entries []PALETTEENTRY
+}
+
+// EMRPoint is EMR_MOVETOEX, EMR_LINETO, EMR_SETWINDOWORGEX,
+// EMR_SETVIEWPORTORGEX, EMR_SETBRUSHORGEX or EMR_OFFSETCLIPRGN.
+type EMRPoint struct {
+	EMR
+	Point POINT
+}
+
+// EMRSize is EMR_SETWINDOWEXTEX or EMR_SETVIEWPORTEXTEX.
+type EMRSize struct {
+	EMR
+	Size SIZE
+}
+
+// EMRScaleExt is EMR_SCALEWINDOWEXTEX or EMR_SCALEVIEWPORTEXTEX.
+type EMRScaleExt struct {
+	EMR
+	XNum   int32
+	XDenom int32
+	YNum   int32
+	YDenom int32
+}
+
+// EMRSetPixel is EMR_SETPIXELV.
+type EMRSetPixel struct {
+	EMR
+	Point POINT
+	Color COLORREF
+}
+
+// EMRRect is EMR_RECTANGLE, EMR_ELLIPSE, EMR_INTERSECTCLIPRECT or
+// EMR_EXCLUDECLIPRECT, or the bounds of EMR_FILLPATH, EMR_STROKEPATH or
+// EMR_STROKEANDFILLPATH.
+type EMRRect struct {
+	EMR
+	Rect RECT
+}
+
+// EMRRoundRect is EMR_ROUNDRECT.
+type EMRRoundRect struct {
+	EMR
+	Rect   RECT
+	Corner SIZE
+}
+
+// EMRArc is EMR_ARC, EMR_ARCTO, EMR_CHORD or EMR_PIE.
+type EMRArc struct {
+	EMR
+	Box   RECT
+	Start POINT
+	End   POINT
+}
+
+// EMRAngleArc is EMR_ANGLEARC.
+type EMRAngleArc struct {
+	EMR
+	Center     POINT
+	Radius     uint32
+	StartAngle float32
+	SweepAngle float32
+}
+
+// EMRXform is EMR_SETWORLDTRANSFORM or EMR_MODIFYWORLDTRANSFORM. Mode is
+// zero for EMR_SETWORLDTRANSFORM.
+type EMRXform struct {
+	EMR
+	Xform XFORM
+	Mode  uint32
+}
+
+// EMRRestoreDC is EMR_RESTOREDC.
+type EMRRestoreDC struct {
+	EMR
+	SavedDC int32
+}
+
+// EMRSetMiterLimit is EMR_SETMITERLIMIT.
+type EMRSetMiterLimit struct {
+	EMR
+	Limit float32
+}
+
+// EMRMode is a record with a single mode or flag value: EMR_SETMAPMODE,
+// EMR_SETBKMODE, EMR_SETPOLYFILLMODE, EMR_SETROP2, EMR_SETSTRETCHBLTMODE,
+// EMR_SETTEXTALIGN, EMR_SETARCDIRECTION, EMR_SETICMMODE, EMR_SETLAYOUT,
+// EMR_SETMAPPERFLAGS or EMR_SELECTCLIPPATH.
+type EMRMode struct {
+	EMR
+	Mode uint32
+}
+
+// EMRColor is EMR_SETTEXTCOLOR or EMR_SETBKCOLOR.
+type EMRColor struct {
+	EMR
+	Color COLORREF
+}
+
+// EMRExtSelectClipRgn is EMR_EXTSELECTCLIPRGN. RgnData is the raw RGNDATA,
+// empty when the clip region is reset.
+type EMRExtSelectClipRgn struct {
+	EMR
+	Mode    uint32
+	RgnData []byte
+}
+
+// EMRComment is EMR_GDICOMMENT.
+type EMRComment struct {
+	EMR
+	Data []byte
+}
+
+// EMREOF is EMR_EOF.
+type EMREOF struct {
+	EMR
+	Palette []PALETTEENTRY
+}
+
+// maxEMFSize bounds the metafiles ParseEMF accepts.
+const maxEMFSize = 1 << 30
+
+// ParseEMF decodes an enhanced metafile, as written by CloseEnhMetaFile or
+// returned by GetEnhMetaFileBits. It checks the header signature and sizes,
+// that every record lies within the file, that the last record is EMR_EOF
+// and that the record count matches the header.
+func ParseEMF(data []byte) (*EnhMetaFile, error) {
+	if len(data) > maxEMFSize {
+		return nil, errors.New("emf: file is too large")
+	}
+	hdr, err := parseEMFHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	data = data[:hdr.NBytes]
+
+	f := &EnhMetaFile{Header: *hdr}
+	if hdr.NDescription > 0 {
+		r := resReader{data: data[:hdr.NSize], pos: int(hdr.OffDescription)}
+		f.description = r.utf16(int(hdr.NDescription))
+	}
+
+	for off := 0; ; {
+		if off+8 > len(data) {
+			return nil, errors.New("emf: missing EMR_EOF record")
+		}
+		typ := binary.LittleEndian.Uint32(data[off:])
+		size := binary.LittleEndian.Uint32(data[off+4:])
+		if size < 8 || size%4 != 0 || uint64(size) > uint64(len(data)-off) {
+			return nil, fmt.Errorf("emf: bad size %d of record %d at offset %#x", size, len(f.Records), off)
+		}
+		if off == 0 && typ != EMR_HEADER || off != 0 && typ == EMR_HEADER {
+			return nil, fmt.Errorf("emf: misplaced EMR_HEADER record at offset %#x", off)
+		}
+
+		rec, err := decodeEMFRecord(data[off : off+int(size)])
+		if err != nil {
+			return nil, fmt.Errorf("emf: record %d (type %d) at offset %#x: %v", len(f.Records), typ, off, err)
+		}
+		f.Records = append(f.Records, rec)
+		off += int(size)
+		if typ == EMR_EOF {
+			break
+		}
+	}
+
+	if uint32(len(f.Records)) != hdr.NRecords {
+		return nil, fmt.Errorf("emf: header claims %d records, found %d", hdr.NRecords, len(f.Records))
+	}
+	return f, nil
+}
+
+// parseEMFHeader reads and checks the ENHMETAHEADER at the start of data.
+// Headers written before Windows 98 lack the pixel format and micrometer
+// fields; those are left zero.
+func parseEMFHeader(data []byte) (*ENHMETAHEADER, error) {
+	const minSize = 88
+	if len(data) < minSize {
+		return nil, errors.New("emf: header is truncated")
+	}
+	hdr := decodeENHMETAHEADER(data)
+
+	switch {
+	case hdr.IType != EMR_HEADER:
+		return nil, fmt.Errorf("emf: first record has type %d, not EMR_HEADER", hdr.IType)
+	case hdr.DSignature != ENHMETA_SIGNATURE:
+		return nil, fmt.Errorf("emf: bad signature %#x", hdr.DSignature)
+	case hdr.NSize < minSize || hdr.NSize%4 != 0:
+		return nil, fmt.Errorf("emf: bad header size %d", hdr.NSize)
+	case hdr.NBytes < hdr.NSize || uint64(hdr.NBytes) > uint64(len(data)):
+		return nil, fmt.Errorf("emf: header claims %d bytes, have %d", hdr.NBytes, len(data))
+	case hdr.NRecords < 2:
+		return nil, fmt.Errorf("emf: bad record count %d", hdr.NRecords)
+	}
+	if hdr.NDescription > 0 {
+		end := uint64(hdr.OffDescription) + 2*uint64(hdr.NDescription)
+		if hdr.OffDescription < minSize || end > uint64(hdr.NSize) {
+			return nil, errors.New("emf: description is outside the header record")
+		}
+	}
+	if hdr.CbPixelFormat > 0 {
+		end := uint64(hdr.OffPixelFormat) + uint64(hdr.CbPixelFormat)
+		if hdr.OffPixelFormat < minSize || end > uint64(hdr.NSize) {
+			return nil, errors.New("emf: pixel format is outside the header record")
+		}
+	}
+	return hdr, nil
+}
+
+// decodeENHMETAHEADER decodes the header at the start of data without
+// checking it. Fields beyond NSize, absent from headers written before
+// Windows 98, are left zero.
+func decodeENHMETAHEADER(data []byte) *ENHMETAHEADER {
+	var buf [108]byte
+	n := copy(buf[:], data)
+	if size := binary.LittleEndian.Uint32(buf[4:]); uint64(size) < uint64(n) {
+		n = int(size)
+	}
+	for i := n; i < len(buf); i++ {
+		buf[i] = 0
+	}
+	hdr := new(ENHMETAHEADER)
+	binary.Read(bytes.NewReader(buf[:]), binary.LittleEndian, hdr)
+	return hdr
+}
+
+// Description returns the application name and picture title stored in the
+// header, either of which may be empty.
+func (f *EnhMetaFile) Description() (application, title string) {
+	parts := strings.SplitN(f.description, "\x00", 3)
+	application = parts[0]
+	if len(parts) > 1 {
+		title = parts[1]
+	}
+	return application, title
+}
+
+// emfReader reads record parameters with the sticky error of resReader.
+type emfReader struct {
+	resReader
+}
+
+func (r *emfReader) i32() int32 {
+	return int32(r.u32())
+}
+
+func (r *emfReader) f32() float32 {
+	return math.Float32frombits(r.u32())
+}
+
+func (r *emfReader) point() POINT {
+	return POINT{r.i32(), r.i32()}
+}
+
+func (r *emfReader) size() SIZE {
+	return SIZE{r.i32(), r.i32()}
+}
+
+func (r *emfReader) rect() RECT {
+	return RECT{r.i32(), r.i32(), r.i32(), r.i32()}
+}
+
+func (r *emfReader) xform() XFORM {
+	return XFORM{r.f32(), r.f32(), r.f32(), r.f32(), r.f32(), r.f32()}
+}
+
+// needN is need for n elements of size bytes, safe against overflow.
+func (r *emfReader) needN(n uint32, size int) bool {
+	if !r.need(0) {
+		return false
+	}
+	if uint64(n)*uint64(size) > uint64(len(r.data)-r.pos) {
+		r.err = fmt.Errorf("%d elements do not fit the record", n)
+	}
+	return r.need(int(n) * size)
+}
+
+// points reads n POINTL or, with short set, n POINTS values.
+func (r *emfReader) points(n uint32, short bool) []POINT {
+	size := 8
+	if short {
+		size = 4
+	}
+	if !r.needN(n, size) {
+		return nil
+	}
+	pts := make([]POINT, n)
+	for i := range pts {
+		if short {
+			pts[i] = POINT{int32(int16(r.u16())), int32(int16(r.u16()))}
+		} else {
+			pts[i] = r.point()
+		}
+	}
+	return pts
+}
+
+// dib reads a bitmap given by the offBmi, cbBmi, offBits and cbBits fields
+// that follow the current position. Offsets are relative to the start of the
+// record. A zero cbBmi means no bitmap.
+func (r *emfReader) dib() *DIB {
+	offBmi, cbBmi, offBits, cbBits := r.u32(), r.u32(), r.u32(), r.u32()
+	if r.err != nil || cbBmi == 0 {
+		return nil
+	}
+	n := uint64(len(r.data))
+	if uint64(offBmi)+uint64(cbBmi) > n || uint64(offBits)+uint64(cbBits) > n {
+		r.err = errors.New("bitmap is outside the record")
+		return nil
+	}
+	d, _, err := parseDIBHeader(r.data[offBmi : offBmi+cbBmi])
+	if err == nil {
+		err = d.setBits(r.data[offBits : offBits+cbBits])
+	}
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	return d
+}
+
+// decodeEMFRecord decodes one complete record, header included.
+func decodeEMFRecord(data []byte) (EMFRecord, error) {
+	e := EMR{binary.LittleEndian.Uint32(data), binary.LittleEndian.Uint32(data[4:])}
+	r := &emfReader{resReader{data: data, pos: 8}}
+	var rec EMFRecord
+
+	switch e.IType {
+	case EMR_HEADER:
+		rec = &EMRHeader{e, *decodeENHMETAHEADER(data)}
+
+	case EMR_POLYBEZIER, EMR_POLYGON, EMR_POLYLINE, EMR_POLYBEZIERTO, EMR_POLYLINETO,
+		EMR_POLYBEZIER16, EMR_POLYGON16, EMR_POLYLINE16, EMR_POLYBEZIERTO16, EMR_POLYLINETO16:
+		bounds := r.rect()
+		n := r.u32()
+		rec = &EMRPoly{e, bounds, r.points(n, e.IType >= EMR_POLYBEZIER16)}
+
+	case EMR_POLYPOLYLINE, EMR_POLYPOLYGON, EMR_POLYPOLYLINE16, EMR_POLYPOLYGON16:
+		p := &EMRPolyPoly{EMR: e, Bounds: r.rect()}
+		nPolys, nPoints := r.u32(), r.u32()
+		if r.needN(nPolys, 4) {
+			p.Counts = make([]uint32, nPolys)
+			var total uint64
+			for i := range p.Counts {
+				p.Counts[i] = r.u32()
+				total += uint64(p.Counts[i])
+			}
+			if total != uint64(nPoints) {
+				return nil, errors.New("polygon point counts do not add up")
+			}
+		}
+		p.Points = r.points(nPoints, e.IType >= EMR_POLYPOLYLINE16)
+		rec = p
+
+	case EMR_EXTTEXTOUTW, EMR_EXTTEXTOUTA:
+		t := &EMRExtTextOut{
+			EMR:          e,
+			Bounds:       r.rect(),
+			GraphicsMode: r.u32(),
+			XScale:       r.f32(),
+			YScale:       r.f32(),
+			Reference:    r.point(),
+		}
+		nChars, offString := r.u32(), r.u32()
+		t.Options = r.u32()
+		t.Rect = r.rect()
+		offDx := r.u32()
+		if r.err != nil {
+			return nil, r.err
+		}
+		// Offsets are checked before they become ints, which they may not
+		// fit on 32-bit platforms.
+		size := uint64(nChars)
+		if e.IType == EMR_EXTTEXTOUTW {
+			size *= 2
+		}
+		if uint64(offString)+size > uint64(len(data)) {
+			return nil, errors.New("text is outside the record")
+		}
+		s := resReader{data: data, pos: int(offString)}
+		if e.IType == EMR_EXTTEXTOUTW {
+			t.Text = s.utf16(int(nChars))
+		} else {
+			t.Text = decodeANSI(s.bytes(int(nChars)))
+		}
+		if s.err != nil {
+			return nil, errors.New("text is outside the record")
+		}
+		if offDx != 0 {
+			n := uint64(nChars)
+			if t.Options&ETO_PDY != 0 {
+				n *= 2
+			}
+			if uint64(offDx)+4*n > uint64(len(data)) {
+				return nil, errors.New("character advances are outside the record")
+			}
+			d := emfReader{resReader{data: data, pos: int(offDx)}}
+			t.Dx = make([]int32, n)
+			for i := range t.Dx {
+				t.Dx[i] = d.i32()
+			}
+		}
+		rec = t
+
+	case EMR_BITBLT, EMR_STRETCHBLT, EMR_ALPHABLEND, EMR_TRANSPARENTBLT:
+		b := &EMRBitBlt{
+			EMR:        e,
+			Bounds:     r.rect(),
+			XDest:      r.i32(),
+			YDest:      r.i32(),
+			CxDest:     r.i32(),
+			CyDest:     r.i32(),
+			Rop:        r.u32(),
+			XSrc:       r.i32(),
+			YSrc:       r.i32(),
+			XformSrc:   r.xform(),
+			BkColorSrc: COLORREF(r.u32()),
+			UsageSrc:   r.u32(),
+		}
+		// The source size follows the bitmap offsets.
+		bmi := r.pos
+		r.pos += 16
+		if e.IType == EMR_BITBLT {
+			b.CxSrc, b.CySrc = b.CxDest, b.CyDest
+		} else {
+			b.CxSrc, b.CySrc = r.i32(), r.i32()
+		}
+		if r.err == nil {
+			r.pos = bmi
+			b.Source = r.dib()
+		}
+		rec = b
+
+	case EMR_STRETCHDIBITS:
+		b := &EMRStretchDIBits{
+			EMR:    e,
+			Bounds: r.rect(),
+			XDest:  r.i32(),
+			YDest:  r.i32(),
+			XSrc:   r.i32(),
+			YSrc:   r.i32(),
+			CxSrc:  r.i32(),
+			CySrc:  r.i32(),
+		}
+		b.Source = r.dib()
+		b.UsageSrc, b.Rop = r.u32(), r.u32()
+		b.CxDest, b.CyDest = r.i32(), r.i32()
+		rec = b
+
+	case EMR_SELECTOBJECT, EMR_DELETEOBJECT, EMR_SELECTPALETTE:
+		rec = &EMRObject{e, r.u32()}
+
+	case EMR_CREATEPEN:
+		rec = &EMRCreatePen{e, r.u32(), LOGPEN{r.u32(), r.point(), COLORREF(r.u32())}}
+
+	case EMR_EXTCREATEPEN:
+		p := &EMRExtCreatePen{EMR: e, Index: r.u32()}
+		p.Pattern = r.dib()
+		p.Style, p.Width, p.BrushStyle = r.u32(), r.u32(), r.u32()
+		p.Color, p.Hatch = COLORREF(r.u32()), r.u32()
+		n := r.u32()
+		if r.needN(n, 4) {
+			p.StyleEntries = make([]uint32, n)
+			for i := range p.StyleEntries {
+				p.StyleEntries[i] = r.u32()
+			}
+		}
+		rec = p
+
+	case EMR_CREATEBRUSHINDIRECT:
+		rec = &EMRCreateBrushIndirect{e, r.u32(), LOGBRUSH32{r.u32(), COLORREF(r.u32()), r.u32()}}
+
+	case EMR_CREATEDIBPATTERNBRUSHPT, EMR_CREATEMONOBRUSH:
+		b := &EMRCreateDIBPatternBrush{EMR: e, Index: r.u32(), Usage: r.u32()}
+		b.Pattern = r.dib()
+		rec = b
+
+	case EMR_EXTCREATEFONTINDIRECTW:
+		f := &EMRExtCreateFontIndirect{EMR: e, Index: r.u32()}
+		if lf := r.bytes(binary.Size(f.Font)); lf != nil {
+			binary.Read(bytes.NewReader(lf), binary.LittleEndian, &f.Font)
+		}
+		rec = f
+
+	case EMR_CREATEPALETTE:
+		p := &EMRCreatePalette{EMR: e, Index: r.u32()}
+		r.u16() // palVersion
+		n := r.u16()
+		if b := r.bytes(4 * int(n)); b != nil {
+			p.Entries = make([]PALETTEENTRY, n)
+			for i := range p.Entries {
+				p.Entries[i] = PALETTEENTRY{b[4*i], b[4*i+1], b[4*i+2], b[4*i+3]}
+			}
+		}
+		rec = p
+
+	case EMR_MOVETOEX, EMR_LINETO, EMR_SETWINDOWORGEX, EMR_SETVIEWPORTORGEX,
+		EMR_SETBRUSHORGEX, EMR_OFFSETCLIPRGN:
+		rec = &EMRPoint{e, r.point()}
+
+	case EMR_SETWINDOWEXTEX, EMR_SETVIEWPORTEXTEX:
+		rec = &EMRSize{e, r.size()}
+
+	case EMR_SCALEWINDOWEXTEX, EMR_SCALEVIEWPORTEXTEX:
+		rec = &EMRScaleExt{e, r.i32(), r.i32(), r.i32(), r.i32()}
+
+	case EMR_SETPIXELV:
+		rec = &EMRSetPixel{e, r.point(), COLORREF(r.u32())}
+
+	case EMR_RECTANGLE, EMR_ELLIPSE, EMR_INTERSECTCLIPRECT, EMR_EXCLUDECLIPRECT,
+		EMR_FILLPATH, EMR_STROKEPATH, EMR_STROKEANDFILLPATH:
+		rec = &EMRRect{e, r.rect()}
+
+	case EMR_ROUNDRECT:
+		rec = &EMRRoundRect{e, r.rect(), r.size()}
+
+	case EMR_ARC, EMR_ARCTO, EMR_CHORD, EMR_PIE:
+		rec = &EMRArc{e, r.rect(), r.point(), r.point()}
+
+	case EMR_ANGLEARC:
+		rec = &EMRAngleArc{e, r.point(), r.u32(), r.f32(), r.f32()}
+
+	case EMR_SETWORLDTRANSFORM:
+		rec = &EMRXform{e, r.xform(), 0}
+
+	case EMR_MODIFYWORLDTRANSFORM:
+		rec = &EMRXform{e, r.xform(), r.u32()}
+
+	case EMR_RESTOREDC:
+		rec = &EMRRestoreDC{e, r.i32()}
+
+	case EMR_SETMITERLIMIT:
+		rec = &EMRSetMiterLimit{e, r.f32()}
+
+	case EMR_SETMAPMODE, EMR_SETBKMODE, EMR_SETPOLYFILLMODE, EMR_SETROP2,
+		EMR_SETSTRETCHBLTMODE, EMR_SETTEXTALIGN, EMR_SETARCDIRECTION, EMR_SETICMMODE,
+		EMR_SETLAYOUT, EMR_SETMAPPERFLAGS, EMR_SELECTCLIPPATH:
+		rec = &EMRMode{e, r.u32()}
+
+	case EMR_SETTEXTCOLOR, EMR_SETBKCOLOR:
+		rec = &EMRColor{e, COLORREF(r.u32())}
+
+	case EMR_EXTSELECTCLIPRGN:
+		n := r.u32()
+		mode := r.u32()
+		rec = &EMRExtSelectClipRgn{e, mode, r.bytes(int(n))}
+
+	case EMR_GDICOMMENT:
+		n := r.u32()
+		rec = &EMRComment{e, r.bytes(int(n))}
+
+	case EMR_EOF:
+		n, off := r.u32(), r.u32()
+		eof := &EMREOF{EMR: e}
+		if n > 0 {
+			if uint64(off)+4*uint64(n) > uint64(len(data)) {
+				return nil, errors.New("palette is outside the record")
+			}
+			b := data[off : off+4*n]
+			eof.Palette = make([]PALETTEENTRY, n)
+			for i := range eof.Palette {
+				eof.Palette[i] = PALETTEENTRY{b[4*i], b[4*i+1], b[4*i+2], b[4*i+3]}
+			}
+		}
+		rec = eof
+
+	default:
+		rec = &EMRRaw{e, data[8:]}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return rec, nil
+}
+
+// EMFObjectTable tracks the objects a metafile creates and deletes during
+// playback. Index 0 is reserved for the metafile itself.
+type EMFObjectTable struct {
+	objects []EMFRecord
+}
+
+// NewEMFObjectTable returns an empty table with ENHMETAHEADER.NHandles
+// slots.
+func NewEMFObjectTable(nHandles uint16) *EMFObjectTable {
+	return &EMFObjectTable{objects: make([]EMFRecord, nHandles)}
+}
+
+// emfObjectIndex returns the object table slot a record creates.
+func emfObjectIndex(rec EMFRecord) (uint32, bool) {
+	switch r := rec.(type) {
+	case *EMRCreatePen:
+		return r.Index, true
+	case *EMRExtCreatePen:
+		return r.Index, true
+	case *EMRCreateBrushIndirect:
+		return r.Index, true
+	case *EMRCreateDIBPatternBrush:
+		return r.Index, true
+	case *EMRExtCreateFontIndirect:
+		return r.Index, true
+	case *EMRCreatePalette:
+		return r.Index, true
+	}
+	return 0, false
+}
+
+// Update applies rec: object-creating records fill their slot and
+// EMR_DELETEOBJECT empties it. Out-of-range indexes are ignored, as
+// PlayEnhMetaFile does.
+func (t *EMFObjectTable) Update(rec EMFRecord) {
+	if i, ok := emfObjectIndex(rec); ok {
+		if i > 0 && int64(i) < int64(len(t.objects)) {
+			t.objects[i] = rec
+		}
+		return
+	}
+	if o, ok := rec.(*EMRObject); ok && o.IType == EMR_DELETEOBJECT {
+		if int64(o.Index) < int64(len(t.objects)) {
+			t.objects[o.Index] = nil
+		}
+	}
+}
+
+// Get returns the record that created the object in slot index, or nil if
+// the slot is empty or index names a stock object.
+func (t *EMFObjectTable) Get(index uint32) EMFRecord {
+	if index&ENHMETA_STOCK_OBJECT != 0 || int64(index) >= int64(len(t.objects)) {
+		return nil
+	}
+	return t.objects[index]
+}
+
+// Len returns the number of slots.
+func (t *EMFObjectTable) Len() int {
+	return len(t.objects)
+}
+
+// StockObject returns the GetStockObject index named by an object index
+// with ENHMETA_STOCK_OBJECT set.
+func StockObject(index uint32) (int, bool) {
+	if index&ENHMETA_STOCK_OBJECT == 0 {
+		return 0, false
+	}
+	return int(index &^ ENHMETA_STOCK_OBJECT), true
+}
+
+// Walk calls fn for each record in order, with the object table as it is
+// after the record has been applied. It stops at the first error fn
+// returns.
+func (f *EnhMetaFile) Walk(fn func(rec EMFRecord, objects *EMFObjectTable) error) error {
+	objects := NewEMFObjectTable(f.Header.NHandles)
+	for _, rec := range f.Records {
+		objects.Update(rec)
+		if err := fn(rec, objects); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Objects returns every object-creating record in order.
+func (f *EnhMetaFile) Objects() []EMFRecord {
+	var objs []EMFRecord
+	for _, rec := range f.Records {
+		if _, ok := emfObjectIndex(rec); ok {
+			objs = append(objs, rec)
+		}
+	}
+	return objs
+}
+
+// cp1252High maps bytes 0x80 to 0x9F of Windows-1252. The five bytes the
+// code page leaves undefined map to the C1 controls of the same value, as
+// MultiByteToWideChar does.
+var cp1252High = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+// decodeANSI decodes Windows-1252 text.
+func decodeANSI(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		if c >= 0x80 && c < 0xA0 {
+			r[i] = cp1252High[c-0x80]
+		} else {
+			r[i] = rune(c)
+		}
+	}
+	return string(r)
+}
+
+// encodeANSI encodes s as Windows-1252.
+func encodeANSI(s string) ([]byte, error) {
+	b := make([]byte, 0, len(s))
+	for _, c := range s {
+		switch {
+		case c < 0x80 || c >= 0xA0 && c <= 0xFF:
+			b = append(b, byte(c))
+		default:
+			i := 0
+			for i < len(cp1252High) && cp1252High[i] != c {
+				i++
+			}
+			if i == len(cp1252High) {
+				return nil, fmt.Errorf("%q is not in Windows-1252", c)
+			}
+			b = append(b, byte(0x80+i))
+		}
+	}
+	return b, nil
+}
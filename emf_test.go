@@ -0,0 +1,190 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+// testEMF records a metafile that uses most record types the recorder
+// writes.
+func testEMF(t testing.TB) []byte {
+	r := NewEMFRecorder(&EMFOptions{Application: "w32", Title: "test"})
+	pen := r.CreatePen(PS_DASH, 3, RGB(255, 0, 0))
+	r.SelectObject(pen)
+	brush := r.CreateHatchBrush(HS_CROSS, RGB(0, 0, 255))
+	r.SelectObject(brush)
+	r.MoveTo(1, 1)
+	r.LineTo(30, 20)
+	r.Rectangle(2, 2, 20, 10)
+	r.Ellipse(5, 5, 25, 15)
+	r.RoundRect(0, 0, 12, 12, 4, 4)
+	r.Polygon([]POINT{{0, 0}, {10, 0}, {5, 8}})
+	r.Polyline([]POINT{{0, 0}, {100000, 5}})
+	r.PolyPolygon([][]POINT{{{0, 0}, {4, 0}, {4, 4}}, {{6, 6}, {9, 6}, {9, 9}}})
+	r.SaveDC()
+	r.SetTextAlign(TA_UPDATECP)
+	r.SetBkMode(OPAQUE)
+	r.ExtTextOut(3, 3, ETO_OPAQUE, &RECT{0, 0, 8, 8}, "héllo", []int32{1, 2, 3, 4, 5})
+	r.Record(&EMRExtTextOut{EMR: EMR{IType: EMR_EXTTEXTOUTA}, Reference: POINT{4, 4}, Text: "ansi €"})
+	r.RestoreDC(-1)
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(1, 1, color.NRGBA{1, 2, 3, 128})
+	r.DrawImage(10, 10, 6, 4, img)
+	r.Comment([]byte("comment"))
+	r.SelectStockObject(BLACK_PEN)
+	r.DeleteObject(pen)
+	data, err := r.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEMFExtTextOutA(t *testing.T) {
+	var all []byte
+	for c := 1; c < 256; c++ {
+		all = append(all, byte(c))
+	}
+	text := decodeANSI(all)
+	if want := "€\u0081‚"; text[0x7F:0x7F+len(want)] != want {
+		t.Errorf("0x80 to 0x82 decode to %q, want %q", text[0x7F:0x7F+len(want)], want)
+	}
+	if b, err := encodeANSI(text); err != nil || string(b) != string(all) {
+		t.Errorf("encodeANSI(decodeANSI(b)) = %x, %v", b, err)
+	}
+	for _, s := range []string{"\u0080", "Ā", "世"} {
+		if _, err := encodeANSI(s); err == nil {
+			t.Errorf("encodeANSI(%q) succeeded", s)
+		}
+	}
+
+	r := NewEMFRecorder(nil)
+	r.Record(&EMRExtTextOut{EMR: EMR{IType: EMR_EXTTEXTOUTA}, Text: text})
+	data, err := r.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ParseEMF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := f.Records[1].(*EMRExtTextOut)
+	if !ok || got.IType != EMR_EXTTEXTOUTA || got.Text != text {
+		t.Errorf("EMR_EXTTEXTOUTA parses as %#v", f.Records[1])
+	}
+
+	r = NewEMFRecorder(nil)
+	r.Record(&EMRExtTextOut{EMR: EMR{IType: EMR_EXTTEXTOUTA}, Text: "世"})
+	if _, err := r.Bytes(); err == nil {
+		t.Error("recorded EMR_EXTTEXTOUTA text outside Windows-1252")
+	}
+}
+
+func FuzzParseEMF(f *testing.F) {
+	f.Add(testEMF(f))
+	empty, err := NewEMFRecorder(nil).Bytes()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(empty)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		e, err := ParseEMF(data)
+		if err != nil {
+			return
+		}
+		e.Description()
+		e.Objects()
+		for _, rec := range e.Records {
+			if s, ok := rec.(*EMRStretchDIBits); ok && s.Source != nil {
+				s.Source.Image()
+			}
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 16, 12))
+		PlayEMF(img, e, img.Rect)
+
+		// Whatever parses records again, except for the header and the
+		// end record, which the recorder writes itself.
+		r := NewEMFRecorder(nil)
+		for _, rec := range e.Records[1 : len(e.Records)-1] {
+			r.Record(rec)
+		}
+		if data, err := r.Bytes(); err == nil {
+			if _, err := ParseEMF(data); err != nil {
+				t.Errorf("re-recorded metafile does not parse: %v", err)
+			}
+		}
+	})
+}
+
+// An RLE bitmap of a few bytes can claim millions of pixels. Metafiles
+// using such bitmaps must be rejected before anything is decoded.
+func TestEMFRLEBomb(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 16, 16), color.Palette{color.Black, color.White})
+	d, err := NewDIB(img, &DIBOptions{BitCount: 8, RLE: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewEMFRecorder(nil)
+	for i := 0; i < 5; i++ {
+		r.StretchDIBits(0, 0, 16, 16, 0, 0, 8192, 8192, d, SRCCOPY)
+	}
+	data, err := r.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Make each BITMAPINFOHEADER claim 8192x8192 pixels.
+	header := func(w, h uint32) []byte {
+		b := make([]byte, 12)
+		binary.LittleEndian.PutUint32(b[0:], 40)
+		binary.LittleEndian.PutUint32(b[4:], w)
+		binary.LittleEndian.PutUint32(b[8:], h)
+		return b
+	}
+	if n := bytes.Count(data, header(16, 16)); n != 5 {
+		t.Fatalf("found %d bitmap headers", n)
+	}
+	data = bytes.ReplaceAll(data, header(16, 16), header(8192, 8192))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = ParseEMF(data)
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Error("metafile with 8192x8192 RLE bitmaps of a few bytes parsed")
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("ParseEMF allocated %d bytes", n)
+	}
+
+	// The player checks bitmaps that did not come from ParseEMF.
+	bomb := *d
+	bomb.Header.BiWidth, bomb.Header.BiHeight = 8192, 8192
+	e, err := ParseEMF(testEMF(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		e.Records = append(e.Records[:len(e.Records)-1], &EMRStretchDIBits{
+			EMR: EMR{IType: EMR_STRETCHDIBITS}, CxDest: 16, CyDest: 16, CxSrc: 8192, CySrc: 8192,
+			UsageSrc: DIB_RGB_COLORS, Rop: SRCCOPY, Source: &bomb,
+		}, e.Records[len(e.Records)-1])
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, 16, 12))
+	runtime.ReadMemStats(&before)
+	err = PlayEMF(dst, e, dst.Rect)
+	runtime.ReadMemStats(&after)
+	if u, ok := err.(*EMFUnsupportedError); !ok || u.Records[EMR_STRETCHDIBITS] != 5 {
+		t.Errorf("PlayEMF error is %v, want 5 skipped EMR_STRETCHDIBITS", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 4<<20 {
+		t.Errorf("PlayEMF allocated %d bytes", n)
+	}
+}
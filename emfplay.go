@@ -0,0 +1,790 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+)
+
+// EMFUnsupportedError is returned by PlayEMF when it skipped records it
+// cannot render. The rest of the picture is drawn regardless.
+type EMFUnsupportedError struct {
+	// Records counts the skipped records by type.
+	Records map[uint32]int
+}
+
+func (e *EMFUnsupportedError) Error() string {
+	types := make([]int, 0, len(e.Records))
+	n := 0
+	for t, c := range e.Records {
+		types = append(types, int(t))
+		n += c
+	}
+	sort.Ints(types)
+	parts := make([]string, len(types))
+	for i, t := range types {
+		parts[i] = fmt.Sprintf("%d (x%d)", t, e.Records[uint32(t)])
+	}
+	return fmt.Sprintf("emf: skipped %d unsupported records of types %s", n, strings.Join(parts, ", "))
+}
+
+// PlayEMF renders f onto dst, mapping its picture frame onto r as
+// PlayEnhMetaFile does. Drawing is clipped to dst but not to r, and pixels
+// outside the picture are left alone.
+//
+// It executes the drawing, object, state, transform, path and clipping
+// records, and the DIB blits with the named raster operations, following
+// GDI's rules for pen widths, fill modes and excluded right and bottom
+// edges. Text is not rendered, beyond the ETO_OPAQUE background. Records
+// it cannot render are skipped and reported by an *EMFUnsupportedError.
+func PlayEMF(dst *image.RGBA, f *EnhMetaFile, r image.Rectangle) error {
+	p := &emfPlayer{
+		f:       f,
+		objects: NewEMFObjectTable(f.Header.NHandles),
+		base:    emfBaseTransform(&f.Header, r),
+		skipped: map[uint32]int{},
+	}
+	p.st = emfPlayState{
+		dc:         *newSoftDC(dst),
+		world:      identityAffine,
+		mapMode:    MM_TEXT,
+		winExt:     SIZE{1, 1},
+		vpExt:      SIZE{1, 1},
+		arcDir:     AD_COUNTERCLOCKWISE,
+		penWidth:   0,
+		miterLimit: 10,
+	}
+	for _, rec := range f.Records {
+		p.objects.Update(rec)
+		p.play(rec)
+	}
+	if len(p.skipped) > 0 {
+		return &EMFUnsupportedError{p.skipped}
+	}
+	return nil
+}
+
+// emfBaseTransform maps the reference device pixels of the picture frame
+// onto r in raster space. Metafiles without a usable frame use their
+// bounds.
+func emfBaseTransform(h *ENHMETAHEADER, r image.Rectangle) affine {
+	dev, mm := h.SzlDevice, h.SzlMillimeters
+	x0, y0, x1, y1 := float64(h.RclBounds.Left), float64(h.RclBounds.Top), float64(h.RclBounds.Right), float64(h.RclBounds.Bottom)
+	if f := h.RclFrame; f.Right > f.Left && f.Bottom > f.Top && dev.CX > 0 && dev.CY > 0 && mm.CX > 0 && mm.CY > 0 {
+		kx := float64(dev.CX) / (float64(mm.CX) * 100)
+		ky := float64(dev.CY) / (float64(mm.CY) * 100)
+		x0, y0, x1, y1 = float64(f.Left)*kx, float64(f.Top)*ky, float64(f.Right)*kx, float64(f.Bottom)*ky
+	}
+	if !(x1 >= x0 && y1 >= y0) {
+		return translateAffine(float64(r.Min.X), float64(r.Min.Y))
+	}
+	sx := float64(r.Dx()) / (x1 - x0 + 1)
+	sy := float64(r.Dy()) / (y1 - y0 + 1)
+	return translateAffine(-x0, -y0).then(scaleAffine(sx, sy)).then(translateAffine(float64(r.Min.X), float64(r.Min.Y)))
+}
+
+// emfPlayState is the DC state saved by EMR_SAVEDC.
+type emfPlayState struct {
+	dc         softDC
+	world      affine
+	mapMode    uint32
+	winOrg     POINT
+	vpOrg      POINT
+	winExt     SIZE
+	vpExt      SIZE
+	arcDir     uint32
+	pos        POINT
+	penWidth   float64 // logical units; 0 for cosmetic pens
+	userStyle  []uint32
+	miterLimit float64
+}
+
+type emfPlayer struct {
+	f       *EnhMetaFile
+	objects *EMFObjectTable
+	base    affine
+	st      emfPlayState
+	saved   []emfPlayState
+	path    []rasterFigure
+	inPath  bool
+	skipped map[uint32]int
+}
+
+func (p *emfPlayer) skip(rec EMFRecord) {
+	p.skipped[rec.RecordType()]++
+}
+
+// page returns the transform from page space to reference device pixels
+// set up by the mapping mode, window and viewport.
+func (p *emfPlayer) page() affine {
+	s := &p.st
+	h := &p.f.Header
+	var sx, sy float64
+	// Millimeters per logical unit of the metric and English modes.
+	metric := map[uint32]float64{
+		MM_LOMETRIC:  0.1,
+		MM_HIMETRIC:  0.01,
+		MM_LOENGLISH: 0.254,
+		MM_HIENGLISH: 0.0254,
+		MM_TWIPS:     25.4 / 1440,
+	}
+	switch mm, ok := metric[s.mapMode]; {
+	case ok && h.SzlMillimeters.CX > 0 && h.SzlMillimeters.CY > 0:
+		sx = mm * float64(h.SzlDevice.CX) / float64(h.SzlMillimeters.CX)
+		sy = -mm * float64(h.SzlDevice.CY) / float64(h.SzlMillimeters.CY)
+	case (s.mapMode == MM_ISOTROPIC || s.mapMode == MM_ANISOTROPIC) && s.winExt.CX != 0 && s.winExt.CY != 0:
+		sx = float64(s.vpExt.CX) / float64(s.winExt.CX)
+		sy = float64(s.vpExt.CY) / float64(s.winExt.CY)
+		if s.mapMode == MM_ISOTROPIC {
+			m := math.Min(math.Abs(sx), math.Abs(sy))
+			sx, sy = math.Copysign(m, sx), math.Copysign(m, sy)
+		}
+	default:
+		sx, sy = 1, 1
+	}
+	return translateAffine(-float64(s.winOrg.X), -float64(s.winOrg.Y)).
+		then(scaleAffine(sx, sy)).
+		then(translateAffine(float64(s.vpOrg.X), float64(s.vpOrg.Y)))
+}
+
+// device returns the transform from logical coordinates to reference
+// device pixels.
+func (p *emfPlayer) device() affine {
+	return p.st.world.then(p.page())
+}
+
+// xf returns the transform from logical coordinates to raster space, in
+// which a device coordinate names the center of its pixel.
+func (p *emfPlayer) xf() affine {
+	return p.device().then(translateAffine(0.5, 0.5)).then(p.base)
+}
+
+// corners returns the transform from logical coordinates to raster space
+// in which a device coordinate names the top left corner of its pixel, as
+// for clip rectangles and blits.
+func (p *emfPlayer) corners() affine {
+	return p.device().then(p.base)
+}
+
+func (p *emfPlayer) pts(pts []POINT) []rasterPt {
+	m := p.xf()
+	out := make([]rasterPt, len(pts))
+	for i, pt := range pts {
+		out[i] = m.apply(float64(pt.X), float64(pt.Y))
+	}
+	return out
+}
+
+func (p *emfPlayer) pt(pt POINT) rasterPt {
+	return p.xf().apply(float64(pt.X), float64(pt.Y))
+}
+
+// box returns the space and the box in it in which to build a shape
+// bounded by rc with GDI's excluded right and bottom edges. Under an axis
+// aligned transform that is device space, where the edges are moved in by
+// a pixel; otherwise it is logical space.
+func (p *emfPlayer) box(rc RECT) (m affine, l, t, r, b float64) {
+	dev := p.device()
+	if !dev.axisAligned() {
+		return p.xf(), float64(rc.Left), float64(rc.Top), float64(rc.Right), float64(rc.Bottom)
+	}
+	a := dev.apply(float64(rc.Left), float64(rc.Top))
+	c := dev.apply(float64(rc.Right), float64(rc.Bottom))
+	l, r = math.Min(a.X, c.X), math.Max(a.X, c.X)-1
+	t, b = math.Min(a.Y, c.Y), math.Max(a.Y, c.Y)-1
+	if hw := p.st.penWidth * dev.scale() / 2; p.st.dc.pen.style&PS_STYLE_MASK == PS_INSIDEFRAME && hw > 0.75 {
+		l, t, r, b = l+hw, t+hw, r-hw, b-hw
+	}
+	return translateAffine(0.5, 0.5).then(p.base), l, t, math.Max(l, r), math.Max(t, b)
+}
+
+// draw fills and strokes figs, or adds them to the path in a path
+// bracket.
+func (p *emfPlayer) draw(figs []rasterFigure, fill, stroke bool) {
+	if p.inPath {
+		p.path = append(p.path, figs...)
+		return
+	}
+	if fill {
+		p.st.dc.fill(figs)
+	}
+	if stroke {
+		p.strokeFigs(figs)
+	}
+}
+
+// strokeFigs outlines figs with the pen scaled to raster space.
+func (p *emfPlayer) strokeFigs(figs []rasterFigure) {
+	dc := &p.st.dc
+	k := p.xf().scale()
+	dc.pen.width = p.st.penWidth * k
+	dc.pen.miterLimit = p.st.miterLimit
+	dc.pen.userStyle = nil
+	for _, d := range p.st.userStyle {
+		dc.pen.userStyle = append(dc.pen.userStyle, float64(d)*k)
+	}
+	dc.stroke(figs)
+}
+
+// lineTo draws from the current position through pts, or extends the
+// current figure of the path.
+func (p *emfPlayer) lineTo(pts []rasterPt) {
+	if len(pts) == 0 {
+		return
+	}
+	start := p.pt(p.st.pos)
+	if p.inPath {
+		if n := len(p.path); n > 0 && !p.path[n-1].closed {
+			if f := &p.path[n-1]; f.pts[len(f.pts)-1] == start {
+				f.pts = append(f.pts, pts...)
+				return
+			}
+		}
+		p.path = append(p.path, rasterFigure{pts: append([]rasterPt{start}, pts...)})
+		return
+	}
+	p.strokeFigs([]rasterFigure{{pts: append([]rasterPt{start}, pts...)}})
+}
+
+// bezier flattens a start point followed by triples of control points.
+func bezier(pts []rasterPt) []rasterPt {
+	if len(pts) == 0 {
+		return nil
+	}
+	out := []rasterPt{pts[0]}
+	for i := 1; i+2 < len(pts); i += 3 {
+		out = flattenBezier(out, pts[i-1], pts[i], pts[i+1], pts[i+2])
+	}
+	return out
+}
+
+// arc returns the points of an EMR_ARC style arc on the ellipse bounded by
+// rc, from the radial through start to the one through end.
+func (p *emfPlayer) arc(rc RECT, start, end POINT) (pts []rasterPt, center rasterPt) {
+	m, l, t, r, b := p.box(rc)
+	inv, ok := m.invert()
+	if !ok {
+		return nil, rasterPt{}
+	}
+	// Bring the radial points into the space of the box.
+	toBox := p.xf().then(inv)
+	s := toBox.apply(float64(start.X), float64(start.Y))
+	e := toBox.apply(float64(end.X), float64(end.Y))
+	c := rasterPt{(l + r) / 2, (t + b) / 2}
+	rx, ry := (r-l)/2, (b-t)/2
+	angle := func(q rasterPt) float64 {
+		return math.Atan2((q.Y-c.Y)/math.Max(ry, 1e-9), (q.X-c.X)/math.Max(rx, 1e-9))
+	}
+	a0, a1 := angle(s), angle(e)
+
+	// Counterclockwise on a y-down display means decreasing angles, unless
+	// the transform mirrors the picture.
+	ccw := p.st.arcDir == AD_COUNTERCLOCKWISE
+	if p.device().det() < 0 {
+		ccw = !ccw
+	}
+	sweep := a1 - a0
+	if ccw {
+		for sweep >= 0 {
+			sweep -= 2 * math.Pi
+		}
+	} else {
+		for sweep <= 0 {
+			sweep += 2 * math.Pi
+		}
+	}
+	return ellipseArc(nil, m, c, rx, ry, a0, sweep), m.apply(c.X, c.Y)
+}
+
+// ellipse returns the outline of the ellipse bounded by rc.
+func (p *emfPlayer) ellipse(rc RECT) []rasterPt {
+	m, l, t, r, b := p.box(rc)
+	pts := ellipseArc(nil, m, rasterPt{(l + r) / 2, (t + b) / 2}, (r-l)/2, (b-t)/2, 0, 2*math.Pi)
+	return pts[:len(pts)-1]
+}
+
+// rectangle returns the outline of rc.
+func (p *emfPlayer) rectangle(rc RECT) []rasterPt {
+	m, l, t, r, b := p.box(rc)
+	return []rasterPt{m.apply(l, t), m.apply(r, t), m.apply(r, b), m.apply(l, b)}
+}
+
+// roundRect returns the outline of rc with corners rounded by ellipses of
+// size corner.
+func (p *emfPlayer) roundRect(rc RECT, corner SIZE) []rasterPt {
+	m, l, t, r, b := p.box(rc)
+	k := p.device()
+	rx := math.Min(math.Abs(float64(corner.CX)*math.Hypot(k.a, k.b))/2, (r-l)/2)
+	ry := math.Min(math.Abs(float64(corner.CY)*math.Hypot(k.c, k.d))/2, (b-t)/2)
+	if !k.axisAligned() {
+		rx = math.Min(math.Abs(float64(corner.CX))/2, (r-l)/2)
+		ry = math.Min(math.Abs(float64(corner.CY))/2, (b-t)/2)
+	}
+	var pts []rasterPt
+	pts = ellipseArc(pts, m, rasterPt{r - rx, t + ry}, rx, ry, -math.Pi/2, math.Pi/2)
+	pts = ellipseArc(pts, m, rasterPt{r - rx, b - ry}, rx, ry, 0, math.Pi/2)
+	pts = ellipseArc(pts, m, rasterPt{l + rx, b - ry}, rx, ry, math.Pi/2, math.Pi/2)
+	pts = ellipseArc(pts, m, rasterPt{l + rx, t + ry}, rx, ry, math.Pi, math.Pi/2)
+	return pts
+}
+
+// clipRect combines the clip region with the logical rectangle rc.
+func (p *emfPlayer) clipRect(rc RECT, mode int) {
+	m := p.corners()
+	fig := rasterFigure{pts: []rasterPt{
+		m.apply(float64(rc.Left), float64(rc.Top)),
+		m.apply(float64(rc.Right), float64(rc.Top)),
+		m.apply(float64(rc.Right), float64(rc.Bottom)),
+		m.apply(float64(rc.Left), float64(rc.Bottom)),
+	}}
+	p.st.dc.combineClip(p.st.dc.maskFigures([]rasterFigure{fig}, true), mode)
+}
+
+// clipRegion applies EMR_EXTSELECTCLIPRGN, whose RGNDATA rectangles are in
+// reference device pixels.
+func (p *emfPlayer) clipRegion(rec *EMRExtSelectClipRgn) bool {
+	if len(rec.RgnData) == 0 {
+		if rec.Mode == RGN_COPY {
+			p.st.dc.clip = nil
+			return true
+		}
+		return false
+	}
+	if len(rec.RgnData) < 32 {
+		return false
+	}
+	n := binary.LittleEndian.Uint32(rec.RgnData[8:])
+	if uint64(n)*16 > uint64(len(rec.RgnData)-32) {
+		return false
+	}
+	var figs []rasterFigure
+	for i := 0; i < int(n); i++ {
+		b := rec.RgnData[32+16*i:]
+		l := float64(int32(binary.LittleEndian.Uint32(b)))
+		t := float64(int32(binary.LittleEndian.Uint32(b[4:])))
+		r := float64(int32(binary.LittleEndian.Uint32(b[8:])))
+		bt := float64(int32(binary.LittleEndian.Uint32(b[12:])))
+		figs = append(figs, rasterFigure{pts: []rasterPt{
+			p.base.apply(l, t), p.base.apply(r, t), p.base.apply(r, bt), p.base.apply(l, bt),
+		}})
+	}
+	p.st.dc.combineClip(p.st.dc.maskFigures(figs, true), int(rec.Mode))
+	return true
+}
+
+// emfStockBrushes holds the colors of the solid stock brushes.
+var emfStockBrushes = map[int]COLORREF{
+	WHITE_BRUSH:  0xFFFFFF,
+	LTGRAY_BRUSH: 0xC0C0C0,
+	GRAY_BRUSH:   0x808080,
+	DKGRAY_BRUSH: 0x404040,
+	BLACK_BRUSH:  0,
+	DC_BRUSH:     0xFFFFFF,
+}
+
+// selectObject makes the pen or brush in slot index current.
+func (p *emfPlayer) selectObject(index uint32) bool {
+	dc := &p.st.dc
+	if stock, ok := StockObject(index); ok {
+		switch {
+		case stock == NULL_BRUSH:
+			dc.brush = softBrush{style: BS_NULL}
+		case stock == WHITE_PEN || stock == BLACK_PEN || stock == DC_PEN:
+			dc.pen.style, dc.pen.color = PS_SOLID, 0
+			if stock == WHITE_PEN {
+				dc.pen.color = 0xFFFFFF
+			}
+			p.st.penWidth, p.st.userStyle = 0, nil
+		case stock == NULL_PEN:
+			dc.pen.style = PS_NULL
+		default:
+			c, ok := emfStockBrushes[stock]
+			if !ok {
+				// Fonts and the default palette.
+				return true
+			}
+			dc.brush = softBrush{style: BS_SOLID, color: c}
+		}
+		return true
+	}
+
+	switch o := p.objects.Get(index).(type) {
+	case *EMRCreatePen:
+		p.st.penWidth, p.st.userStyle = float64(o.Pen.LopnWidth.X), nil
+		dc.pen.style, dc.pen.color = createPenStyle(o.Pen.LopnStyle, p.st.penWidth), o.Pen.LopnColor
+	case *EMRExtCreatePen:
+		dc.pen.style, dc.pen.color = o.Style, o.Color
+		p.st.penWidth, p.st.userStyle = float64(o.Width), o.StyleEntries
+		if o.Style&PS_TYPE_MASK == PS_COSMETIC {
+			p.st.penWidth = 0
+		}
+	case *EMRCreateBrushIndirect:
+		dc.brush = softBrush{style: o.Brush.LbStyle, color: o.Brush.LbColor, hatch: o.Brush.LbHatch}
+		if o.Brush.LbStyle != BS_SOLID && o.Brush.LbStyle != BS_HATCHED {
+			dc.brush.style = BS_NULL
+		}
+	case *EMRCreateDIBPatternBrush:
+		img, err := o.Pattern.Image()
+		if err != nil {
+			return false
+		}
+		dc.brush = softBrush{style: BS_DIBPATTERN, pattern: img, mono: o.IType == EMR_CREATEMONOBRUSH}
+	case *EMRExtCreateFontIndirect:
+	default:
+		return false
+	}
+	return true
+}
+
+// blit plays the blit records.
+func (p *emfPlayer) blit(typ uint32, xDest, yDest, cxDest, cyDest, xSrc, ySrc, cxSrc, cySrc int32, src *DIB, rop uint32) bool {
+	if cxDest == 0 || cyDest == 0 {
+		return true
+	}
+	var img image.Image
+	if src != nil {
+		if cxSrc == 0 || cySrc == 0 {
+			return true
+		}
+		d := src
+		if typ == EMR_ALPHABLEND && rop>>24&AC_SRC_ALPHA != 0 {
+			c := *src
+			c.Premultiplied = true
+			d = &c
+		}
+		var err error
+		if img, err = d.Image(); err != nil {
+			return false
+		}
+	} else {
+		cxSrc, cySrc = cxDest, cyDest
+	}
+
+	// Source pixels to logical units to raster space.
+	toLogical := translateAffine(-float64(xSrc), -float64(ySrc)).
+		then(scaleAffine(float64(cxDest)/float64(cxSrc), float64(cyDest)/float64(cySrc))).
+		then(translateAffine(float64(xDest), float64(yDest)))
+	m := toLogical.then(p.corners())
+	toSrc, ok := m.invert()
+	if !ok {
+		return true
+	}
+	x0, y0 := float64(xSrc), float64(ySrc)
+	x1, y1 := x0+float64(cxSrc), y0+float64(cySrc)
+	box := boundsOf(m.apply(x0, y0), m.apply(x1, y0), m.apply(x1, y1), m.apply(x0, y1))
+	srcRect := image.Rect(int(xSrc), int(ySrc), int(xSrc+cxSrc), int(ySrc+cySrc)).Canon()
+
+	dc := &p.st.dc
+	switch typ {
+	case EMR_ALPHABLEND:
+		if img == nil || rop&0xFF != AC_SRC_OVER {
+			return false
+		}
+		dc.alphaBlend(box, toSrc, img, srcRect, uint8(rop>>16), rop>>24&AC_SRC_ALPHA != 0)
+	case EMR_TRANSPARENTBLT:
+		if img == nil {
+			return false
+		}
+		dc.transparentBlt(box, toSrc, img, srcRect, COLORREF(rop))
+	default:
+		dc.blit(box, toSrc, img, srcRect, rop)
+	}
+	return true
+}
+
+// play executes one record.
+func (p *emfPlayer) play(rec EMFRecord) {
+	s := &p.st
+	dc := &s.dc
+	ok := true
+
+	switch r := rec.(type) {
+	case *EMRHeader, *EMREOF, *EMRComment, *EMRCreatePalette:
+	case *EMRCreatePen, *EMRExtCreatePen, *EMRCreateBrushIndirect, *EMRCreateDIBPatternBrush, *EMRExtCreateFontIndirect:
+
+	case *EMRObject:
+		switch r.IType {
+		case EMR_SELECTOBJECT:
+			ok = p.selectObject(r.Index)
+		case EMR_DELETEOBJECT, EMR_SELECTPALETTE:
+		}
+
+	case *EMRRaw:
+		ok = p.playRaw(r)
+
+	case *EMRColor:
+		if r.IType == EMR_SETTEXTCOLOR {
+			dc.textColor = r.Color
+		} else {
+			dc.bkColor = r.Color
+		}
+
+	case *EMRMode:
+		switch r.IType {
+		case EMR_SETMAPMODE:
+			s.mapMode = r.Mode
+		case EMR_SETBKMODE:
+			dc.bkMode = int(r.Mode)
+		case EMR_SETPOLYFILLMODE:
+			dc.fillMode = int(r.Mode)
+		case EMR_SETROP2:
+			if r.Mode >= R2_BLACK && r.Mode <= R2_WHITE {
+				dc.rop2 = int(r.Mode)
+			}
+		case EMR_SETARCDIRECTION:
+			s.arcDir = r.Mode
+		case EMR_SELECTCLIPPATH:
+			if figs := p.takePath(); figs != nil {
+				dc.combineClip(dc.maskFigures(figs, dc.fillMode == WINDING), int(r.Mode))
+			}
+		case EMR_SETLAYOUT:
+			ok = r.Mode == 0
+		case EMR_SETSTRETCHBLTMODE, EMR_SETTEXTALIGN, EMR_SETICMMODE, EMR_SETMAPPERFLAGS:
+		}
+
+	case *EMRPoint:
+		switch r.IType {
+		case EMR_MOVETOEX:
+			s.pos = r.Point
+			if p.inPath {
+				p.path = append(p.path, rasterFigure{pts: []rasterPt{p.pt(r.Point)}})
+			}
+		case EMR_LINETO:
+			p.lineTo([]rasterPt{p.pt(r.Point)})
+			s.pos = r.Point
+		case EMR_SETWINDOWORGEX:
+			s.winOrg = r.Point
+		case EMR_SETVIEWPORTORGEX:
+			s.vpOrg = r.Point
+		case EMR_SETBRUSHORGEX:
+			x, y := p.base.apply(float64(r.Point.X), float64(r.Point.Y)).pixel()
+			dc.brushOrg = image.Pt(x, y)
+		case EMR_OFFSETCLIPRGN:
+			m := p.device()
+			d := rasterPt{m.a*float64(r.Point.X) + m.c*float64(r.Point.Y), m.b*float64(r.Point.X) + m.d*float64(r.Point.Y)}
+			dc.offsetClip(int(math.Round(d.X*p.base.a)), int(math.Round(d.Y*p.base.d)))
+		}
+
+	case *EMRSize:
+		if r.IType == EMR_SETWINDOWEXTEX {
+			s.winExt = r.Size
+		} else {
+			s.vpExt = r.Size
+		}
+
+	case *EMRScaleExt:
+		if r.XDenom == 0 || r.YDenom == 0 {
+			break
+		}
+		ext := &s.vpExt
+		if r.IType == EMR_SCALEWINDOWEXTEX {
+			ext = &s.winExt
+		}
+		ext.CX = int32(int64(ext.CX) * int64(r.XNum) / int64(r.XDenom))
+		ext.CY = int32(int64(ext.CY) * int64(r.YNum) / int64(r.YDenom))
+
+	case *EMRXform:
+		x := affineFromXFORM(r.Xform)
+		switch {
+		case r.IType == EMR_SETWORLDTRANSFORM:
+			s.world = x
+		case r.Mode == MWT_IDENTITY:
+			s.world = identityAffine
+		case r.Mode == MWT_LEFTMULTIPLY:
+			s.world = x.then(s.world)
+		case r.Mode == MWT_RIGHTMULTIPLY:
+			s.world = s.world.then(x)
+		default:
+			ok = false
+		}
+
+	case *EMRRestoreDC:
+		n := int(r.SavedDC)
+		if n < 0 {
+			n += len(p.saved) + 1
+		}
+		if n >= 1 && n <= len(p.saved) {
+			p.st = p.saved[n-1]
+			p.saved = p.saved[:n-1]
+		}
+
+	case *EMRSetMiterLimit:
+		s.miterLimit = float64(r.Limit)
+
+	case *EMRPoly:
+		pts := p.pts(r.Points)
+		switch r.IType {
+		case EMR_POLYLINE, EMR_POLYLINE16:
+			p.draw([]rasterFigure{{pts: pts}}, false, true)
+		case EMR_POLYGON, EMR_POLYGON16:
+			p.draw([]rasterFigure{{pts: pts, closed: true}}, true, true)
+		case EMR_POLYBEZIER, EMR_POLYBEZIER16:
+			p.draw([]rasterFigure{{pts: bezier(pts)}}, false, true)
+		case EMR_POLYLINETO, EMR_POLYLINETO16:
+			p.lineTo(pts)
+		case EMR_POLYBEZIERTO, EMR_POLYBEZIERTO16:
+			if curve := bezier(append([]rasterPt{p.pt(s.pos)}, pts...)); len(curve) > 1 {
+				p.lineTo(curve[1:])
+			}
+		}
+		if n := len(r.Points); n > 0 && (r.IType == EMR_POLYLINETO || r.IType == EMR_POLYLINETO16 ||
+			r.IType == EMR_POLYBEZIERTO || r.IType == EMR_POLYBEZIERTO16) {
+			s.pos = r.Points[n-1]
+		}
+
+	case *EMRPolyPoly:
+		var figs []rasterFigure
+		pts := p.pts(r.Points)
+		closed := r.IType == EMR_POLYPOLYGON || r.IType == EMR_POLYPOLYGON16
+		for _, n := range r.Counts {
+			figs = append(figs, rasterFigure{pts: pts[:n], closed: closed})
+			pts = pts[n:]
+		}
+		p.draw(figs, closed, true)
+
+	case *EMRRect:
+		switch r.IType {
+		case EMR_RECTANGLE:
+			p.draw([]rasterFigure{{pts: p.rectangle(r.Rect), closed: true}}, true, true)
+		case EMR_ELLIPSE:
+			p.draw([]rasterFigure{{pts: p.ellipse(r.Rect), closed: true}}, true, true)
+		case EMR_INTERSECTCLIPRECT:
+			p.clipRect(r.Rect, RGN_AND)
+		case EMR_EXCLUDECLIPRECT:
+			p.clipRect(r.Rect, RGN_DIFF)
+		case EMR_FILLPATH, EMR_STROKEPATH, EMR_STROKEANDFILLPATH:
+			figs := p.takePath()
+			if r.IType != EMR_STROKEPATH {
+				for i := range figs {
+					figs[i].closed = true
+				}
+				dc.fill(figs)
+			}
+			if r.IType != EMR_FILLPATH {
+				p.strokeFigs(figs)
+			}
+		}
+
+	case *EMRRoundRect:
+		p.draw([]rasterFigure{{pts: p.roundRect(r.Rect, r.Corner), closed: true}}, true, true)
+
+	case *EMRArc:
+		pts, c := p.arc(r.Box, r.Start, r.End)
+		switch r.IType {
+		case EMR_ARC:
+			p.draw([]rasterFigure{{pts: pts}}, false, true)
+		case EMR_ARCTO:
+			p.lineTo(pts)
+			if len(pts) > 0 {
+				if inv, ok := p.xf().invert(); ok {
+					e := inv.apply(pts[len(pts)-1].X, pts[len(pts)-1].Y)
+					s.pos = POINT{int32(math.Round(e.X)), int32(math.Round(e.Y))}
+				}
+			}
+		case EMR_CHORD:
+			p.draw([]rasterFigure{{pts: pts, closed: true}}, true, true)
+		case EMR_PIE:
+			p.draw([]rasterFigure{{pts: append(pts, c), closed: true}}, true, true)
+		}
+
+	case *EMRAngleArc:
+		m := p.xf()
+		c := rasterPt{float64(r.Center.X), float64(r.Center.Y)}
+		rad := float64(r.Radius)
+		a0 := -float64(r.StartAngle) * math.Pi / 180
+		sweep := -float64(r.SweepAngle) * math.Pi / 180
+		pts := ellipseArc(nil, m, c, rad, rad, a0, sweep)
+		p.lineTo(pts)
+		end := a0 + sweep
+		s.pos = POINT{int32(math.Round(c.X + rad*math.Cos(end))), int32(math.Round(c.Y + rad*math.Sin(end)))}
+
+	case *EMRSetPixel:
+		if !p.inPath {
+			x, y := p.pt(r.Point).pixel()
+			dc.put(x, y, r.Color)
+		}
+
+	case *EMRExtTextOut:
+		if r.Options&ETO_OPAQUE != 0 && !p.inPath {
+			m := p.corners()
+			rc := r.Rect
+			fig := rasterFigure{pts: []rasterPt{
+				m.apply(float64(rc.Left), float64(rc.Top)),
+				m.apply(float64(rc.Right), float64(rc.Top)),
+				m.apply(float64(rc.Right), float64(rc.Bottom)),
+				m.apply(float64(rc.Left), float64(rc.Bottom)),
+			}}
+			rop := dc.rop2
+			dc.rop2 = R2_COPYPEN
+			dc.fillColor([]rasterFigure{fig}, dc.bkColor)
+			dc.rop2 = rop
+		}
+		ok = r.Text == ""
+
+	case *EMRBitBlt:
+		ok = p.blit(r.IType, r.XDest, r.YDest, r.CxDest, r.CyDest, r.XSrc, r.YSrc, r.CxSrc, r.CySrc, r.Source, r.Rop)
+
+	case *EMRStretchDIBits:
+		ySrc := r.YSrc
+		if r.Source != nil && !r.Source.TopDown() {
+			// The source origin of a bottom-up DIB is its lower left corner.
+			ySrc = int32(r.Source.Height()) - r.YSrc - r.CySrc
+		}
+		ok = r.UsageSrc == DIB_RGB_COLORS &&
+			p.blit(r.IType, r.XDest, r.YDest, r.CxDest, r.CyDest, r.XSrc, ySrc, r.CxSrc, r.CySrc, r.Source, r.Rop)
+
+	case *EMRExtSelectClipRgn:
+		ok = p.clipRegion(r)
+
+	default:
+		ok = false
+	}
+
+	if !ok {
+		p.skip(rec)
+	}
+}
+
+// takePath ends the path bracket and returns its figures, leaving out
+// lone points from EMR_MOVETOEX.
+func (p *emfPlayer) takePath() []rasterFigure {
+	var figs []rasterFigure
+	for _, f := range p.path {
+		if len(f.pts) > 1 {
+			figs = append(figs, f)
+		}
+	}
+	p.path, p.inPath = nil, false
+	return figs
+}
+
+// playRaw executes the records without parameters that the player knows.
+func (p *emfPlayer) playRaw(r *EMRRaw) bool {
+	switch r.IType {
+	case EMR_SAVEDC:
+		st := p.st
+		st.dc = p.st.dc.clone()
+		p.saved = append(p.saved, st)
+	case EMR_BEGINPATH:
+		p.path, p.inPath = nil, true
+	case EMR_ENDPATH:
+		p.inPath = false
+	case EMR_ABORTPATH:
+		p.path, p.inPath = nil, false
+	case EMR_CLOSEFIGURE:
+		if n := len(p.path); n > 0 {
+			p.path[n-1].closed = true
+		}
+	case EMR_FLATTENPATH, EMR_REALIZEPALETTE, EMR_SETMETARGN:
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,1080 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"unicode/utf16"
+)
+
+// EMFOptions describes the reference device of a recorded metafile.
+type EMFOptions struct {
+	// Device is the size of the reference device in pixels and
+	// Millimeters its size in millimeters. They default to a 1920x1080
+	// screen at 96 DPI.
+	Device      SIZE
+	Millimeters SIZE
+
+	// Frame is the picture frame in .01 millimeter units, inclusive, as
+	// passed to CreateEnhMetaFile. If it is empty it is computed from the
+	// bounds of everything drawn.
+	Frame RECT
+
+	// Application and Title make up the header description. Both may be
+	// empty.
+	Application string
+	Title       string
+}
+
+// EMFRecorder records drawing commands into an enhanced metafile, in the
+// manner of a metafile DC returned by CreateEnhMetaFile. Objects are
+// referred to by their index in the metafile object table. Errors are
+// sticky and reported by Bytes.
+//
+// Coordinates are logical units of the default MM_TEXT mapping, which are
+// reference device pixels.
+type EMFRecorder struct {
+	opts     EMFOptions
+	body     resWriter
+	nRecords uint32
+	objects  []EMFRecord
+	bounds   RECT
+	drawn    bool
+	state    emfDCState
+	saved    []emfDCState
+	err      error
+}
+
+// emfDCState is the part of the DC state the recorder needs to compute
+// bounds.
+type emfDCState struct {
+	pos        POINT
+	penWidth   int32
+	fontHeight int32
+	fontWidth  int32
+	textAlign  uint32
+}
+
+// emfDefaultFontHeight is the cell height of the stock SYSTEM_FONT on a
+// 96 DPI display.
+const emfDefaultFontHeight = 16
+
+// NewEMFRecorder returns an empty recorder. opts may be nil.
+func NewEMFRecorder(opts *EMFOptions) *EMFRecorder {
+	r := &EMFRecorder{
+		objects: make([]EMFRecord, 1),
+		state:   emfDCState{penWidth: 1, fontHeight: emfDefaultFontHeight},
+	}
+	if opts != nil {
+		r.opts = *opts
+	}
+	if r.opts.Device.CX <= 0 || r.opts.Device.CY <= 0 {
+		r.opts.Device = SIZE{1920, 1080}
+	}
+	if r.opts.Millimeters.CX <= 0 || r.opts.Millimeters.CY <= 0 {
+		r.opts.Millimeters = SIZE{
+			int32(math.Round(float64(r.opts.Device.CX) * 25.4 / 96)),
+			int32(math.Round(float64(r.opts.Device.CY) * 25.4 / 96)),
+		}
+	}
+	return r
+}
+
+// Record appends rec, which may come from ParseEMF, keeping the object
+// table, the DC state and the picture bounds up to date. The recorder
+// writes EMR_HEADER and EMR_EOF itself; passing either is an error.
+func (r *EMFRecorder) Record(rec EMFRecord) {
+	if r.err != nil {
+		return
+	}
+	switch rec.RecordType() {
+	case EMR_HEADER, EMR_EOF:
+		r.err = errors.New("emf: EMR_HEADER and EMR_EOF are written by the recorder")
+		return
+	}
+	data, err := encodeEMFRecord(rec)
+	if err != nil {
+		r.err = fmt.Errorf("emf: record %d (type %d): %v", r.nRecords+1, rec.RecordType(), err)
+		return
+	}
+	if r.body.b.Len()+len(data) > maxEMFSize {
+		r.err = errors.New("emf: metafile is too large")
+		return
+	}
+	if i, ok := emfObjectIndex(rec); ok {
+		if i == 0 || i > 0xFFFE {
+			r.err = fmt.Errorf("emf: bad object index %d", i)
+			return
+		}
+		for uint32(len(r.objects)) <= i {
+			r.objects = append(r.objects, nil)
+		}
+		r.objects[i] = rec
+	}
+	r.body.b.Write(data)
+	r.nRecords++
+	r.update(rec)
+}
+
+// update applies the effect of rec on the DC state and the bounds.
+func (r *EMFRecorder) update(rec EMFRecord) {
+	s := &r.state
+	half := s.penWidth / 2
+	switch rec := rec.(type) {
+	case *EMRObject:
+		switch rec.IType {
+		case EMR_SELECTOBJECT:
+			r.selectObject(rec.Index)
+		case EMR_DELETEOBJECT:
+			if int64(rec.Index) < int64(len(r.objects)) {
+				r.objects[rec.Index] = nil
+			}
+		}
+
+	case *EMRMode:
+		if rec.IType == EMR_SETTEXTALIGN {
+			s.textAlign = rec.Mode
+		}
+
+	case *EMRRaw:
+		if rec.IType == EMR_SAVEDC {
+			r.saved = append(r.saved, *s)
+		}
+
+	case *EMRRestoreDC:
+		n := int(rec.SavedDC)
+		if n < 0 {
+			n += len(r.saved) + 1
+		}
+		if n >= 1 && n <= len(r.saved) {
+			*s = r.saved[n-1]
+			r.saved = r.saved[:n-1]
+		}
+
+	case *EMRPoint:
+		switch rec.IType {
+		case EMR_MOVETOEX:
+			s.pos = rec.Point
+		case EMR_LINETO:
+			r.extend(inflateRect(pointsBounds([]POINT{s.pos, rec.Point}), half))
+			s.pos = rec.Point
+		}
+
+	case *EMRPoly:
+		r.extend(rec.Bounds)
+		switch rec.IType {
+		case EMR_POLYLINETO, EMR_POLYBEZIERTO, EMR_POLYLINETO16, EMR_POLYBEZIERTO16:
+			if len(rec.Points) > 0 {
+				s.pos = rec.Points[len(rec.Points)-1]
+			}
+		}
+
+	case *EMRPolyPoly:
+		r.extend(rec.Bounds)
+	case *EMRExtTextOut:
+		r.extend(rec.Bounds)
+	case *EMRBitBlt:
+		r.extend(rec.Bounds)
+	case *EMRStretchDIBits:
+		r.extend(rec.Bounds)
+
+	case *EMRRect:
+		switch rec.IType {
+		case EMR_RECTANGLE, EMR_ELLIPSE:
+			r.extend(inflateRect(exclusiveBounds(rec.Rect), half))
+		}
+	case *EMRRoundRect:
+		r.extend(inflateRect(exclusiveBounds(rec.Rect), half))
+	case *EMRArc:
+		r.extend(inflateRect(exclusiveBounds(rec.Box), half))
+		if rec.IType == EMR_ARCTO {
+			s.pos = rec.End
+		}
+	case *EMRAngleArc:
+		c, rad := rec.Center, int32(rec.Radius)
+		r.extend(inflateRect(RECT{c.X - rad, c.Y - rad, c.X + rad, c.Y + rad}, half))
+	case *EMRSetPixel:
+		r.extend(RECT{rec.Point.X, rec.Point.Y, rec.Point.X, rec.Point.Y})
+	}
+}
+
+// selectObject tracks the pen width and font size of a selected object.
+func (r *EMFRecorder) selectObject(index uint32) {
+	s := &r.state
+	if stock, ok := StockObject(index); ok {
+		switch stock {
+		case WHITE_PEN, BLACK_PEN, DC_PEN:
+			s.penWidth = 1
+		case NULL_PEN:
+			s.penWidth = 0
+		case OEM_FIXED_FONT, ANSI_FIXED_FONT, ANSI_VAR_FONT, SYSTEM_FONT,
+			DEVICE_DEFAULT_FONT, SYSTEM_FIXED_FONT, DEFAULT_GUI_FONT:
+			s.fontHeight, s.fontWidth = emfDefaultFontHeight, 0
+		}
+		return
+	}
+	if int64(index) >= int64(len(r.objects)) {
+		return
+	}
+	switch o := r.objects[index].(type) {
+	case *EMRCreatePen:
+		s.penWidth = o.Pen.LopnWidth.X
+		if s.penWidth < 1 {
+			s.penWidth = 1
+		}
+		if o.Pen.LopnStyle&PS_STYLE_MASK == PS_NULL {
+			s.penWidth = 0
+		}
+	case *EMRExtCreatePen:
+		s.penWidth = int32(o.Width)
+		if o.Style&PS_STYLE_MASK == PS_NULL {
+			s.penWidth = 0
+		}
+	case *EMRExtCreateFontIndirect:
+		s.fontHeight, s.fontWidth = o.Font.Height, o.Font.Width
+		if s.fontHeight < 0 {
+			s.fontHeight = -s.fontHeight
+		}
+		if s.fontHeight == 0 {
+			s.fontHeight = emfDefaultFontHeight
+		}
+	}
+}
+
+// extend grows the picture bounds by the inclusive rectangle b.
+func (r *EMFRecorder) extend(b RECT) {
+	if b.Right < b.Left || b.Bottom < b.Top {
+		return
+	}
+	if !r.drawn {
+		r.bounds, r.drawn = b, true
+		return
+	}
+	r.bounds.Left = min32(r.bounds.Left, b.Left)
+	r.bounds.Top = min32(r.bounds.Top, b.Top)
+	r.bounds.Right = max32(r.bounds.Right, b.Right)
+	r.bounds.Bottom = max32(r.bounds.Bottom, b.Bottom)
+}
+
+// pointsBounds returns the inclusive bounds of pts.
+func pointsBounds(pts []POINT) RECT {
+	if len(pts) == 0 {
+		return RECT{0, 0, -1, -1}
+	}
+	b := RECT{pts[0].X, pts[0].Y, pts[0].X, pts[0].Y}
+	for _, p := range pts[1:] {
+		b.Left, b.Right = min32(b.Left, p.X), max32(b.Right, p.X)
+		b.Top, b.Bottom = min32(b.Top, p.Y), max32(b.Bottom, p.Y)
+	}
+	return b
+}
+
+// exclusiveBounds returns the inclusive bounds of a rectangle whose right
+// and bottom edges are excluded, as for Rectangle and Ellipse.
+func exclusiveBounds(rc RECT) RECT {
+	if rc.Left > rc.Right {
+		rc.Left, rc.Right = rc.Right, rc.Left
+	}
+	if rc.Top > rc.Bottom {
+		rc.Top, rc.Bottom = rc.Bottom, rc.Top
+	}
+	return RECT{rc.Left, rc.Top, rc.Right - 1, rc.Bottom - 1}
+}
+
+func min32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func inflateRect(rc RECT, d int32) RECT {
+	return RECT{rc.Left - d, rc.Top - d, rc.Right + d, rc.Bottom + d}
+}
+
+// newObject returns the lowest free object table slot, as GDI assigns
+// them.
+func (r *EMFRecorder) newObject() uint32 {
+	for i := 1; i < len(r.objects); i++ {
+		if r.objects[i] == nil {
+			return uint32(i)
+		}
+	}
+	return uint32(len(r.objects))
+}
+
+// CreatePen records a cosmetic or PS_INSIDEFRAME pen and returns its object
+// index.
+func (r *EMFRecorder) CreatePen(style uint32, width int32, color COLORREF) uint32 {
+	i := r.newObject()
+	r.Record(&EMRCreatePen{EMR{IType: EMR_CREATEPEN}, i, LOGPEN{style, POINT{X: width}, color}})
+	return i
+}
+
+// ExtCreatePen records a solid-color pen with the end cap, join and user
+// style of ExtCreatePen and returns its object index. styleEntries is used
+// with PS_USERSTYLE.
+func (r *EMFRecorder) ExtCreatePen(style, width uint32, color COLORREF, styleEntries []uint32) uint32 {
+	i := r.newObject()
+	r.Record(&EMRExtCreatePen{
+		EMR:          EMR{IType: EMR_EXTCREATEPEN},
+		Index:        i,
+		Style:        style,
+		Width:        width,
+		BrushStyle:   BS_SOLID,
+		Color:        color,
+		StyleEntries: styleEntries,
+	})
+	return i
+}
+
+// CreateBrushIndirect records a solid, hatched or null brush and returns
+// its object index.
+func (r *EMFRecorder) CreateBrushIndirect(lb LOGBRUSH32) uint32 {
+	i := r.newObject()
+	r.Record(&EMRCreateBrushIndirect{EMR{IType: EMR_CREATEBRUSHINDIRECT}, i, lb})
+	return i
+}
+
+// CreateSolidBrush records a solid brush and returns its object index.
+func (r *EMFRecorder) CreateSolidBrush(color COLORREF) uint32 {
+	return r.CreateBrushIndirect(LOGBRUSH32{BS_SOLID, color, 0})
+}
+
+// CreateHatchBrush records a brush with one of the HS_* patterns and
+// returns its object index.
+func (r *EMFRecorder) CreateHatchBrush(hatch uint32, color COLORREF) uint32 {
+	return r.CreateBrushIndirect(LOGBRUSH32{BS_HATCHED, color, hatch})
+}
+
+// CreateFontIndirect records a font and returns its object index.
+func (r *EMFRecorder) CreateFontIndirect(lf *LOGFONT) uint32 {
+	i := r.newObject()
+	r.Record(&EMRExtCreateFontIndirect{EMR{IType: EMR_EXTCREATEFONTINDIRECTW}, i, *lf})
+	return i
+}
+
+// SelectObject selects a pen, brush or font created by the recorder.
+func (r *EMFRecorder) SelectObject(index uint32) {
+	r.Record(&EMRObject{EMR{IType: EMR_SELECTOBJECT}, index})
+}
+
+// SelectStockObject selects one of the GetStockObject objects.
+func (r *EMFRecorder) SelectStockObject(object int) {
+	r.SelectObject(ENHMETA_STOCK_OBJECT | uint32(object))
+}
+
+// DeleteObject frees the object table slot index for reuse.
+func (r *EMFRecorder) DeleteObject(index uint32) {
+	r.Record(&EMRObject{EMR{IType: EMR_DELETEOBJECT}, index})
+}
+
+// SetTextColor records the text color.
+func (r *EMFRecorder) SetTextColor(color COLORREF) {
+	r.Record(&EMRColor{EMR{IType: EMR_SETTEXTCOLOR}, color})
+}
+
+// SetBkColor records the background color.
+func (r *EMFRecorder) SetBkColor(color COLORREF) {
+	r.Record(&EMRColor{EMR{IType: EMR_SETBKCOLOR}, color})
+}
+
+// SetBkMode records TRANSPARENT or OPAQUE.
+func (r *EMFRecorder) SetBkMode(mode int) {
+	r.Record(&EMRMode{EMR{IType: EMR_SETBKMODE}, uint32(mode)})
+}
+
+// SetPolyFillMode records ALTERNATE or WINDING.
+func (r *EMFRecorder) SetPolyFillMode(mode int) {
+	r.Record(&EMRMode{EMR{IType: EMR_SETPOLYFILLMODE}, uint32(mode)})
+}
+
+// SetTextAlign records a combination of the TA_* flags.
+func (r *EMFRecorder) SetTextAlign(align uint32) {
+	r.Record(&EMRMode{EMR{IType: EMR_SETTEXTALIGN}, align})
+}
+
+// SaveDC pushes the DC state.
+func (r *EMFRecorder) SaveDC() {
+	r.Record(&EMRRaw{EMR: EMR{IType: EMR_SAVEDC}})
+}
+
+// RestoreDC pops the DC state. A negative savedDC is relative to the
+// current state, as for RestoreDC.
+func (r *EMFRecorder) RestoreDC(savedDC int32) {
+	r.Record(&EMRRestoreDC{EMR{IType: EMR_RESTOREDC}, savedDC})
+}
+
+// MoveTo sets the current position.
+func (r *EMFRecorder) MoveTo(x, y int32) {
+	r.Record(&EMRPoint{EMR{IType: EMR_MOVETOEX}, POINT{x, y}})
+}
+
+// LineTo draws a line from the current position to, but not including,
+// (x, y) and makes it the current position.
+func (r *EMFRecorder) LineTo(x, y int32) {
+	r.Record(&EMRPoint{EMR{IType: EMR_LINETO}, POINT{x, y}})
+}
+
+// Rectangle outlines a rectangle with the pen and fills it with the brush.
+// The right and bottom edges are excluded.
+func (r *EMFRecorder) Rectangle(left, top, right, bottom int32) {
+	r.Record(&EMRRect{EMR{IType: EMR_RECTANGLE}, RECT{left, top, right, bottom}})
+}
+
+// Ellipse draws the ellipse inscribed in a rectangle.
+func (r *EMFRecorder) Ellipse(left, top, right, bottom int32) {
+	r.Record(&EMRRect{EMR{IType: EMR_ELLIPSE}, RECT{left, top, right, bottom}})
+}
+
+// RoundRect draws a rectangle with corners rounded by ellipses of the
+// given size.
+func (r *EMFRecorder) RoundRect(left, top, right, bottom, width, height int32) {
+	r.Record(&EMRRoundRect{EMR{IType: EMR_ROUNDRECT}, RECT{left, top, right, bottom}, SIZE{width, height}})
+}
+
+// SetPixel sets one pixel.
+func (r *EMFRecorder) SetPixel(x, y int32, color COLORREF) {
+	r.Record(&EMRSetPixel{EMR{IType: EMR_SETPIXELV}, POINT{x, y}, color})
+}
+
+// poly records one of the EMR_POLY* records, using its 16-bit variant when
+// every point fits, as GDI does.
+func (r *EMFRecorder) poly(typ, typ16 uint32, pts []POINT) {
+	if fitsPOINTS(pts) {
+		typ = typ16
+	}
+	bounds := inflateRect(pointsBounds(pts), r.state.penWidth/2)
+	r.Record(&EMRPoly{EMR{IType: typ}, bounds, pts})
+}
+
+func fitsPOINTS(pts []POINT) bool {
+	for _, p := range pts {
+		if p.X != int32(int16(p.X)) || p.Y != int32(int16(p.Y)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Polyline draws line segments connecting pts.
+func (r *EMFRecorder) Polyline(pts []POINT) {
+	r.poly(EMR_POLYLINE, EMR_POLYLINE16, pts)
+}
+
+// PolylineTo draws line segments from the current position through pts
+// and moves the current position to the last point.
+func (r *EMFRecorder) PolylineTo(pts []POINT) {
+	r.poly(EMR_POLYLINETO, EMR_POLYLINETO16, pts)
+}
+
+// Polygon draws a closed polygon, filled with the brush in the current
+// polygon fill mode.
+func (r *EMFRecorder) Polygon(pts []POINT) {
+	r.poly(EMR_POLYGON, EMR_POLYGON16, pts)
+}
+
+// PolyBezier draws cubic Bézier curves: a start point followed by three
+// points for each curve.
+func (r *EMFRecorder) PolyBezier(pts []POINT) {
+	r.poly(EMR_POLYBEZIER, EMR_POLYBEZIER16, pts)
+}
+
+// PolyPolygon draws a series of closed polygons filled as one figure.
+func (r *EMFRecorder) PolyPolygon(polys [][]POINT) {
+	p := &EMRPolyPoly{EMR: EMR{IType: EMR_POLYPOLYGON}}
+	for _, poly := range polys {
+		p.Counts = append(p.Counts, uint32(len(poly)))
+		p.Points = append(p.Points, poly...)
+	}
+	if fitsPOINTS(p.Points) {
+		p.IType = EMR_POLYPOLYGON16
+	}
+	p.Bounds = inflateRect(pointsBounds(p.Points), r.state.penWidth/2)
+	r.Record(p)
+}
+
+// TextOut draws s at (x, y) with the selected font, aligned by the
+// SetTextAlign flags.
+func (r *EMFRecorder) TextOut(x, y int32, s string) {
+	r.ExtTextOut(x, y, 0, nil, s, nil)
+}
+
+// ExtTextOut draws s as ExtTextOut does. rect is used with ETO_OPAQUE and
+// ETO_CLIPPED and may be nil. dx holds the advance of each UTF-16 code
+// unit, or of each x and y pair with ETO_PDY, and may be nil.
+//
+// The recorder has no font metrics: unless dx is given, the bounds of the
+// text are estimated from the font height.
+func (r *EMFRecorder) ExtTextOut(x, y int32, options uint32, rect *RECT, s string, dx []int32) {
+	t := &EMRExtTextOut{
+		EMR:          EMR{IType: EMR_EXTTEXTOUTW},
+		GraphicsMode: GM_COMPATIBLE,
+		Reference:    POINT{x, y},
+		Options:      options,
+		Text:         s,
+		Dx:           dx,
+	}
+	if rect != nil {
+		t.Rect = *rect
+	}
+	var width int32
+	t.Bounds, width = r.textBounds(t)
+	r.Record(t)
+	if r.err == nil && r.state.textAlign&TA_UPDATECP != 0 {
+		r.state.pos.X += width
+	}
+}
+
+// textBounds estimates the inclusive bounds of t and the width of the text.
+func (r *EMFRecorder) textBounds(t *EMRExtTextOut) (RECT, int32) {
+	s := r.state
+	n := int32(len(utf16.Encode([]rune(t.Text))))
+	var width int32
+	if len(t.Dx) > 0 {
+		step := 1
+		if t.Options&ETO_PDY != 0 {
+			step = 2
+		}
+		for i := 0; i < len(t.Dx); i += step {
+			width += t.Dx[i]
+		}
+	} else {
+		cw := s.fontWidth
+		if cw <= 0 {
+			cw = (s.fontHeight + 1) / 2
+		}
+		width = n * cw
+	}
+
+	ref := t.Reference
+	if s.textAlign&TA_UPDATECP != 0 {
+		ref = s.pos
+	}
+	b := RECT{Left: ref.X, Top: ref.Y}
+	switch {
+	case s.textAlign&TA_CENTER == TA_CENTER:
+		b.Left -= width / 2
+	case s.textAlign&TA_RIGHT != 0:
+		b.Left -= width
+	}
+	switch {
+	case s.textAlign&TA_BASELINE == TA_BASELINE:
+		b.Top -= s.fontHeight * 4 / 5
+	case s.textAlign&TA_BOTTOM != 0:
+		b.Top -= s.fontHeight
+	}
+	b.Right, b.Bottom = b.Left+width-1, b.Top+s.fontHeight-1
+	if n == 0 {
+		b = RECT{0, 0, -1, -1}
+	}
+
+	rc := exclusiveBounds(t.Rect)
+	if t.Options&ETO_CLIPPED != 0 {
+		b.Left, b.Top = max32(b.Left, rc.Left), max32(b.Top, rc.Top)
+		b.Right, b.Bottom = min32(b.Right, rc.Right), min32(b.Bottom, rc.Bottom)
+	}
+	if t.Options&ETO_OPAQUE != 0 && rc.Right >= rc.Left && rc.Bottom >= rc.Top {
+		if b.Right < b.Left || b.Bottom < b.Top {
+			return rc, width
+		}
+		b.Left, b.Top = min32(b.Left, rc.Left), min32(b.Top, rc.Top)
+		b.Right, b.Bottom = max32(b.Right, rc.Right), max32(b.Bottom, rc.Bottom)
+	}
+	return b, width
+}
+
+// StretchDIBits copies the source rectangle of d to the destination
+// rectangle, stretching it as needed, with the raster operation rop.
+func (r *EMFRecorder) StretchDIBits(xDest, yDest, cxDest, cyDest, xSrc, ySrc, cxSrc, cySrc int32, d *DIB, rop uint32) {
+	r.Record(&EMRStretchDIBits{
+		EMR:      EMR{IType: EMR_STRETCHDIBITS},
+		Bounds:   exclusiveBounds(RECT{xDest, yDest, xDest + cxDest, yDest + cyDest}),
+		XDest:    xDest,
+		YDest:    yDest,
+		XSrc:     xSrc,
+		YSrc:     ySrc,
+		CxSrc:    cxSrc,
+		CySrc:    cySrc,
+		UsageSrc: DIB_RGB_COLORS,
+		Rop:      rop,
+		CxDest:   cxDest,
+		CyDest:   cyDest,
+		Source:   d,
+	})
+}
+
+// DrawImage draws img stretched to the destination rectangle. It is
+// converted with NewDIB and default options.
+func (r *EMFRecorder) DrawImage(x, y, cx, cy int32, img image.Image) {
+	if r.err != nil {
+		return
+	}
+	d, err := NewDIB(img, nil)
+	if err != nil {
+		r.err = fmt.Errorf("emf: %v", err)
+		return
+	}
+	r.StretchDIBits(x, y, cx, cy, 0, 0, int32(d.Width()), int32(d.Height()), d, SRCCOPY)
+}
+
+// Comment records an EMR_GDICOMMENT with private data.
+func (r *EMFRecorder) Comment(data []byte) {
+	r.Record(&EMRComment{EMR{IType: EMR_GDICOMMENT}, data})
+}
+
+// Bounds returns the inclusive bounds of everything drawn so far, in
+// reference device pixels. It is {0, 0, -1, -1} if nothing was drawn.
+func (r *EMFRecorder) Bounds() RECT {
+	if !r.drawn {
+		return RECT{0, 0, -1, -1}
+	}
+	return r.bounds
+}
+
+// Bytes encodes the metafile recorded so far, which may be passed to
+// ParseEMF or SetEnhMetaFileBits or written to a .emf file. Recording may
+// continue afterwards.
+func (r *EMFRecorder) Bytes() ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	var desc []byte
+	if r.opts.Application != "" || r.opts.Title != "" {
+		desc = utf16Bytes(r.opts.Application+"\x00"+r.opts.Title+"\x00", true)
+	}
+	const hdrSize = 108
+	size := hdrSize + (len(desc)+3)&^3
+	const eofSize = 20
+
+	hdr := ENHMETAHEADER{
+		IType:          EMR_HEADER,
+		NSize:          uint32(size),
+		RclBounds:      r.Bounds(),
+		RclFrame:       r.frame(),
+		DSignature:     ENHMETA_SIGNATURE,
+		NVersion:       0x10000,
+		NBytes:         uint32(size + r.body.b.Len() + eofSize),
+		NRecords:       r.nRecords + 2,
+		NHandles:       uint16(len(r.objects)),
+		SzlDevice:      r.opts.Device,
+		SzlMillimeters: r.opts.Millimeters,
+		SzlMicrometers: SIZE{r.opts.Millimeters.CX * 1000, r.opts.Millimeters.CY * 1000},
+	}
+	if desc != nil {
+		hdr.NDescription = uint32(len(desc) / 2)
+		hdr.OffDescription = hdrSize
+	}
+
+	var w resWriter
+	binary.Write(&w.b, binary.LittleEndian, &hdr)
+	w.b.Write(desc)
+	w.align(4)
+	w.b.Write(r.body.b.Bytes())
+	w.u32(EMR_EOF)
+	w.u32(eofSize)
+	w.u32(0)  // nPalEntries
+	w.u32(16) // offPalEntries
+	w.u32(eofSize)
+	return w.b.Bytes(), nil
+}
+
+// frame returns the picture frame: EMFOptions.Frame, or the bounds
+// converted to .01 millimeter units.
+func (r *EMFRecorder) frame() RECT {
+	if f := r.opts.Frame; f != (RECT{}) {
+		return f
+	}
+	if !r.drawn {
+		return RECT{}
+	}
+	sx := float64(r.opts.Millimeters.CX) * 100 / float64(r.opts.Device.CX)
+	sy := float64(r.opts.Millimeters.CY) * 100 / float64(r.opts.Device.CY)
+	b := r.bounds
+	return RECT{
+		int32(math.Round(float64(b.Left) * sx)),
+		int32(math.Round(float64(b.Top) * sy)),
+		int32(math.Round(float64(b.Right) * sx)),
+		int32(math.Round(float64(b.Bottom) * sy)),
+	}
+}
+
+// emfWriter encodes record parameters.
+type emfWriter struct {
+	resWriter
+}
+
+func (w *emfWriter) i32(v int32) {
+	w.u32(uint32(v))
+}
+
+func (w *emfWriter) f32(v float32) {
+	w.u32(math.Float32bits(v))
+}
+
+func (w *emfWriter) point(p POINT) {
+	w.i32(p.X)
+	w.i32(p.Y)
+}
+
+func (w *emfWriter) size(s SIZE) {
+	w.i32(s.CX)
+	w.i32(s.CY)
+}
+
+func (w *emfWriter) rect(rc RECT) {
+	w.i32(rc.Left)
+	w.i32(rc.Top)
+	w.i32(rc.Right)
+	w.i32(rc.Bottom)
+}
+
+func (w *emfWriter) xform(x XFORM) {
+	for _, v := range [...]float32{x.EM11, x.EM12, x.EM21, x.EM22, x.EDx, x.EDy} {
+		w.f32(v)
+	}
+}
+
+// points writes POINTL or, with short set, POINTS values.
+func (w *emfWriter) points(pts []POINT, short bool) error {
+	for _, p := range pts {
+		if !short {
+			w.point(p)
+			continue
+		}
+		if p.X != int32(int16(p.X)) || p.Y != int32(int16(p.Y)) {
+			return fmt.Errorf("point (%d, %d) does not fit a 16-bit record", p.X, p.Y)
+		}
+		w.u16(uint16(p.X))
+		w.u16(uint16(p.Y))
+	}
+	return nil
+}
+
+func (w *emfWriter) palette(entries []PALETTEENTRY) {
+	for _, e := range entries {
+		w.b.Write([]byte{e.PeRed, e.PeGreen, e.PeBlue, e.PeFlags})
+	}
+}
+
+// emfBitmap splits a bitmap into the BITMAPINFO and bits stored in a
+// record. Color profiles, which would need to follow the bits, are
+// dropped.
+func emfBitmap(d *DIB) (info, bits []byte, err error) {
+	if d == nil {
+		return nil, nil, nil
+	}
+	if err := d.check(); err != nil {
+		return nil, nil, err
+	}
+	if d.Profile != nil {
+		c := *d
+		c.Profile = nil
+		if c.ColorSpace == PROFILE_EMBEDDED || c.ColorSpace == PROFILE_LINKED {
+			c.ColorSpace = LCS_sRGB
+		}
+		d = &c
+	}
+	return d.InfoBytes(), d.Bits, nil
+}
+
+// dibRef writes the offBmi, cbBmi, offBits and cbBits fields for a bitmap
+// stored at offset off of the record.
+func (w *emfWriter) dibRef(info, bits []byte, off int) {
+	if info == nil {
+		w.b.Write(make([]byte, 16))
+		return
+	}
+	w.u32(uint32(off))
+	w.u32(uint32(len(info)))
+	w.u32(uint32(off + (len(info)+3)&^3))
+	w.u32(uint32(len(bits)))
+}
+
+func (w *emfWriter) dibData(info, bits []byte) {
+	w.b.Write(info)
+	w.align(4)
+	w.b.Write(bits)
+	w.align(4)
+}
+
+// encodeEMFRecord encodes rec, computing its size.
+func encodeEMFRecord(rec EMFRecord) ([]byte, error) {
+	typ := rec.RecordType()
+	if typ < EMR_MIN || typ > EMR_MAX {
+		return nil, fmt.Errorf("bad record type %d", typ)
+	}
+	w := new(emfWriter)
+	w.u32(typ)
+	w.u32(0) // nSize, patched below
+
+	switch rec := rec.(type) {
+	case *EMRRaw:
+		w.b.Write(rec.Data)
+
+	case *EMRPoly:
+		w.rect(rec.Bounds)
+		w.u32(uint32(len(rec.Points)))
+		if err := w.points(rec.Points, typ >= EMR_POLYBEZIER16); err != nil {
+			return nil, err
+		}
+
+	case *EMRPolyPoly:
+		var total uint64
+		for _, n := range rec.Counts {
+			total += uint64(n)
+		}
+		if total != uint64(len(rec.Points)) {
+			return nil, errors.New("polygon point counts do not add up")
+		}
+		w.rect(rec.Bounds)
+		w.u32(uint32(len(rec.Counts)))
+		w.u32(uint32(len(rec.Points)))
+		for _, n := range rec.Counts {
+			w.u32(n)
+		}
+		if err := w.points(rec.Points, typ >= EMR_POLYPOLYLINE16); err != nil {
+			return nil, err
+		}
+
+	case *EMRExtTextOut:
+		var text []byte
+		var n int
+		if typ == EMR_EXTTEXTOUTW {
+			text = utf16Bytes(rec.Text, false)
+			n = len(text) / 2
+		} else {
+			var err error
+			if text, err = encodeANSI(rec.Text); err != nil {
+				return nil, err
+			}
+			n = len(text)
+		}
+		if rec.Dx != nil {
+			want := n
+			if rec.Options&ETO_PDY != 0 {
+				want *= 2
+			}
+			if len(rec.Dx) != want {
+				return nil, fmt.Errorf("%d character advances for %d characters", len(rec.Dx), n)
+			}
+		}
+		const fixed = 76
+		offDx := 0
+		if rec.Dx != nil {
+			offDx = fixed + (len(text)+3)&^3
+		}
+		w.rect(rec.Bounds)
+		w.u32(rec.GraphicsMode)
+		w.f32(rec.XScale)
+		w.f32(rec.YScale)
+		w.point(rec.Reference)
+		w.u32(uint32(n))
+		w.u32(fixed)
+		w.u32(rec.Options)
+		w.rect(rec.Rect)
+		w.u32(uint32(offDx))
+		w.b.Write(text)
+		w.align(4)
+		for _, d := range rec.Dx {
+			w.i32(d)
+		}
+
+	case *EMRBitBlt:
+		info, bits, err := emfBitmap(rec.Source)
+		if err != nil {
+			return nil, err
+		}
+		fixed := 108
+		if typ == EMR_BITBLT {
+			fixed = 100
+		}
+		w.rect(rec.Bounds)
+		w.i32(rec.XDest)
+		w.i32(rec.YDest)
+		w.i32(rec.CxDest)
+		w.i32(rec.CyDest)
+		w.u32(rec.Rop)
+		w.i32(rec.XSrc)
+		w.i32(rec.YSrc)
+		w.xform(rec.XformSrc)
+		w.u32(uint32(rec.BkColorSrc))
+		w.u32(rec.UsageSrc)
+		w.dibRef(info, bits, fixed)
+		if typ != EMR_BITBLT {
+			w.i32(rec.CxSrc)
+			w.i32(rec.CySrc)
+		}
+		w.dibData(info, bits)
+
+	case *EMRStretchDIBits:
+		info, bits, err := emfBitmap(rec.Source)
+		if err != nil {
+			return nil, err
+		}
+		w.rect(rec.Bounds)
+		w.i32(rec.XDest)
+		w.i32(rec.YDest)
+		w.i32(rec.XSrc)
+		w.i32(rec.YSrc)
+		w.i32(rec.CxSrc)
+		w.i32(rec.CySrc)
+		w.dibRef(info, bits, 80)
+		w.u32(rec.UsageSrc)
+		w.u32(rec.Rop)
+		w.i32(rec.CxDest)
+		w.i32(rec.CyDest)
+		w.dibData(info, bits)
+
+	case *EMRObject:
+		w.u32(rec.Index)
+
+	case *EMRCreatePen:
+		w.u32(rec.Index)
+		w.u32(rec.Pen.LopnStyle)
+		w.point(rec.Pen.LopnWidth)
+		w.u32(uint32(rec.Pen.LopnColor))
+
+	case *EMRExtCreatePen:
+		info, bits, err := emfBitmap(rec.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		w.u32(rec.Index)
+		w.dibRef(info, bits, 52+4*len(rec.StyleEntries))
+		w.u32(rec.Style)
+		w.u32(rec.Width)
+		w.u32(rec.BrushStyle)
+		w.u32(uint32(rec.Color))
+		w.u32(rec.Hatch)
+		w.u32(uint32(len(rec.StyleEntries)))
+		for _, e := range rec.StyleEntries {
+			w.u32(e)
+		}
+		w.dibData(info, bits)
+
+	case *EMRCreateBrushIndirect:
+		w.u32(rec.Index)
+		w.u32(rec.Brush.LbStyle)
+		w.u32(uint32(rec.Brush.LbColor))
+		w.u32(rec.Brush.LbHatch)
+
+	case *EMRCreateDIBPatternBrush:
+		if rec.Pattern == nil {
+			return nil, errors.New("pattern brush without a bitmap")
+		}
+		info, bits, err := emfBitmap(rec.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		w.u32(rec.Index)
+		w.u32(rec.Usage)
+		w.dibRef(info, bits, 32)
+		w.dibData(info, bits)
+
+	case *EMRExtCreateFontIndirect:
+		w.u32(rec.Index)
+		binary.Write(&w.b, binary.LittleEndian, &rec.Font)
+
+	case *EMRCreatePalette:
+		if len(rec.Entries) > 0xFFFF {
+			return nil, errors.New("too many palette entries")
+		}
+		w.u32(rec.Index)
+		w.u16(0x300) // palVersion
+		w.u16(uint16(len(rec.Entries)))
+		w.palette(rec.Entries)
+
+	case *EMRPoint:
+		w.point(rec.Point)
+
+	case *EMRSize:
+		w.size(rec.Size)
+
+	case *EMRScaleExt:
+		w.i32(rec.XNum)
+		w.i32(rec.XDenom)
+		w.i32(rec.YNum)
+		w.i32(rec.YDenom)
+
+	case *EMRSetPixel:
+		w.point(rec.Point)
+		w.u32(uint32(rec.Color))
+
+	case *EMRRect:
+		w.rect(rec.Rect)
+
+	case *EMRRoundRect:
+		w.rect(rec.Rect)
+		w.size(rec.Corner)
+
+	case *EMRArc:
+		w.rect(rec.Box)
+		w.point(rec.Start)
+		w.point(rec.End)
+
+	case *EMRAngleArc:
+		w.point(rec.Center)
+		w.u32(rec.Radius)
+		w.f32(rec.StartAngle)
+		w.f32(rec.SweepAngle)
+
+	case *EMRXform:
+		w.xform(rec.Xform)
+		if typ == EMR_MODIFYWORLDTRANSFORM {
+			w.u32(rec.Mode)
+		}
+
+	case *EMRRestoreDC:
+		w.i32(rec.SavedDC)
+
+	case *EMRSetMiterLimit:
+		w.f32(rec.Limit)
+
+	case *EMRMode:
+		w.u32(rec.Mode)
+
+	case *EMRColor:
+		w.u32(uint32(rec.Color))
+
+	case *EMRExtSelectClipRgn:
+		w.u32(uint32(len(rec.RgnData)))
+		w.u32(rec.Mode)
+		w.b.Write(rec.RgnData)
+
+	case *EMRComment:
+		w.u32(uint32(len(rec.Data)))
+		w.b.Write(rec.Data)
+
+	default:
+		return nil, fmt.Errorf("cannot encode %T", rec)
+	}
+
+	w.align(4)
+	data := w.b.Bytes()
+	if len(data) > maxEMFSize {
+		return nil, errors.New("record is too large")
+	}
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)))
+
+	// Check the encoding against the decoder, which catches records whose
+	// fields do not match their type.
+	if _, err := decodeEMFRecord(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"unicode/utf16"
+)
+
+// FontSpec builds the LOGFONT for CreateFontIndirect from a face name
+// and a size in points:
+//
+//	lf := NewFontSpec("Segoe UI", 9).Bold().Quality(CLEARTYPE_QUALITY).LOGFONT(96)
+//
+// The setters modify and return the spec.
+type FontSpec struct {
+	lf     LOGFONT
+	points int
+}
+
+// NewFontSpec returns a spec for a regular font of the given face and
+// point size in the default character set.
+func NewFontSpec(face string, points int) *FontSpec {
+	f := &FontSpec{points: points}
+	f.lf.Weight = FW_NORMAL
+	f.lf.CharSet = DEFAULT_CHARSET
+	f.lf.SetFaceName(face)
+	return f
+}
+
+// Height sets lfHeight directly in logical units, overriding the point
+// size: positive values give the cell height, negative ones the character
+// height.
+func (f *FontSpec) Height(height int32) *FontSpec {
+	f.lf.Height, f.points = height, 0
+	return f
+}
+
+// Weight sets the weight, one of the FW_* values.
+func (f *FontSpec) Weight(weight int32) *FontSpec {
+	f.lf.Weight = weight
+	return f
+}
+
+func (f *FontSpec) Bold() *FontSpec {
+	return f.Weight(FW_BOLD)
+}
+
+func (f *FontSpec) Italic() *FontSpec {
+	f.lf.Italic = 1
+	return f
+}
+
+func (f *FontSpec) Underline() *FontSpec {
+	f.lf.Underline = 1
+	return f
+}
+
+func (f *FontSpec) StrikeOut() *FontSpec {
+	f.lf.StrikeOut = 1
+	return f
+}
+
+// Quality sets the output quality, one of the *_QUALITY values.
+func (f *FontSpec) Quality(quality byte) *FontSpec {
+	f.lf.Quality = quality
+	return f
+}
+
+// CharSet sets the character set, one of the *_CHARSET values.
+func (f *FontSpec) CharSet(charSet byte) *FontSpec {
+	f.lf.CharSet = charSet
+	return f
+}
+
+// PitchAndFamily sets a *_PITCH value ORed with an FF_* family, used to
+// pick a font when the face is not installed.
+func (f *FontSpec) PitchAndFamily(pitchAndFamily byte) *FontSpec {
+	f.lf.PitchAndFamily = pitchAndFamily
+	return f
+}
+
+// Escapement sets the angle of the baseline in tenths of a degree.
+func (f *FontSpec) Escapement(tenths int32) *FontSpec {
+	f.lf.Escapement, f.lf.Orientation = tenths, tenths
+	return f
+}
+
+// LOGFONT returns the LOGFONT for a device with dpi pixels per logical
+// inch vertically, as reported by GetDeviceCaps(hdc, LOGPIXELSY).
+func (f *FontSpec) LOGFONT(dpi int) LOGFONT {
+	lf := f.lf
+	if f.points != 0 {
+		lf.Height = PointsToHeight(f.points, dpi)
+	}
+	return lf
+}
+
+// PointsToHeight returns the lfHeight that selects a font of the given
+// point size at dpi pixels per inch. As in the Windows documentation it
+// is -MulDiv(points, dpi, 72), which rounds halves away from zero.
+func PointsToHeight(points, dpi int) int32 {
+	return -mulDiv(int32(points), int32(dpi), 72)
+}
+
+// HeightToPoints is the inverse of PointsToHeight, for the character
+// height of a font, such as a negative lfHeight or TEXTMETRIC's TmHeight
+// less TmInternalLeading.
+func HeightToPoints(height int32, dpi int) int {
+	if height < 0 {
+		height = -height
+	}
+	return int(mulDiv(height, 72, int32(dpi)))
+}
+
+// mulDiv computes a*b/c with a 64-bit intermediate, rounding halves away
+// from zero, and returns -1 if c is zero or the result overflows, like
+// kernel32's MulDiv.
+func mulDiv(a, b, c int32) int32 {
+	if c == 0 {
+		return -1
+	}
+	n, d := int64(a)*int64(b), int64(c)
+	neg := n < 0 != (d < 0)
+	if n < 0 {
+		n = -n
+	}
+	if d < 0 {
+		d = -d
+	}
+	q := (n + d/2) / d
+	if neg {
+		q = -q
+	}
+	if q < -1<<31 || q > 1<<31-1 {
+		return -1
+	}
+	return int32(q)
+}
+
+// SetFaceName stores name in FaceName, truncated to the 31 UTF-16 code
+// units that fit before the terminating NUL without splitting a surrogate
+// pair.
+func (lf *LOGFONT) SetFaceName(name string) {
+	putUTF16(lf.FaceName[:], name)
+}
+
+// GetFaceName returns FaceName up to its terminating NUL.
+func (lf *LOGFONT) GetFaceName() string {
+	return getUTF16(lf.FaceName[:])
+}
+
+// GetFullName returns the unique name of the font, such as "Arial Bold".
+func (elf *ENUMLOGFONTEX) GetFullName() string {
+	return getUTF16(elf.FullName[:])
+}
+
+// GetStyle returns the style name, such as "Bold Italic".
+func (elf *ENUMLOGFONTEX) GetStyle() string {
+	return getUTF16(elf.Style[:])
+}
+
+// GetScript returns the name of the character set, such as "Western".
+func (elf *ENUMLOGFONTEX) GetScript() string {
+	return getUTF16(elf.Script[:])
+}
+
+// putUTF16 stores s NUL terminated in buf, dropping whole characters from
+// its end if it does not fit.
+func putUTF16(buf []uint16, s string) {
+	u := utf16.Encode([]rune(s))
+	if len(u) > len(buf)-1 {
+		u = u[:len(buf)-1]
+		if n := len(u); n > 0 && utf16.IsSurrogate(rune(u[n-1])) && u[n-1] < 0xDC00 {
+			u = u[:n-1]
+		}
+	}
+	n := copy(buf, u)
+	for i := n; i < len(buf); i++ {
+		buf[i] = 0
+	}
+}
+
+func getUTF16(buf []uint16) string {
+	for i, c := range buf {
+		if c == 0 {
+			buf = buf[:i]
+			break
+		}
+	}
+	return string(utf16.Decode(buf))
+}
@@ -0,0 +1,162 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestMulDiv(t *testing.T) {
+	tests := []struct {
+		a, b, c, want int32
+	}{
+		{9, 96, 72, 12},
+		{10, 96, 72, 13}, // 13.33
+		{11, 96, 72, 15}, // 14.67
+
+		// Halves round away from zero, whatever the signs.
+		{7, 1, 2, 4},
+		{-7, 1, 2, -4},
+		{7, -1, 2, -4},
+		{7, 1, -2, -4},
+		{-7, -1, 2, 4},
+		{-7, 1, -2, 4},
+		{-7, -1, -2, -4},
+		{5, 3, 2, 8},
+		{-5, 3, 2, -8},
+		{1, 1, 3, 0},
+		{-1, 1, 3, 0},
+		{-1, 1, 1, -1},
+
+		// Division by zero.
+		{1, 1, 0, -1},
+		{0, 0, 0, -1},
+
+		// The 64-bit intermediate does not overflow, but the result may.
+		{math.MaxInt32, math.MaxInt32, math.MaxInt32, math.MaxInt32},
+		{math.MinInt32, math.MinInt32, math.MinInt32, math.MinInt32},
+		{math.MaxInt32, 2, 4, 1 << 30},
+		{-(1 << 30), 2, 1, math.MinInt32},
+		{1 << 30, 2, 1, -1},
+		{1 << 30, 4, 1, -1},
+		{math.MinInt32, -1, 1, -1},
+		{math.MinInt32, 1, -1, -1},
+	}
+	for _, tt := range tests {
+		if got := mulDiv(tt.a, tt.b, tt.c); got != tt.want {
+			t.Errorf("mulDiv(%d, %d, %d) = %d, want %d", tt.a, tt.b, tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestPointsToHeight(t *testing.T) {
+	tests := []struct {
+		points, dpi int
+		height      int32
+	}{
+		{8, 96, -11}, // 10.67
+		{9, 96, -12},
+		{10, 96, -13}, // 13.33
+		{12, 96, -16},
+		{9, 120, -15},
+		{10, 120, -17}, // 16.67
+		{11, 120, -18}, // 18.33
+		{9, 144, -18},
+		{10, 144, -20},
+		{11, 144, -22},
+		{0, 96, 0},
+	}
+	for _, tt := range tests {
+		if got := PointsToHeight(tt.points, tt.dpi); got != tt.height {
+			t.Errorf("PointsToHeight(%d, %d) = %d, want %d", tt.points, tt.dpi, got, tt.height)
+		}
+	}
+
+	for _, tt := range []struct {
+		height int32
+		dpi    int
+		points int
+	}{
+		{-13, 96, 10},  // 9.75
+		{13, 96, 10},   // the sign is ignored
+		{-14, 96, 11},  // 10.5
+		{-17, 120, 10}, // 10.2
+		{-18, 144, 9},
+		{-21, 144, 11}, // 10.5
+		{0, 96, 0},
+		{-12, 0, -1},
+	} {
+		if got := HeightToPoints(tt.height, tt.dpi); got != tt.points {
+			t.Errorf("HeightToPoints(%d, %d) = %d, want %d", tt.height, tt.dpi, got, tt.points)
+		}
+	}
+
+	// At 72 dpi and above, converting back gives the point size.
+	for _, dpi := range []int{72, 96, 120, 144, 192} {
+		for points := 1; points <= 96; points++ {
+			if got := HeightToPoints(PointsToHeight(points, dpi), dpi); got != points {
+				t.Errorf("%d points at %d dpi come back as %d", points, dpi, got)
+			}
+		}
+	}
+
+	lf := NewFontSpec("Segoe UI", 9).Bold().LOGFONT(120)
+	if lf.Height != -15 || lf.Weight != FW_BOLD || lf.CharSet != DEFAULT_CHARSET || lf.GetFaceName() != "Segoe UI" {
+		t.Errorf("LOGFONT(120) = %+v", lf)
+	}
+	if lf := NewFontSpec("Arial", 9).Height(20).LOGFONT(144); lf.Height != 20 {
+		t.Errorf("Height(20) gives lfHeight %d at 144 dpi", lf.Height)
+	}
+}
+
+func TestPutUTF16(t *testing.T) {
+	a, b := strings.Repeat("a", 30), strings.Repeat("b", 29)
+	tests := []struct {
+		name, in, want string
+	}{
+		{"short", "Arial", "Arial"},
+		{"empty", "", ""},
+		{"31 units", a + "c", a + "c"},
+		{"32 units", a + "cd", a + "c"},
+		// A surrogate pair that would lose its low half is dropped.
+		{"split pair", a + "😀x", a},
+		{"whole pair", b + "😀x", b + "😀"},
+		{"pair at the end", b + "😀", b + "😀"},
+		{"only pairs", strings.Repeat("😀", 16), strings.Repeat("😀", 15)},
+		{"BMP characters", strings.Repeat("é", 40), strings.Repeat("é", 31)},
+	}
+	for _, tt := range tests {
+		var lf LOGFONT
+		// Fill the buffer so that stale code units would show.
+		for i := range lf.FaceName {
+			lf.FaceName[i] = 'z'
+		}
+		lf.SetFaceName(tt.in)
+		if got := lf.GetFaceName(); got != tt.want {
+			t.Errorf("%s: SetFaceName(%q) stores %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+		n := len(utf16.Encode([]rune(tt.want)))
+		for i := n; i < len(lf.FaceName); i++ {
+			if lf.FaceName[i] != 0 {
+				t.Errorf("%s: FaceName[%d] = %#x after the name", tt.name, i, lf.FaceName[i])
+				break
+			}
+		}
+	}
+
+	buf := make([]uint16, 1)
+	putUTF16(buf, "x")
+	if buf[0] != 0 {
+		t.Errorf("putUTF16 into one unit stores %#x", buf[0])
+	}
+	// getUTF16 stops at the end of a buffer without a NUL.
+	full := utf16.Encode([]rune("abc"))
+	if got := getUTF16(full); got != "abc" {
+		t.Errorf("getUTF16(%v) = %q", full, got)
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"time"
+)
+
+// dwmTimingInfoSize is the size of DWM_TIMING_INFO, which dwmapi.h packs
+// to 1 byte, unlike the Go struct.
+const dwmTimingInfoSize = 292
+
+// packedFields returns pointers to the fields of t in the order of the
+// packed C struct.
+func (t *DWM_TIMING_INFO) packedFields() []interface{} {
+	return []interface{}{
+		&t.cbSize,
+		&t.rateRefresh.uiNumerator, &t.rateRefresh.uiDenominator,
+		&t.qpcRefreshPeriod,
+		&t.rateCompose.uiNumerator, &t.rateCompose.uiDenominator,
+		&t.qpcVBlank, &t.cRefresh, &t.cDXRefresh, &t.qpcCompose,
+		&t.cFrame, &t.cDXPresent, &t.cRefreshFrame,
+		&t.cFrameSubmitted, &t.cDXPresentSubmitted,
+		&t.cFrameConfirmed, &t.cDXPresentConfirmed,
+		&t.cRefreshConfirmed, &t.cDXRefreshConfirmed,
+		&t.cFramesLate, &t.cFramesOutstanding,
+		&t.cFrameDisplayed, &t.qpcFrameDisplayed, &t.cRefreshFrameDisplayed,
+		&t.cFrameComplete, &t.qpcFrameComplete,
+		&t.cFramePending, &t.qpcFramePending,
+		&t.cFramesDisplayed, &t.cFramesComplete, &t.cFramesPending,
+		&t.cFramesAvailable, &t.cFramesDropped, &t.cFramesMissed,
+		&t.cRefreshNextDisplayed, &t.cRefreshNextPresented,
+		&t.cRefreshesDisplayed, &t.cRefreshesPresented, &t.cRefreshStarted,
+		&t.cPixelsReceived, &t.cPixelsDrawn, &t.cBuffersEmpty,
+	}
+}
+
+// marshal writes t in the packed layout to b, which must be
+// dwmTimingInfoSize bytes long.
+func (t *DWM_TIMING_INFO) marshal(b []byte) {
+	le := binary.LittleEndian
+	for _, f := range t.packedFields() {
+		switch f := f.(type) {
+		case *uint32:
+			le.PutUint32(b, *f)
+			b = b[4:]
+		case *QPC_TIME:
+			le.PutUint64(b, uint64(*f))
+			b = b[8:]
+		case *DWM_FRAME_COUNT:
+			le.PutUint64(b, uint64(*f))
+			b = b[8:]
+		case *uint64:
+			le.PutUint64(b, *f)
+			b = b[8:]
+		}
+	}
+}
+
+// unmarshal reads t from b in the packed layout.
+func (t *DWM_TIMING_INFO) unmarshal(b []byte) {
+	le := binary.LittleEndian
+	for _, f := range t.packedFields() {
+		switch f := f.(type) {
+		case *uint32:
+			*f = le.Uint32(b)
+			b = b[4:]
+		case *QPC_TIME:
+			*f = QPC_TIME(le.Uint64(b))
+			b = b[8:]
+		case *DWM_FRAME_COUNT:
+			*f = DWM_FRAME_COUNT(le.Uint64(b))
+			b = b[8:]
+		case *uint64:
+			*f = le.Uint64(b)
+			b = b[8:]
+		}
+	}
+}
+
+// FrameTimingSample holds the fields of DWM_TIMING_INFO that
+// FramePacingAnalyzer uses. Times are in QPC ticks.
+type FrameTimingSample struct {
+	RefreshNumerator   uint32 // monitor refresh rate as a ratio, 0/0 if unknown
+	RefreshDenominator uint32
+	RefreshPeriod      QPC_TIME
+	VBlank             QPC_TIME // time of the last vertical blank
+	Refresh            DWM_FRAME_COUNT
+	FrameComplete      DWM_FRAME_COUNT // last frame composed
+	CompleteTime       QPC_TIME
+	FrameDisplayed     DWM_FRAME_COUNT // last frame shown on the monitor
+	DisplayedTime      QPC_TIME
+	FramesDisplayed    DWM_FRAME_COUNT // running totals
+	FramesDropped      DWM_FRAME_COUNT
+	FramesLate         DWM_FRAME_COUNT
+	FramesMissed       DWM_FRAME_COUNT
+}
+
+// Sample returns the fields of t used by FramePacingAnalyzer.
+func (t *DWM_TIMING_INFO) Sample() FrameTimingSample {
+	return FrameTimingSample{
+		RefreshNumerator:   t.rateRefresh.uiNumerator,
+		RefreshDenominator: t.rateRefresh.uiDenominator,
+		RefreshPeriod:      t.qpcRefreshPeriod,
+		VBlank:             t.qpcVBlank,
+		Refresh:            t.cRefresh,
+		FrameComplete:      t.cFrameComplete,
+		CompleteTime:       t.qpcFrameComplete,
+		FrameDisplayed:     t.cFrameDisplayed,
+		DisplayedTime:      t.qpcFrameDisplayed,
+		FramesDisplayed:    t.cFramesDisplayed,
+		FramesDropped:      t.cFramesDropped,
+		FramesLate:         t.cFramesLate,
+		FramesMissed:       t.cFramesMissed,
+	}
+}
+
+// FramePacingStats summarizes a set of durations.
+type FramePacingStats struct {
+	Count              int
+	Mean               time.Duration
+	P50, P90, P99, Max time.Duration
+}
+
+// FramePacingReport is the result of a FramePacingAnalyzer.
+type FramePacingReport struct {
+	Samples     int
+	Duration    time.Duration // from the first to the last vertical blank
+	RefreshRate float64       // in Hz
+	FrameRate   float64       // displayed frames per second
+
+	FramesDisplayed, FramesDropped, FramesLate, FramesMissed uint64
+
+	// Latency is the time from the composition of a frame to its display.
+	Latency FramePacingStats
+	// FrameInterval is the time between two displayed frames and Jitter
+	// its distance from the mean interval.
+	FrameInterval, Jitter FramePacingStats
+}
+
+// FramePacingAnalyzer computes frame pacing statistics from a stream of
+// DWM timing samples. Samples should be taken more often than frames are
+// displayed; when several frames are displayed between two samples, they
+// count as one interval of the average length.
+type FramePacingAnalyzer struct {
+	freq      uint64
+	samples   int
+	first     FrameTimingSample
+	last      FrameTimingSample
+	completed map[DWM_FRAME_COUNT]QPC_TIME // composition times of frames not yet displayed
+
+	displayed, dropped, late, missed uint64
+	latencies, intervals             []uint64
+}
+
+// NewFramePacingAnalyzer returns an analyzer for samples taken with the
+// given QPC frequency, as returned by QueryPerformanceFrequency.
+func NewFramePacingAnalyzer(qpcFrequency int64) (*FramePacingAnalyzer, error) {
+	if qpcFrequency <= 0 {
+		return nil, errors.New("QPC frequency must be positive")
+	}
+	a := &FramePacingAnalyzer{freq: uint64(qpcFrequency)}
+	a.Reset()
+	return a, nil
+}
+
+// Reset forgets all samples.
+func (a *FramePacingAnalyzer) Reset() {
+	*a = FramePacingAnalyzer{freq: a.freq, completed: make(map[DWM_FRAME_COUNT]QPC_TIME)}
+}
+
+// counterDelta returns how much a running total grew, treating a smaller
+// value as a counter restarted from 0.
+func counterDelta(cur, prev DWM_FRAME_COUNT) uint64 {
+	if cur < prev {
+		return uint64(cur)
+	}
+	return uint64(cur - prev)
+}
+
+// Add adds a sample. Samples taken within the same refresh as the
+// previous one are ignored.
+func (a *FramePacingAnalyzer) Add(s FrameTimingSample) {
+	if s.FrameComplete != 0 && s.CompleteTime != 0 {
+		if _, ok := a.completed[s.FrameComplete]; !ok {
+			a.completed[s.FrameComplete] = s.CompleteTime
+		}
+	}
+	if a.samples == 0 {
+		a.first, a.last = s, s
+		a.samples = 1
+		a.frameDisplayed(s)
+		return
+	}
+	p := a.last
+	if s.Refresh == p.Refresh && s.VBlank == p.VBlank && s.FrameDisplayed == p.FrameDisplayed {
+		return
+	}
+	a.samples++
+	a.last = s
+	a.displayed += counterDelta(s.FramesDisplayed, p.FramesDisplayed)
+	a.dropped += counterDelta(s.FramesDropped, p.FramesDropped)
+	a.late += counterDelta(s.FramesLate, p.FramesLate)
+	a.missed += counterDelta(s.FramesMissed, p.FramesMissed)
+	if s.FrameDisplayed > p.FrameDisplayed && s.DisplayedTime > p.DisplayedTime && p.DisplayedTime != 0 {
+		n := uint64(s.FrameDisplayed - p.FrameDisplayed)
+		a.intervals = append(a.intervals, uint64(s.DisplayedTime-p.DisplayedTime)/n)
+	}
+	if s.FrameDisplayed != p.FrameDisplayed {
+		a.frameDisplayed(s)
+	}
+}
+
+// frameDisplayed records the latency of the frame displayed in s and
+// forgets the composition times of older frames.
+func (a *FramePacingAnalyzer) frameDisplayed(s FrameTimingSample) {
+	if t, ok := a.completed[s.FrameDisplayed]; ok && s.DisplayedTime >= t {
+		a.latencies = append(a.latencies, uint64(s.DisplayedTime-t))
+	}
+	for f := range a.completed {
+		if f <= s.FrameDisplayed {
+			delete(a.completed, f)
+		}
+	}
+}
+
+// duration converts QPC ticks to a time.Duration.
+func (a *FramePacingAnalyzer) duration(ticks uint64) time.Duration {
+	sec, rem := ticks/a.freq, ticks%a.freq
+	return time.Duration(sec)*time.Second + time.Duration(rem*uint64(time.Second)/a.freq)
+}
+
+func (a *FramePacingAnalyzer) stats(ticks []uint64) FramePacingStats {
+	st := FramePacingStats{Count: len(ticks)}
+	if len(ticks) == 0 {
+		return st
+	}
+	sorted := append([]uint64(nil), ticks...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum uint64
+	for _, t := range sorted {
+		sum += t
+	}
+	// Nearest-rank percentiles.
+	rank := func(p int) time.Duration {
+		i := (p*len(sorted)+99)/100 - 1
+		if i < 0 {
+			i = 0
+		}
+		return a.duration(sorted[i])
+	}
+	st.Mean = a.duration(sum / uint64(len(sorted)))
+	st.P50, st.P90, st.P99 = rank(50), rank(90), rank(99)
+	st.Max = a.duration(sorted[len(sorted)-1])
+	return st
+}
+
+// Report computes the statistics of the samples added so far.
+func (a *FramePacingAnalyzer) Report() FramePacingReport {
+	r := FramePacingReport{
+		Samples:         a.samples,
+		FramesDisplayed: a.displayed,
+		FramesDropped:   a.dropped,
+		FramesLate:      a.late,
+		FramesMissed:    a.missed,
+		Latency:         a.stats(a.latencies),
+		FrameInterval:   a.stats(a.intervals),
+	}
+	if a.samples == 0 {
+		return r
+	}
+	if a.last.VBlank > a.first.VBlank {
+		r.Duration = a.duration(uint64(a.last.VBlank - a.first.VBlank))
+	}
+	switch s := a.last; {
+	case s.RefreshNumerator != 0 && s.RefreshDenominator != 0:
+		r.RefreshRate = float64(s.RefreshNumerator) / float64(s.RefreshDenominator)
+	case s.RefreshPeriod != 0:
+		r.RefreshRate = float64(a.freq) / float64(s.RefreshPeriod)
+	case s.Refresh > a.first.Refresh && s.VBlank > a.first.VBlank:
+		r.RefreshRate = float64(s.Refresh-a.first.Refresh) * float64(a.freq) / float64(s.VBlank-a.first.VBlank)
+	}
+	if r.Duration > 0 {
+		r.FrameRate = float64(r.FramesDisplayed) / r.Duration.Seconds()
+	}
+	if len(a.intervals) > 0 {
+		var sum uint64
+		for _, t := range a.intervals {
+			sum += t
+		}
+		mean := sum / uint64(len(a.intervals))
+		jitter := make([]uint64, len(a.intervals))
+		for i, t := range a.intervals {
+			if t > mean {
+				jitter[i] = t - mean
+			} else {
+				jitter[i] = mean - t
+			}
+		}
+		r.Jitter = a.stats(jitter)
+	}
+	return r
+}
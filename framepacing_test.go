@@ -0,0 +1,230 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDWMTimingInfoLayout(t *testing.T) {
+	var in DWM_TIMING_INFO
+	fields := in.packedFields()
+	if len(fields) != 42 {
+		t.Fatalf("%d packed fields, want 42", len(fields))
+	}
+	// Give every field a different value with no 0xEE byte in it.
+	for i, f := range fields {
+		switch f := f.(type) {
+		case *uint32:
+			*f = 0x01000000 | uint32(i)
+		case *QPC_TIME:
+			*f = QPC_TIME(0x0100000000000000 | uint64(i)<<32 | uint64(i))
+		case *DWM_FRAME_COUNT:
+			*f = DWM_FRAME_COUNT(0x0200000000000000 | uint64(i)<<32 | uint64(i))
+		case *uint64:
+			*f = 0x0300000000000000 | uint64(i)<<32 | uint64(i)
+		default:
+			t.Fatalf("field %d is a %T", i, f)
+		}
+	}
+
+	b := bytes.Repeat([]byte{0xEE}, dwmTimingInfoSize+8)
+	in.marshal(b)
+	if i := bytes.IndexByte(b, 0xEE); i != dwmTimingInfoSize {
+		t.Errorf("marshal wrote %d bytes, want %d", i, dwmTimingInfoSize)
+	}
+	le := binary.LittleEndian
+	for _, tt := range []struct {
+		name      string
+		off, size int
+		want      uint64
+	}{
+		{"cbSize", 0, 4, uint64(in.cbSize)},
+		{"qpcVBlank", 28, 8, uint64(in.qpcVBlank)},
+		{"cDXRefresh", 44, 4, uint64(in.cDXRefresh)},
+		{"cFrameDisplayed", 124, 8, uint64(in.cFrameDisplayed)},
+		{"cBuffersEmpty", 284, 8, uint64(in.cBuffersEmpty)},
+	} {
+		got := uint64(le.Uint32(b[tt.off:]))
+		if tt.size == 8 {
+			got = le.Uint64(b[tt.off:])
+		}
+		if got != tt.want {
+			t.Errorf("%s at offset %d is %#x, want %#x", tt.name, tt.off, got, tt.want)
+		}
+	}
+
+	var out DWM_TIMING_INFO
+	out.unmarshal(b[:dwmTimingInfoSize])
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip gave %+v, want %+v", out, in)
+	}
+
+	s := in.Sample()
+	if s.VBlank != in.qpcVBlank || s.FrameDisplayed != in.cFrameDisplayed || s.DisplayedTime != in.qpcFrameDisplayed ||
+		s.FramesDropped != in.cFramesDropped || s.RefreshDenominator != in.rateRefresh.uiDenominator {
+		t.Errorf("Sample() = %+v", s)
+	}
+}
+
+func TestCounterDelta(t *testing.T) {
+	for _, tt := range []struct {
+		cur, prev DWM_FRAME_COUNT
+		want      uint64
+	}{
+		{5, 3, 2},
+		{3, 3, 0},
+		{2, 50, 2}, // restarted
+		{0, 50, 0},
+	} {
+		if got := counterDelta(tt.cur, tt.prev); got != tt.want {
+			t.Errorf("counterDelta(%d, %d) = %d, want %d", tt.cur, tt.prev, got, tt.want)
+		}
+	}
+}
+
+// testFrameSamples is a stream sampled with a 1 MHz QPC and a refresh
+// every 16000 ticks.
+func testFrameSamples() []FrameTimingSample {
+	const v0, p = 1000000, 16000
+	return []FrameTimingSample{
+		{Refresh: 100, VBlank: v0, FrameComplete: 10, CompleteTime: v0 - 4000,
+			FrameDisplayed: 9, DisplayedTime: v0, FramesDisplayed: 50, FramesDropped: 2, FramesLate: 1},
+		// Frame 10 takes 20000 ticks to show.
+		{Refresh: 101, VBlank: v0 + p, FrameComplete: 11, CompleteTime: v0 + p - 3000,
+			FrameDisplayed: 10, DisplayedTime: v0 + p, FramesDisplayed: 51, FramesDropped: 2, FramesLate: 1},
+		// Same refresh: ignored, but frame 12 has been composed.
+		{Refresh: 101, VBlank: v0 + p, FrameComplete: 12, CompleteTime: v0 + p + 2000,
+			FrameDisplayed: 10, DisplayedTime: v0 + p, FramesDisplayed: 51, FramesDropped: 2, FramesLate: 1},
+		// Frame 11 is dropped; two frames in two refreshes make one
+		// interval of 16000.
+		{Refresh: 103, VBlank: v0 + 3*p, FrameComplete: 13, CompleteTime: v0 + 3*p - 1000,
+			FrameDisplayed: 12, DisplayedTime: v0 + 3*p,
+			FramesDisplayed: 53, FramesDropped: 3, FramesLate: 2, FramesMissed: 1},
+		{Refresh: 104, VBlank: v0 + 4*p, FrameComplete: 14, CompleteTime: v0 + 4*p + 1000,
+			FrameDisplayed: 13, DisplayedTime: v0 + 4*p + 4000,
+			FramesDisplayed: 54, FramesDropped: 3, FramesLate: 2, FramesMissed: 1},
+		// The running totals restart.
+		{Refresh: 105, VBlank: v0 + 5*p, FrameComplete: 14, CompleteTime: v0 + 4*p + 1000,
+			FrameDisplayed: 14, DisplayedTime: v0 + 5*p, FramesDisplayed: 2},
+	}
+}
+
+func ms(f float64) time.Duration { return time.Duration(f * float64(time.Millisecond)) }
+
+func TestFramePacingAnalyzer(t *testing.T) {
+	if _, err := NewFramePacingAnalyzer(0); err == nil {
+		t.Error("NewFramePacingAnalyzer(0) succeeded")
+	}
+	a, err := NewFramePacingAnalyzer(1000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := a.Report(); !reflect.DeepEqual(r, FramePacingReport{}) {
+		t.Errorf("empty report is %+v", r)
+	}
+
+	samples := testFrameSamples()
+	samples[len(samples)-1].RefreshNumerator = 60000
+	samples[len(samples)-1].RefreshDenominator = 1001
+	for _, s := range samples {
+		a.Add(s)
+	}
+	want := FramePacingReport{
+		Samples:         5,
+		Duration:        ms(80),
+		RefreshRate:     60000.0 / 1001,
+		FrameRate:       75, // 6 frames in 80ms
+		FramesDisplayed: 6,
+		FramesDropped:   1,
+		FramesLate:      1,
+		FramesMissed:    1,
+		// 20000, 30000, 21000 and 15000 ticks.
+		Latency: FramePacingStats{Count: 4, Mean: ms(21.5), P50: ms(20), P90: ms(30), P99: ms(30), Max: ms(30)},
+		// 16000, 16000, 20000 and 12000 ticks.
+		FrameInterval: FramePacingStats{Count: 4, Mean: ms(16), P50: ms(16), P90: ms(20), P99: ms(20), Max: ms(20)},
+		Jitter:        FramePacingStats{Count: 4, Mean: ms(2), P50: 0, P90: ms(4), P99: ms(4), Max: ms(4)},
+	}
+	r := a.Report()
+	if math.Abs(r.FrameRate-want.FrameRate) < 1e-9 {
+		r.FrameRate = want.FrameRate
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("report is\n%+v\nwant\n%+v", r, want)
+	}
+
+	a.Reset()
+	if r := a.Report(); !reflect.DeepEqual(r, FramePacingReport{}) {
+		t.Errorf("report after Reset is %+v", r)
+	}
+}
+
+func TestFramePacingRefreshRate(t *testing.T) {
+	tests := []struct {
+		num, den uint32
+		period   QPC_TIME
+		want     float64
+	}{
+		{120, 1, 20000, 120}, // the reported rate
+		{120, 0, 20000, 50},  // the refresh period
+		{0, 0, 0, 62.5},      // 5 refreshes in 80000 ticks
+	}
+	for _, tt := range tests {
+		a, _ := NewFramePacingAnalyzer(1000000)
+		samples := testFrameSamples()
+		last := &samples[len(samples)-1]
+		last.RefreshNumerator, last.RefreshDenominator, last.RefreshPeriod = tt.num, tt.den, tt.period
+		for _, s := range samples {
+			a.Add(s)
+		}
+		if got := a.Report().RefreshRate; math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%d/%d Hz, period %d: refresh rate %v, want %v", tt.num, tt.den, tt.period, got, tt.want)
+		}
+	}
+
+	// A single sample has no refresh count to go by.
+	a, _ := NewFramePacingAnalyzer(1000000)
+	a.Add(testFrameSamples()[0])
+	if r := a.Report(); r.RefreshRate != 0 || r.Samples != 1 || r.Duration != 0 || r.FrameRate != 0 {
+		t.Errorf("single sample: %+v", r)
+	}
+}
+
+func TestFramePacingStats(t *testing.T) {
+	a, _ := NewFramePacingAnalyzer(1000) // 1 tick is 1ms
+	ticks := func(n int) []uint64 {
+		t := make([]uint64, n)
+		for i := range t {
+			t[i] = uint64(n - i) // unsorted
+		}
+		return t
+	}
+	tests := []struct {
+		ticks []uint64
+		want  FramePacingStats
+	}{
+		{nil, FramePacingStats{}},
+		{[]uint64{7}, FramePacingStats{Count: 1, Mean: ms(7), P50: ms(7), P90: ms(7), P99: ms(7), Max: ms(7)}},
+		{ticks(10), FramePacingStats{Count: 10, Mean: ms(5), P50: ms(5), P90: ms(9), P99: ms(10), Max: ms(10)}},
+		{ticks(100), FramePacingStats{Count: 100, Mean: ms(50), P50: ms(50), P90: ms(90), P99: ms(99), Max: ms(100)}},
+		{ticks(200), FramePacingStats{Count: 200, Mean: ms(100), P50: ms(100), P90: ms(180), P99: ms(198), Max: ms(200)}},
+	}
+	for _, tt := range tests {
+		if got := a.stats(tt.ticks); got != tt.want {
+			t.Errorf("stats of %d values is %+v, want %+v", len(tt.ticks), got, tt.want)
+		}
+	}
+
+	// Tick counts that do not divide evenly into nanoseconds.
+	a, _ = NewFramePacingAnalyzer(3)
+	if got := a.duration(7); got != 2*time.Second+333333333 {
+		t.Errorf("7 ticks at 3 Hz is %v", got)
+	}
+}
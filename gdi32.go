@@ -2,9 +2,12 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+//go:build windows
+
 package w32
 
 import (
+	"sync"
 	"syscall"
 	"unsafe"
 )
@@ -19,21 +22,28 @@ var (
 	procBitBlt                    = modgdi32.NewProc("BitBlt")
 	procCloseEnhMetaFile          = modgdi32.NewProc("CloseEnhMetaFile")
 	procCopyEnhMetaFile           = modgdi32.NewProc("CopyEnhMetaFileW")
+	procCreateBitmap              = modgdi32.NewProc("CreateBitmap")
 	procCreateBrushIndirect       = modgdi32.NewProc("CreateBrushIndirect")
 	procCreateCompatibleDC        = modgdi32.NewProc("CreateCompatibleDC")
 	procCreateDC                  = modgdi32.NewProc("CreateDCW")
 	procCreateDIBSection          = modgdi32.NewProc("CreateDIBSection")
 	procCreateEnhMetaFile         = modgdi32.NewProc("CreateEnhMetaFileW")
 	procCreateIC                  = modgdi32.NewProc("CreateICW")
+	procCreateHatchBrush          = modgdi32.NewProc("CreateHatchBrush")
+	procCreatePen                 = modgdi32.NewProc("CreatePen")
+	procCreateSolidBrush          = modgdi32.NewProc("CreateSolidBrush")
 	procDeleteDC                  = modgdi32.NewProc("DeleteDC")
 	procDeleteEnhMetaFile         = modgdi32.NewProc("DeleteEnhMetaFile")
 	procEllipse                   = modgdi32.NewProc("Ellipse")
 	procEndDoc                    = modgdi32.NewProc("EndDoc")
 	procEndPage                   = modgdi32.NewProc("EndPage")
+	procEnumFontFamiliesEx        = modgdi32.NewProc("EnumFontFamiliesExW")
 	procExtCreatePen              = modgdi32.NewProc("ExtCreatePen")
 	procGetEnhMetaFile            = modgdi32.NewProc("GetEnhMetaFileW")
+	procGetEnhMetaFileBits        = modgdi32.NewProc("GetEnhMetaFileBits")
 	procGetEnhMetaFileHeader      = modgdi32.NewProc("GetEnhMetaFileHeader")
 	procGetObject                 = modgdi32.NewProc("GetObjectW")
+	procGetPixel                  = modgdi32.NewProc("GetPixel")
 	procGetStockObject            = modgdi32.NewProc("GetStockObject")
 	procGetTextExtentExPoint      = modgdi32.NewProc("GetTextExtentExPointW")
 	procGetTextExtentPoint32      = modgdi32.NewProc("GetTextExtentPoint32W")
@@ -46,9 +56,12 @@ var (
 	procSelectObject              = modgdi32.NewProc("SelectObject")
 	procSetBkMode                 = modgdi32.NewProc("SetBkMode")
 	procSetBrushOrgEx             = modgdi32.NewProc("SetBrushOrgEx")
+	procSetPixel                  = modgdi32.NewProc("SetPixel")
+	procSetROP2                   = modgdi32.NewProc("SetROP2")
 	procSetStretchBltMode         = modgdi32.NewProc("SetStretchBltMode")
 	procSetTextColor              = modgdi32.NewProc("SetTextColor")
 	procSetBkColor                = modgdi32.NewProc("SetBkColor")
+	procSetEnhMetaFileBits        = modgdi32.NewProc("SetEnhMetaFileBits")
 	procStartDoc                  = modgdi32.NewProc("StartDocW")
 	procStartPage                 = modgdi32.NewProc("StartPage")
 	procStretchBlt                = modgdi32.NewProc("StretchBlt")
@@ -59,6 +72,30 @@ var (
 	procGetPixelFormat            = modgdi32.NewProc("GetPixelFormat")
 	procSetPixelFormat            = modgdi32.NewProc("SetPixelFormat")
 	procSwapBuffers               = modgdi32.NewProc("SwapBuffers")
+	procPolyline                  = modgdi32.NewProc("Polyline")
+	procPolylineTo                = modgdi32.NewProc("PolylineTo")
+	procPolygon                   = modgdi32.NewProc("Polygon")
+	procPolyPolygon               = modgdi32.NewProc("PolyPolygon")
+	procPolyBezier                = modgdi32.NewProc("PolyBezier")
+	procPolyBezierTo              = modgdi32.NewProc("PolyBezierTo")
+	procArc                       = modgdi32.NewProc("Arc")
+	procPie                       = modgdi32.NewProc("Pie")
+	procChord                     = modgdi32.NewProc("Chord")
+	procRoundRect                 = modgdi32.NewProc("RoundRect")
+	procBeginPath                 = modgdi32.NewProc("BeginPath")
+	procEndPath                   = modgdi32.NewProc("EndPath")
+	procAbortPath                 = modgdi32.NewProc("AbortPath")
+	procCloseFigure               = modgdi32.NewProc("CloseFigure")
+	procStrokePath                = modgdi32.NewProc("StrokePath")
+	procFillPath                  = modgdi32.NewProc("FillPath")
+	procStrokeAndFillPath         = modgdi32.NewProc("StrokeAndFillPath")
+	procPathToRegion              = modgdi32.NewProc("PathToRegion")
+	procGetPath                   = modgdi32.NewProc("GetPath")
+	procSetPolyFillMode           = modgdi32.NewProc("SetPolyFillMode")
+	procSetGraphicsMode           = modgdi32.NewProc("SetGraphicsMode")
+	procSetWorldTransform         = modgdi32.NewProc("SetWorldTransform")
+	procGetWorldTransform         = modgdi32.NewProc("GetWorldTransform")
+	procModifyWorldTransform      = modgdi32.NewProc("ModifyWorldTransform")
 )
 
 func GetDeviceCaps(hdc HDC, index int) int {
@@ -70,9 +107,14 @@ func GetDeviceCaps(hdc HDC, index int) int {
 }
 
 func DeleteObject(hObject HGDIOBJ) bool {
+	r := untrackHandle("DeleteObject", uintptr(hObject))
 	ret, _, _ := procDeleteObject.Call(
 		uintptr(hObject))
 
+	if ret == 0 {
+		restoreHandle(r)
+	}
+
 	return ret != 0
 }
 
@@ -80,6 +122,8 @@ func CreateFontIndirect(logFont *LOGFONT) HFONT {
 	ret, _, _ := procCreateFontIndirect.Call(
 		uintptr(unsafe.Pointer(logFont)))
 
+	trackHandle(HandleKindFont, "CreateFontIndirect", ret)
+
 	return HFONT(ret)
 }
 
@@ -122,10 +166,25 @@ func CopyEnhMetaFile(hemfSrc HENHMETAFILE, lpszFile *uint16) HENHMETAFILE {
 	return HENHMETAFILE(ret)
 }
 
+func CreateBitmap(nWidth, nHeight int, cPlanes, cBitsPerPel uint, lpvBits *byte) HBITMAP {
+	ret, _, _ := procCreateBitmap.Call(
+		uintptr(nWidth),
+		uintptr(nHeight),
+		uintptr(cPlanes),
+		uintptr(cBitsPerPel),
+		uintptr(unsafe.Pointer(lpvBits)))
+
+	trackHandle(HandleKindBitmap, "CreateBitmap", ret)
+
+	return HBITMAP(ret)
+}
+
 func CreateBrushIndirect(lplb *LOGBRUSH) HBRUSH {
 	ret, _, _ := procCreateBrushIndirect.Call(
 		uintptr(unsafe.Pointer(lplb)))
 
+	trackHandle(HandleKindBrush, "CreateBrushIndirect", ret)
+
 	return HBRUSH(ret)
 }
 
@@ -137,6 +196,8 @@ func CreateCompatibleDC(hdc HDC) HDC {
 		panic("Create compatible DC failed")
 	}
 
+	trackHandle(HandleKindDC, "CreateCompatibleDC", ret)
+
 	return HDC(ret)
 }
 
@@ -147,6 +208,8 @@ func CreateDC(lpszDriver, lpszDevice, lpszOutput *uint16, lpInitData *DEVMODE) H
 		uintptr(unsafe.Pointer(lpszOutput)),
 		uintptr(unsafe.Pointer(lpInitData)))
 
+	trackHandle(HandleKindDC, "CreateDC", ret)
+
 	return HDC(ret)
 }
 
@@ -159,6 +222,8 @@ func CreateDIBSection(hdc HDC, pbmi *BITMAPINFO, iUsage uint, ppvBits *unsafe.Po
 		uintptr(hSection),
 		uintptr(dwOffset))
 
+	trackHandle(HandleKindBitmap, "CreateDIBSection", ret)
+
 	return HBITMAP(ret)
 }
 
@@ -182,10 +247,52 @@ func CreateIC(lpszDriver, lpszDevice, lpszOutput *uint16, lpdvmInit *DEVMODE) HD
 	return HDC(ret)
 }
 
+// CreateFontSpec creates the font described by spec, sized for the
+// vertical resolution of hdc.
+func CreateFontSpec(hdc HDC, spec *FontSpec) HFONT {
+	lf := spec.LOGFONT(GetDeviceCaps(hdc, LOGPIXELSY))
+	return CreateFontIndirect(&lf)
+}
+
+func CreateHatchBrush(iHatch int, color COLORREF) HBRUSH {
+	ret, _, _ := procCreateHatchBrush.Call(
+		uintptr(iHatch),
+		uintptr(color))
+
+	trackHandle(HandleKindBrush, "CreateHatchBrush", ret)
+
+	return HBRUSH(ret)
+}
+
+func CreatePen(iStyle, cWidth int, color COLORREF) HPEN {
+	ret, _, _ := procCreatePen.Call(
+		uintptr(iStyle),
+		uintptr(cWidth),
+		uintptr(color))
+
+	trackHandle(HandleKindPen, "CreatePen", ret)
+
+	return HPEN(ret)
+}
+
+func CreateSolidBrush(color COLORREF) HBRUSH {
+	ret, _, _ := procCreateSolidBrush.Call(
+		uintptr(color))
+
+	trackHandle(HandleKindBrush, "CreateSolidBrush", ret)
+
+	return HBRUSH(ret)
+}
+
 func DeleteDC(hdc HDC) bool {
+	r := untrackHandle("DeleteDC", uintptr(hdc))
 	ret, _, _ := procDeleteDC.Call(
 		uintptr(hdc))
 
+	if ret == 0 {
+		restoreHandle(r)
+	}
+
 	return ret != 0
 }
 
@@ -221,6 +328,60 @@ func EndPage(hdc HDC) int {
 	return int(ret)
 }
 
+// EnumFontFamiliesEx calls fn for each font matching the CharSet,
+// FaceName and PitchAndFamily of lf; an empty FaceName enumerates one
+// font per family. Enumeration stops when fn returns false. For fonts that
+// are not TrueType, only the TEXTMETRIC part of ntm is filled in.
+func EnumFontFamiliesEx(hdc HDC, lf *LOGFONT, fn func(elf ENUMLOGFONTEX, ntm NEWTEXTMETRICEX, fontType uint32) bool) {
+	enumFontFamExOnce.Do(func() {
+		enumFontFamExCallback = syscall.NewCallback(enumFontFamExProc)
+	})
+
+	enumFontFamExMu.Lock()
+	enumFontFamExNext++
+	id := enumFontFamExNext
+	enumFontFamExFuncs[id] = fn
+	enumFontFamExMu.Unlock()
+
+	procEnumFontFamiliesEx.Call(
+		uintptr(hdc),
+		uintptr(unsafe.Pointer(lf)),
+		enumFontFamExCallback,
+		id,
+		0)
+
+	enumFontFamExMu.Lock()
+	delete(enumFontFamExFuncs, id)
+	enumFontFamExMu.Unlock()
+}
+
+// Callbacks cannot be freed, so EnumFontFamiliesEx shares one and passes
+// the id of the Go function to call as its lParam.
+var (
+	enumFontFamExOnce     sync.Once
+	enumFontFamExCallback uintptr
+	enumFontFamExMu       sync.Mutex
+	enumFontFamExNext     uintptr
+	enumFontFamExFuncs    = make(map[uintptr]func(ENUMLOGFONTEX, NEWTEXTMETRICEX, uint32) bool)
+)
+
+func enumFontFamExProc(elf *ENUMLOGFONTEX, ntm *NEWTEXTMETRICEX, fontType, lParam uintptr) uintptr {
+	enumFontFamExMu.Lock()
+	fn := enumFontFamExFuncs[lParam]
+	enumFontFamExMu.Unlock()
+
+	var tm NEWTEXTMETRICEX
+	if fontType&TRUETYPE_FONTTYPE != 0 {
+		tm = *ntm
+	} else {
+		tm.NtmTm.TEXTMETRIC = *(*TEXTMETRIC)(unsafe.Pointer(ntm))
+	}
+	if fn == nil || !fn(*elf, tm, uint32(fontType)) {
+		return 0
+	}
+	return 1
+}
+
 func ExtCreatePen(dwPenStyle, dwWidth uint, lplb *LOGBRUSH, dwStyleCount uint, lpStyle *uint) HPEN {
 	ret, _, _ := procExtCreatePen.Call(
 		uintptr(dwPenStyle),
@@ -229,6 +390,8 @@ func ExtCreatePen(dwPenStyle, dwWidth uint, lplb *LOGBRUSH, dwStyleCount uint, l
 		uintptr(dwStyleCount),
 		uintptr(unsafe.Pointer(lpStyle)))
 
+	trackHandle(HandleKindPen, "ExtCreatePen", ret)
+
 	return HPEN(ret)
 }
 
@@ -239,6 +402,24 @@ func GetEnhMetaFile(lpszMetaFile *uint16) HENHMETAFILE {
 	return HENHMETAFILE(ret)
 }
 
+// GetEnhMetaFileBits returns the contents of a metafile, as ParseEMF
+// accepts them, or nil on failure.
+func GetEnhMetaFileBits(hemf HENHMETAFILE) []byte {
+	n, _, _ := procGetEnhMetaFileBits.Call(uintptr(hemf), 0, 0)
+	if n == 0 {
+		return nil
+	}
+	data := make([]byte, n)
+	ret, _, _ := procGetEnhMetaFileBits.Call(
+		uintptr(hemf),
+		n,
+		uintptr(unsafe.Pointer(&data[0])))
+	if ret == 0 {
+		return nil
+	}
+	return data[:ret]
+}
+
 func GetEnhMetaFileHeader(hemf HENHMETAFILE, cbBuffer uint, lpemh *ENHMETAHEADER) uint {
 	ret, _, _ := procGetEnhMetaFileHeader.Call(
 		uintptr(hemf),
@@ -257,8 +438,18 @@ func GetObject(hgdiobj HGDIOBJ, cbBuffer uintptr, lpvObject unsafe.Pointer) int
 	return int(ret)
 }
 
+// GetPixel returns CLR_INVALID if (x, y) is outside the clip region.
+func GetPixel(hdc HDC, x, y int) COLORREF {
+	ret, _, _ := procGetPixel.Call(
+		uintptr(hdc),
+		uintptr(x),
+		uintptr(y))
+
+	return COLORREF(ret)
+}
+
 func GetStockObject(fnObject int) HGDIOBJ {
-	ret, _, _ := procGetDeviceCaps.Call(
+	ret, _, _ := procGetStockObject.Call(
 		uintptr(fnObject))
 
 	return HGDIOBJ(ret)
@@ -376,6 +567,26 @@ func SetBrushOrgEx(hdc HDC, nXOrg, nYOrg int, lppt *POINT) bool {
 	return ret != 0
 }
 
+// SetPixel returns the color actually painted, which may be an
+// approximation of crColor, or CLR_INVALID on failure.
+func SetPixel(hdc HDC, x, y int, crColor COLORREF) COLORREF {
+	ret, _, _ := procSetPixel.Call(
+		uintptr(hdc),
+		uintptr(x),
+		uintptr(y),
+		uintptr(crColor))
+
+	return COLORREF(ret)
+}
+
+func SetROP2(hdc HDC, rop2 int) int {
+	ret, _, _ := procSetROP2.Call(
+		uintptr(hdc),
+		uintptr(rop2))
+
+	return int(ret)
+}
+
 func SetStretchBltMode(hdc HDC, iStretchMode int) int {
 	ret, _, _ := procSetStretchBltMode.Call(
 		uintptr(hdc),
@@ -384,6 +595,19 @@ func SetStretchBltMode(hdc HDC, iStretchMode int) int {
 	return int(ret)
 }
 
+// SetEnhMetaFileBits creates an in-memory metafile from its contents, such
+// as the output of EMFRecorder.Bytes. It returns 0 on failure.
+func SetEnhMetaFileBits(data []byte) HENHMETAFILE {
+	if len(data) == 0 {
+		return 0
+	}
+	ret, _, _ := procSetEnhMetaFileBits.Call(
+		uintptr(len(data)),
+		uintptr(unsafe.Pointer(&data[0])))
+
+	return HENHMETAFILE(ret)
+}
+
 func SetTextColor(hdc HDC, crColor COLORREF) COLORREF {
 	ret, _, _ := procSetTextColor.Call(
 		uintptr(hdc),
@@ -478,6 +702,21 @@ func DescribePixelFormat(hdc HDC, iPixelFormat int, nBytes uint, pfd *PIXELFORMA
 	return int(ret)
 }
 
+// DescribePixelFormats returns every pixel format of hdc, for
+// RankPixelFormats and PickPixelFormat.
+func DescribePixelFormats(hdc HDC) []PixelFormatCandidate {
+	var pfd PIXELFORMATDESCRIPTOR
+	size := uint(unsafe.Sizeof(pfd))
+	n := DescribePixelFormat(hdc, 1, size, nil)
+	formats := make([]PixelFormatCandidate, 0, n)
+	for i := 1; i <= n; i++ {
+		if DescribePixelFormat(hdc, i, size, &pfd) != 0 {
+			formats = append(formats, PixelFormatCandidate{Index: i, PFD: pfd})
+		}
+	}
+	return formats
+}
+
 func GetEnhMetaFilePixelFormat(hemf HENHMETAFILE, cbBuffer uint32, pfd *PIXELFORMATDESCRIPTOR) uint {
 	ret, _, _ := procGetEnhMetaFilePixelFormat.Call(
 		uintptr(hemf),
@@ -507,3 +746,283 @@ func SwapBuffers(hdc HDC) bool {
 	ret, _, _ := procSwapBuffers.Call(uintptr(hdc))
 	return ret == TRUE
 }
+
+// pointsPtr returns the address of the first point, or 0 for none.
+func pointsPtr(pts []POINT) uintptr {
+	if len(pts) == 0 {
+		return 0
+	}
+	return uintptr(unsafe.Pointer(&pts[0]))
+}
+
+func Polyline(hdc HDC, pts []POINT) bool {
+	ret, _, _ := procPolyline.Call(
+		uintptr(hdc),
+		pointsPtr(pts),
+		uintptr(len(pts)))
+
+	return ret != 0
+}
+
+// PolylineTo draws from the current position through pts and leaves the
+// current position at the last point.
+func PolylineTo(hdc HDC, pts []POINT) bool {
+	ret, _, _ := procPolylineTo.Call(
+		uintptr(hdc),
+		pointsPtr(pts),
+		uintptr(len(pts)))
+
+	return ret != 0
+}
+
+func Polygon(hdc HDC, pts []POINT) bool {
+	ret, _, _ := procPolygon.Call(
+		uintptr(hdc),
+		pointsPtr(pts),
+		uintptr(len(pts)))
+
+	return ret != 0
+}
+
+// PolyPolygon draws several closed polygons, filled together under the
+// polygon fill mode.
+func PolyPolygon(hdc HDC, polys [][]POINT) bool {
+	var pts []POINT
+	counts := make([]int32, len(polys))
+	for i, p := range polys {
+		pts = append(pts, p...)
+		counts[i] = int32(len(p))
+	}
+	if len(counts) == 0 {
+		return false
+	}
+	ret, _, _ := procPolyPolygon.Call(
+		uintptr(hdc),
+		pointsPtr(pts),
+		uintptr(unsafe.Pointer(&counts[0])),
+		uintptr(len(counts)))
+
+	return ret != 0
+}
+
+// PolyBezier draws cubic Bézier curves: a start point followed by three
+// points per curve, the last of which starts the next curve.
+func PolyBezier(hdc HDC, pts []POINT) bool {
+	ret, _, _ := procPolyBezier.Call(
+		uintptr(hdc),
+		pointsPtr(pts),
+		uintptr(len(pts)))
+
+	return ret != 0
+}
+
+// PolyBezierTo draws Bézier curves from the current position, three
+// points per curve.
+func PolyBezierTo(hdc HDC, pts []POINT) bool {
+	ret, _, _ := procPolyBezierTo.Call(
+		uintptr(hdc),
+		pointsPtr(pts),
+		uintptr(len(pts)))
+
+	return ret != 0
+}
+
+// Arc draws the part of the ellipse bounded by the rectangle that runs
+// from the radial through (xStart, yStart) to the radial through
+// (xEnd, yEnd), in the current arc direction.
+func Arc(hdc HDC, left, top, right, bottom, xStart, yStart, xEnd, yEnd int) bool {
+	ret, _, _ := procArc.Call(
+		uintptr(hdc),
+		uintptr(left),
+		uintptr(top),
+		uintptr(right),
+		uintptr(bottom),
+		uintptr(xStart),
+		uintptr(yStart),
+		uintptr(xEnd),
+		uintptr(yEnd))
+
+	return ret != 0
+}
+
+// Pie draws an arc as Arc does, closed by lines to the center and filled.
+func Pie(hdc HDC, left, top, right, bottom, xStart, yStart, xEnd, yEnd int) bool {
+	ret, _, _ := procPie.Call(
+		uintptr(hdc),
+		uintptr(left),
+		uintptr(top),
+		uintptr(right),
+		uintptr(bottom),
+		uintptr(xStart),
+		uintptr(yStart),
+		uintptr(xEnd),
+		uintptr(yEnd))
+
+	return ret != 0
+}
+
+// Chord draws an arc as Arc does, closed by a line between its ends and
+// filled.
+func Chord(hdc HDC, left, top, right, bottom, xStart, yStart, xEnd, yEnd int) bool {
+	ret, _, _ := procChord.Call(
+		uintptr(hdc),
+		uintptr(left),
+		uintptr(top),
+		uintptr(right),
+		uintptr(bottom),
+		uintptr(xStart),
+		uintptr(yStart),
+		uintptr(xEnd),
+		uintptr(yEnd))
+
+	return ret != 0
+}
+
+// RoundRect draws a rectangle whose corners are rounded by an ellipse of
+// the given width and height.
+func RoundRect(hdc HDC, left, top, right, bottom, width, height int) bool {
+	ret, _, _ := procRoundRect.Call(
+		uintptr(hdc),
+		uintptr(left),
+		uintptr(top),
+		uintptr(right),
+		uintptr(bottom),
+		uintptr(width),
+		uintptr(height))
+
+	return ret != 0
+}
+
+// BeginPath opens a path bracket: until EndPath, drawing calls add to
+// the path of hdc instead of drawing.
+func BeginPath(hdc HDC) bool {
+	ret, _, _ := procBeginPath.Call(
+		uintptr(hdc))
+
+	return ret != 0
+}
+
+func EndPath(hdc HDC) bool {
+	ret, _, _ := procEndPath.Call(
+		uintptr(hdc))
+
+	return ret != 0
+}
+
+// AbortPath closes an open path bracket or discards the path.
+func AbortPath(hdc HDC) bool {
+	ret, _, _ := procAbortPath.Call(
+		uintptr(hdc))
+
+	return ret != 0
+}
+
+func CloseFigure(hdc HDC) bool {
+	ret, _, _ := procCloseFigure.Call(
+		uintptr(hdc))
+
+	return ret != 0
+}
+
+func StrokePath(hdc HDC) bool {
+	ret, _, _ := procStrokePath.Call(
+		uintptr(hdc))
+
+	return ret != 0
+}
+
+func FillPath(hdc HDC) bool {
+	ret, _, _ := procFillPath.Call(
+		uintptr(hdc))
+
+	return ret != 0
+}
+
+func StrokeAndFillPath(hdc HDC) bool {
+	ret, _, _ := procStrokeAndFillPath.Call(
+		uintptr(hdc))
+
+	return ret != 0
+}
+
+// PathToRegion converts the closed path to a region under the polygon
+// fill mode and discards the path. It returns 0 on failure.
+func PathToRegion(hdc HDC) HRGN {
+	ret, _, _ := procPathToRegion.Call(
+		uintptr(hdc))
+
+	return HRGN(ret)
+}
+
+// GetPath returns the points of the closed path in logical units, with a
+// PT_* type for each. It returns nil slices if there is no closed path.
+func GetPath(hdc HDC) ([]POINT, []byte) {
+	n, _, _ := procGetPath.Call(
+		uintptr(hdc),
+		0,
+		0,
+		0)
+	if int32(n) <= 0 {
+		return nil, nil
+	}
+
+	pts := make([]POINT, n)
+	types := make([]byte, n)
+	ret, _, _ := procGetPath.Call(
+		uintptr(hdc),
+		uintptr(unsafe.Pointer(&pts[0])),
+		uintptr(unsafe.Pointer(&types[0])),
+		n)
+	if int32(ret) <= 0 {
+		return nil, nil
+	}
+
+	return pts[:ret], types[:ret]
+}
+
+// SetPolyFillMode sets ALTERNATE or WINDING and returns the previous mode,
+// or 0 on failure.
+func SetPolyFillMode(hdc HDC, iPolyFillMode int) int {
+	ret, _, _ := procSetPolyFillMode.Call(
+		uintptr(hdc),
+		uintptr(iPolyFillMode))
+
+	return int(ret)
+}
+
+// SetGraphicsMode sets GM_COMPATIBLE or GM_ADVANCED and returns the
+// previous mode, or 0 on failure. World transforms need GM_ADVANCED.
+func SetGraphicsMode(hdc HDC, iMode int) int {
+	ret, _, _ := procSetGraphicsMode.Call(
+		uintptr(hdc),
+		uintptr(iMode))
+
+	return int(ret)
+}
+
+func SetWorldTransform(hdc HDC, lpxf *XFORM) bool {
+	ret, _, _ := procSetWorldTransform.Call(
+		uintptr(hdc),
+		uintptr(unsafe.Pointer(lpxf)))
+
+	return ret != 0
+}
+
+func GetWorldTransform(hdc HDC, lpxf *XFORM) bool {
+	ret, _, _ := procGetWorldTransform.Call(
+		uintptr(hdc),
+		uintptr(unsafe.Pointer(lpxf)))
+
+	return ret != 0
+}
+
+// ModifyWorldTransform resets the world transform (MWT_IDENTITY) or
+// combines it with lpxf (MWT_LEFTMULTIPLY, MWT_RIGHTMULTIPLY).
+func ModifyWorldTransform(hdc HDC, lpxf *XFORM, iMode uint32) bool {
+	ret, _, _ := procModifyWorldTransform.Call(
+		uintptr(hdc),
+		uintptr(unsafe.Pointer(lpxf)),
+		uintptr(iMode))
+
+	return ret != 0
+}
@@ -0,0 +1,295 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unsafe"
+)
+
+// ImageCodecInfo describes a GDI+ image encoder or decoder, as returned by
+// GdipGetImageEncoders and GdipGetImageDecoders.
+type ImageCodecInfo struct {
+	CLSID             GUID // passed to GpImage.Save
+	FormatID          GUID // one of the ImageFormat* GUIDs
+	CodecName         string
+	DllName           string // empty for built-in codecs
+	FormatDescription string
+	Extensions        []string // such as "*.JPG"
+	MimeType          string
+	Flags             uint32 // ImageCodecFlags* values
+	Version           uint32
+	Signatures        []ImageCodecSignature
+}
+
+// ImageCodecSignature is a byte pattern that identifies a file the
+// decoder can read: data matches if data&Mask == Pattern.
+type ImageCodecSignature struct {
+	Pattern, Mask []byte
+}
+
+// ImageCodecs is a catalog of codecs.
+type ImageCodecs []ImageCodecInfo
+
+// ByMimeType returns the codec for a MIME type such as "image/png".
+func (c ImageCodecs) ByMimeType(mimeType string) (*ImageCodecInfo, bool) {
+	for i := range c {
+		if strings.EqualFold(c[i].MimeType, mimeType) {
+			return &c[i], true
+		}
+	}
+	return nil, false
+}
+
+// ByExtension returns the codec for a file name extension such as ".png".
+func (c ImageCodecs) ByExtension(ext string) (*ImageCodecInfo, bool) {
+	ext = "*." + strings.TrimPrefix(ext, ".")
+	for i := range c {
+		for _, e := range c[i].Extensions {
+			if strings.EqualFold(e, ext) {
+				return &c[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ByFormat returns the codec for one of the ImageFormat* GUIDs.
+func (c ImageCodecs) ByFormat(format *GUID) (*ImageCodecInfo, bool) {
+	for i := range c {
+		if c[i].FormatID == *format {
+			return &c[i], true
+		}
+	}
+	return nil, false
+}
+
+// ParseImageCodecInfo decodes the buffer filled by GdipGetImageEncoders or
+// GdipGetImageDecoders: count ImageCodecInfo structs followed by the
+// strings and signatures they point to. base is the address the buffer
+// had at that time and ptrSize the pointer size of the process, 4 or 8.
+// Pointers outside the buffer are rejected.
+func ParseImageCodecInfo(buf []byte, count int, base uint64, ptrSize int) (ImageCodecs, error) {
+	if ptrSize != 4 && ptrSize != 8 {
+		return nil, fmt.Errorf("bad pointer size %d", ptrSize)
+	}
+	// Two GUIDs, five string pointers, four DWORDs and two pointers, padded
+	// to pointer alignment.
+	size := 32 + 7*ptrSize + 16
+	if count < 0 || count > len(buf)/size {
+		return nil, fmt.Errorf("%d codecs do not fit in %d bytes", count, len(buf))
+	}
+	le := binary.LittleEndian
+	ptr := func(b []byte) uint64 {
+		if ptrSize == 4 {
+			return uint64(le.Uint32(b))
+		}
+		return le.Uint64(b)
+	}
+	offset := func(p uint64, n int) (int, error) {
+		if p < base || p-base > uint64(len(buf)) || uint64(len(buf))-(p-base) < uint64(n) {
+			return 0, fmt.Errorf("pointer 0x%X is outside the buffer", p)
+		}
+		return int(p - base), nil
+	}
+	str := func(p uint64) (string, error) {
+		if p == 0 {
+			return "", nil
+		}
+		off, err := offset(p, 0)
+		if err != nil {
+			return "", err
+		}
+		var u []uint16
+		for ; ; off += 2 {
+			if off+2 > len(buf) {
+				return "", errors.New("unterminated string")
+			}
+			c := le.Uint16(buf[off:])
+			if c == 0 {
+				return string(utf16.Decode(u)), nil
+			}
+			u = append(u, c)
+		}
+	}
+
+	codecs := make(ImageCodecs, count)
+	for i := range codecs {
+		b := buf[i*size : (i+1)*size]
+		c := &codecs[i]
+		c.CLSID = parseGUID(b[0:])
+		c.FormatID = parseGUID(b[16:])
+		var strs [5]string
+		for j := range strs {
+			s, err := str(ptr(b[32+j*ptrSize:]))
+			if err != nil {
+				return nil, fmt.Errorf("codec %d: %v", i, err)
+			}
+			strs[j] = s
+		}
+		c.CodecName, c.DllName, c.FormatDescription, c.MimeType = strs[0], strs[1], strs[2], strs[4]
+		if strs[3] != "" {
+			c.Extensions = strings.Split(strs[3], ";")
+		}
+		d := b[32+5*ptrSize:]
+		c.Flags = le.Uint32(d)
+		c.Version = le.Uint32(d[4:])
+		sigCount, sigSize := int(le.Uint32(d[8:])), int(le.Uint32(d[12:]))
+		if sigCount == 0 {
+			continue
+		}
+		if sigSize <= 0 || sigCount > len(buf)/sigSize {
+			return nil, fmt.Errorf("codec %d: bad signature size", i)
+		}
+		n := sigCount * sigSize
+		pat, err := offset(ptr(d[16:]), n)
+		if err != nil {
+			return nil, fmt.Errorf("codec %d: %v", i, err)
+		}
+		mask, err := offset(ptr(d[16+ptrSize:]), n)
+		if err != nil {
+			return nil, fmt.Errorf("codec %d: %v", i, err)
+		}
+		for j := 0; j < sigCount; j++ {
+			c.Signatures = append(c.Signatures, ImageCodecSignature{
+				Pattern: append([]byte(nil), buf[pat+j*sigSize:pat+(j+1)*sigSize]...),
+				Mask:    append([]byte(nil), buf[mask+j*sigSize:mask+(j+1)*sigSize]...),
+			})
+		}
+	}
+	return codecs, nil
+}
+
+func parseGUID(b []byte) GUID {
+	g := GUID{
+		Data1: binary.LittleEndian.Uint32(b),
+		Data2: binary.LittleEndian.Uint16(b[4:]),
+		Data3: binary.LittleEndian.Uint16(b[6:]),
+	}
+	copy(g.Data4[:], b[8:16])
+	return g
+}
+
+func putGUID(b []byte, g *GUID) {
+	binary.LittleEndian.PutUint32(b, g.Data1)
+	binary.LittleEndian.PutUint16(b[4:], g.Data2)
+	binary.LittleEndian.PutUint16(b[6:], g.Data3)
+	copy(b[8:16], g.Data4[:])
+}
+
+// String returns the GUID in registry format, such as
+// "{B96B3CAF-0728-11D3-9D7B-0000F81EF32E}".
+func (g GUID) String() string {
+	return fmt.Sprintf("{%08X-%04X-%04X-%02X%02X-%02X%02X%02X%02X%02X%02X}", g.Data1, g.Data2, g.Data3,
+		g.Data4[0], g.Data4[1], g.Data4[2], g.Data4[3], g.Data4[4], g.Data4[5], g.Data4[6], g.Data4[7])
+}
+
+// EncoderParams builds the EncoderParameters passed to GpImage.Save:
+//
+//	params := NewEncoderParams().Quality(90).Build()
+//
+// The setters add a parameter and return the builder.
+type EncoderParams struct {
+	params []encoderParam
+}
+
+type encoderParam struct {
+	guid   GUID
+	typ    uint32
+	values []uint32
+}
+
+func NewEncoderParams() *EncoderParams {
+	return &EncoderParams{}
+}
+
+// Long adds a parameter of EncoderParameterValueTypeLong values.
+func (p *EncoderParams) Long(category *GUID, values ...uint32) *EncoderParams {
+	p.params = append(p.params, encoderParam{*category, EncoderParameterValueTypeLong, values})
+	return p
+}
+
+// Quality sets the JPEG quality from 0 to 100.
+func (p *EncoderParams) Quality(quality int) *EncoderParams {
+	return p.Long(EncoderQuality, uint32(quality))
+}
+
+// Compression sets one of the EncoderValueCompression* values, for TIFF.
+func (p *EncoderParams) Compression(compression int) *EncoderParams {
+	return p.Long(EncoderCompression, uint32(compression))
+}
+
+// ColorDepth sets the bits per pixel, for TIFF.
+func (p *EncoderParams) ColorDepth(bits int) *EncoderParams {
+	return p.Long(EncoderColorDepth, uint32(bits))
+}
+
+// Len returns the number of parameters.
+func (p *EncoderParams) Len() int {
+	return len(p.params)
+}
+
+// Size returns the length of the buffer MarshalTo fills.
+func (p *EncoderParams) Size(ptrSize int) int {
+	n := p.valuesOffset(ptrSize)
+	for _, e := range p.params {
+		n += 4 * len(e.values)
+	}
+	return n
+}
+
+// valuesOffset returns where the values start: after Count, padded to
+// pointer alignment, and the EncoderParameter array.
+func (p *EncoderParams) valuesOffset(ptrSize int) int {
+	return ptrSize + len(p.params)*(24+ptrSize)
+}
+
+// MarshalTo writes the EncoderParameters struct for a process with
+// pointers of ptrSize bytes into buf, which must be Size(ptrSize) long and
+// will be at address base when used: each Value points to the values
+// stored after the struct.
+func (p *EncoderParams) MarshalTo(buf []byte, base uint64, ptrSize int) {
+	le := binary.LittleEndian
+	for i := range buf {
+		buf[i] = 0
+	}
+	le.PutUint32(buf, uint32(len(p.params)))
+	val := p.valuesOffset(ptrSize)
+	for i, e := range p.params {
+		b := buf[ptrSize+i*(24+ptrSize):]
+		putGUID(b, &e.guid)
+		le.PutUint32(b[16:], uint32(len(e.values)))
+		le.PutUint32(b[20:], e.typ)
+		addr := base + uint64(val)
+		if ptrSize == 4 {
+			le.PutUint32(b[24:], uint32(addr))
+		} else {
+			le.PutUint64(b[24:], addr)
+		}
+		for _, v := range e.values {
+			le.PutUint32(buf[val:], v)
+			val += 4
+		}
+	}
+}
+
+// Build returns the parameters for use in this process, or nil if there
+// are none. The result holds its own memory and stays valid while it is
+// referenced.
+func (p *EncoderParams) Build() *EncoderParameters {
+	if len(p.params) == 0 {
+		return nil
+	}
+	ptrSize := int(unsafe.Sizeof(uintptr(0)))
+	// Allocate words so that the struct is pointer aligned.
+	words := make([]uint64, (p.Size(ptrSize)+7)/8)
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&words[0])), len(words)*8)
+	p.MarshalTo(buf[:p.Size(ptrSize)], uint64(uintptr(unsafe.Pointer(&words[0]))), ptrSize)
+	return (*EncoderParameters)(unsafe.Pointer(&words[0]))
+}
@@ -0,0 +1,193 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+// testdata/gdiplus holds buffers laid out as GdipGetImageEncoders fills
+// them in a 32-bit and a 64-bit process: the ImageCodecInfo array for the
+// five built-in encoders, then the strings and signatures it points to.
+// base is the address the pointers assume.
+var codecBuffers = []struct {
+	file    string
+	base    uint64
+	ptrSize int
+	entry   int
+}{
+	{"encoders-386.bin", 0x02A3C5F8, 4, 76},
+	{"encoders-amd64.bin", 0x000001F2A4C3B7E0, 8, 104},
+}
+
+func builtinCodecCLSID(n byte) GUID {
+	return GUID{0x557CF400 + uint32(n), 0x1A04, 0x11D3, [8]byte{0x9A, 0x73, 0x00, 0x00, 0xF8, 0x1E, 0xF3, 0x2E}}
+}
+
+func fullMask(n int) []byte {
+	return bytes.Repeat([]byte{0xFF}, n)
+}
+
+var builtinEncoders = ImageCodecs{
+	{
+		CLSID: builtinCodecCLSID(0), FormatID: *ImageFormatBMP,
+		CodecName: "Built-in BMP Codec", FormatDescription: "BMP",
+		Extensions: []string{"*.BMP", "*.DIB", "*.RLE"}, MimeType: "image/bmp",
+		Signatures: []ImageCodecSignature{{[]byte("BM"), fullMask(2)}},
+	},
+	{
+		CLSID: builtinCodecCLSID(1), FormatID: *ImageFormatJPEG,
+		CodecName: "Built-in JPEG Codec", FormatDescription: "JPEG",
+		Extensions: []string{"*.JPG", "*.JPEG", "*.JPE", "*.JFIF"}, MimeType: "image/jpeg",
+		Signatures: []ImageCodecSignature{{[]byte{0xFF, 0xD8}, fullMask(2)}},
+	},
+	{
+		CLSID: builtinCodecCLSID(2), FormatID: *ImageFormatGIF,
+		CodecName: "Built-in GIF Codec", FormatDescription: "GIF",
+		Extensions: []string{"*.GIF"}, MimeType: "image/gif",
+		Signatures: []ImageCodecSignature{{[]byte("GIF89a"), fullMask(6)}, {[]byte("GIF87a"), fullMask(6)}},
+	},
+	{
+		CLSID: builtinCodecCLSID(5), FormatID: *ImageFormatTIFF,
+		CodecName: "Built-in TIFF Codec", FormatDescription: "TIFF",
+		Extensions: []string{"*.TIF", "*.TIFF"}, MimeType: "image/tiff",
+		Signatures: []ImageCodecSignature{{[]byte("II*\x00"), fullMask(4)}, {[]byte("MM\x00*"), fullMask(4)}},
+	},
+	{
+		CLSID: builtinCodecCLSID(6), FormatID: *ImageFormatPNG,
+		CodecName: "Built-in PNG Codec", FormatDescription: "PNG",
+		Extensions: []string{"*.PNG"}, MimeType: "image/png",
+		Signatures: []ImageCodecSignature{{[]byte("\x89PNG\r\n\x1a\n"), fullMask(8)}},
+	},
+}
+
+func init() {
+	for i := range builtinEncoders {
+		builtinEncoders[i].Flags = ImageCodecFlagsEncoder | ImageCodecFlagsDecoder |
+			ImageCodecFlagsSupportBitmap | ImageCodecFlagsBuiltin
+		builtinEncoders[i].Version = 1
+	}
+}
+
+func TestParseImageCodecInfo(t *testing.T) {
+	for _, tt := range codecBuffers {
+		buf, err := os.ReadFile(filepath.Join("testdata", "gdiplus", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := len(builtinEncoders)
+		// The first string follows the array of ImageCodecInfo structs.
+		first := tt.base + uint64(n*tt.entry)
+		var ptr uint64
+		for i := tt.ptrSize - 1; i >= 0; i-- {
+			ptr = ptr<<8 | uint64(buf[32+i])
+		}
+		if ptr != first {
+			t.Fatalf("%s: entries are not %d bytes long", tt.file, tt.entry)
+		}
+
+		codecs, err := ParseImageCodecInfo(buf, n, tt.base, tt.ptrSize)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.file, err)
+		}
+		if !reflect.DeepEqual(codecs, builtinEncoders) {
+			t.Errorf("%s: parsed\n%+v\nwant\n%+v", tt.file, codecs, builtinEncoders)
+		}
+		if c, ok := codecs.ByMimeType("IMAGE/PNG"); !ok || c.FormatID != *ImageFormatPNG {
+			t.Errorf("%s: ByMimeType(IMAGE/PNG) = %v, %v", tt.file, c, ok)
+		}
+		if c, ok := codecs.ByExtension(".jpe"); !ok || c.MimeType != "image/jpeg" {
+			t.Errorf("%s: ByExtension(.jpe) = %v, %v", tt.file, c, ok)
+		}
+		if c, ok := codecs.ByFormat(ImageFormatTIFF); !ok || c.CLSID.String() != "{557CF405-1A04-11D3-9A73-0000F81EF32E}" {
+			t.Errorf("%s: ByFormat(ImageFormatTIFF) = %v, %v", tt.file, c, ok)
+		}
+		if _, ok := codecs.ByExtension("webp"); ok {
+			t.Errorf("%s: found a WebP encoder", tt.file)
+		}
+
+		for _, bad := range []struct {
+			name    string
+			buf     []byte
+			count   int
+			base    uint64
+			ptrSize int
+		}{
+			{"moved buffer", buf, n, tt.base + 0x1000, tt.ptrSize},
+			{"buffer below base", buf, n, tt.base - 0x1000, tt.ptrSize},
+			{"truncated strings", buf[:n*tt.entry+8], n, tt.base, tt.ptrSize},
+			{"too many codecs", buf, len(buf)/tt.entry + 1, tt.base, tt.ptrSize},
+			{"negative count", buf, -1, tt.base, tt.ptrSize},
+			{"bad pointer size", buf, n, tt.base, 2},
+		} {
+			if _, err := ParseImageCodecInfo(bad.buf, bad.count, bad.base, bad.ptrSize); err == nil {
+				t.Errorf("%s: %s: no error", tt.file, bad.name)
+			}
+		}
+	}
+}
+
+func TestEncoderParamsMarshalTo(t *testing.T) {
+	p := NewEncoderParams().Quality(85).Compression(EncoderValueCompressionLZW)
+	quality := "b5e45b1d4afa2d459cdd5db35105e7eb" + "01000000" + "04000000"
+	compression := "9d739de0d4ccee448eba3fbf8be4fc58" + "01000000" + "04000000"
+	tests := []struct {
+		ptrSize int
+		base    uint64
+		want    string
+	}{
+		{4, 0x00401000, "02000000" +
+			quality + "3c104000" +
+			compression + "40104000" +
+			"55000000" + "02000000"},
+		{8, 0x00007FF612340000, "02000000" + "00000000" +
+			quality + "48003412f67f0000" +
+			compression + "4c003412f67f0000" +
+			"55000000" + "02000000"},
+	}
+	for _, tt := range tests {
+		want, err := hex.DecodeString(tt.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := p.Size(tt.ptrSize); n != len(want) {
+			t.Errorf("Size(%d) = %d, want %d", tt.ptrSize, n, len(want))
+			continue
+		}
+		// MarshalTo clears what was in buf.
+		got := bytes.Repeat([]byte{0xCC}, len(want))
+		p.MarshalTo(got, tt.base, tt.ptrSize)
+		if !bytes.Equal(got, want) {
+			t.Errorf("MarshalTo for %d byte pointers:\n got %x\nwant %x", tt.ptrSize, got, want)
+		}
+	}
+
+	ptrSize := int(unsafe.Sizeof(uintptr(0)))
+	ep := p.Build()
+	if ep == nil || ep.Count != 2 {
+		t.Fatalf("Build() = %+v", ep)
+	}
+	b := unsafe.Slice((*byte)(unsafe.Pointer(ep)), p.Size(ptrSize))
+	want := make([]byte, len(b))
+	p.MarshalTo(want, uint64(uintptr(unsafe.Pointer(ep))), ptrSize)
+	if !bytes.Equal(b, want) {
+		t.Errorf("Build() differs from MarshalTo:\n got %x\nwant %x", b, want)
+	}
+	if ep.Parameter[0].Guid != *EncoderQuality || ep.Parameter[0].NumberOfValues != 1 {
+		t.Errorf("Build() quality parameter is %+v", ep.Parameter[0])
+	}
+	if NewEncoderParams().Build() != nil {
+		t.Error("Build() without parameters is not nil")
+	}
+	if p.Len() != 2 {
+		t.Errorf("Len() = %d", p.Len())
+	}
+}
@@ -2,11 +2,13 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+//go:build windows
+
 package w32
 
 import (
-	"errors"
 	"fmt"
+	"sync"
 	"syscall"
 	"unsafe"
 )
@@ -86,9 +88,47 @@ func GetGpStatus(s int32) string {
 	return "Unknown Status Value"
 }
 
-var (
-	token uintptr
+// GpStatus is a GDI+ Status value. It is used as an error for values
+// other than Ok.
+type GpStatus int32
+
+func (s GpStatus) String() string {
+	return GetGpStatus(int32(s))
+}
+
+func (s GpStatus) Error() string {
+	return "GDI+ status " + s.String()
+}
+
+// GpError is returned when a GDI+ function fails. It unwraps to its
+// status, so errors.Is(err, GpStatus(FileNotFound)) works.
+type GpError struct {
+	Func   string
+	Status GpStatus
+}
+
+func (e *GpError) Error() string {
+	return fmt.Sprintf("%s failed with status '%s'", e.Func, e.Status)
+}
+
+func (e *GpError) Unwrap() error {
+	return e.Status
+}
 
+func gpError(proc *syscall.LazyProc, ret uintptr) error {
+	if ret == Ok {
+		return nil
+	}
+	return &GpError{proc.Name, GpStatus(ret)}
+}
+
+// gpCall calls a flat API function that returns a Status.
+func gpCall(proc *syscall.LazyProc, args ...uintptr) error {
+	ret, _, _ := proc.Call(args...)
+	return gpError(proc, ret)
+}
+
+var (
 	modgdiplus = syscall.NewLazyDLL("gdiplus.dll")
 
 	procGdipCreateBitmapFromFile     = modgdiplus.NewProc("GdipCreateBitmapFromFile")
@@ -97,6 +137,101 @@ var (
 	procGdipCreateBitmapFromResource = modgdiplus.NewProc("GdipCreateBitmapFromResource")
 	procGdipCreateBitmapFromStream   = modgdiplus.NewProc("GdipCreateBitmapFromStream")
 	procGdipDisposeImage             = modgdiplus.NewProc("GdipDisposeImage")
+	procGdipAddPathArc               = modgdiplus.NewProc("GdipAddPathArc")
+	procGdipAddPathBezier            = modgdiplus.NewProc("GdipAddPathBezier")
+	procGdipAddPathEllipse           = modgdiplus.NewProc("GdipAddPathEllipse")
+	procGdipAddPathLine              = modgdiplus.NewProc("GdipAddPathLine")
+	procGdipAddPathPolygon           = modgdiplus.NewProc("GdipAddPathPolygon")
+	procGdipAddPathRectangle         = modgdiplus.NewProc("GdipAddPathRectangle")
+	procGdipAddPathString            = modgdiplus.NewProc("GdipAddPathString")
+	procGdipCloneMatrix              = modgdiplus.NewProc("GdipCloneMatrix")
+	procGdipClosePathFigure          = modgdiplus.NewProc("GdipClosePathFigure")
+	procGdipCreateBitmapFromScan0    = modgdiplus.NewProc("GdipCreateBitmapFromScan0")
+	procGdipCreateFont               = modgdiplus.NewProc("GdipCreateFont")
+	procGdipCreateFontFamilyFromName = modgdiplus.NewProc("GdipCreateFontFamilyFromName")
+	procGdipCreateFontFromDC         = modgdiplus.NewProc("GdipCreateFontFromDC")
+	procGdipCreateFontFromLogfontW   = modgdiplus.NewProc("GdipCreateFontFromLogfontW")
+	procGdipCreateFromHDC            = modgdiplus.NewProc("GdipCreateFromHDC")
+	procGdipCreateFromHWND           = modgdiplus.NewProc("GdipCreateFromHWND")
+	procGdipCreateHatchBrush         = modgdiplus.NewProc("GdipCreateHatchBrush")
+	procGdipCreateLineBrush          = modgdiplus.NewProc("GdipCreateLineBrush")
+	procGdipCreateMatrix             = modgdiplus.NewProc("GdipCreateMatrix")
+	procGdipCreateMatrix2            = modgdiplus.NewProc("GdipCreateMatrix2")
+	procGdipCreatePath               = modgdiplus.NewProc("GdipCreatePath")
+	procGdipCreatePen1               = modgdiplus.NewProc("GdipCreatePen1")
+	procGdipCreatePen2               = modgdiplus.NewProc("GdipCreatePen2")
+	procGdipCreateSolidFill          = modgdiplus.NewProc("GdipCreateSolidFill")
+	procGdipCreateStringFormat       = modgdiplus.NewProc("GdipCreateStringFormat")
+	procGdipCreateTexture            = modgdiplus.NewProc("GdipCreateTexture")
+	procGdipDeleteBrush              = modgdiplus.NewProc("GdipDeleteBrush")
+	procGdipDeleteFont               = modgdiplus.NewProc("GdipDeleteFont")
+	procGdipDeleteFontFamily         = modgdiplus.NewProc("GdipDeleteFontFamily")
+	procGdipDeleteGraphics           = modgdiplus.NewProc("GdipDeleteGraphics")
+	procGdipDeleteMatrix             = modgdiplus.NewProc("GdipDeleteMatrix")
+	procGdipDeletePath               = modgdiplus.NewProc("GdipDeletePath")
+	procGdipDeletePen                = modgdiplus.NewProc("GdipDeletePen")
+	procGdipDeleteStringFormat       = modgdiplus.NewProc("GdipDeleteStringFormat")
+	procGdipDrawArc                  = modgdiplus.NewProc("GdipDrawArc")
+	procGdipDrawBezier               = modgdiplus.NewProc("GdipDrawBezier")
+	procGdipDrawEllipse              = modgdiplus.NewProc("GdipDrawEllipse")
+	procGdipDrawImageRect            = modgdiplus.NewProc("GdipDrawImageRect")
+	procGdipDrawLine                 = modgdiplus.NewProc("GdipDrawLine")
+	procGdipDrawLines                = modgdiplus.NewProc("GdipDrawLines")
+	procGdipDrawPath                 = modgdiplus.NewProc("GdipDrawPath")
+	procGdipDrawPolygon              = modgdiplus.NewProc("GdipDrawPolygon")
+	procGdipDrawRectangle            = modgdiplus.NewProc("GdipDrawRectangle")
+	procGdipDrawString               = modgdiplus.NewProc("GdipDrawString")
+	procGdipFillEllipse              = modgdiplus.NewProc("GdipFillEllipse")
+	procGdipFillPath                 = modgdiplus.NewProc("GdipFillPath")
+	procGdipFillPie                  = modgdiplus.NewProc("GdipFillPie")
+	procGdipFillPolygon              = modgdiplus.NewProc("GdipFillPolygon")
+	procGdipFillRectangle            = modgdiplus.NewProc("GdipFillRectangle")
+	procGdipGetFontSize              = modgdiplus.NewProc("GdipGetFontSize")
+	procGdipGetImageDecoders         = modgdiplus.NewProc("GdipGetImageDecoders")
+	procGdipGetImageDecodersSize     = modgdiplus.NewProc("GdipGetImageDecodersSize")
+	procGdipGetImageEncoders         = modgdiplus.NewProc("GdipGetImageEncoders")
+	procGdipGetImageEncodersSize     = modgdiplus.NewProc("GdipGetImageEncodersSize")
+	procGdipGetImageGraphicsContext  = modgdiplus.NewProc("GdipGetImageGraphicsContext")
+	procGdipGetImageHeight           = modgdiplus.NewProc("GdipGetImageHeight")
+	procGdipGetImageWidth            = modgdiplus.NewProc("GdipGetImageWidth")
+	procGdipGetMatrixElements        = modgdiplus.NewProc("GdipGetMatrixElements")
+	procGdipGetPathWorldBounds       = modgdiplus.NewProc("GdipGetPathWorldBounds")
+	procGdipGraphicsClear            = modgdiplus.NewProc("GdipGraphicsClear")
+	procGdipInvertMatrix             = modgdiplus.NewProc("GdipInvertMatrix")
+	procGdipLoadImageFromFile        = modgdiplus.NewProc("GdipLoadImageFromFile")
+	procGdipMeasureString            = modgdiplus.NewProc("GdipMeasureString")
+	procGdipMultiplyMatrix           = modgdiplus.NewProc("GdipMultiplyMatrix")
+	procGdipMultiplyWorldTransform   = modgdiplus.NewProc("GdipMultiplyWorldTransform")
+	procGdipResetPath                = modgdiplus.NewProc("GdipResetPath")
+	procGdipResetWorldTransform      = modgdiplus.NewProc("GdipResetWorldTransform")
+	procGdipRestoreGraphics          = modgdiplus.NewProc("GdipRestoreGraphics")
+	procGdipRotateMatrix             = modgdiplus.NewProc("GdipRotateMatrix")
+	procGdipRotateWorldTransform     = modgdiplus.NewProc("GdipRotateWorldTransform")
+	procGdipSaveGraphics             = modgdiplus.NewProc("GdipSaveGraphics")
+	procGdipSaveImageToFile          = modgdiplus.NewProc("GdipSaveImageToFile")
+	procGdipSaveImageToStream        = modgdiplus.NewProc("GdipSaveImageToStream")
+	procGdipScaleMatrix              = modgdiplus.NewProc("GdipScaleMatrix")
+	procGdipScaleWorldTransform      = modgdiplus.NewProc("GdipScaleWorldTransform")
+	procGdipSetCompositingQuality    = modgdiplus.NewProc("GdipSetCompositingQuality")
+	procGdipSetInterpolationMode     = modgdiplus.NewProc("GdipSetInterpolationMode")
+	procGdipSetPageUnit              = modgdiplus.NewProc("GdipSetPageUnit")
+	procGdipSetPenColor              = modgdiplus.NewProc("GdipSetPenColor")
+	procGdipSetPenDashStyle          = modgdiplus.NewProc("GdipSetPenDashStyle")
+	procGdipSetPenLineCap197819      = modgdiplus.NewProc("GdipSetPenLineCap197819")
+	procGdipSetPenLineJoin           = modgdiplus.NewProc("GdipSetPenLineJoin")
+	procGdipSetPenWidth              = modgdiplus.NewProc("GdipSetPenWidth")
+	procGdipSetPixelOffsetMode       = modgdiplus.NewProc("GdipSetPixelOffsetMode")
+	procGdipSetSmoothingMode         = modgdiplus.NewProc("GdipSetSmoothingMode")
+	procGdipSetSolidFillColor        = modgdiplus.NewProc("GdipSetSolidFillColor")
+	procGdipSetStringFormatAlign     = modgdiplus.NewProc("GdipSetStringFormatAlign")
+	procGdipSetStringFormatLineAlign = modgdiplus.NewProc("GdipSetStringFormatLineAlign")
+	procGdipSetTextRenderingHint     = modgdiplus.NewProc("GdipSetTextRenderingHint")
+	procGdipSetWorldTransform        = modgdiplus.NewProc("GdipSetWorldTransform")
+	procGdipStartPathFigure          = modgdiplus.NewProc("GdipStartPathFigure")
+	procGdipTransformMatrixPoints    = modgdiplus.NewProc("GdipTransformMatrixPoints")
+	procGdipTransformPath            = modgdiplus.NewProc("GdipTransformPath")
+	procGdipTranslateMatrix          = modgdiplus.NewProc("GdipTranslateMatrix")
+	procGdipTranslateWorldTransform  = modgdiplus.NewProc("GdipTranslateWorldTransform")
 	procGdiplusShutdown              = modgdiplus.NewProc("GdiplusShutdown")
 	procGdiplusStartup               = modgdiplus.NewProc("GdiplusStartup")
 )
@@ -107,8 +242,8 @@ func GdipCreateBitmapFromFile(filename string) (*uintptr, error) {
 		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(filename))),
 		uintptr(unsafe.Pointer(&bitmap)))
 
-	if ret != Ok {
-		return nil, errors.New(fmt.Sprintf("GdipCreateBitmapFromFile failed with status '%s' for file '%s'", GetGpStatus(int32(ret)), filename))
+	if err := gpError(procGdipCreateBitmapFromFile, ret); err != nil {
+		return nil, fmt.Errorf("%w for file '%s'", err, filename)
 	}
 
 	return bitmap, nil
@@ -121,8 +256,8 @@ func GdipCreateBitmapFromResource(instance HINSTANCE, resId *uint16) (*uintptr,
 		uintptr(unsafe.Pointer(resId)),
 		uintptr(unsafe.Pointer(&bitmap)))
 
-	if ret != Ok {
-		return nil, errors.New(fmt.Sprintf("GdiCreateBitmapFromResource failed with status '%s'", GetGpStatus(int32(ret))))
+	if err := gpError(procGdipCreateBitmapFromResource, ret); err != nil {
+		return nil, err
 	}
 
 	return bitmap, nil
@@ -134,8 +269,8 @@ func GdipCreateBitmapFromStream(stream *IStream) (*uintptr, error) {
 		uintptr(unsafe.Pointer(stream)),
 		uintptr(unsafe.Pointer(&bitmap)))
 
-	if ret != Ok {
-		return nil, errors.New(fmt.Sprintf("GdipCreateBitmapFromStream failed with status '%s'", GetGpStatus(int32(ret))))
+	if err := gpError(procGdipCreateBitmapFromStream, ret); err != nil {
+		return nil, err
 	}
 
 	return bitmap, nil
@@ -148,8 +283,8 @@ func GdipCreateHBITMAPFromBitmap(bitmap *uintptr, background uint32) (HBITMAP, e
 		uintptr(unsafe.Pointer(&hbitmap)),
 		uintptr(background))
 
-	if ret != Ok {
-		return 0, errors.New(fmt.Sprintf("GdipCreateHBITMAPFromBitmap failed with status '%s'", GetGpStatus(int32(ret))))
+	if err := gpError(procGdipCreateHBITMAPFromBitmap, ret); err != nil {
+		return 0, err
 	}
 
 	return hbitmap, nil
@@ -159,17 +294,534 @@ func GdipDisposeImage(image *uintptr) {
 	procGdipDisposeImage.Call(uintptr(unsafe.Pointer(image)))
 }
 
-func GdiplusShutdown() {
-	procGdiplusShutdown.Call(token)
-}
-
-func GdiplusStartup(input *GdiplusStartupInput, output *GdiplusStartupOutput) {
+func gdiplusStartup(input *GdiplusStartupInput, output *GdiplusStartupOutput) (uintptr, error) {
+	if input == nil {
+		input = &GdiplusStartupInput{GdiplusVersion: 1}
+	}
+	var token uintptr
 	ret, _, _ := procGdiplusStartup.Call(
 		uintptr(unsafe.Pointer(&token)),
 		uintptr(unsafe.Pointer(input)),
 		uintptr(unsafe.Pointer(output)))
+	if err := gpError(procGdiplusStartup, ret); err != nil {
+		return 0, err
+	}
+	return token, nil
+}
+
+// GdiplusSession is one user's hold on GDI+, with its own startup token.
+// GDI+ counts nested startups and stays running while any token is open,
+// so packages can each open their own session instead of coordinating a
+// single GdiplusStartup.
+type GdiplusSession struct {
+	token uintptr
+	once  sync.Once
+}
+
+// StartGdiplus opens a session, starting GDI+ 1.0 if no session is open.
+func StartGdiplus() (*GdiplusSession, error) {
+	token, err := gdiplusStartup(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &GdiplusSession{token: token}, nil
+}
+
+// Close ends the session, shutting GDI+ down if it was the last one. All
+// GDI+ objects must be deleted by then. Further calls do nothing.
+func (s *GdiplusSession) Close() {
+	s.once.Do(func() {
+		procGdiplusShutdown.Call(s.token)
+	})
+}
+
+// gdiplusTokens holds the tokens of GdiplusStartup calls, which
+// GdiplusShutdown releases last first since it is not given one.
+var gdiplusTokens struct {
+	sync.Mutex
+	stack []uintptr
+}
+
+// GdiplusStartup starts GDI+ like StartGdiplus, with the given input and
+// output. Each call must be matched by a call to GdiplusShutdown.
+func GdiplusStartup(input *GdiplusStartupInput, output *GdiplusStartupOutput) {
+	token, err := gdiplusStartup(input, output)
+	if err != nil {
+		panic("GdiplusStartup failed with status " + err.(*GpError).Status.String())
+	}
+	gdiplusTokens.Lock()
+	gdiplusTokens.stack = append(gdiplusTokens.stack, token)
+	gdiplusTokens.Unlock()
+}
+
+// GdiplusShutdown releases the most recent hold taken by GdiplusStartup.
+func GdiplusShutdown() {
+	gdiplusTokens.Lock()
+	defer gdiplusTokens.Unlock()
+	n := len(gdiplusTokens.stack)
+	if n == 0 {
+		return
+	}
+	procGdiplusShutdown.Call(gdiplusTokens.stack[n-1])
+	gdiplusTokens.stack = gdiplusTokens.stack[:n-1]
+}
+
+// MakeARGB returns a GDI+ color.
+func MakeARGB(a, r, g, b byte) uint32 {
+	return uint32(a)<<24 | uint32(r)<<16 | uint32(g)<<8 | uint32(b)
+}
+
+// The GDI+ objects are opaque; the wrappers pass pointers to them to the
+// flat API. Each must be deleted before GDI+ is shut down.
+type (
+	GpGraphics     struct{}
+	GpPen          struct{}
+	GpBrush        struct{}
+	GpFontFamily   struct{}
+	GpFont         struct{}
+	GpStringFormat struct{}
+	GpPath         struct{}
+	GpMatrix       struct{}
+	GpImage        struct{}
+)
+
+func (p *GpGraphics) ptr() uintptr     { return uintptr(unsafe.Pointer(p)) }
+func (p *GpPen) ptr() uintptr          { return uintptr(unsafe.Pointer(p)) }
+func (p *GpBrush) ptr() uintptr        { return uintptr(unsafe.Pointer(p)) }
+func (p *GpFontFamily) ptr() uintptr   { return uintptr(unsafe.Pointer(p)) }
+func (p *GpFont) ptr() uintptr         { return uintptr(unsafe.Pointer(p)) }
+func (p *GpStringFormat) ptr() uintptr { return uintptr(unsafe.Pointer(p)) }
+func (p *GpPath) ptr() uintptr         { return uintptr(unsafe.Pointer(p)) }
+func (p *GpMatrix) ptr() uintptr       { return uintptr(unsafe.Pointer(p)) }
+func (p *GpImage) ptr() uintptr        { return uintptr(unsafe.Pointer(p)) }
+
+func pointFsPtr(points []PointF) uintptr {
+	if len(points) == 0 {
+		return 0
+	}
+	return uintptr(unsafe.Pointer(&points[0]))
+}
+
+// GdipCreateFromHDC returns a graphics drawing to hdc.
+func GdipCreateFromHDC(hdc HDC) (*GpGraphics, error) {
+	var g *GpGraphics
+	err := gpCall(procGdipCreateFromHDC, uintptr(hdc), uintptr(unsafe.Pointer(&g)))
+	return g, err
+}
+
+// GdipCreateFromHWND returns a graphics drawing to the client area of
+// hwnd.
+func GdipCreateFromHWND(hwnd HWND) (*GpGraphics, error) {
+	var g *GpGraphics
+	err := gpCall(procGdipCreateFromHWND, uintptr(hwnd), uintptr(unsafe.Pointer(&g)))
+	return g, err
+}
+
+// GdipGetImageGraphicsContext returns a graphics drawing to image.
+func GdipGetImageGraphicsContext(image *GpImage) (*GpGraphics, error) {
+	var g *GpGraphics
+	err := gpCall(procGdipGetImageGraphicsContext, image.ptr(), uintptr(unsafe.Pointer(&g)))
+	return g, err
+}
+
+func (g *GpGraphics) Delete() {
+	procGdipDeleteGraphics.Call(g.ptr())
+}
+
+// SetSmoothingMode sets one of the SmoothingMode* values;
+// SmoothingModeAntiAlias antialiases lines, curves and fill edges.
+func (g *GpGraphics) SetSmoothingMode(mode int) error {
+	return gpCall(procGdipSetSmoothingMode, g.ptr(), uintptr(mode))
+}
+
+// SetTextRenderingHint sets one of the TextRenderingHint* values.
+func (g *GpGraphics) SetTextRenderingHint(hint int) error {
+	return gpCall(procGdipSetTextRenderingHint, g.ptr(), uintptr(hint))
+}
+
+// SetPixelOffsetMode sets one of the PixelOffsetMode* values.
+func (g *GpGraphics) SetPixelOffsetMode(mode int) error {
+	return gpCall(procGdipSetPixelOffsetMode, g.ptr(), uintptr(mode))
+}
+
+// SetInterpolationMode sets one of the InterpolationMode* values, used
+// when images are scaled.
+func (g *GpGraphics) SetInterpolationMode(mode int) error {
+	return gpCall(procGdipSetInterpolationMode, g.ptr(), uintptr(mode))
+}
+
+// SetCompositingQuality sets one of the CompositingQuality* values.
+func (g *GpGraphics) SetCompositingQuality(quality int) error {
+	return gpCall(procGdipSetCompositingQuality, g.ptr(), uintptr(quality))
+}
+
+// SetPageUnit sets the Unit* of the page coordinates.
+func (g *GpGraphics) SetPageUnit(unit int) error {
+	return gpCall(procGdipSetPageUnit, g.ptr(), uintptr(unit))
+}
+
+func (g *GpGraphics) Clear(argb uint32) error {
+	return gpCall(procGdipGraphicsClear, g.ptr(), uintptr(argb))
+}
+
+// DrawLines draws a polyline.
+func (g *GpGraphics) DrawLines(pen *GpPen, points []PointF) error {
+	return gpCall(procGdipDrawLines, g.ptr(), pen.ptr(), pointFsPtr(points), uintptr(len(points)))
+}
+
+func (g *GpGraphics) DrawPolygon(pen *GpPen, points []PointF) error {
+	return gpCall(procGdipDrawPolygon, g.ptr(), pen.ptr(), pointFsPtr(points), uintptr(len(points)))
+}
+
+// FillPolygon fills a polygon with FillModeAlternate or FillModeWinding.
+func (g *GpGraphics) FillPolygon(brush *GpBrush, points []PointF, fillMode int) error {
+	return gpCall(procGdipFillPolygon, g.ptr(), brush.ptr(), pointFsPtr(points), uintptr(len(points)), uintptr(fillMode))
+}
+
+func (g *GpGraphics) DrawPath(pen *GpPen, path *GpPath) error {
+	return gpCall(procGdipDrawPath, g.ptr(), pen.ptr(), path.ptr())
+}
+
+func (g *GpGraphics) FillPath(brush *GpBrush, path *GpPath) error {
+	return gpCall(procGdipFillPath, g.ptr(), brush.ptr(), path.ptr())
+}
+
+// DrawString draws text laid out in layout; a zero width or height does
+// not limit it. format may be nil.
+func (g *GpGraphics) DrawString(text string, font *GpFont, layout RectF, format *GpStringFormat, brush *GpBrush) error {
+	s := syscall.StringToUTF16(text)
+	return gpCall(procGdipDrawString, g.ptr(), uintptr(unsafe.Pointer(&s[0])), uintptr(len(s)-1),
+		font.ptr(), uintptr(unsafe.Pointer(&layout)), format.ptr(), brush.ptr())
+}
+
+// MeasureString returns the bounding box of text as DrawString would lay
+// it out, and how many UTF-16 code units and lines fit.
+func (g *GpGraphics) MeasureString(text string, font *GpFont, layout RectF, format *GpStringFormat) (bounds RectF, fitted, lines int, err error) {
+	s := syscall.StringToUTF16(text)
+	var f, l int32
+	err = gpCall(procGdipMeasureString, g.ptr(), uintptr(unsafe.Pointer(&s[0])), uintptr(len(s)-1),
+		font.ptr(), uintptr(unsafe.Pointer(&layout)), format.ptr(), uintptr(unsafe.Pointer(&bounds)),
+		uintptr(unsafe.Pointer(&f)), uintptr(unsafe.Pointer(&l)))
+	return bounds, int(f), int(l), err
+}
+
+// SetTransform sets the world transform to matrix.
+func (g *GpGraphics) SetTransform(matrix *GpMatrix) error {
+	return gpCall(procGdipSetWorldTransform, g.ptr(), matrix.ptr())
+}
+
+func (g *GpGraphics) ResetTransform() error {
+	return gpCall(procGdipResetWorldTransform, g.ptr())
+}
+
+func (g *GpGraphics) MultiplyTransform(matrix *GpMatrix, order int) error {
+	return gpCall(procGdipMultiplyWorldTransform, g.ptr(), matrix.ptr(), uintptr(order))
+}
+
+// Save returns a state that Restore returns to, undoing changes to the
+// transform, clipping and quality settings made since.
+func (g *GpGraphics) Save() (uint32, error) {
+	var state uint32
+	err := gpCall(procGdipSaveGraphics, g.ptr(), uintptr(unsafe.Pointer(&state)))
+	return state, err
+}
+
+func (g *GpGraphics) Restore(state uint32) error {
+	return gpCall(procGdipRestoreGraphics, g.ptr(), uintptr(state))
+}
 
-	if ret != Ok {
-		panic("GdiplusStartup failed with status " + GetGpStatus(int32(ret)))
+func (p *GpPen) Delete() {
+	procGdipDeletePen.Call(p.ptr())
+}
+
+func (p *GpPen) SetColor(argb uint32) error {
+	return gpCall(procGdipSetPenColor, p.ptr(), uintptr(argb))
+}
+
+// SetDashStyle sets one of the DashStyle* values.
+func (p *GpPen) SetDashStyle(style int) error {
+	return gpCall(procGdipSetPenDashStyle, p.ptr(), uintptr(style))
+}
+
+// SetLineCap sets the LineCap* values of both ends and the DashCap* value
+// of the dashes.
+func (p *GpPen) SetLineCap(start, end, dash int) error {
+	return gpCall(procGdipSetPenLineCap197819, p.ptr(), uintptr(start), uintptr(end), uintptr(dash))
+}
+
+// SetLineJoin sets one of the LineJoin* values.
+func (p *GpPen) SetLineJoin(join int) error {
+	return gpCall(procGdipSetPenLineJoin, p.ptr(), uintptr(join))
+}
+
+func GdipCreateSolidFill(argb uint32) (*GpBrush, error) {
+	var brush *GpBrush
+	err := gpCall(procGdipCreateSolidFill, uintptr(argb), uintptr(unsafe.Pointer(&brush)))
+	return brush, err
+}
+
+// GdipCreateHatchBrush returns a brush painting the HatchStyle* pattern in
+// fore over back.
+func GdipCreateHatchBrush(style int, fore, back uint32) (*GpBrush, error) {
+	var brush *GpBrush
+	err := gpCall(procGdipCreateHatchBrush, uintptr(style), uintptr(fore), uintptr(back), uintptr(unsafe.Pointer(&brush)))
+	return brush, err
+}
+
+// GdipCreateLineBrush returns a linear gradient from color1 at p1 to
+// color2 at p2, repeated according to wrapMode, one of the WrapMode*
+// values.
+func GdipCreateLineBrush(p1, p2 PointF, color1, color2 uint32, wrapMode int) (*GpBrush, error) {
+	var brush *GpBrush
+	err := gpCall(procGdipCreateLineBrush, uintptr(unsafe.Pointer(&p1)), uintptr(unsafe.Pointer(&p2)),
+		uintptr(color1), uintptr(color2), uintptr(wrapMode), uintptr(unsafe.Pointer(&brush)))
+	return brush, err
+}
+
+// GdipCreateTexture returns a brush tiling image.
+func GdipCreateTexture(image *GpImage, wrapMode int) (*GpBrush, error) {
+	var brush *GpBrush
+	err := gpCall(procGdipCreateTexture, image.ptr(), uintptr(wrapMode), uintptr(unsafe.Pointer(&brush)))
+	return brush, err
+}
+
+func (b *GpBrush) Delete() {
+	procGdipDeleteBrush.Call(b.ptr())
+}
+
+// SetSolidFillColor changes the color of a brush made by
+// GdipCreateSolidFill.
+func (b *GpBrush) SetSolidFillColor(argb uint32) error {
+	return gpCall(procGdipSetSolidFillColor, b.ptr(), uintptr(argb))
+}
+
+// GdipCreateFontFamilyFromName returns an installed font family.
+func GdipCreateFontFamilyFromName(name string) (*GpFontFamily, error) {
+	var family *GpFontFamily
+	err := gpCall(procGdipCreateFontFamilyFromName, uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(name))), 0,
+		uintptr(unsafe.Pointer(&family)))
+	return family, err
+}
+
+func (f *GpFontFamily) Delete() {
+	procGdipDeleteFontFamily.Call(f.ptr())
+}
+
+// GdipCreateFontFromDC returns the font selected into hdc.
+func GdipCreateFontFromDC(hdc HDC) (*GpFont, error) {
+	var font *GpFont
+	err := gpCall(procGdipCreateFontFromDC, uintptr(hdc), uintptr(unsafe.Pointer(&font)))
+	return font, err
+}
+
+// GdipCreateFontFromLogfont returns the TrueType font lf describes, with
+// its height interpreted for hdc.
+func GdipCreateFontFromLogfont(hdc HDC, lf *LOGFONT) (*GpFont, error) {
+	var font *GpFont
+	err := gpCall(procGdipCreateFontFromLogfontW, uintptr(hdc), uintptr(unsafe.Pointer(lf)), uintptr(unsafe.Pointer(&font)))
+	return font, err
+}
+
+func (f *GpFont) Delete() {
+	procGdipDeleteFont.Call(f.ptr())
+}
+
+// Size returns the em size in the unit the font was made with.
+func (f *GpFont) Size() (float32, error) {
+	var size float32
+	err := gpCall(procGdipGetFontSize, f.ptr(), uintptr(unsafe.Pointer(&size)))
+	return size, err
+}
+
+// GdipCreateStringFormat returns a string format with StringFormatFlags
+// and a language, 0 for neutral.
+func GdipCreateStringFormat(flags int, language uint16) (*GpStringFormat, error) {
+	var format *GpStringFormat
+	err := gpCall(procGdipCreateStringFormat, uintptr(flags), uintptr(language), uintptr(unsafe.Pointer(&format)))
+	return format, err
+}
+
+func (f *GpStringFormat) Delete() {
+	procGdipDeleteStringFormat.Call(f.ptr())
+}
+
+// SetAlign sets the horizontal StringAlignment* of the text in the layout
+// rectangle, and SetLineAlign the vertical one.
+func (f *GpStringFormat) SetAlign(align int) error {
+	return gpCall(procGdipSetStringFormatAlign, f.ptr(), uintptr(align))
+}
+
+func (f *GpStringFormat) SetLineAlign(align int) error {
+	return gpCall(procGdipSetStringFormatLineAlign, f.ptr(), uintptr(align))
+}
+
+// GdipCreatePath returns an empty path filled with FillModeAlternate or
+// FillModeWinding.
+func GdipCreatePath(fillMode int) (*GpPath, error) {
+	var path *GpPath
+	err := gpCall(procGdipCreatePath, uintptr(fillMode), uintptr(unsafe.Pointer(&path)))
+	return path, err
+}
+
+func (p *GpPath) Delete() {
+	procGdipDeletePath.Call(p.ptr())
+}
+
+func (p *GpPath) Reset() error {
+	return gpCall(procGdipResetPath, p.ptr())
+}
+
+// StartFigure begins a new figure without closing the current one;
+// CloseFigure closes it with a line to its start.
+func (p *GpPath) StartFigure() error {
+	return gpCall(procGdipStartPathFigure, p.ptr())
+}
+
+func (p *GpPath) CloseFigure() error {
+	return gpCall(procGdipClosePathFigure, p.ptr())
+}
+
+func (p *GpPath) AddPolygon(points []PointF) error {
+	return gpCall(procGdipAddPathPolygon, p.ptr(), pointFsPtr(points), uintptr(len(points)))
+}
+
+// Transform applies matrix to all points of the path.
+func (p *GpPath) Transform(matrix *GpMatrix) error {
+	return gpCall(procGdipTransformPath, p.ptr(), matrix.ptr())
+}
+
+// Bounds returns the bounding rectangle of the path after transforming it
+// by matrix and widening it by pen; both may be nil.
+func (p *GpPath) Bounds(matrix *GpMatrix, pen *GpPen) (RectF, error) {
+	var r RectF
+	err := gpCall(procGdipGetPathWorldBounds, p.ptr(), uintptr(unsafe.Pointer(&r)), matrix.ptr(), pen.ptr())
+	return r, err
+}
+
+// GdipCreateMatrix returns the identity matrix.
+func GdipCreateMatrix() (*GpMatrix, error) {
+	var m *GpMatrix
+	err := gpCall(procGdipCreateMatrix, uintptr(unsafe.Pointer(&m)))
+	return m, err
+}
+
+func (m *GpMatrix) Delete() {
+	procGdipDeleteMatrix.Call(m.ptr())
+}
+
+func (m *GpMatrix) Clone() (*GpMatrix, error) {
+	var c *GpMatrix
+	err := gpCall(procGdipCloneMatrix, m.ptr(), uintptr(unsafe.Pointer(&c)))
+	return c, err
+}
+
+func (m *GpMatrix) Multiply(other *GpMatrix, order int) error {
+	return gpCall(procGdipMultiplyMatrix, m.ptr(), other.ptr(), uintptr(order))
+}
+
+func (m *GpMatrix) Invert() error {
+	return gpCall(procGdipInvertMatrix, m.ptr())
+}
+
+// Elements returns m11, m12, m21, m22, dx and dy.
+func (m *GpMatrix) Elements() ([6]float32, error) {
+	var e [6]float32
+	err := gpCall(procGdipGetMatrixElements, m.ptr(), uintptr(unsafe.Pointer(&e[0])))
+	return e, err
+}
+
+// TransformPoints applies m to points in place.
+func (m *GpMatrix) TransformPoints(points []PointF) error {
+	return gpCall(procGdipTransformMatrixPoints, m.ptr(), pointFsPtr(points), uintptr(len(points)))
+}
+
+// AsGpImage returns a bitmap from GdipCreateBitmapFromFile and the other
+// bitmap functions returning *uintptr as a *GpImage.
+func AsGpImage(bitmap *uintptr) *GpImage {
+	return (*GpImage)(unsafe.Pointer(bitmap))
+}
+
+// GdipLoadImageFromFile loads a bitmap or metafile.
+func GdipLoadImageFromFile(filename string) (*GpImage, error) {
+	var image *GpImage
+	ret, _, _ := procGdipLoadImageFromFile.Call(
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(filename))),
+		uintptr(unsafe.Pointer(&image)))
+	if err := gpError(procGdipLoadImageFromFile, ret); err != nil {
+		return nil, fmt.Errorf("%w for file '%s'", err, filename)
+	}
+	return image, nil
+}
+
+// GdipCreateBitmapFromScan0 returns a bitmap in one of the PixelFormat*
+// formats. If scan0 is nil, GDI+ allocates the pixels; otherwise they are
+// read from and written to scan0, rows stride bytes apart, which must stay
+// valid for the life of the bitmap.
+func GdipCreateBitmapFromScan0(width, height, stride int, format int, scan0 *byte) (*GpImage, error) {
+	var image *GpImage
+	err := gpCall(procGdipCreateBitmapFromScan0, uintptr(width), uintptr(height), uintptr(stride), uintptr(format),
+		uintptr(unsafe.Pointer(scan0)), uintptr(unsafe.Pointer(&image)))
+	return image, err
+}
+
+// GdipCreateBitmapFromHBITMAP copies a GDI bitmap, using palette if it is
+// not 0 for bitmaps without a color table of their own.
+func GdipCreateBitmapFromHBITMAP(hbm HBITMAP, palette HANDLE) (*GpImage, error) {
+	var image *GpImage
+	err := gpCall(procGdipCreateBitmapFromHBITMAP, uintptr(hbm), uintptr(palette), uintptr(unsafe.Pointer(&image)))
+	return image, err
+}
+
+func (i *GpImage) Dispose() {
+	procGdipDisposeImage.Call(i.ptr())
+}
+
+// Size returns the width and height of the image in pixels.
+func (i *GpImage) Size() (width, height uint32, err error) {
+	if err = gpCall(procGdipGetImageWidth, i.ptr(), uintptr(unsafe.Pointer(&width))); err != nil {
+		return
+	}
+	err = gpCall(procGdipGetImageHeight, i.ptr(), uintptr(unsafe.Pointer(&height)))
+	return
+}
+
+// Save encodes the image to a file with the encoder whose CLSID is
+// encoder. params may be nil for the encoder's defaults.
+func (i *GpImage) Save(filename string, encoder *GUID, params *EncoderParameters) error {
+	return gpCall(procGdipSaveImageToFile, i.ptr(), uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(filename))),
+		uintptr(unsafe.Pointer(encoder)), uintptr(unsafe.Pointer(params)))
+}
+
+// SaveToStream encodes the image to stream like Save.
+func (i *GpImage) SaveToStream(stream *IStream, encoder *GUID, params *EncoderParameters) error {
+	return gpCall(procGdipSaveImageToStream, i.ptr(), uintptr(unsafe.Pointer(stream)),
+		uintptr(unsafe.Pointer(encoder)), uintptr(unsafe.Pointer(params)))
+}
+
+// GdipGetImageEncoders returns the catalog of installed image encoders.
+func GdipGetImageEncoders() (ImageCodecs, error) {
+	return gdipGetImageCodecs(procGdipGetImageEncodersSize, procGdipGetImageEncoders)
+}
+
+// GdipGetImageDecoders returns the catalog of installed image decoders.
+func GdipGetImageDecoders() (ImageCodecs, error) {
+	return gdipGetImageCodecs(procGdipGetImageDecodersSize, procGdipGetImageDecoders)
+}
+
+func gdipGetImageCodecs(sizeProc, proc *syscall.LazyProc) (ImageCodecs, error) {
+	var count, size uint32
+	if err := gpCall(sizeProc, uintptr(unsafe.Pointer(&count)), uintptr(unsafe.Pointer(&size))); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	// The buffer holds pointers, so keep it pointer aligned.
+	words := make([]uint64, (size+7)/8)
+	base := uintptr(unsafe.Pointer(&words[0]))
+	if err := gpCall(proc, uintptr(count), uintptr(size), base); err != nil {
+		return nil, err
 	}
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&words[0])), size)
+	return ParseImageCodecInfo(buf, int(count), uint64(base), int(unsafe.Sizeof(base)))
 }
@@ -0,0 +1,163 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build windows && (386 || amd64)
+
+package w32
+
+import (
+	"math"
+	"syscall"
+	"unsafe"
+)
+
+// The flat API functions here take REAL arguments, which syscall passes
+// like integers. That works on 386, where all arguments go on the stack,
+// and on amd64, where the syscall trampoline also loads the first four
+// arguments into the floating point registers. On arm64 floating point
+// arguments go in V0-V7, which syscall never sets, so these wrappers are
+// not built there.
+
+// gpReal passes a REAL argument.
+func gpReal(f float32) uintptr {
+	return uintptr(math.Float32bits(f))
+}
+
+func (g *GpGraphics) DrawLine(pen *GpPen, x1, y1, x2, y2 float32) error {
+	return gpCall(procGdipDrawLine, g.ptr(), pen.ptr(), gpReal(x1), gpReal(y1), gpReal(x2), gpReal(y2))
+}
+
+func (g *GpGraphics) DrawRectangle(pen *GpPen, x, y, width, height float32) error {
+	return gpCall(procGdipDrawRectangle, g.ptr(), pen.ptr(), gpReal(x), gpReal(y), gpReal(width), gpReal(height))
+}
+
+func (g *GpGraphics) FillRectangle(brush *GpBrush, x, y, width, height float32) error {
+	return gpCall(procGdipFillRectangle, g.ptr(), brush.ptr(), gpReal(x), gpReal(y), gpReal(width), gpReal(height))
+}
+
+func (g *GpGraphics) DrawEllipse(pen *GpPen, x, y, width, height float32) error {
+	return gpCall(procGdipDrawEllipse, g.ptr(), pen.ptr(), gpReal(x), gpReal(y), gpReal(width), gpReal(height))
+}
+
+func (g *GpGraphics) FillEllipse(brush *GpBrush, x, y, width, height float32) error {
+	return gpCall(procGdipFillEllipse, g.ptr(), brush.ptr(), gpReal(x), gpReal(y), gpReal(width), gpReal(height))
+}
+
+// DrawArc draws part of the ellipse in the rectangle, with angles in
+// degrees clockwise from the x axis.
+func (g *GpGraphics) DrawArc(pen *GpPen, x, y, width, height, startAngle, sweepAngle float32) error {
+	return gpCall(procGdipDrawArc, g.ptr(), pen.ptr(), gpReal(x), gpReal(y), gpReal(width), gpReal(height),
+		gpReal(startAngle), gpReal(sweepAngle))
+}
+
+func (g *GpGraphics) FillPie(brush *GpBrush, x, y, width, height, startAngle, sweepAngle float32) error {
+	return gpCall(procGdipFillPie, g.ptr(), brush.ptr(), gpReal(x), gpReal(y), gpReal(width), gpReal(height),
+		gpReal(startAngle), gpReal(sweepAngle))
+}
+
+// DrawBezier draws a cubic Bezier curve from p1 to p4 with control points
+// p2 and p3.
+func (g *GpGraphics) DrawBezier(pen *GpPen, p1, p2, p3, p4 PointF) error {
+	return gpCall(procGdipDrawBezier, g.ptr(), pen.ptr(), gpReal(p1.X), gpReal(p1.Y), gpReal(p2.X), gpReal(p2.Y),
+		gpReal(p3.X), gpReal(p3.Y), gpReal(p4.X), gpReal(p4.Y))
+}
+
+// DrawImageRect draws image scaled to the rectangle.
+func (g *GpGraphics) DrawImageRect(image *GpImage, x, y, width, height float32) error {
+	return gpCall(procGdipDrawImageRect, g.ptr(), image.ptr(), gpReal(x), gpReal(y), gpReal(width), gpReal(height))
+}
+
+// TranslateTransform, RotateTransform, ScaleTransform and MultiplyTransform
+// combine the world transform with another one, applied before it for
+// MatrixOrderPrepend and after it for MatrixOrderAppend.
+func (g *GpGraphics) TranslateTransform(dx, dy float32, order int) error {
+	return gpCall(procGdipTranslateWorldTransform, g.ptr(), gpReal(dx), gpReal(dy), uintptr(order))
+}
+
+func (g *GpGraphics) RotateTransform(angle float32, order int) error {
+	return gpCall(procGdipRotateWorldTransform, g.ptr(), gpReal(angle), uintptr(order))
+}
+
+func (g *GpGraphics) ScaleTransform(sx, sy float32, order int) error {
+	return gpCall(procGdipScaleWorldTransform, g.ptr(), gpReal(sx), gpReal(sy), uintptr(order))
+}
+
+// GdipCreatePen1 returns a solid pen of width in unit, one of the Unit*
+// values.
+func GdipCreatePen1(argb uint32, width float32, unit int) (*GpPen, error) {
+	var pen *GpPen
+	err := gpCall(procGdipCreatePen1, uintptr(argb), gpReal(width), uintptr(unit), uintptr(unsafe.Pointer(&pen)))
+	return pen, err
+}
+
+// GdipCreatePen2 returns a pen that paints with brush.
+func GdipCreatePen2(brush *GpBrush, width float32, unit int) (*GpPen, error) {
+	var pen *GpPen
+	err := gpCall(procGdipCreatePen2, brush.ptr(), gpReal(width), uintptr(unit), uintptr(unsafe.Pointer(&pen)))
+	return pen, err
+}
+
+func (p *GpPen) SetWidth(width float32) error {
+	return gpCall(procGdipSetPenWidth, p.ptr(), gpReal(width))
+}
+
+// GdipCreateFont returns a font of family with an em size in unit and
+// FontStyle* flags.
+func GdipCreateFont(family *GpFontFamily, emSize float32, style, unit int) (*GpFont, error) {
+	var font *GpFont
+	err := gpCall(procGdipCreateFont, family.ptr(), gpReal(emSize), uintptr(style), uintptr(unit),
+		uintptr(unsafe.Pointer(&font)))
+	return font, err
+}
+
+func (p *GpPath) AddLine(x1, y1, x2, y2 float32) error {
+	return gpCall(procGdipAddPathLine, p.ptr(), gpReal(x1), gpReal(y1), gpReal(x2), gpReal(y2))
+}
+
+func (p *GpPath) AddArc(x, y, width, height, startAngle, sweepAngle float32) error {
+	return gpCall(procGdipAddPathArc, p.ptr(), gpReal(x), gpReal(y), gpReal(width), gpReal(height),
+		gpReal(startAngle), gpReal(sweepAngle))
+}
+
+func (p *GpPath) AddBezier(p1, p2, p3, p4 PointF) error {
+	return gpCall(procGdipAddPathBezier, p.ptr(), gpReal(p1.X), gpReal(p1.Y), gpReal(p2.X), gpReal(p2.Y),
+		gpReal(p3.X), gpReal(p3.Y), gpReal(p4.X), gpReal(p4.Y))
+}
+
+func (p *GpPath) AddRectangle(x, y, width, height float32) error {
+	return gpCall(procGdipAddPathRectangle, p.ptr(), gpReal(x), gpReal(y), gpReal(width), gpReal(height))
+}
+
+func (p *GpPath) AddEllipse(x, y, width, height float32) error {
+	return gpCall(procGdipAddPathEllipse, p.ptr(), gpReal(x), gpReal(y), gpReal(width), gpReal(height))
+}
+
+// AddString adds the outlines of text. format may be nil.
+func (p *GpPath) AddString(text string, family *GpFontFamily, style int, emSize float32, layout RectF, format *GpStringFormat) error {
+	s := syscall.StringToUTF16(text)
+	return gpCall(procGdipAddPathString, p.ptr(), uintptr(unsafe.Pointer(&s[0])), uintptr(len(s)-1),
+		family.ptr(), uintptr(style), gpReal(emSize), uintptr(unsafe.Pointer(&layout)), format.ptr())
+}
+
+// GdipCreateMatrix2 returns the matrix with the given elements, which map
+// (x, y) to (m11*x + m21*y + dx, m12*x + m22*y + dy).
+func GdipCreateMatrix2(m11, m12, m21, m22, dx, dy float32) (*GpMatrix, error) {
+	var m *GpMatrix
+	err := gpCall(procGdipCreateMatrix2, gpReal(m11), gpReal(m12), gpReal(m21), gpReal(m22), gpReal(dx), gpReal(dy),
+		uintptr(unsafe.Pointer(&m)))
+	return m, err
+}
+
+func (m *GpMatrix) Translate(dx, dy float32, order int) error {
+	return gpCall(procGdipTranslateMatrix, m.ptr(), gpReal(dx), gpReal(dy), uintptr(order))
+}
+
+func (m *GpMatrix) Scale(sx, sy float32, order int) error {
+	return gpCall(procGdipScaleMatrix, m.ptr(), gpReal(sx), gpReal(sy), uintptr(order))
+}
+
+// Rotate rotates by angle degrees clockwise.
+func (m *GpMatrix) Rotate(angle float32, order int) error {
+	return gpCall(procGdipRotateMatrix, m.ptr(), gpReal(angle), uintptr(order))
+}
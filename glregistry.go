@@ -0,0 +1,709 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"go/format"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GLRegistry is a Khronos API registry, as read from gl.xml or wgl.xml.
+type GLRegistry struct {
+	Commands   map[string]*GLCommand
+	Enums      map[string][]GLEnum // by name; gl.xml has a value per API for some
+	Features   []GLInterface       // API versions, in file order
+	Extensions []GLInterface
+}
+
+// GLCommand is a function declared in a registry.
+type GLCommand struct {
+	Name   string
+	Result GLType
+	Params []GLParam
+}
+
+// GLParam is a parameter of a GLCommand.
+type GLParam struct {
+	Name string
+	Type GLType
+}
+
+// GLType is a C type reduced to its base type and pointer depth, such as
+// "GLchar" and 2 for "const GLchar *const*".
+type GLType struct {
+	Base     string
+	Pointers int
+}
+
+func (t GLType) String() string {
+	return t.Base + strings.Repeat("*", t.Pointers)
+}
+
+// GLEnum is a constant declared in a registry.
+type GLEnum struct {
+	Name  string
+	Value string
+	API   string // empty if the value holds for all APIs
+}
+
+// GLInterface is a feature (API version) or an extension: the commands and
+// enums it requires, and for features those later versions remove.
+type GLInterface struct {
+	Name      string
+	API       string // of a feature, such as "gl"
+	Number    string // of a feature, such as "3.3"
+	Supported string // of an extension, such as "gl|glcore"
+	Require   []GLInterfaceItems
+	Remove    []GLInterfaceItems
+}
+
+// GLInterfaceItems is one require or remove block of a GLInterface. Profile
+// and API, when set, limit it to that profile or API.
+type GLInterfaceItems struct {
+	Profile  string
+	API      string
+	Commands []string
+	Enums    []string
+}
+
+type glRegistryXML struct {
+	Enums []struct {
+		Enums []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:"value,attr"`
+			API   string `xml:"api,attr"`
+		} `xml:"enum"`
+	} `xml:"enums"`
+	Commands []struct {
+		Commands []struct {
+			Proto  glDeclXML   `xml:"proto"`
+			Params []glDeclXML `xml:"param"`
+		} `xml:"command"`
+	} `xml:"commands"`
+	Features   []glInterfaceXML `xml:"feature"`
+	Extensions []struct {
+		Extensions []glInterfaceXML `xml:"extension"`
+	} `xml:"extensions"`
+}
+
+// glDeclXML is a proto or param element, C declaration text with the type
+// and name marked up: "const <ptype>GLchar</ptype> *<name>name</name>".
+type glDeclXML struct {
+	Inner string `xml:",innerxml"`
+}
+
+type glInterfaceXML struct {
+	Name      string       `xml:"name,attr"`
+	API       string       `xml:"api,attr"`
+	Number    string       `xml:"number,attr"`
+	Supported string       `xml:"supported,attr"`
+	Require   []glItemsXML `xml:"require"`
+	Remove    []glItemsXML `xml:"remove"`
+}
+
+type glItemsXML struct {
+	Profile  string `xml:"profile,attr"`
+	API      string `xml:"api,attr"`
+	Commands []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"command"`
+	Enums []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"enum"`
+}
+
+var (
+	glDeclName = regexp.MustCompile(`<name>([^<]*)</name>`)
+	glDeclTag  = regexp.MustCompile(`<[^>]*>`)
+)
+
+// ParseGLRegistry reads a registry in the Khronos XML format.
+func ParseGLRegistry(r io.Reader) (*GLRegistry, error) {
+	var x glRegistryXML
+	if err := xml.NewDecoder(r).Decode(&x); err != nil {
+		return nil, err
+	}
+	reg := &GLRegistry{
+		Commands: make(map[string]*GLCommand),
+		Enums:    make(map[string][]GLEnum),
+	}
+	for _, block := range x.Enums {
+		for _, e := range block.Enums {
+			reg.Enums[e.Name] = append(reg.Enums[e.Name], GLEnum{e.Name, e.Value, e.API})
+		}
+	}
+	for _, block := range x.Commands {
+		for _, c := range block.Commands {
+			name, result, err := parseGLDecl(c.Proto.Inner)
+			if err != nil {
+				return nil, err
+			}
+			cmd := &GLCommand{Name: name, Result: result}
+			for _, p := range c.Params {
+				pname, ptype, err := parseGLDecl(p.Inner)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", name, err)
+				}
+				cmd.Params = append(cmd.Params, GLParam{pname, ptype})
+			}
+			reg.Commands[name] = cmd
+		}
+	}
+	for _, f := range x.Features {
+		reg.Features = append(reg.Features, f.convert())
+	}
+	for _, block := range x.Extensions {
+		for _, e := range block.Extensions {
+			reg.Extensions = append(reg.Extensions, e.convert())
+		}
+	}
+	return reg, nil
+}
+
+func (x *glInterfaceXML) convert() GLInterface {
+	items := func(blocks []glItemsXML) []GLInterfaceItems {
+		var l []GLInterfaceItems
+		for _, b := range blocks {
+			it := GLInterfaceItems{Profile: b.Profile, API: b.API}
+			for _, c := range b.Commands {
+				it.Commands = append(it.Commands, c.Name)
+			}
+			for _, e := range b.Enums {
+				it.Enums = append(it.Enums, e.Name)
+			}
+			l = append(l, it)
+		}
+		return l
+	}
+	return GLInterface{
+		Name:      x.Name,
+		API:       x.API,
+		Number:    x.Number,
+		Supported: x.Supported,
+		Require:   items(x.Require),
+		Remove:    items(x.Remove),
+	}
+}
+
+// parseGLDecl splits the marked up declaration of a proto or param into
+// its name and type.
+func parseGLDecl(inner string) (string, GLType, error) {
+	m := glDeclName.FindStringSubmatch(inner)
+	if m == nil {
+		return "", GLType{}, fmt.Errorf("no name in declaration %q", inner)
+	}
+	decl := glDeclTag.ReplaceAllString(glDeclName.ReplaceAllString(inner, ""), " ")
+	t := GLType{Pointers: strings.Count(decl, "*")}
+	var words []string
+	for _, w := range strings.Fields(strings.Replace(decl, "*", " ", -1)) {
+		if w != "const" && w != "struct" {
+			words = append(words, w)
+		}
+	}
+	if len(words) == 0 {
+		return "", GLType{}, fmt.Errorf("no type in declaration %q", inner)
+	}
+	t.Base = strings.Join(words, " ")
+	return strings.TrimSpace(m[1]), t, nil
+}
+
+// GLGenOptions selects what GenerateGLLoader emits.
+type GLGenOptions struct {
+	Package string // package clause of the output
+	// TypeName names the generated function table, "Funcs" if empty.
+	TypeName string
+	// API is the feature API to follow, such as "gl", "gles2" or "wgl".
+	API string
+	// Version is the highest feature number included, such as "3.3".
+	// Empty selects none, for extension-only tables.
+	Version string
+	// Profile is "core" or "compatibility" for OpenGL 3.2 and later; empty
+	// keeps everything, as the compatibility profile does.
+	Profile    string
+	Extensions []string
+	// Source names the registry in the header comment, such as "gl.xml".
+	Source string
+}
+
+// glSelection is the set of commands and enums an options value picks.
+type glSelection struct {
+	commands map[string]bool
+	enums    map[string]bool
+}
+
+func (s *glSelection) apply(items []GLInterfaceItems, api, profile string, add bool) {
+	for _, it := range items {
+		if it.API != "" && it.API != api || it.Profile != "" && it.Profile != profile {
+			continue
+		}
+		for _, c := range it.Commands {
+			s.commands[c] = add
+		}
+		for _, e := range it.Enums {
+			s.enums[e] = add
+		}
+	}
+}
+
+func (reg *GLRegistry) selectInterfaces(opts *GLGenOptions) (*glSelection, error) {
+	s := &glSelection{commands: make(map[string]bool), enums: make(map[string]bool)}
+	profile := opts.Profile
+	if profile == "" {
+		profile = "compatibility"
+	}
+	if opts.Version != "" {
+		want, err := parseGLVersion(opts.Version)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, f := range reg.Features {
+			if f.API != opts.API {
+				continue
+			}
+			v, err := parseGLVersion(f.Number)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f.Name, err)
+			}
+			if v[0] > want[0] || v[0] == want[0] && v[1] > want[1] {
+				continue
+			}
+			found = found || v == want
+			s.apply(f.Require, opts.API, profile, true)
+			s.apply(f.Remove, opts.API, profile, false)
+		}
+		if !found {
+			return nil, fmt.Errorf("no %s feature with number %s", opts.API, opts.Version)
+		}
+	}
+	for _, name := range opts.Extensions {
+		var ext *GLInterface
+		for i := range reg.Extensions {
+			if reg.Extensions[i].Name == name {
+				ext = &reg.Extensions[i]
+				break
+			}
+		}
+		if ext == nil {
+			return nil, fmt.Errorf("unknown extension %s", name)
+		}
+		if !glSupports(ext.Supported, opts.API, opts.Profile) {
+			return nil, fmt.Errorf("extension %s is not supported by %s", name, opts.API)
+		}
+		s.apply(ext.Require, opts.API, profile, true)
+		s.apply(ext.Remove, opts.API, profile, false)
+	}
+	return s, nil
+}
+
+func glSupports(supported, api, profile string) bool {
+	for _, a := range strings.Split(supported, "|") {
+		if a == api || api == "gl" && profile == "core" && a == "glcore" {
+			return true
+		}
+	}
+	return false
+}
+
+func parseGLVersion(s string) ([2]int, error) {
+	var v [2]int
+	parts := strings.SplitN(s, ".", 2)
+	if len(parts) != 2 {
+		return v, fmt.Errorf("bad version %q", s)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return v, fmt.Errorf("bad version %q", s)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+// glGoType describes how a C scalar type is passed in Go.
+type glGoType struct {
+	name string
+	kind int // glInt, glFloat32, glFloat64 or glInt64
+}
+
+const (
+	glInt = iota
+	glFloat32
+	glFloat64
+	glInt64
+)
+
+// glScalarTypes maps the base types of gl.xml and wgl.xml to Go. Types not
+// listed are treated as handles.
+var glScalarTypes = map[string]glGoType{
+	"GLenum":                {"uint32", glInt},
+	"GLboolean":             {"uint8", glInt},
+	"GLbitfield":            {"uint32", glInt},
+	"GLbyte":                {"int8", glInt},
+	"GLubyte":               {"uint8", glInt},
+	"GLchar":                {"byte", glInt},
+	"GLcharARB":             {"byte", glInt},
+	"GLshort":               {"int16", glInt},
+	"GLushort":              {"uint16", glInt},
+	"GLhalf":                {"uint16", glInt},
+	"GLhalfNV":              {"uint16", glInt},
+	"GLint":                 {"int32", glInt},
+	"GLuint":                {"uint32", glInt},
+	"GLsizei":               {"int32", glInt},
+	"GLfixed":               {"int32", glInt},
+	"GLclampx":              {"int32", glInt},
+	"GLhandleARB":           {"uint32", glInt},
+	"GLintptr":              {"int", glInt},
+	"GLsizeiptr":            {"int", glInt},
+	"GLintptrARB":           {"int", glInt},
+	"GLsizeiptrARB":         {"int", glInt},
+	"GLvdpauSurfaceNV":      {"int", glInt},
+	"GLfloat":               {"float32", glFloat32},
+	"GLclampf":              {"float32", glFloat32},
+	"GLdouble":              {"float64", glFloat64},
+	"GLclampd":              {"float64", glFloat64},
+	"GLint64":               {"int64", glInt64},
+	"GLint64EXT":            {"int64", glInt64},
+	"GLuint64":              {"uint64", glInt64},
+	"GLuint64EXT":           {"uint64", glInt64},
+	"GLsync":                {"uintptr", glInt},
+	"GLeglImageOES":         {"uintptr", glInt},
+	"GLDEBUGPROC":           {"uintptr", glInt},
+	"GLDEBUGPROCARB":        {"uintptr", glInt},
+	"GLDEBUGPROCKHR":        {"uintptr", glInt},
+	"GLDEBUGPROCAMD":        {"uintptr", glInt},
+	"void":                  {"", glInt},
+	"char":                  {"byte", glInt},
+	"int":                   {"int32", glInt},
+	"unsigned int":          {"uint32", glInt},
+	"float":                 {"float32", glFloat32},
+	"BOOL":                  {"int32", glInt},
+	"BYTE":                  {"uint8", glInt},
+	"CHAR":                  {"byte", glInt},
+	"USHORT":                {"uint16", glInt},
+	"INT":                   {"int32", glInt},
+	"INT32":                 {"int32", glInt},
+	"UINT":                  {"uint32", glInt},
+	"DWORD":                 {"uint32", glInt},
+	"FLOAT":                 {"float32", glFloat32},
+	"INT64":                 {"int64", glInt64},
+	"LPVOID":                {"unsafe.Pointer", glInt},
+	"LPCSTR":                {"*byte", glInt},
+	"PROC":                  {"uintptr", glInt},
+	"HDC":                   {"w32.HDC", glInt},
+	"HGLRC":                 {"w32.HGLRC", glInt},
+	"HANDLE":                {"w32.HANDLE", glInt},
+	"COLORREF":              {"w32.COLORREF", glInt},
+	"RECT":                  {"w32.RECT", glInt},
+	"PIXELFORMATDESCRIPTOR": {"w32.PIXELFORMATDESCRIPTOR", glInt},
+}
+
+// goType returns the Go type of t and how it is passed. Unknown scalars
+// are taken for handles and pointers to unknown types become *byte.
+func (t GLType) goType() (string, int) {
+	s, ok := glScalarTypes[t.Base]
+	if !ok {
+		s = glGoType{"uintptr", glInt}
+		if t.Pointers > 0 {
+			s.name = "byte"
+		}
+	}
+	if t.Pointers == 0 {
+		return s.name, s.kind
+	}
+	n := t.Pointers
+	if t.Base == "void" {
+		s.name = "unsafe.Pointer"
+		n--
+	}
+	return strings.Repeat("*", n) + s.name, glInt
+}
+
+var glGoKeywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true,
+	"default": true, "defer": true, "else": true, "fallthrough": true, "for": true,
+	"func": true, "go": true, "goto": true, "if": true, "import": true,
+	"interface": true, "map": true, "package": true, "range": true, "return": true,
+	"select": true, "struct": true, "switch": true, "type": true, "var": true,
+	// Predeclared types, which the generated code converts to, and its
+	// own names.
+	"bool": true, "byte": true, "float32": true, "float64": true, "int": true,
+	"int8": true, "int16": true, "int32": true, "int64": true, "string": true,
+	"uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"fns": true, "args": true, "ret": true, "v": true,
+	"math": true, "syscall": true, "unsafe": true, "w32": true,
+}
+
+// glGoName strips the API prefix from a command name: glClear becomes
+// Clear and wglSwapIntervalEXT SwapIntervalEXT.
+func glGoName(name string) string {
+	for _, p := range []string{"wgl", "glX", "egl", "gl"} {
+		if strings.HasPrefix(name, p) && len(name) > len(p) {
+			return name[len(p):]
+		}
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// GenerateGLLoader writes Go source for the commands and enums of reg that
+// opts selects. The output is a Windows-only file holding the enums as
+// constants and a function table type with a method per command. Its Load
+// method resolves the commands with w32.WglGetProcAddress for the current
+// context, falling back to the exports of opengl32.dll, which is where the
+// OpenGL 1.1 functions live.
+//
+// Arguments are passed in integer registers or stack slots; 64-bit scalars
+// take two slots on 386, and 64-bit results are put together from both
+// result registers there. Commands returning floating point values cannot
+// be called this way and are left out, listed in a comment. Floating point
+// arguments only reach the callee on 386, where they go on the stack, and
+// on amd64, where the syscall trampoline also loads them into the XMM
+// registers; output with such commands builds on those two only.
+func GenerateGLLoader(w io.Writer, reg *GLRegistry, opts GLGenOptions) error {
+	if opts.Package == "" {
+		return fmt.Errorf("no package name")
+	}
+	if opts.TypeName == "" {
+		opts.TypeName = "Funcs"
+	}
+	sel, err := reg.selectInterfaces(&opts)
+	if err != nil {
+		return err
+	}
+
+	var commands, enums, skipped []string
+	for name, ok := range sel.commands {
+		if !ok {
+			continue
+		}
+		cmd := reg.Commands[name]
+		if cmd == nil {
+			return fmt.Errorf("command %s is not declared", name)
+		}
+		if _, kind := cmd.Result.goType(); kind == glFloat32 || kind == glFloat64 {
+			skipped = append(skipped, name)
+			continue
+		}
+		commands = append(commands, name)
+	}
+	for name, ok := range sel.enums {
+		if ok {
+			enums = append(enums, name)
+		}
+	}
+	sort.Strings(commands)
+	sort.Strings(enums)
+	sort.Strings(skipped)
+
+	build := "windows"
+	for _, name := range commands {
+		if reg.Commands[name].floatParams() {
+			build = "windows && (386 || amd64)"
+			break
+		}
+	}
+
+	var b bytes.Buffer
+	src := opts.Source
+	if src == "" {
+		src = "the Khronos registry"
+	}
+	fmt.Fprintf(&b, "// Code generated by glgen from %s; DO NOT EDIT.\n\n//go:build %s\n\n", src, build)
+	fmt.Fprintf(&b, "package %s\n\n", opts.Package)
+	b.WriteString("import (\n\"math\"\n\"syscall\"\n\"unsafe\"\n\n\"github.com/AllenDang/w32\"\n)\n\n")
+	b.WriteString("var (\n_ = math.Float32bits\n_ = unsafe.Sizeof(0)\n)\n\n")
+
+	if len(enums) > 0 {
+		b.WriteString("const (\n")
+		for _, name := range enums {
+			value, ok := glEnumValue(reg.Enums[name], opts.API)
+			if !ok {
+				return fmt.Errorf("enum %s has no value for %s", name, opts.API)
+			}
+			fmt.Fprintf(&b, "%s = %s\n", name, value)
+		}
+		b.WriteString(")\n\n")
+	}
+
+	what := opts.API
+	if opts.Version != "" {
+		what += " " + opts.Version
+	}
+	if opts.Profile != "" {
+		what += " " + opts.Profile
+	}
+	if len(opts.Extensions) > 0 {
+		what += " with " + strings.Join(opts.Extensions, ", ")
+	}
+	fmt.Fprintf(&b, "// %s holds the entry points of %s.\n// Call Load with a context current before using them.\n", opts.TypeName, what)
+	if len(skipped) > 0 {
+		fmt.Fprintf(&b, "//\n// Left out for returning floating point values: %s.\n", strings.Join(skipped, ", "))
+	}
+	fmt.Fprintf(&b, "type %s struct {\n", opts.TypeName)
+	for _, name := range commands {
+		fmt.Fprintf(&b, "proc%s uintptr\n", glGoName(name))
+	}
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(&b, "var %sNames = [...]string{\n", glLowerFirst(opts.TypeName))
+	for _, name := range commands {
+		fmt.Fprintf(&b, "%q,\n", name)
+	}
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(&b, `// Load resolves all entry points for the current context and returns the
+// names of those the driver does not provide, which must not be called.
+func (fns *%[1]s) Load() []string {
+	opengl32 := syscall.NewLazyDLL("opengl32.dll")
+	procs := []*uintptr{
+`, opts.TypeName)
+	for _, name := range commands {
+		fmt.Fprintf(&b, "&fns.proc%s,\n", glGoName(name))
+	}
+	fmt.Fprintf(&b, `}
+	var missing []string
+	for i, name := range %[1]sNames {
+		p := w32.WglGetProcAddress(name)
+		switch p {
+		case 0, 1, 2, 3, ^uintptr(0):
+			p = 0
+			if proc := opengl32.NewProc(name); proc.Find() == nil {
+				p = proc.Addr()
+			}
+		}
+		if p == 0 {
+			missing = append(missing, name)
+		}
+		*procs[i] = p
+	}
+	return missing
+}
+`, glLowerFirst(opts.TypeName))
+
+	for _, name := range commands {
+		if err := writeGLMethod(&b, opts.TypeName, reg.Commands[name]); err != nil {
+			return err
+		}
+	}
+
+	out, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("generated code does not parse: %v", err)
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// glEnumValue picks the value of an enum for api, preferring one made
+// for that API over a generic one.
+func glEnumValue(values []GLEnum, api string) (string, bool) {
+	var generic string
+	found := false
+	for _, e := range values {
+		if e.API == api {
+			return e.Value, true
+		}
+		if e.API == "" {
+			generic, found = e.Value, true
+		}
+	}
+	return generic, found
+}
+
+func glLowerFirst(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// floatParams reports whether any parameter of cmd is a float or double
+// passed by value.
+func (cmd *GLCommand) floatParams() bool {
+	for _, p := range cmd.Params {
+		if _, kind := p.Type.goType(); kind == glFloat32 || kind == glFloat64 {
+			return true
+		}
+	}
+	return false
+}
+
+func writeGLMethod(b *bytes.Buffer, typeName string, cmd *GLCommand) error {
+	type arg struct {
+		expr string
+		wide bool // 64 bits, taking two slots on 386
+	}
+	goName := glGoName(cmd.Name)
+	var params []string
+	var args []arg
+	split := false
+	for i, p := range cmd.Params {
+		name := p.Name
+		if name == "" {
+			name = fmt.Sprintf("p%d", i)
+		}
+		if glGoKeywords[name] {
+			name += "_"
+		}
+		typ, kind := p.Type.goType()
+		params = append(params, name+" "+typ)
+		switch {
+		case typ == "w32.RECT" || typ == "w32.PIXELFORMATDESCRIPTOR":
+			return fmt.Errorf("%s: %s passed by value", cmd.Name, p.Type)
+		case typ == "unsafe.Pointer":
+			args = append(args, arg{fmt.Sprintf("uintptr(%s)", name), false})
+		case strings.HasPrefix(typ, "*"):
+			args = append(args, arg{fmt.Sprintf("uintptr(unsafe.Pointer(%s))", name), false})
+		case kind == glFloat32:
+			args = append(args, arg{fmt.Sprintf("uintptr(math.Float32bits(%s))", name), false})
+		case kind == glFloat64:
+			args = append(args, arg{fmt.Sprintf("math.Float64bits(%s)", name), true})
+			split = true
+		case kind == glInt64:
+			args = append(args, arg{fmt.Sprintf("uint64(%s)", name), true})
+			split = true
+		default:
+			args = append(args, arg{fmt.Sprintf("uintptr(%s)", name), false})
+		}
+	}
+	result, resultKind := cmd.Result.goType()
+
+	fmt.Fprintf(b, "\nfunc (fns *%s) %s(%s) %s {\n", typeName, goName, strings.Join(params, ", "), result)
+	call := "syscall.SyscallN(fns.proc" + goName
+	if split {
+		b.WriteString("var args []uintptr\n")
+		for _, a := range args {
+			if a.wide {
+				fmt.Fprintf(b, "if v := %s; unsafe.Sizeof(uintptr(0)) == 4 {\nargs = append(args, uintptr(v), uintptr(v>>32))\n} else {\nargs = append(args, uintptr(v))\n}\n", a.expr)
+			} else {
+				fmt.Fprintf(b, "args = append(args, %s)\n", a.expr)
+			}
+		}
+		call += ", args...)"
+	} else {
+		for _, a := range args {
+			call += ", " + a.expr
+		}
+		call += ")"
+	}
+	switch {
+	case result == "":
+		b.WriteString(call + "\n")
+	case resultKind == glInt64:
+		// 386 returns 64-bit values in EDX:EAX, which are r2 and r1.
+		fmt.Fprintf(b, "r1, r2, _ := %s\nif unsafe.Sizeof(uintptr(0)) == 4 {\nreturn %s(uint64(r2)<<32 | uint64(r1))\n}\nreturn %s(r1)\n", call, result, result)
+	case strings.HasPrefix(result, "*") || result == "unsafe.Pointer":
+		fmt.Fprintf(b, "ret, _, _ := %s\nreturn (%s)(*(*unsafe.Pointer)(unsafe.Pointer(&ret)))\n", call, result)
+	default:
+		fmt.Fprintf(b, "ret, _, _ := %s\nreturn %s(ret)\n", call, result)
+	}
+	b.WriteString("}\n")
+	return nil
+}
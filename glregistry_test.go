@@ -0,0 +1,174 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readGLRegistry(t *testing.T, name string) *GLRegistry {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "gl", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	reg, err := ParseGLRegistry(f)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return reg
+}
+
+func TestParseGLRegistry(t *testing.T) {
+	reg := readGLRegistry(t, "gl.xml")
+	if len(reg.Commands) != 14 || len(reg.Features) != 5 || len(reg.Extensions) != 3 {
+		t.Errorf("%d commands, %d features and %d extensions", len(reg.Commands), len(reg.Features), len(reg.Extensions))
+	}
+
+	want := &GLCommand{
+		Name:   "glShaderSource",
+		Result: GLType{"void", 0},
+		Params: []GLParam{
+			{"shader", GLType{"GLuint", 0}},
+			{"count", GLType{"GLsizei", 0}},
+			{"string", GLType{"GLchar", 2}},
+			{"length", GLType{"GLint", 1}},
+		},
+	}
+	if got := reg.Commands["glShaderSource"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("glShaderSource is %+v, want %+v", got, want)
+	}
+	if got := reg.Commands["glGetString"].Result; got != (GLType{"GLubyte", 1}) {
+		t.Errorf("glGetString returns %v", got)
+	}
+	if got := reg.Commands["glMapBuffer"].Result; got.String() != "void*" {
+		t.Errorf("glMapBuffer returns %v", got)
+	}
+
+	wantEnums := []GLEnum{{"GL_ACTIVE_PROGRAM_EXT", "0x8B8D", "gles2"}, {"GL_ACTIVE_PROGRAM_EXT", "0x8259", "gl"}}
+	if got := reg.Enums["GL_ACTIVE_PROGRAM_EXT"]; !reflect.DeepEqual(got, wantEnums) {
+		t.Errorf("GL_ACTIVE_PROGRAM_EXT is %+v, want %+v", got, wantEnums)
+	}
+
+	f := reg.Features[3]
+	wantFeature := GLInterface{
+		Name: "GL_VERSION_3_2", API: "gl", Number: "3.2",
+		Require: []GLInterfaceItems{{
+			Commands: []string{"glFenceSync", "glWaitSync", "glGetInteger64v"},
+			Enums:    []string{"GL_MAX_SERVER_WAIT_TIMEOUT", "GL_TIMEOUT_IGNORED"},
+		}},
+		Remove: []GLInterfaceItems{{
+			Profile:  "core",
+			Commands: []string{"glAlphaFunc", "glBegin"},
+			Enums:    []string{"GL_QUADS"},
+		}},
+	}
+	if !reflect.DeepEqual(f, wantFeature) {
+		t.Errorf("feature 3 is %+v, want %+v", f, wantFeature)
+	}
+	if e := reg.Extensions[2]; e.Supported != "gl|gles2" || len(e.Require) != 2 || e.Require[1].API != "gles2" {
+		t.Errorf("extension 2 is %+v", e)
+	}
+
+	for _, bad := range []string{
+		`<registry><commands><command><proto>void glClear</proto></command></commands></registry>`,
+		`<registry><commands><command><proto><name>glClear</name></proto></command></commands></registry>`,
+		`<registry><commands><command><proto>void <name>glClear</name></proto><param>*<name>p</name></param></command></commands></registry>`,
+		`<registry><commands>`,
+	} {
+		if _, err := ParseGLRegistry(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseGLRegistry(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestGenerateGLLoader(t *testing.T) {
+	gl := readGLRegistry(t, "gl.xml")
+	wgl := readGLRegistry(t, "wgl.xml")
+	tests := []struct {
+		golden string
+		reg    *GLRegistry
+		opts   GLGenOptions
+	}{
+		// 64-bit arguments and results, a float result left out and
+		// compatibility commands removed from the core profile.
+		{"gl32core.go", gl, GLGenOptions{
+			Package: "gl", API: "gl", Version: "3.2", Profile: "core",
+			Extensions: []string{"GL_ARB_bindless_texture", "GL_NV_path_rendering"},
+			Source:     "gl.xml",
+		}},
+		// float and double arguments, pointer results and per-API enums.
+		{"gl15.go", gl, GLGenOptions{
+			Package: "gl", API: "gl", Version: "1.5",
+			Extensions: []string{"GL_EXT_separate_shader_objects"},
+		}},
+		{"gles2.go", gl, GLGenOptions{
+			Package: "gles", TypeName: "ES2", API: "gles2", Version: "2.0",
+			Extensions: []string{"GL_EXT_separate_shader_objects"},
+			Source:     "gl.xml",
+		}},
+		{"wgl.go", wgl, GLGenOptions{
+			Package: "gl", TypeName: "WGLFuncs", API: "wgl",
+			Extensions: []string{"WGL_ARB_create_context", "WGL_ARB_create_context_profile", "WGL_EXT_swap_control"},
+			Source:     "wgl.xml",
+		}},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := GenerateGLLoader(&b, tt.reg, tt.opts); err != nil {
+			t.Errorf("%s: %v", tt.golden, err)
+			continue
+		}
+		checkGolden(t, filepath.Join("gl", tt.golden+".golden"), b.Bytes())
+	}
+
+	errors := []struct {
+		reg  *GLRegistry
+		opts GLGenOptions
+		err  string
+	}{
+		{gl, GLGenOptions{API: "gl", Version: "1.0"}, "no package name"},
+		{gl, GLGenOptions{Package: "gl", API: "gl", Version: "3.0"}, "no gl feature with number 3.0"},
+		{gl, GLGenOptions{Package: "gl", API: "gl", Version: "3"}, `bad version "3"`},
+		{gl, GLGenOptions{Package: "gl", API: "gl", Extensions: []string{"GL_ARB_nothing"}}, "unknown extension GL_ARB_nothing"},
+		{gl, GLGenOptions{Package: "gl", API: "gles2", Extensions: []string{"GL_ARB_bindless_texture"}},
+			"extension GL_ARB_bindless_texture is not supported by gles2"},
+		{wgl, GLGenOptions{Package: "gl", API: "gl", Version: "1.0"}, "no gl feature with number 1.0"},
+	}
+	for _, tt := range errors {
+		err := GenerateGLLoader(new(bytes.Buffer), tt.reg, tt.opts)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("GenerateGLLoader(%+v) error is %v, want %q", tt.opts, err, tt.err)
+		}
+	}
+
+	// Commands and enums the registry requires but does not declare, and
+	// structs passed by value.
+	broken := readGLRegistry(t, "wgl.xml")
+	delete(broken.Commands, "wglCreateContext")
+	if err := GenerateGLLoader(new(bytes.Buffer), broken, GLGenOptions{Package: "gl", API: "wgl", Version: "1.0"}); err == nil ||
+		err.Error() != "command wglCreateContext is not declared" {
+		t.Errorf("undeclared command: %v", err)
+	}
+	broken = readGLRegistry(t, "wgl.xml")
+	delete(broken.Enums, "WGL_CONTEXT_CORE_PROFILE_BIT_ARB")
+	if err := GenerateGLLoader(new(bytes.Buffer), broken, GLGenOptions{Package: "gl", API: "wgl",
+		Extensions: []string{"WGL_ARB_create_context_profile"}}); err == nil ||
+		err.Error() != "enum WGL_CONTEXT_CORE_PROFILE_BIT_ARB has no value for wgl" {
+		t.Errorf("undeclared enum: %v", err)
+	}
+	broken = readGLRegistry(t, "wgl.xml")
+	broken.Commands["wglCreateContext"].Params[0].Type = GLType{"RECT", 0}
+	if err := GenerateGLLoader(new(bytes.Buffer), broken, GLGenOptions{Package: "gl", API: "wgl", Version: "1.0"}); err == nil ||
+		err.Error() != "wglCreateContext: RECT passed by value" {
+		t.Errorf("struct by value: %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// HandleKind names the type of a tracked handle.
+type HandleKind string
+
+const (
+	HandleKindDC        HandleKind = "DC"         // CreateCompatibleDC, CreateDC; DeleteDC
+	HandleKindWindowDC  HandleKind = "window DC"  // GetDC; ReleaseDC
+	HandleKindBitmap    HandleKind = "bitmap"     // CreateDIBSection, CreateBitmap; DeleteObject
+	HandleKindFont      HandleKind = "font"       // CreateFontIndirect; DeleteObject
+	HandleKindBrush     HandleKind = "brush"      // CreateBrushIndirect, CreateSolidBrush, CreateHatchBrush; DeleteObject
+	HandleKindPen       HandleKind = "pen"        // ExtCreatePen, CreatePen; DeleteObject
+	HandleKindIcon      HandleKind = "icon"       // CreateIcon, CreateIconIndirect; DestroyIcon
+	HandleKindImageList HandleKind = "image list" // ImageList_Create; ImageList_Destroy
+)
+
+// handleReleasers lists the function that frees each kind of handle.
+var handleReleasers = map[HandleKind]string{
+	HandleKindDC:        "DeleteDC",
+	HandleKindWindowDC:  "ReleaseDC",
+	HandleKindBitmap:    "DeleteObject",
+	HandleKindFont:      "DeleteObject",
+	HandleKindBrush:     "DeleteObject",
+	HandleKindPen:       "DeleteObject",
+	HandleKindIcon:      "DestroyIcon",
+	HandleKindImageList: "ImageList_Destroy",
+}
+
+type handleRecord struct {
+	handle  uintptr
+	kind    HandleKind
+	creator string
+	stack   []uintptr
+}
+
+var handleTracker struct {
+	enabled    int32 // read atomically so that untracked calls stay cheap
+	mu         sync.Mutex
+	live       map[uintptr]*handleRecord
+	mismatched []HandleMismatch
+}
+
+// TrackHandles turns handle tracking on or off. While it is on, the
+// wrappers of the functions listed with the HandleKind values record each
+// handle they create, with the stack of the caller, until the matching
+// function frees it. Turning tracking off forgets all records.
+func TrackHandles(on bool) {
+	handleTracker.mu.Lock()
+	defer handleTracker.mu.Unlock()
+	if on {
+		if handleTracker.live == nil {
+			handleTracker.live = make(map[uintptr]*handleRecord)
+		}
+		atomic.StoreInt32(&handleTracker.enabled, 1)
+		return
+	}
+	atomic.StoreInt32(&handleTracker.enabled, 0)
+	handleTracker.live = nil
+	handleTracker.mismatched = nil
+}
+
+// trackHandle records a handle returned by creator. A handle value that is
+// still recorded was freed without being seen, and its record is replaced.
+func trackHandle(kind HandleKind, creator string, h uintptr) {
+	if h == 0 || atomic.LoadInt32(&handleTracker.enabled) == 0 {
+		return
+	}
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	r := &handleRecord{h, kind, creator, append([]uintptr(nil), pcs[:n]...)}
+
+	handleTracker.mu.Lock()
+	defer handleTracker.mu.Unlock()
+	if handleTracker.live != nil {
+		handleTracker.live[h] = r
+	}
+}
+
+// untrackHandle drops the record of a handle that releaser is about to
+// free and returns it, or nil if the handle is not tracked. A handle passed
+// to the wrong function is noted as a mismatch, whether or not the call
+// then succeeds. The record is dropped before the call so that a new
+// handle with the same value, created by another goroutine as soon as the
+// old one is freed, keeps its own record.
+func untrackHandle(releaser string, h uintptr) *handleRecord {
+	if h == 0 || atomic.LoadInt32(&handleTracker.enabled) == 0 {
+		return nil
+	}
+	handleTracker.mu.Lock()
+	defer handleTracker.mu.Unlock()
+	r := handleTracker.live[h]
+	if r == nil {
+		return nil
+	}
+	delete(handleTracker.live, h)
+	if want := handleReleasers[r.kind]; want != releaser {
+		var pcs [32]uintptr
+		n := runtime.Callers(2, pcs[:])
+		handleTracker.mismatched = append(handleTracker.mismatched, HandleMismatch{
+			Handle:   h,
+			Kind:     r.kind,
+			Released: releaser,
+			Expected: want,
+			Site:     handleCallSite(pcs[:n]),
+		})
+	}
+	return r
+}
+
+// restoreHandle puts back a record dropped by untrackHandle when the call
+// that was to free the handle failed, leaving the handle alive.
+func restoreHandle(r *handleRecord) {
+	if r == nil {
+		return
+	}
+	handleTracker.mu.Lock()
+	defer handleTracker.mu.Unlock()
+	if handleTracker.live != nil && handleTracker.live[r.handle] == nil {
+		handleTracker.live[r.handle] = r
+	}
+}
+
+// HandleGroup is a set of live handles of one kind created at one call
+// site.
+type HandleGroup struct {
+	Kind    HandleKind
+	Creator string // the w32 function that created them
+	Site    string // the first caller outside this package, "function file:line"
+	Stack   string // the full stack of the first handle
+	Handles []uintptr
+}
+
+// HandleMismatch is a handle freed by the wrong function, such as a
+// GetDC handle passed to DeleteDC.
+type HandleMismatch struct {
+	Handle             uintptr
+	Kind               HandleKind
+	Released, Expected string
+	Site               string
+}
+
+// HandleReport lists the handles alive at the time it was made.
+type HandleReport struct {
+	Groups     []HandleGroup // largest first
+	Mismatches []HandleMismatch
+}
+
+// Live returns the number of live handles of kind, or of all kinds if
+// kind is empty.
+func (r *HandleReport) Live(kind HandleKind) int {
+	n := 0
+	for _, g := range r.Groups {
+		if kind == "" || g.Kind == kind {
+			n += len(g.Handles)
+		}
+	}
+	return n
+}
+
+func (r *HandleReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d live handles\n", r.Live(""))
+	for _, g := range r.Groups {
+		fmt.Fprintf(&b, "%d %s from %s at %s\n", len(g.Handles), g.Kind, g.Creator, g.Site)
+	}
+	for _, m := range r.Mismatches {
+		fmt.Fprintf(&b, "%s 0x%X freed with %s instead of %s at %s\n", m.Kind, m.Handle, m.Released, m.Expected, m.Site)
+	}
+	return b.String()
+}
+
+// GetHandleReport groups the live tracked handles by kind and call site.
+// It is empty unless tracking is on.
+func GetHandleReport() *HandleReport {
+	handleTracker.mu.Lock()
+	records := make([]*handleRecord, 0, len(handleTracker.live))
+	for _, r := range handleTracker.live {
+		records = append(records, r)
+	}
+	report := &HandleReport{Mismatches: append([]HandleMismatch(nil), handleTracker.mismatched...)}
+	handleTracker.mu.Unlock()
+
+	sort.Slice(records, func(i, j int) bool { return records[i].handle < records[j].handle })
+	index := make(map[string]int)
+	for _, r := range records {
+		site := handleCallSite(r.stack)
+		key := string(r.kind) + "\x00" + r.creator + "\x00" + site
+		i, ok := index[key]
+		if !ok {
+			i = len(report.Groups)
+			index[key] = i
+			report.Groups = append(report.Groups, HandleGroup{
+				Kind:    r.kind,
+				Creator: r.creator,
+				Site:    site,
+				Stack:   formatHandleStack(r.stack),
+			})
+		}
+		report.Groups[i].Handles = append(report.Groups[i].Handles, r.handle)
+	}
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		a, b := &report.Groups[i], &report.Groups[j]
+		if len(a.Handles) != len(b.Handles) {
+			return len(a.Handles) > len(b.Handles)
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Site < b.Site
+	})
+	return report
+}
+
+// handleTrackPkg is the function name prefix of this package.
+var handleTrackPkg = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	// name is "<path>.init.func1" or similar; the path ends at the first
+	// dot after the last slash.
+	slash := strings.LastIndex(name, "/")
+	return name[:slash+1+strings.Index(name[slash+1:], ".")+1]
+}()
+
+// handleCallSite returns the first frame of stack outside this package,
+// counting its tests as outside.
+func handleCallSite(stack []uintptr) string {
+	frames := runtime.CallersFrames(stack)
+	var first string
+	for {
+		f, more := frames.Next()
+		s := fmt.Sprintf("%s %s:%d", f.Function, f.File, f.Line)
+		if first == "" {
+			first = s
+		}
+		if !strings.HasPrefix(f.Function, handleTrackPkg) || strings.HasSuffix(f.File, "_test.go") {
+			return s
+		}
+		if !more {
+			return first
+		}
+	}
+}
+
+func formatHandleStack(stack []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(stack)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			return b.String()
+		}
+	}
+}
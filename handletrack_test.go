@@ -0,0 +1,195 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// The tracker only sees handle values, so these tests use made-up ones.
+
+func createFakeBitmap(h uintptr) {
+	trackHandle(HandleKindBitmap, "CreateDIBSection", h)
+}
+
+// checkTestSite checks that site names a function in this file.
+func checkTestSite(t *testing.T, what, site, function string) {
+	t.Helper()
+	if !strings.Contains(site, "handletrack_test.go:") || !strings.Contains(site, "."+function+" ") {
+		t.Errorf("%s site is %q, want %s in handletrack_test.go", what, site, function)
+	}
+}
+
+func TestHandleReport(t *testing.T) {
+	TrackHandles(true)
+	defer TrackHandles(false)
+
+	for h := uintptr(0x1003); h >= 0x1001; h-- {
+		createFakeBitmap(h)
+	}
+	trackHandle(HandleKindPen, "CreatePen", 0x2001)
+	trackHandle(HandleKindPen, "ExtCreatePen", 0x2002)
+	trackHandle(HandleKindPen, "ExtCreatePen", 0x2003)
+	trackHandle(HandleKindFont, "CreateFontIndirect", 0) // failed calls are not tracked
+
+	r := GetHandleReport()
+	if r.Live("") != 6 || r.Live(HandleKindBitmap) != 3 || r.Live(HandleKindPen) != 3 || r.Live(HandleKindFont) != 0 {
+		t.Errorf("live handles: %d in all, %d bitmaps, %d pens, %d fonts",
+			r.Live(""), r.Live(HandleKindBitmap), r.Live(HandleKindPen), r.Live(HandleKindFont))
+	}
+	// Largest group first, then by kind and site; handles in increasing
+	// order.
+	want := []struct {
+		kind     HandleKind
+		creator  string
+		function string
+		handles  []uintptr
+	}{
+		{HandleKindBitmap, "CreateDIBSection", "createFakeBitmap", []uintptr{0x1001, 0x1002, 0x1003}},
+		{HandleKindPen, "CreatePen", "TestHandleReport", []uintptr{0x2001}},
+		{HandleKindPen, "ExtCreatePen", "TestHandleReport", []uintptr{0x2002}},
+		{HandleKindPen, "ExtCreatePen", "TestHandleReport", []uintptr{0x2003}},
+	}
+	if len(r.Groups) != len(want) {
+		t.Fatalf("report has %d groups, want %d:\n%s", len(r.Groups), len(want), r)
+	}
+	for i, w := range want {
+		g := r.Groups[i]
+		if g.Kind != w.kind || g.Creator != w.creator || len(g.Handles) != len(w.handles) {
+			t.Errorf("group %d is %d %s from %s, want %d %s from %s",
+				i, len(g.Handles), g.Kind, g.Creator, len(w.handles), w.kind, w.creator)
+			continue
+		}
+		for j, h := range w.handles {
+			if g.Handles[j] != h {
+				t.Errorf("group %d holds %#x, want %#x", i, g.Handles, w.handles)
+				break
+			}
+		}
+		checkTestSite(t, fmt.Sprintf("group %d", i), g.Site, w.function)
+		if !strings.HasPrefix(g.Stack, strings.Fields(g.Site)[0]+"\n") {
+			t.Errorf("group %d stack does not start at its site:\n%s", i, g.Stack)
+		}
+	}
+
+	s := r.String()
+	for _, line := range []string{
+		"6 live handles\n",
+		"3 bitmap from CreateDIBSection at ",
+		"1 pen from CreatePen at ",
+	} {
+		if !strings.Contains(s, line) {
+			t.Errorf("String() does not contain %q:\n%s", line, s)
+		}
+	}
+
+	// A handle value seen again was freed without the tracker knowing;
+	// the new record replaces the old one.
+	trackHandle(HandleKindBrush, "CreateSolidBrush", 0x1002)
+	r = GetHandleReport()
+	if r.Live(HandleKindBitmap) != 2 || r.Live(HandleKindBrush) != 1 {
+		t.Errorf("after reuse: %d bitmaps, %d brushes", r.Live(HandleKindBitmap), r.Live(HandleKindBrush))
+	}
+}
+
+func TestHandleRelease(t *testing.T) {
+	TrackHandles(true)
+	defer TrackHandles(false)
+
+	createFakeBitmap(0x10)
+	trackHandle(HandleKindWindowDC, "GetDC", 0x20)
+	trackHandle(HandleKindIcon, "CreateIcon", 0x30)
+
+	// The right releaser.
+	if rec := untrackHandle("DeleteObject", 0x10); rec == nil || rec.handle != 0x10 {
+		t.Errorf("untrackHandle(DeleteObject, 0x10) = %+v", rec)
+	}
+	// Handles that are not tracked.
+	if rec := untrackHandle("DeleteObject", 0x10); rec != nil {
+		t.Errorf("0x10 is still tracked: %+v", rec)
+	}
+	if rec := untrackHandle("DeleteObject", 0); rec != nil {
+		t.Errorf("0 is tracked: %+v", rec)
+	}
+	r := GetHandleReport()
+	if r.Live("") != 2 || len(r.Mismatches) != 0 {
+		t.Errorf("after DeleteObject:\n%s", r)
+	}
+
+	// A failed release leaves the handle alive.
+	restoreHandle(untrackHandle("ReleaseDC", 0x20))
+	if r := GetHandleReport(); r.Live(HandleKindWindowDC) != 1 || len(r.Mismatches) != 0 {
+		t.Errorf("after a failed ReleaseDC:\n%s", r)
+	}
+
+	// The wrong releaser is reported even when the call fails, and the
+	// handle stays alive then.
+	restoreHandle(untrackHandle("DeleteDC", 0x20))
+	untrackHandle("DeleteObject", 0x30)
+	r = GetHandleReport()
+	if r.Live(HandleKindWindowDC) != 1 || r.Live(HandleKindIcon) != 0 {
+		t.Errorf("after the wrong releasers:\n%s", r)
+	}
+	want := []HandleMismatch{
+		{Handle: 0x20, Kind: HandleKindWindowDC, Released: "DeleteDC", Expected: "ReleaseDC"},
+		{Handle: 0x30, Kind: HandleKindIcon, Released: "DeleteObject", Expected: "DestroyIcon"},
+	}
+	if len(r.Mismatches) != len(want) {
+		t.Fatalf("%d mismatches, want %d:\n%s", len(r.Mismatches), len(want), r)
+	}
+	for i, w := range want {
+		m := r.Mismatches[i]
+		checkTestSite(t, "mismatch", m.Site, "TestHandleRelease")
+		m.Site = ""
+		if m != w {
+			t.Errorf("mismatch %d is %+v, want %+v", i, m, w)
+		}
+	}
+	if s := r.String(); !strings.Contains(s, "window DC 0x20 freed with DeleteDC instead of ReleaseDC at ") {
+		t.Errorf("String() does not report the DeleteDC mismatch:\n%s", s)
+	}
+
+	// A record is not restored over one made for the same value since.
+	rec := untrackHandle("ReleaseDC", 0x20)
+	trackHandle(HandleKindDC, "CreateCompatibleDC", 0x20)
+	restoreHandle(rec)
+	if r := GetHandleReport(); r.Live(HandleKindDC) != 1 || r.Live(HandleKindWindowDC) != 0 {
+		t.Errorf("restoreHandle replaced a newer record:\n%s", r)
+	}
+}
+
+func TestTrackHandlesOff(t *testing.T) {
+	createFakeBitmap(0x40)
+	if r := GetHandleReport(); r.Live("") != 0 {
+		t.Errorf("handles tracked while tracking is off:\n%s", r)
+	}
+
+	TrackHandles(true)
+	createFakeBitmap(0x40)
+	trackHandle(HandleKindWindowDC, "GetDC", 0x50)
+	rec := untrackHandle("DeleteDC", 0x50)
+	TrackHandles(false)
+	if r := GetHandleReport(); r.Live("") != 0 || len(r.Mismatches) != 0 {
+		t.Errorf("TrackHandles(false) kept state:\n%s", r)
+	}
+	restoreHandle(rec)
+	if rec := untrackHandle("DeleteObject", 0x40); rec != nil {
+		t.Errorf("untrackHandle found %+v while tracking is off", rec)
+	}
+
+	// Turning tracking back on starts from scratch.
+	TrackHandles(true)
+	defer TrackHandles(false)
+	if r := GetHandleReport(); r.Live("") != 0 || len(r.Mismatches) != 0 {
+		t.Errorf("old records came back:\n%s", r)
+	}
+	TrackHandles(true)
+	createFakeBitmap(0x60)
+	if r := GetHandleReport(); r.Live("") != 1 {
+		t.Errorf("%d live handles, want 1", r.Live(""))
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package w32
+
+// HDCCanvas is a Canvas that draws on a GDI device context. It does not
+// own the DC.
+type HDCCanvas struct {
+	hdc HDC
+}
+
+// NewHDCCanvas returns a canvas that draws on hdc.
+func NewHDCCanvas(hdc HDC) *HDCCanvas {
+	return &HDCCanvas{hdc: hdc}
+}
+
+// HDC returns the device context the canvas draws on.
+func (c *HDCCanvas) HDC() HDC {
+	return c.hdc
+}
+
+func (c *HDCCanvas) CreatePen(iStyle, cWidth int, color COLORREF) HPEN {
+	return CreatePen(iStyle, cWidth, color)
+}
+
+func (c *HDCCanvas) CreateSolidBrush(color COLORREF) HBRUSH {
+	return CreateSolidBrush(color)
+}
+
+func (c *HDCCanvas) CreateHatchBrush(iHatch int, color COLORREF) HBRUSH {
+	return CreateHatchBrush(iHatch, color)
+}
+
+func (c *HDCCanvas) CreateBrushIndirect(lplb *LOGBRUSH) HBRUSH {
+	return CreateBrushIndirect(lplb)
+}
+
+func (c *HDCCanvas) GetStockObject(fnObject int) HGDIOBJ {
+	return GetStockObject(fnObject)
+}
+
+func (c *HDCCanvas) SelectObject(hgdiobj HGDIOBJ) HGDIOBJ {
+	ret, _, _ := procSelectObject.Call(
+		uintptr(c.hdc),
+		uintptr(hgdiobj))
+
+	return HGDIOBJ(ret)
+}
+
+func (c *HDCCanvas) DeleteObject(hObject HGDIOBJ) bool {
+	return DeleteObject(hObject)
+}
+
+func (c *HDCCanvas) SetTextColor(crColor COLORREF) COLORREF {
+	ret, _, _ := procSetTextColor.Call(
+		uintptr(c.hdc),
+		uintptr(crColor))
+
+	return COLORREF(ret)
+}
+
+func (c *HDCCanvas) SetBkColor(crColor COLORREF) COLORREF {
+	ret, _, _ := procSetBkColor.Call(
+		uintptr(c.hdc),
+		uintptr(crColor))
+
+	return COLORREF(ret)
+}
+
+func (c *HDCCanvas) SetBkMode(iBkMode int) int {
+	ret, _, _ := procSetBkMode.Call(
+		uintptr(c.hdc),
+		uintptr(iBkMode))
+
+	return int(ret)
+}
+
+func (c *HDCCanvas) SetROP2(rop2 int) int {
+	return SetROP2(c.hdc, rop2)
+}
+
+func (c *HDCCanvas) SetBrushOrgEx(nXOrg, nYOrg int, lppt *POINT) bool {
+	return SetBrushOrgEx(c.hdc, nXOrg, nYOrg, lppt)
+}
+
+func (c *HDCCanvas) MoveToEx(x, y int, lpPoint *POINT) bool {
+	return MoveToEx(c.hdc, x, y, lpPoint)
+}
+
+func (c *HDCCanvas) LineTo(nXEnd, nYEnd int) bool {
+	return LineTo(c.hdc, nXEnd, nYEnd)
+}
+
+func (c *HDCCanvas) Rectangle(nLeftRect, nTopRect, nRightRect, nBottomRect int) bool {
+	return Rectangle(c.hdc, nLeftRect, nTopRect, nRightRect, nBottomRect)
+}
+
+func (c *HDCCanvas) Ellipse(nLeftRect, nTopRect, nRightRect, nBottomRect int) bool {
+	return Ellipse(c.hdc, nLeftRect, nTopRect, nRightRect, nBottomRect)
+}
+
+func (c *HDCCanvas) FillRect(lprc *RECT, hbr HBRUSH) bool {
+	return fillRect(c.hdc, lprc, hbr)
+}
+
+func (c *HDCCanvas) SetPixel(x, y int, crColor COLORREF) COLORREF {
+	return SetPixel(c.hdc, x, y, crColor)
+}
+
+func (c *HDCCanvas) GetPixel(x, y int) COLORREF {
+	return GetPixel(c.hdc, x, y)
+}
+
+// BitBlt copies from another HDCCanvas.
+func (c *HDCCanvas) BitBlt(nXDest, nYDest, nWidth, nHeight int, src Canvas, nXSrc, nYSrc int, dwRop uint) bool {
+	var hdcSrc HDC
+	switch s := src.(type) {
+	case nil:
+	case *HDCCanvas:
+		hdcSrc = s.hdc
+	default:
+		return false
+	}
+	ret, _, _ := procBitBlt.Call(
+		uintptr(c.hdc),
+		uintptr(nXDest),
+		uintptr(nYDest),
+		uintptr(nWidth),
+		uintptr(nHeight),
+		uintptr(hdcSrc),
+		uintptr(nXSrc),
+		uintptr(nYSrc),
+		uintptr(dwRop))
+
+	return ret != 0
+}
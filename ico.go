@@ -0,0 +1,322 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+// IconFile is a decoded .ico or .cur file.
+//
+// http://msdn.microsoft.com/en-us/library/ms997538.aspx
+type IconFile struct {
+	Cursor  bool
+	Entries []*IconFileEntry
+}
+
+// IconFileEntry is one image of an IconFile. Data holds the image exactly as
+// stored in the file: a PNG stream, or a BITMAPINFOHEADER followed by the
+// XOR and AND masks, which is also the layout of RT_ICON and (minus the
+// hotspot) RT_CURSOR resources.
+//
+// Icons use Planes and BitCount; cursors store their hotspot in the same
+// directory fields and use HotspotX and HotspotY instead.
+type IconFileEntry struct {
+	Width, Height      int
+	ColorCount         int
+	Planes             uint16
+	BitCount           uint16
+	HotspotX, HotspotY uint16
+	Data               []byte
+}
+
+// http://msdn.microsoft.com/en-us/library/ms997538.aspx
+type ICONDIRENTRY struct {
+	Width       byte
+	Height      byte
+	ColorCount  byte
+	Reserved    byte
+	Planes      uint16
+	BitCount    uint16
+	BytesInRes  uint32
+	ImageOffset uint32
+}
+
+// DecodeIconFile reads a .ico or .cur file.
+func DecodeIconFile(r io.Reader) (*IconFile, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var hdr GRPICONDIR
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &hdr); err != nil {
+		return nil, errors.New("icon file header is truncated")
+	}
+	if hdr.Reserved != 0 || (hdr.Type != 1 && hdr.Type != 2) {
+		return nil, errors.New("not an icon or cursor file")
+	}
+	entries := make([]ICONDIRENTRY, hdr.Count)
+	if err := binary.Read(bytes.NewReader(data[6:]), binary.LittleEndian, entries); err != nil {
+		return nil, errors.New("icon directory is truncated")
+	}
+
+	f := &IconFile{Cursor: hdr.Type == 2}
+	for i, de := range entries {
+		end := uint64(de.ImageOffset) + uint64(de.BytesInRes)
+		if end > uint64(len(data)) {
+			return nil, fmt.Errorf("icon image %d lies outside the file", i)
+		}
+		e := &IconFileEntry{
+			Width:      int(de.Width),
+			Height:     int(de.Height),
+			ColorCount: int(de.ColorCount),
+			Data:       data[de.ImageOffset:end],
+		}
+		if e.Width == 0 {
+			e.Width = 256
+		}
+		if e.Height == 0 {
+			e.Height = 256
+		}
+		if f.Cursor {
+			e.HotspotX, e.HotspotY = de.Planes, de.BitCount
+		} else {
+			e.Planes, e.BitCount = de.Planes, de.BitCount
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	return f, nil
+}
+
+// Encode writes f in .ico or .cur format.
+func (f *IconFile) Encode(w io.Writer) error {
+	typ := uint16(1)
+	if f.Cursor {
+		typ = 2
+	}
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, GRPICONDIR{Type: typ, Count: uint16(len(f.Entries))})
+	offset := 6 + 16*len(f.Entries)
+	for _, e := range f.Entries {
+		if e.Width < 1 || e.Width > 256 || e.Height < 1 || e.Height > 256 {
+			return fmt.Errorf("icon size %dx%d is out of range", e.Width, e.Height)
+		}
+		de := ICONDIRENTRY{
+			Width:       byte(e.Width),
+			Height:      byte(e.Height),
+			ColorCount:  byte(e.ColorCount),
+			Planes:      e.Planes,
+			BitCount:    e.BitCount,
+			BytesInRes:  uint32(len(e.Data)),
+			ImageOffset: uint32(offset),
+		}
+		if f.Cursor {
+			de.Planes, de.BitCount = e.HotspotX, e.HotspotY
+		}
+		binary.Write(&b, binary.LittleEndian, de)
+		offset += len(e.Data)
+	}
+	for _, e := range f.Entries {
+		b.Write(e.Data)
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Best returns the entry closest to size pixels wide, preferring the highest
+// color depth among equally close entries.
+func (f *IconFile) Best(size int) *IconFileEntry {
+	var best *IconFileEntry
+	bestDist := 0
+	for _, e := range f.Entries {
+		dist := e.Width - size
+		if dist < 0 {
+			// Upscaling looks worse than downscaling.
+			dist = -dist * 2
+		}
+		if best == nil || dist < bestDist || (dist == bestDist && e.bitCount() > best.bitCount()) {
+			best, bestDist = e, dist
+		}
+	}
+	return best
+}
+
+func (e *IconFileEntry) bitCount() uint16 {
+	if img, err := ParseIconImage(e.Data, false); err == nil {
+		return img.BitCount
+	}
+	return e.BitCount
+}
+
+// IsPNG reports whether the entry is stored as a PNG stream.
+func (e *IconFileEntry) IsPNG() bool {
+	return bytes.HasPrefix(e.Data, pngSignature)
+}
+
+// Image decodes the entry. Bitmap entries with an alpha channel use it;
+// others take their transparency from the AND mask.
+func (e *IconFileEntry) Image() (image.Image, error) {
+	if e.IsPNG() {
+		return png.Decode(bytes.NewReader(e.Data))
+	}
+	return decodeIconBitmap(e.Data)
+}
+
+// NewIconFileEntry encodes img as an icon image, either as a PNG stream or as
+// a 32 bpp bitmap with an AND mask generated from the alpha channel. Windows
+// Vista and later accept both; PNG is customary for 256x256 images.
+func NewIconFileEntry(img image.Image, compressPNG bool) (*IconFileEntry, error) {
+	m := toNRGBA(img)
+	w, h := m.Rect.Dx(), m.Rect.Dy()
+	if w < 1 || w > 256 || h < 1 || h > 256 {
+		return nil, fmt.Errorf("icon size %dx%d is out of range", w, h)
+	}
+
+	e := &IconFileEntry{Width: w, Height: h, Planes: 1, BitCount: 32}
+	if compressPNG {
+		var b bytes.Buffer
+		if err := png.Encode(&b, m); err != nil {
+			return nil, err
+		}
+		e.Data = b.Bytes()
+		return e, nil
+	}
+
+	xor := make([]byte, 4*w*h)
+	and := IconANDMask(m, 4)
+	andStride := len(and) / h
+	hdr := BITMAPINFOHEADER{
+		BiSize:      40,
+		BiWidth:     int32(w),
+		BiHeight:    int32(2 * h),
+		BiPlanes:    1,
+		BiBitCount:  32,
+		BiSizeImage: uint32(len(xor) + len(and)),
+	}
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, hdr)
+	// Both masks are stored bottom-up.
+	for y := h - 1; y >= 0; y-- {
+		row := m.Pix[y*m.Stride : y*m.Stride+4*w]
+		for x := 0; x < w; x++ {
+			p := row[4*x : 4*x+4]
+			b.Write([]byte{p[2], p[1], p[0], p[3]})
+		}
+	}
+	for y := h - 1; y >= 0; y-- {
+		b.Write(and[y*andStride : (y+1)*andStride])
+	}
+	e.Data = b.Bytes()
+	return e, nil
+}
+
+// IconANDMask returns the monochrome AND mask for img: one bit per pixel,
+// set for fully transparent pixels, most significant bit first, rows top-down
+// and padded to a multiple of align bytes. CreateIcon expects WORD (2 byte)
+// alignment, icon files DWORD (4 byte) alignment.
+func IconANDMask(img image.Image, align int) []byte {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	stride := ((w + 8*align - 1) / (8 * align)) * align
+	mask := make([]byte, stride*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if _, _, _, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA(); a == 0 {
+				mask[y*stride+x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+	}
+	return mask
+}
+
+// IconBitPlanes converts img to the AND and XOR bit planes that CreateIcon
+// takes with cPlanes 1 and cBitsPerPixel 32: a WORD-aligned monochrome AND
+// mask and top-down BGRA color bits with straight alpha. Fully transparent
+// pixels are black in the XOR plane so they stay invisible where the alpha
+// channel is ignored.
+func IconBitPlanes(img image.Image) (andBits, xorBits []byte) {
+	m := toNRGBA(img)
+	w, h := m.Rect.Dx(), m.Rect.Dy()
+	xorBits = make([]byte, 4*w*h)
+	for y := 0; y < h; y++ {
+		row := m.Pix[y*m.Stride : y*m.Stride+4*w]
+		for x := 0; x < w; x++ {
+			p := row[4*x : 4*x+4]
+			if p[3] == 0 {
+				continue
+			}
+			copy(xorBits[4*(y*w+x):], []byte{p[2], p[1], p[0], p[3]})
+		}
+	}
+	return IconANDMask(m, 2), xorBits
+}
+
+// decodeIconBitmap decodes the BITMAPINFOHEADER, XOR and AND masks of a
+// bitmap icon image.
+func decodeIconBitmap(data []byte) (image.Image, error) {
+	d, off, err := parseDIBHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	// The header covers both masks; the XOR mask is the top half.
+	d.Header.BiHeight /= 2
+	w, h := d.Width(), d.Height()
+	if w <= 0 || h <= 0 || w > 1<<12 || h > 1<<12 || d.TopDown() {
+		return nil, fmt.Errorf("bad icon bitmap size %dx%d", w, h)
+	}
+	if d.Header.BiCompression != BI_RGB {
+		return nil, fmt.Errorf("unsupported icon bitmap compression %d", d.Header.BiCompression)
+	}
+
+	xorSize := d.Stride() * h
+	if off+xorSize > len(data) {
+		return nil, errors.New("icon XOR mask is truncated")
+	}
+	d.Bits = data[off : off+xorSize]
+	img, err := d.Image()
+	if err != nil {
+		return nil, err
+	}
+	m := toNRGBA(img)
+	if d.Header.BiBitCount == 32 && d.hasAlpha() {
+		return m, nil
+	}
+
+	// Some 32 bpp icons omit the AND mask entirely.
+	andStride := DIBStride(w, 1)
+	andStart := off + xorSize
+	hasAND := andStart+andStride*h <= len(data)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := m.PixOffset(x, y)
+			m.Pix[i+3] = 0xFF
+			if hasAND && data[andStart+(h-1-y)*andStride+x/8]&(0x80>>uint(x%8)) != 0 {
+				m.Pix[i+3] = 0
+			}
+		}
+	}
+	return m, nil
+}
+
+// toNRGBA returns img as an *image.NRGBA whose bounds start at the origin.
+func toNRGBA(img image.Image) *image.NRGBA {
+	if m, ok := img.(*image.NRGBA); ok && m.Rect.Min == (image.Point{}) {
+		return m
+	}
+	b := img.Bounds()
+	m := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(m, m.Rect, img, b.Min, draw.Src)
+	return m
+}
@@ -0,0 +1,236 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testIconImage returns a w x h image whose left columns fade from
+// transparent to opaque.
+func testIconImage(w, h int) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a := uint8(255)
+			if x < 4 {
+				a = uint8(x * 60)
+			}
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x * 7), uint8(y * 5), 200, a})
+		}
+	}
+	return m
+}
+
+func checkSameImage(t *testing.T, name string, got, want image.Image) {
+	t.Helper()
+	if got.Bounds() != want.Bounds() {
+		t.Errorf("%s: bounds are %v, want %v", name, got.Bounds(), want.Bounds())
+		return
+	}
+	b := got.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			g := color.NRGBAModel.Convert(got.At(x, y))
+			w := color.NRGBAModel.Convert(want.At(x, y))
+			if g != w {
+				t.Errorf("%s: pixel (%d, %d) is %v, want %v", name, x, y, g, w)
+				return
+			}
+		}
+	}
+}
+
+func TestIconFileRoundTrip(t *testing.T) {
+	for _, cursor := range []bool{false, true} {
+		src := []*image.NRGBA{testIconImage(16, 16), testIconImage(48, 32), testIconImage(256, 256)}
+		f := &IconFile{Cursor: cursor}
+		for i, img := range src {
+			e, err := NewIconFileEntry(img, i == 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cursor {
+				e.HotspotX, e.HotspotY = uint16(i), uint16(i+1)
+			}
+			f.Entries = append(f.Entries, e)
+		}
+		var b bytes.Buffer
+		if err := f.Encode(&b); err != nil {
+			t.Fatal(err)
+		}
+		g, err := DecodeIconFile(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.Cursor != cursor || len(g.Entries) != len(src) {
+			t.Fatalf("decoded cursor %v with %d entries, want %v with %d", g.Cursor, len(g.Entries), cursor, len(src))
+		}
+		for i, e := range g.Entries {
+			want := *f.Entries[i]
+			if cursor {
+				// Cursors keep the hotspot where icons keep planes
+				// and bit count.
+				want.Planes, want.BitCount = 0, 0
+			}
+			if e.Width != want.Width || e.Height != want.Height ||
+				e.Planes != want.Planes || e.BitCount != want.BitCount ||
+				e.HotspotX != want.HotspotX || e.HotspotY != want.HotspotY ||
+				!bytes.Equal(e.Data, want.Data) {
+				t.Errorf("cursor %v entry %d: decoded %+v, want %+v", cursor, i, e, want)
+			}
+			if e.IsPNG() != (i == 2) {
+				t.Errorf("cursor %v entry %d: IsPNG() = %v", cursor, i, e.IsPNG())
+			}
+			img, err := e.Image()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSameImage(t, fmt.Sprintf("cursor %v entry %d", cursor, i), img, src[i])
+		}
+	}
+}
+
+func TestIconFileEncodeSize(t *testing.T) {
+	for _, size := range []int{0, 257} {
+		f := &IconFile{Entries: []*IconFileEntry{{Width: size, Height: 16}}}
+		if err := f.Encode(new(bytes.Buffer)); err == nil {
+			t.Errorf("encoded a %dx16 icon", size)
+		}
+		if _, err := NewIconFileEntry(image.NewNRGBA(image.Rect(0, 0, size, 16)), false); err == nil {
+			t.Errorf("NewIconFileEntry accepted a %dx16 image", size)
+		}
+	}
+}
+
+// testdata/icon/test.ico holds a 16x16 32 bpp bitmap with alpha, a 32x32
+// PNG, an 8x2 1 bpp bitmap and a 2x1 32 bpp bitmap without alpha. The
+// last two take their transparency from the AND mask.
+func TestDecodeIconFileGolden(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "icon", "test.ico"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := DecodeIconFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		w, h     int
+		bitCount uint16
+		png      bool
+	}{
+		{16, 16, 32, false},
+		{32, 32, 32, true},
+		{8, 2, 1, false},
+		{2, 1, 32, false},
+	}
+	if f.Cursor || len(f.Entries) != len(want) {
+		t.Fatalf("decoded cursor %v with %d entries", f.Cursor, len(f.Entries))
+	}
+	var images []image.Image
+	for i, e := range f.Entries {
+		w := want[i]
+		if e.Width != w.w || e.Height != w.h || e.BitCount != w.bitCount || e.IsPNG() != w.png {
+			t.Errorf("entry %d is %dx%d, %d bpp, PNG %v; want %dx%d, %d bpp, PNG %v",
+				i, e.Width, e.Height, e.BitCount, e.IsPNG(), w.w, w.h, w.bitCount, w.png)
+		}
+		img, err := e.Image()
+		if err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+		checkGoldenImage(t, fmt.Sprintf("icon/test-%d.png", i), img)
+		images = append(images, img)
+	}
+
+	// The AND mask entries, pixel by pixel.
+	black, white := color.NRGBA{0, 0, 0, 255}, color.NRGBA{255, 255, 255, 255}
+	transparent := func(c color.NRGBA) color.NRGBA { c.A = 0; return c }
+	mono := [][]color.NRGBA{
+		{transparent(white), transparent(black), white, black, white, black, white, black},
+		{black, black, black, black, white, white, white, white},
+	}
+	for y, row := range mono {
+		for x, c := range row {
+			if got := color.NRGBAModel.Convert(images[2].At(x, y)); got != c {
+				t.Errorf("1 bpp pixel (%d, %d) is %v, want %v", x, y, got, c)
+			}
+		}
+	}
+	for x, c := range []color.NRGBA{{0x10, 0x20, 0x30, 255}, {0x40, 0x50, 0x60, 0}} {
+		if got := color.NRGBAModel.Convert(images[3].At(x, 0)); got != c {
+			t.Errorf("32 bpp pixel (%d, 0) without alpha is %v, want %v", x, got, c)
+		}
+	}
+
+	if e := f.Best(20); e != f.Entries[0] {
+		t.Errorf("Best(20) is the %dx%d entry", e.Width, e.Height)
+	}
+	if e := f.Best(30); e != f.Entries[1] {
+		t.Errorf("Best(30) is the %dx%d entry", e.Width, e.Height)
+	}
+}
+
+func TestDecodeIconFileErrors(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "icon", "test.ico"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad type", []byte{0, 0, 3, 0, 0, 0}},
+		{"truncated directory", data[:6+16*3]},
+		{"truncated image", data[:len(data)-1]},
+	} {
+		if _, err := DecodeIconFile(bytes.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+func TestIconBitPlanes(t *testing.T) {
+	m := image.NewNRGBA(image.Rect(0, 0, 17, 2))
+	m.SetNRGBA(1, 0, color.NRGBA{0x10, 0x20, 0x30, 0x80})
+	m.SetNRGBA(16, 1, color.NRGBA{0xFF, 0, 0, 0xFF})
+	m.SetNRGBA(2, 1, color.NRGBA{0xFF, 0xFF, 0xFF, 0})
+
+	// Set bits are transparent pixels; rows are padded to the alignment.
+	and := []byte{
+		0xBF, 0xFF, 0x80, 0x00,
+		0xFF, 0xFF, 0x00, 0x00,
+	}
+	if got := IconANDMask(m, 4); !bytes.Equal(got, and) {
+		t.Errorf("IconANDMask(m, 4) = %x, want %x", got, and)
+	}
+	for align, want := range map[int][]byte{2: {0xFF, 0x00}, 4: {0xFF, 0x00, 0x00, 0x00}} {
+		if got := IconANDMask(image.NewNRGBA(image.Rect(0, 0, 8, 1)), align); !bytes.Equal(got, want) {
+			t.Errorf("IconANDMask of a transparent 8x1 image, aligned to %d = %x, want %x", align, got, want)
+		}
+	}
+
+	andBits, xorBits := IconBitPlanes(m)
+	if len(andBits) != 2*4 || len(xorBits) != 4*17*2 {
+		t.Fatalf("IconBitPlanes gives %d and %d bytes", len(andBits), len(xorBits))
+	}
+	if got := xorBits[4*1 : 4*2]; !bytes.Equal(got, []byte{0x30, 0x20, 0x10, 0x80}) {
+		t.Errorf("XOR pixel (1, 0) = %x", got)
+	}
+	if got := xorBits[4*(17+16) : 4*(17+17)]; !bytes.Equal(got, []byte{0, 0, 0xFF, 0xFF}) {
+		t.Errorf("XOR pixel (16, 1) = %x", got)
+	}
+	// Transparent pixels are black in the XOR plane.
+	if got := xorBits[4*(17+2) : 4*(17+3)]; !bytes.Equal(got, []byte{0, 0, 0, 0}) {
+		t.Errorf("XOR pixel (2, 1) = %x", got)
+	}
+}
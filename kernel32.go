@@ -2,9 +2,12 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+//go:build windows
+
 package w32
 
 import (
+	"os"
 	"syscall"
 	"unsafe"
 )
@@ -37,6 +40,12 @@ var (
 	procGetSystemTimes             = modkernel32.NewProc("GetSystemTimes")
 	procGetConsoleScreenBufferInfo = modkernel32.NewProc("GetConsoleScreenBufferInfo")
 	procSetConsoleTextAttribute    = modkernel32.NewProc("SetConsoleTextAttribute")
+	procCreateActCtx               = modkernel32.NewProc("CreateActCtxW")
+	procActivateActCtx             = modkernel32.NewProc("ActivateActCtx")
+	procDeactivateActCtx           = modkernel32.NewProc("DeactivateActCtx")
+	procReleaseActCtx              = modkernel32.NewProc("ReleaseActCtx")
+	procQueryPerformanceCounter    = modkernel32.NewProc("QueryPerformanceCounter")
+	procQueryPerformanceFrequency  = modkernel32.NewProc("QueryPerformanceFrequency")
 )
 
 func GetModuleHandle(modulename string) HINSTANCE {
@@ -257,3 +266,88 @@ func SetConsoleTextAttribute(hConsoleOutput HANDLE, wAttributes uint16) bool {
 		uintptr(wAttributes))
 	return ret != 0
 }
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/aa375125.aspx
+func CreateActCtx(actctx *ACTCTX) (HANDLE, error) {
+	if actctx.CbSize == 0 {
+		actctx.CbSize = uint32(unsafe.Sizeof(*actctx))
+	}
+	ret, _, err := procCreateActCtx.Call(uintptr(unsafe.Pointer(actctx)))
+
+	if HANDLE(ret) == INVALID_HANDLE_VALUE {
+		return 0, err
+	}
+
+	return HANDLE(ret), nil
+}
+
+// CreateActCtxFromManifest creates an activation context from manifest XML,
+// such as the output of Manifest.Bytes. CreateActCtx only reads manifests
+// from files or modules, so the XML goes through a temporary file.
+func CreateActCtxFromManifest(manifest []byte) (HANDLE, error) {
+	f, err := os.CreateTemp("", "w32-*.manifest")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(manifest)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return CreateActCtx(&ACTCTX{LpSource: syscall.StringToUTF16Ptr(f.Name())})
+}
+
+// ActivateActCtx activates hActCtx on the calling thread and returns the
+// cookie for DeactivateActCtx. Callers should hold runtime.LockOSThread
+// until the context is deactivated.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/aa374151.aspx
+func ActivateActCtx(hActCtx HANDLE) (uintptr, error) {
+	var cookie uintptr
+	ret, _, err := procActivateActCtx.Call(
+		uintptr(hActCtx),
+		uintptr(unsafe.Pointer(&cookie)))
+
+	if ret == 0 {
+		return 0, err
+	}
+
+	return cookie, nil
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/aa375140.aspx
+func DeactivateActCtx(flags uint32, cookie uintptr) error {
+	ret, _, err := procDeactivateActCtx.Call(
+		uintptr(flags),
+		cookie)
+
+	if ret == 0 {
+		return err
+	}
+
+	return nil
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/aa375713.aspx
+func ReleaseActCtx(hActCtx HANDLE) {
+	procReleaseActCtx.Call(uintptr(hActCtx))
+}
+
+func QueryPerformanceCounter() int64 {
+	var count int64
+	procQueryPerformanceCounter.Call(
+		uintptr(unsafe.Pointer(&count)))
+	return count
+}
+
+func QueryPerformanceFrequency() int64 {
+	var freq int64
+	procQueryPerformanceFrequency.Call(
+		uintptr(unsafe.Pointer(&freq)))
+	return freq
+}
@@ -0,0 +1,331 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Execution levels for Manifest.ExecutionLevel.
+const (
+	ExecutionAsInvoker            = "asInvoker"
+	ExecutionHighestAvailable     = "highestAvailable"
+	ExecutionRequireAdministrator = "requireAdministrator"
+)
+
+// DPI awareness modes for Manifest.DPIAwareness.
+const (
+	DPIUnaware          = "unaware"
+	DPISystemAware      = "system"
+	DPIPerMonitor       = "permonitor"
+	DPIPerMonitorV2     = "permonitorv2"
+	DPIUnawareGdiScaled = "gdiscaled"
+)
+
+// supportedOS IDs of the compatibility section.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/aa374191.aspx
+const (
+	SupportedOSVista = "{e2011457-1546-43c5-a5fe-008deee3d3f0}"
+	SupportedOSWin7  = "{35138b9a-5d96-4fbd-8e2d-a2440225f93a}"
+	SupportedOSWin8  = "{4a2f28e3-53b9-4441-ba9c-d69d4a4a6e38}"
+	SupportedOSWin81 = "{1f676c76-80e1-4239-95bb-83d0f6d0da78}"
+	SupportedOSWin10 = "{8e0f7a12-bfb3-4fe8-b9a5-48fd50a15a9a}"
+)
+
+// AllSupportedOS lists every supportedOS ID, oldest first.
+var AllSupportedOS = []string{
+	SupportedOSVista,
+	SupportedOSWin7,
+	SupportedOSWin8,
+	SupportedOSWin81,
+	SupportedOSWin10,
+}
+
+// Manifest describes a side-by-side application manifest. The zero value
+// produces a bare manifest; NewManifest returns the usual settings for a GUI
+// program.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/aa374191.aspx
+type Manifest struct {
+	// Name and Version make up the assemblyIdentity. Both are omitted
+	// when Name is empty. Version defaults to "1.0.0.0".
+	Name                  string
+	Version               string
+	ProcessorArchitecture string
+	Description           string
+
+	// CommonControls adds the Microsoft.Windows.Common-Controls 6.0
+	// dependency, without which comctl32 v5 is loaded.
+	CommonControls bool
+
+	// ExecutionLevel is one of the Execution* constants; empty omits
+	// the trustInfo section.
+	ExecutionLevel string
+	UIAccess       bool
+
+	// SupportedOS holds SupportedOS* IDs.
+	SupportedOS []string
+
+	// DPIAwareness is one of the DPI* constants; empty leaves DPI
+	// awareness to the system default.
+	DPIAwareness  string
+	LongPathAware bool
+
+	// UTF8 sets the process code page to UTF-8 (Windows 10 1903 and
+	// later).
+	UTF8 bool
+}
+
+// NewManifest returns a manifest for a GUI program named name that uses the
+// version 6 common controls, runs as invoker, declares every known Windows
+// version and is per-monitor DPI aware.
+func NewManifest(name string) *Manifest {
+	return &Manifest{
+		Name:           name,
+		CommonControls: true,
+		ExecutionLevel: ExecutionAsInvoker,
+		SupportedOS:    append([]string(nil), AllSupportedOS...),
+		DPIAwareness:   DPIPerMonitorV2,
+	}
+}
+
+var manifestGUID = regexp.MustCompile(`^\{[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\}$`)
+
+// Validate reports the first setting that Windows would reject.
+func (m *Manifest) Validate() error {
+	if m.Name != "" {
+		if strings.ContainsAny(m.Name, " \t\r\n\"'<>&") {
+			return fmt.Errorf("manifest: bad assembly name %q", m.Name)
+		}
+		if m.Version != "" && strings.Count(m.Version, ".") != 3 {
+			return fmt.Errorf("manifest: version %q must have four components", m.Version)
+		}
+		if m.Version != "" {
+			if _, err := ParseVersionString(m.Version); err != nil {
+				return fmt.Errorf("manifest: %v", err)
+			}
+		}
+		switch m.ProcessorArchitecture {
+		case "", "*", "x86", "amd64", "arm", "arm64", "ia64", "msil":
+		default:
+			return fmt.Errorf("manifest: bad processor architecture %q", m.ProcessorArchitecture)
+		}
+	} else if m.Version != "" || m.ProcessorArchitecture != "" {
+		return fmt.Errorf("manifest: version and processor architecture require a name")
+	}
+
+	switch m.ExecutionLevel {
+	case "", ExecutionAsInvoker, ExecutionHighestAvailable, ExecutionRequireAdministrator:
+	default:
+		return fmt.Errorf("manifest: bad execution level %q", m.ExecutionLevel)
+	}
+	if m.UIAccess && m.ExecutionLevel == "" {
+		return fmt.Errorf("manifest: uiAccess requires an execution level")
+	}
+
+	for _, id := range m.SupportedOS {
+		if !manifestGUID.MatchString(id) {
+			return fmt.Errorf("manifest: bad supportedOS id %q", id)
+		}
+	}
+
+	switch m.DPIAwareness {
+	case "", DPIUnaware, DPISystemAware, DPIPerMonitor, DPIPerMonitorV2, DPIUnawareGdiScaled:
+	default:
+		return fmt.Errorf("manifest: bad DPI awareness %q", m.DPIAwareness)
+	}
+	return nil
+}
+
+// The xml* types mirror the manifest schema. Elements that switch default
+// namespace carry it in their XMLName.
+type xmlAssembly struct {
+	XMLName         xml.Name              `xml:"urn:schemas-microsoft-com:asm.v1 assembly"`
+	ManifestVersion string                `xml:"manifestVersion,attr"`
+	Identity        *xmlAssemblyIdentity  `xml:"assemblyIdentity"`
+	Description     string                `xml:"description,omitempty"`
+	Dependency      *xmlDependency        `xml:"dependency"`
+	TrustInfo       *xmlTrustInfo         `xml:"urn:schemas-microsoft-com:asm.v3 trustInfo"`
+	Compatibility   *xmlCompatibility     `xml:"urn:schemas-microsoft-com:compatibility.v1 compatibility"`
+	Application     *xmlWindowsSettingsV3 `xml:"urn:schemas-microsoft-com:asm.v3 application"`
+}
+
+type xmlAssemblyIdentity struct {
+	Type                  string `xml:"type,attr"`
+	Name                  string `xml:"name,attr"`
+	Version               string `xml:"version,attr"`
+	ProcessorArchitecture string `xml:"processorArchitecture,attr"`
+	PublicKeyToken        string `xml:"publicKeyToken,attr,omitempty"`
+	Language              string `xml:"language,attr,omitempty"`
+}
+
+type xmlDependency struct {
+	Identity xmlAssemblyIdentity `xml:"dependentAssembly>assemblyIdentity"`
+}
+
+type xmlTrustInfo struct {
+	Level xmlExecutionLevel `xml:"security>requestedPrivileges>requestedExecutionLevel"`
+}
+
+type xmlExecutionLevel struct {
+	Level    string `xml:"level,attr"`
+	UIAccess bool   `xml:"uiAccess,attr"`
+}
+
+type xmlCompatibility struct {
+	SupportedOS []xmlSupportedOS `xml:"application>supportedOS"`
+}
+
+type xmlSupportedOS struct {
+	ID string `xml:"Id,attr"`
+}
+
+type xmlWindowsSettingsV3 struct {
+	Settings xmlWindowsSettings `xml:"windowsSettings"`
+}
+
+type xmlWindowsSettings struct {
+	DPIAware       *xmlSetting `xml:"http://schemas.microsoft.com/SMI/2005/WindowsSettings dpiAware"`
+	DPIAwareness   *xmlSetting `xml:"http://schemas.microsoft.com/SMI/2016/WindowsSettings dpiAwareness"`
+	GdiScaling     *xmlSetting `xml:"http://schemas.microsoft.com/SMI/2017/WindowsSettings gdiScaling"`
+	LongPathAware  *xmlSetting `xml:"http://schemas.microsoft.com/SMI/2016/WindowsSettings longPathAware"`
+	ActiveCodePage *xmlSetting `xml:"http://schemas.microsoft.com/SMI/2019/WindowsSettings activeCodePage"`
+}
+
+type xmlSetting struct {
+	Value string `xml:",chardata"`
+}
+
+// Bytes validates m and encodes it as UTF-8 XML, ready for
+// ResourceTable.AddManifest or CreateActCtxFromManifest.
+func (m *Manifest) Bytes() ([]byte, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	a := &xmlAssembly{ManifestVersion: "1.0", Description: m.Description}
+	if m.Name != "" {
+		id := &xmlAssemblyIdentity{
+			Type:                  "win32",
+			Name:                  m.Name,
+			Version:               m.Version,
+			ProcessorArchitecture: m.ProcessorArchitecture,
+		}
+		if id.Version == "" {
+			id.Version = "1.0.0.0"
+		}
+		if id.ProcessorArchitecture == "" {
+			id.ProcessorArchitecture = "*"
+		}
+		a.Identity = id
+	}
+	if m.CommonControls {
+		a.Dependency = &xmlDependency{xmlAssemblyIdentity{
+			Type:                  "win32",
+			Name:                  "Microsoft.Windows.Common-Controls",
+			Version:               "6.0.0.0",
+			ProcessorArchitecture: "*",
+			PublicKeyToken:        "6595b64144ccf1df",
+			Language:              "*",
+		}}
+	}
+	if m.ExecutionLevel != "" {
+		a.TrustInfo = &xmlTrustInfo{xmlExecutionLevel{m.ExecutionLevel, m.UIAccess}}
+	}
+	if len(m.SupportedOS) > 0 {
+		c := new(xmlCompatibility)
+		for _, id := range m.SupportedOS {
+			c.SupportedOS = append(c.SupportedOS, xmlSupportedOS{id})
+		}
+		a.Compatibility = c
+	}
+
+	var ws xmlWindowsSettings
+	switch m.DPIAwareness {
+	case DPIUnaware:
+		ws.DPIAware = &xmlSetting{"false"}
+	case DPIUnawareGdiScaled:
+		ws.DPIAware = &xmlSetting{"false"}
+		ws.GdiScaling = &xmlSetting{"true"}
+	case DPISystemAware:
+		ws.DPIAware = &xmlSetting{"true"}
+		ws.DPIAwareness = &xmlSetting{"system"}
+	case DPIPerMonitor:
+		ws.DPIAware = &xmlSetting{"true/pm"}
+		ws.DPIAwareness = &xmlSetting{"PerMonitor"}
+	case DPIPerMonitorV2:
+		// Windows 10 before 1703 ignores PerMonitorV2 and falls back to
+		// the next entry; older systems only read dpiAware.
+		ws.DPIAware = &xmlSetting{"true/pm"}
+		ws.DPIAwareness = &xmlSetting{"PerMonitorV2, PerMonitor"}
+	}
+	if m.LongPathAware {
+		ws.LongPathAware = &xmlSetting{"true"}
+	}
+	if m.UTF8 {
+		ws.ActiveCodePage = &xmlSetting{"UTF-8"}
+	}
+	if ws != (xmlWindowsSettings{}) {
+		a.Application = &xmlWindowsSettingsV3{ws}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "  ")
+	if err := enc.Encode(a); err != nil {
+		return nil, err
+	}
+	b.WriteByte('\n')
+
+	if err := checkManifestXML(b.Bytes()); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// checkManifestXML verifies that data is well-formed XML with an assembly
+// root in the asm.v1 namespace, which is all the loader insists on before
+// looking at individual elements.
+func checkManifestXML(data []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	// The text is UTF-8 by now even if the declaration says otherwise.
+	d.CharsetReader = func(label string, in io.Reader) (io.Reader, error) { return in, nil }
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("manifest: %v", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 && (t.Name.Local != "assembly" || t.Name.Space != "urn:schemas-microsoft-com:asm.v1") {
+				return fmt.Errorf("manifest: root element is %s %s, not asm.v1 assembly", t.Name.Space, t.Name.Local)
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("manifest: unbalanced elements")
+	}
+	return nil
+}
+
+// ValidateManifest checks that a hand-written manifest is well-formed and
+// rooted at an asm.v1 assembly element.
+func ValidateManifest(data []byte) error {
+	return checkManifestXML([]byte(DecodeManifest(data)))
+}
@@ -0,0 +1,107 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManifestBytes(t *testing.T) {
+	modes := []struct{ name, mode string }{
+		{"default", ""},
+		{"unaware", DPIUnaware},
+		{"system", DPISystemAware},
+		{"permonitor", DPIPerMonitor},
+		{"permonitorv2", DPIPerMonitorV2},
+		{"gdiscaled", DPIUnawareGdiScaled},
+	}
+	for _, m := range modes {
+		for _, comctl := range []bool{false, true} {
+			golden := "dpi-" + m.name
+			if comctl {
+				golden += "-comctl6"
+			}
+			data, err := (&Manifest{Name: "Example.App", DPIAwareness: m.mode, CommonControls: comctl}).Bytes()
+			if err != nil {
+				t.Errorf("%s: %v", golden, err)
+				continue
+			}
+			checkGolden(t, filepath.Join("manifest", golden+".xml"), data)
+		}
+	}
+
+	full := NewManifest("Example.App")
+	full.Version = "1.2.3.4"
+	full.ProcessorArchitecture = "amd64"
+	full.Description = "An example & more"
+	full.ExecutionLevel = ExecutionRequireAdministrator
+	full.UIAccess = true
+	full.LongPathAware = true
+	full.UTF8 = true
+	for _, tt := range []struct {
+		golden string
+		m      *Manifest
+	}{
+		{"bare.xml", &Manifest{}},
+		{"new.xml", NewManifest("Example.App")},
+		{"full.xml", full},
+	} {
+		data, err := tt.m.Bytes()
+		if err != nil {
+			t.Errorf("%s: %v", tt.golden, err)
+			continue
+		}
+		checkGolden(t, filepath.Join("manifest", tt.golden), data)
+		if err := ValidateManifest(data); err != nil {
+			t.Errorf("%s: %v", tt.golden, err)
+		}
+	}
+}
+
+func TestManifestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		m   Manifest
+		err string
+	}{
+		{Manifest{Name: "bad name"}, `manifest: bad assembly name "bad name"`},
+		{Manifest{Name: "a", Version: "1.2.3"}, `manifest: version "1.2.3" must have four components`},
+		{Manifest{Name: "a", Version: "1.2.3.x"}, "manifest: "},
+		{Manifest{Name: "a", ProcessorArchitecture: "mips"}, `manifest: bad processor architecture "mips"`},
+		{Manifest{Version: "1.0.0.0"}, "manifest: version and processor architecture require a name"},
+		{Manifest{ExecutionLevel: "root"}, `manifest: bad execution level "root"`},
+		{Manifest{UIAccess: true}, "manifest: uiAccess requires an execution level"},
+		{Manifest{SupportedOS: []string{"e2011457-1546-43c5-a5fe-008deee3d3f0"}},
+			`manifest: bad supportedOS id "e2011457-1546-43c5-a5fe-008deee3d3f0"`},
+		{Manifest{DPIAwareness: "PerMonitorV2"}, `manifest: bad DPI awareness "PerMonitorV2"`},
+	} {
+		err := tt.m.Validate()
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.m, err, tt.err)
+		}
+		if _, err := tt.m.Bytes(); err == nil {
+			t.Errorf("Bytes(%+v) succeeded", tt.m)
+		}
+	}
+}
+
+func TestValidateManifest(t *testing.T) {
+	for _, tt := range []struct {
+		data string
+		ok   bool
+	}{
+		{`<assembly xmlns="urn:schemas-microsoft-com:asm.v1" manifestVersion="1.0"/>`, true},
+		{"\xEF\xBB\xBF<?xml version=\"1.0\" encoding=\"UTF-8\"?><assembly xmlns=\"urn:schemas-microsoft-com:asm.v1\"/>", true},
+		{`<assembly manifestVersion="1.0"/>`, false},
+		{`<asm:assembly xmlns:asm="urn:schemas-microsoft-com:asm.v3"/>`, false},
+		{`<assembly xmlns="urn:schemas-microsoft-com:asm.v1"><dependency></assembly>`, false},
+		{`<assembly xmlns="urn:schemas-microsoft-com:asm.v1">`, false},
+	} {
+		if err := ValidateManifest([]byte(tt.data)); (err == nil) != tt.ok {
+			t.Errorf("ValidateManifest(%q) = %v", tt.data, err)
+		}
+	}
+}
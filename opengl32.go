@@ -2,9 +2,14 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+//go:build windows
+
 package w32
 
 import (
+	"errors"
+	"fmt"
+	"runtime"
 	"syscall"
 	"unsafe"
 )
@@ -15,6 +20,8 @@ var (
 	procwglCreateContext      = modopengl32.NewProc("wglCreateContext")
 	procwglCreateLayerContext = modopengl32.NewProc("wglCreateLayerContext")
 	procwglDeleteContext      = modopengl32.NewProc("wglDeleteContext")
+	procwglGetCurrentContext  = modopengl32.NewProc("wglGetCurrentContext")
+	procwglGetCurrentDC       = modopengl32.NewProc("wglGetCurrentDC")
 	procwglGetProcAddress     = modopengl32.NewProc("wglGetProcAddress")
 	procwglMakeCurrent        = modopengl32.NewProc("wglMakeCurrent")
 	procwglShareLists         = modopengl32.NewProc("wglShareLists")
@@ -45,6 +52,18 @@ func WglDeleteContext(hglrc HGLRC) bool {
 	return ret == TRUE
 }
 
+func WglGetCurrentContext() HGLRC {
+	ret, _, _ := procwglGetCurrentContext.Call()
+
+	return HGLRC(ret)
+}
+
+func WglGetCurrentDC() HDC {
+	ret, _, _ := procwglGetCurrentDC.Call()
+
+	return HDC(ret)
+}
+
 func WglGetProcAddress(szProc string) uintptr {
 	ret, _, _ := procwglGetProcAddress.Call(
 		uintptr(unsafe.Pointer(syscall.StringBytePtr(szProc))),
@@ -70,3 +89,217 @@ func WglShareLists(hglrc1, hglrc2 HGLRC) bool {
 
 	return ret == TRUE
 }
+
+// WGLContextFactory creates OpenGL contexts through WGL_ARB_create_context
+// and picks pixel formats through WGL_ARB_pixel_format. Those functions
+// can only be looked up with a context current, so NewWGLContextFactory
+// makes a throwaway window and legacy context to load them.
+type WGLContextFactory struct {
+	// Extensions lists the WGL extensions of the driver.
+	Extensions WGLExtensions
+
+	createContextAttribs   uintptr
+	choosePixelFormat      uintptr
+	getPixelFormatAttribiv uintptr
+	swapInterval           uintptr
+	getSwapInterval        uintptr
+}
+
+// NewWGLContextFactory loads the WGL extension functions of the default
+// display driver. It fails if WGL_ARB_create_context is not supported; the
+// other extensions are optional. The current context of the calling
+// thread is left as it was.
+func NewWGLContextFactory() (*WGLContextFactory, error) {
+	// The current context belongs to the thread, so the goroutine must not
+	// move between making the dummy context current, the lookups and
+	// restoring the previous context.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	hwnd := createWindowEx(0, syscall.StringToUTF16Ptr("STATIC"), nil,
+		WS_POPUP|WS_CLIPSIBLINGS|WS_CLIPCHILDREN, 0, 0, 1, 1, 0, 0, GetModuleHandle(""), nil)
+	if hwnd == 0 {
+		return nil, fmt.Errorf("cannot create dummy window: error %d", GetLastError())
+	}
+	defer destroyWindow(hwnd)
+	hdc := getDC(hwnd)
+	defer releaseDC(hwnd, hdc)
+
+	pfd := PixelFormatSpec{ColorBits: 24, DoubleBuffer: true}.Descriptor()
+	format := ChoosePixelFormat(hdc, &pfd)
+	if format == 0 || !SetPixelFormat(hdc, format, &pfd) {
+		return nil, fmt.Errorf("cannot set dummy pixel format: error %d", GetLastError())
+	}
+	rc := WglCreateContext(hdc)
+	if rc == 0 {
+		return nil, fmt.Errorf("cannot create dummy context: error %d", GetLastError())
+	}
+	defer WglDeleteContext(rc)
+	prevDC, prevRC := WglGetCurrentDC(), WglGetCurrentContext()
+	if !WglMakeCurrent(hdc, rc) {
+		return nil, fmt.Errorf("cannot make dummy context current: error %d", GetLastError())
+	}
+	defer WglMakeCurrent(prevDC, prevRC)
+
+	f := &WGLContextFactory{
+		createContextAttribs:   wglProc("wglCreateContextAttribsARB"),
+		choosePixelFormat:      wglProc("wglChoosePixelFormatARB"),
+		getPixelFormatAttribiv: wglProc("wglGetPixelFormatAttribivARB"),
+		swapInterval:           wglProc("wglSwapIntervalEXT"),
+		getSwapInterval:        wglProc("wglGetSwapIntervalEXT"),
+	}
+	var exts string
+	if p := wglProc("wglGetExtensionsStringARB"); p != 0 {
+		ret, _, _ := syscall.Syscall(p, 1, uintptr(hdc), 0, 0)
+		exts = bytePtrToString(*(*unsafe.Pointer)(unsafe.Pointer(&ret)))
+	} else if p := wglProc("wglGetExtensionsStringEXT"); p != 0 {
+		ret, _, _ := syscall.Syscall(p, 0, 0, 0, 0)
+		exts = bytePtrToString(*(*unsafe.Pointer)(unsafe.Pointer(&ret)))
+	}
+	f.Extensions = ParseWGLExtensions(exts)
+	if f.createContextAttribs == 0 {
+		return nil, errors.New("WGL_ARB_create_context is not supported")
+	}
+	return f, nil
+}
+
+// wglProc looks up an extension function of the current context. Some
+// drivers return small values instead of NULL for unknown names.
+func wglProc(name string) uintptr {
+	p := WglGetProcAddress(name)
+	switch p {
+	case 1, 2, 3, ^uintptr(0):
+		return 0
+	}
+	return p
+}
+
+// bytePtrToString copies the NUL-terminated string at p.
+func bytePtrToString(p unsafe.Pointer) string {
+	if p == nil {
+		return ""
+	}
+	n := 0
+	for *(*byte)(unsafe.Add(p, n)) != 0 {
+		n++
+	}
+	return string(unsafe.Slice((*byte)(p), n))
+}
+
+// ChoosePixelFormat returns the index of the pixel format of hdc that
+// best meets spec. Formats matching the spec according to
+// wglChoosePixelFormatARB are ranked with RankPixelFormats, so the choice
+// is the same as PickPixelFormat would make.
+func (f *WGLContextFactory) ChoosePixelFormat(hdc HDC, spec PixelFormatSpec) (int, error) {
+	if f.choosePixelFormat == 0 {
+		return 0, errors.New("WGL_ARB_pixel_format is not supported")
+	}
+	if spec.Samples > 0 && !f.Extensions.Has("WGL_ARB_multisample") {
+		return 0, errors.New("WGL_ARB_multisample is not supported")
+	}
+	if spec.SRGB && !f.Extensions.Has("WGL_ARB_framebuffer_sRGB") && !f.Extensions.Has("WGL_EXT_framebuffer_sRGB") {
+		return 0, errors.New("WGL_ARB_framebuffer_sRGB is not supported")
+	}
+
+	attribs := spec.WGLAttribs()
+	var formats [256]int32
+	var n uint32
+	ret, _, _ := syscall.Syscall6(f.choosePixelFormat, 6,
+		uintptr(hdc),
+		uintptr(unsafe.Pointer(&attribs[0])),
+		0,
+		uintptr(len(formats)),
+		uintptr(unsafe.Pointer(&formats[0])),
+		uintptr(unsafe.Pointer(&n)))
+	if ret == 0 || n == 0 {
+		return 0, errors.New("no pixel format matches")
+	}
+	if n > uint32(len(formats)) {
+		n = uint32(len(formats))
+	}
+	if f.getPixelFormatAttribiv == 0 {
+		return int(formats[0]), nil
+	}
+
+	query := wglPixelFormatQuery(f.Extensions)
+	values := make([]int32, len(query))
+	candidates := make([]PixelFormatCandidate, 0, n)
+	for _, format := range formats[:n] {
+		ret, _, _ := syscall.Syscall6(f.getPixelFormatAttribiv, 6,
+			uintptr(hdc),
+			uintptr(format),
+			0,
+			uintptr(len(query)),
+			uintptr(unsafe.Pointer(&query[0])),
+			uintptr(unsafe.Pointer(&values[0])))
+		if ret != 0 {
+			candidates = append(candidates, wglPixelFormatCandidate(int(format), query, values))
+		}
+	}
+	if len(candidates) == 0 {
+		return int(formats[0]), nil
+	}
+	best, err := PickPixelFormat(spec, candidates)
+	if err != nil {
+		return 0, err
+	}
+	return best.Candidate.Index, nil
+}
+
+// SetPixelFormat chooses the pixel format of hdc for spec and sets it. The
+// pixel format of a window can only be set once.
+func (f *WGLContextFactory) SetPixelFormat(hdc HDC, spec PixelFormatSpec) error {
+	format, err := f.ChoosePixelFormat(hdc, spec)
+	if err != nil {
+		return err
+	}
+	var pfd PIXELFORMATDESCRIPTOR
+	DescribePixelFormat(hdc, format, uint(unsafe.Sizeof(pfd)), &pfd)
+	if !SetPixelFormat(hdc, format, &pfd) {
+		return fmt.Errorf("cannot set pixel format %d: error %d", format, GetLastError())
+	}
+	return nil
+}
+
+// CreateContext creates a context with attribs for hdc, whose pixel
+// format must already be set. If share is not 0, the new context shares
+// its objects with it.
+func (f *WGLContextFactory) CreateContext(hdc HDC, share HGLRC, attribs WGLContextAttribs) (HGLRC, error) {
+	list := attribs.List()
+	ret, _, errno := syscall.Syscall(f.createContextAttribs, 3,
+		uintptr(hdc),
+		uintptr(share),
+		uintptr(unsafe.Pointer(&list[0])))
+	if ret != 0 {
+		return HGLRC(ret), nil
+	}
+	// Drivers disagree on the high word of these codes.
+	switch uint32(errno) & 0xFFFF {
+	case ERROR_INVALID_VERSION_ARB:
+		return 0, fmt.Errorf("OpenGL %d.%d is not supported", attribs.Major, attribs.Minor)
+	case ERROR_INVALID_PROFILE_ARB:
+		return 0, fmt.Errorf("OpenGL profile 0x%X is not supported", attribs.Profile)
+	}
+	return 0, fmt.Errorf("wglCreateContextAttribsARB failed: error %d", uint32(errno))
+}
+
+// SwapInterval sets how many vertical blanks SwapBuffers waits for on the
+// current context: 0 disables vsync. It returns false if
+// WGL_EXT_swap_control is not supported.
+func (f *WGLContextFactory) SwapInterval(interval int) bool {
+	if f.swapInterval == 0 {
+		return false
+	}
+	ret, _, _ := syscall.Syscall(f.swapInterval, 1, uintptr(interval), 0, 0)
+	return ret != 0
+}
+
+// GetSwapInterval returns the swap interval of the current context, or -1
+// if WGL_EXT_swap_control is not supported.
+func (f *WGLContextFactory) GetSwapInterval() int {
+	if f.getSwapInterval == 0 {
+		return -1
+	}
+	ret, _, _ := syscall.Syscall(f.getSwapInterval, 0, 0, 0, 0)
+	return int(int32(ret))
+}
@@ -0,0 +1,271 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+// PixelFormatSpec lists the minimum requirements for an OpenGL pixel
+// format. Zero fields are not required.
+type PixelFormatSpec struct {
+	ColorBits    int // red, green and blue together
+	AlphaBits    int
+	DepthBits    int
+	StencilBits  int
+	DoubleBuffer bool
+	SRGB         bool
+	Samples      int // multisample count
+	DrawToBitmap bool
+}
+
+// PixelFormatCandidate is a pixel format offered by a device. DescribePixelFormat
+// cannot report sRGB support or multisampling, so SRGB and Samples are only
+// set for formats enumerated through WGL_ARB_pixel_format.
+type PixelFormatCandidate struct {
+	Index   int // 1-based, as for SetPixelFormat
+	PFD     PIXELFORMATDESCRIPTOR
+	SRGB    bool
+	Samples int
+}
+
+// PixelFormatScore is the verdict on one candidate. A candidate that meets
+// the spec has no Rejections; Notes explain how it was ranked.
+type PixelFormatScore struct {
+	Candidate  PixelFormatCandidate
+	Rejections []string
+	Notes      []string
+	key        [5]int
+}
+
+// Acceptable reports whether the candidate meets the spec.
+func (s *PixelFormatScore) Acceptable() bool {
+	return len(s.Rejections) == 0
+}
+
+func (s *PixelFormatScore) String() string {
+	if !s.Acceptable() {
+		return fmt.Sprintf("format %d: rejected: %s", s.Candidate.Index, strings.Join(s.Rejections, "; "))
+	}
+	return fmt.Sprintf("format %d: %s", s.Candidate.Index, strings.Join(s.Notes, "; "))
+}
+
+// Descriptor returns a PIXELFORMATDESCRIPTOR requesting spec, for
+// ChoosePixelFormat.
+func (spec PixelFormatSpec) Descriptor() PIXELFORMATDESCRIPTOR {
+	pfd := PIXELFORMATDESCRIPTOR{
+		Version:     1,
+		DwFlags:     PFD_SUPPORT_OPENGL,
+		IPixelType:  PFD_TYPE_RGBA,
+		ColorBits:   byte(spec.ColorBits),
+		AlphaBits:   byte(spec.AlphaBits),
+		DepthBits:   byte(spec.DepthBits),
+		StencilBits: byte(spec.StencilBits),
+		ILayerType:  PFD_MAIN_PLANE,
+	}
+	pfd.Size = uint16(unsafe.Sizeof(pfd))
+	if spec.DrawToBitmap {
+		pfd.DwFlags |= PFD_DRAW_TO_BITMAP
+	} else {
+		pfd.DwFlags |= PFD_DRAW_TO_WINDOW
+	}
+	if spec.DoubleBuffer {
+		pfd.DwFlags |= PFD_DOUBLEBUFFER
+	}
+	return pfd
+}
+
+// ScorePixelFormat judges one candidate against spec.
+func ScorePixelFormat(spec PixelFormatSpec, c PixelFormatCandidate) PixelFormatScore {
+	s := PixelFormatScore{Candidate: c}
+	pfd := &c.PFD
+	reject := func(format string, args ...interface{}) {
+		s.Rejections = append(s.Rejections, fmt.Sprintf(format, args...))
+	}
+	note := func(format string, args ...interface{}) {
+		s.Notes = append(s.Notes, fmt.Sprintf(format, args...))
+	}
+
+	// Hard requirements.
+	if pfd.DwFlags&PFD_SUPPORT_OPENGL == 0 {
+		reject("no OpenGL support")
+	}
+	if pfd.IPixelType != PFD_TYPE_RGBA {
+		reject("color index pixels")
+	}
+	if spec.DrawToBitmap && pfd.DwFlags&PFD_DRAW_TO_BITMAP == 0 {
+		reject("cannot draw to a bitmap")
+	}
+	if !spec.DrawToBitmap && pfd.DwFlags&PFD_DRAW_TO_WINDOW == 0 {
+		reject("cannot draw to a window")
+	}
+	if spec.DoubleBuffer && pfd.DwFlags&PFD_DOUBLEBUFFER == 0 {
+		reject("single buffered")
+	}
+	bits := []struct {
+		name      string
+		have, req int
+	}{
+		{"color", int(pfd.ColorBits), spec.ColorBits},
+		{"alpha", int(pfd.AlphaBits), spec.AlphaBits},
+		{"depth", int(pfd.DepthBits), spec.DepthBits},
+		{"stencil", int(pfd.StencilBits), spec.StencilBits},
+	}
+	for _, b := range bits {
+		if b.have < b.req {
+			reject("%d %s bits, need %d", b.have, b.name, b.req)
+		}
+	}
+	if spec.SRGB && !c.SRGB {
+		reject("not sRGB capable")
+	}
+	if c.Samples < spec.Samples {
+		reject("%d samples, need %d", c.Samples, spec.Samples)
+	}
+	if !s.Acceptable() {
+		return s
+	}
+
+	// Ranking, most important first: acceleration, unwanted features,
+	// then how far color, samples and the ancillary buffers overshoot.
+	switch {
+	case pfd.DwFlags&PFD_GENERIC_FORMAT == 0:
+		note("hardware accelerated (ICD)")
+	case pfd.DwFlags&PFD_GENERIC_ACCELERATED != 0:
+		s.key[0] = 1
+		note("partly accelerated (MCD)")
+	default:
+		s.key[0] = 2
+		note("software renderer")
+	}
+	if !spec.DoubleBuffer && pfd.DwFlags&PFD_DOUBLEBUFFER != 0 {
+		s.key[1]++
+		note("double buffered though not requested")
+	}
+	if pfd.DwFlags&PFD_STEREO != 0 {
+		s.key[1]++
+		note("stereo")
+	}
+	for i, b := range bits {
+		d := b.have - b.req
+		if d > 0 {
+			note("%d %s bits, %d more than needed", b.have, b.name, d)
+		}
+		if i < 2 {
+			s.key[2] += d * d
+		} else {
+			s.key[4] += d * d
+		}
+	}
+	if d := c.Samples - spec.Samples; d > 0 {
+		s.key[3] = d * d
+		note("%d samples, %d more than needed", c.Samples, d)
+	}
+	if n := int(pfd.AccumBits) + int(pfd.AuxBuffers); n > 0 {
+		s.key[4] += n * n
+		note("%d accumulation bits and %d aux buffers", pfd.AccumBits, pfd.AuxBuffers)
+	}
+	if len(s.Notes) == 1 {
+		note("exact match")
+	}
+	return s
+}
+
+// RankPixelFormats scores all candidates and sorts them best first;
+// rejected candidates come last in index order. Ties go to the lower
+// index, so the result only depends on the candidates.
+func RankPixelFormats(spec PixelFormatSpec, candidates []PixelFormatCandidate) []PixelFormatScore {
+	scores := make([]PixelFormatScore, len(candidates))
+	for i, c := range candidates {
+		scores[i] = ScorePixelFormat(spec, c)
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		a, b := &scores[i], &scores[j]
+		if a.Acceptable() != b.Acceptable() {
+			return a.Acceptable()
+		}
+		if a.Acceptable() && a.key != b.key {
+			for k := range a.key {
+				if a.key[k] != b.key[k] {
+					return a.key[k] < b.key[k]
+				}
+			}
+		}
+		return a.Candidate.Index < b.Candidate.Index
+	})
+	return scores
+}
+
+// PickPixelFormat returns the best candidate for spec. If none is
+// acceptable, the error lists why each was rejected.
+func PickPixelFormat(spec PixelFormatSpec, candidates []PixelFormatCandidate) (PixelFormatScore, error) {
+	if len(candidates) == 0 {
+		return PixelFormatScore{}, errors.New("no pixel formats to choose from")
+	}
+	scores := RankPixelFormats(spec, candidates)
+	if scores[0].Acceptable() {
+		return scores[0], nil
+	}
+	lines := make([]string, len(scores))
+	for i := range scores {
+		lines[i] = scores[i].String()
+	}
+	return PixelFormatScore{}, fmt.Errorf("no acceptable pixel format:\n%s", strings.Join(lines, "\n"))
+}
+
+var pfdFlagNames = []struct {
+	flag uint32
+	name string
+}{
+	{PFD_DOUBLEBUFFER, "PFD_DOUBLEBUFFER"},
+	{PFD_STEREO, "PFD_STEREO"},
+	{PFD_DRAW_TO_WINDOW, "PFD_DRAW_TO_WINDOW"},
+	{PFD_DRAW_TO_BITMAP, "PFD_DRAW_TO_BITMAP"},
+	{PFD_SUPPORT_GDI, "PFD_SUPPORT_GDI"},
+	{PFD_SUPPORT_OPENGL, "PFD_SUPPORT_OPENGL"},
+	{PFD_GENERIC_FORMAT, "PFD_GENERIC_FORMAT"},
+	{PFD_NEED_PALETTE, "PFD_NEED_PALETTE"},
+	{PFD_NEED_SYSTEM_PALETTE, "PFD_NEED_SYSTEM_PALETTE"},
+	{PFD_SWAP_EXCHANGE, "PFD_SWAP_EXCHANGE"},
+	{PFD_SWAP_COPY, "PFD_SWAP_COPY"},
+	{PFD_SWAP_LAYER_BUFFERS, "PFD_SWAP_LAYER_BUFFERS"},
+	{PFD_GENERIC_ACCELERATED, "PFD_GENERIC_ACCELERATED"},
+	{PFD_SUPPORT_DIRECTDRAW, "PFD_SUPPORT_DIRECTDRAW"},
+	{PFD_DIRECT3D_ACCELERATED, "PFD_DIRECT3D_ACCELERATED"},
+	{PFD_SUPPORT_COMPOSITION, "PFD_SUPPORT_COMPOSITION"},
+	{PFD_DEPTH_DONTCARE, "PFD_DEPTH_DONTCARE"},
+	{PFD_DOUBLEBUFFER_DONTCARE, "PFD_DOUBLEBUFFER_DONTCARE"},
+	{PFD_STEREO_DONTCARE, "PFD_STEREO_DONTCARE"},
+}
+
+// PFDFlagsString returns flags as PFD_* names joined by "|", with any
+// unknown bits in hex.
+func PFDFlagsString(flags uint32) string {
+	var names []string
+	for _, f := range pfdFlagNames {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+			flags &^= f.flag
+		}
+	}
+	if flags != 0 || len(names) == 0 {
+		names = append(names, fmt.Sprintf("0x%X", flags))
+	}
+	return strings.Join(names, "|")
+}
+
+func (pfd *PIXELFORMATDESCRIPTOR) String() string {
+	typ := "RGBA"
+	if pfd.IPixelType == PFD_TYPE_COLORINDEX {
+		typ = "COLORINDEX"
+	}
+	return fmt.Sprintf("%s color %d (r%d g%d b%d a%d) depth %d stencil %d accum %d aux %d %s",
+		typ, pfd.ColorBits, pfd.RedBits, pfd.GreenBits, pfd.BlueBits, pfd.AlphaBits,
+		pfd.DepthBits, pfd.StencilBits, pfd.AccumBits, pfd.AuxBuffers, PFDFlagsString(pfd.DwFlags))
+}
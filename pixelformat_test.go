@@ -0,0 +1,205 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const pfdWindowGL = PFD_SUPPORT_OPENGL | PFD_DRAW_TO_WINDOW | PFD_DOUBLEBUFFER
+
+// pixelFormat returns a candidate with the given flags, color, alpha, depth
+// and stencil bits.
+func pixelFormat(index int, flags uint32, color, alpha, depth, stencil byte) PixelFormatCandidate {
+	return PixelFormatCandidate{Index: index, PFD: PIXELFORMATDESCRIPTOR{
+		DwFlags:     flags,
+		IPixelType:  PFD_TYPE_RGBA,
+		ColorBits:   color,
+		AlphaBits:   alpha,
+		DepthBits:   depth,
+		StencilBits: stencil,
+	}}
+}
+
+func TestScorePixelFormatRejections(t *testing.T) {
+	spec := PixelFormatSpec{ColorBits: 24, AlphaBits: 8, DepthBits: 24, StencilBits: 8, DoubleBuffer: true}
+	colorIndex := pixelFormat(1, pfdWindowGL, 24, 8, 24, 8)
+	colorIndex.PFD.IPixelType = PFD_TYPE_COLORINDEX
+	srgb := pixelFormat(1, pfdWindowGL, 24, 8, 24, 8)
+	srgb.Samples = 2
+	tests := []struct {
+		spec PixelFormatSpec
+		c    PixelFormatCandidate
+		want []string
+	}{
+		{spec, pixelFormat(1, pfdWindowGL, 24, 8, 24, 8), nil},
+		{spec, pixelFormat(1, PFD_DRAW_TO_WINDOW|PFD_DOUBLEBUFFER, 24, 8, 24, 8), []string{"no OpenGL support"}},
+		{spec, colorIndex, []string{"color index pixels"}},
+		{spec, pixelFormat(1, PFD_SUPPORT_OPENGL|PFD_DRAW_TO_BITMAP, 24, 8, 24, 8),
+			[]string{"cannot draw to a window", "single buffered"}},
+		{PixelFormatSpec{DrawToBitmap: true}, pixelFormat(1, pfdWindowGL, 24, 8, 24, 8),
+			[]string{"cannot draw to a bitmap"}},
+		{spec, pixelFormat(1, pfdWindowGL, 16, 0, 16, 0), []string{
+			"16 color bits, need 24", "0 alpha bits, need 8", "16 depth bits, need 24", "0 stencil bits, need 8",
+		}},
+		{PixelFormatSpec{SRGB: true, Samples: 4}, srgb, []string{"not sRGB capable", "2 samples, need 4"}},
+	}
+	for _, tt := range tests {
+		s := ScorePixelFormat(tt.spec, tt.c)
+		if !reflect.DeepEqual(s.Rejections, tt.want) {
+			t.Errorf("%+v against %+v: rejected for %q, want %q", tt.c, tt.spec, s.Rejections, tt.want)
+		}
+		if s.Acceptable() != (tt.want == nil) {
+			t.Errorf("%+v against %+v: Acceptable() = %v", tt.c, tt.spec, s.Acceptable())
+		}
+	}
+}
+
+func TestRankPixelFormats(t *testing.T) {
+	spec := PixelFormatSpec{ColorBits: 24, DepthBits: 24, DoubleBuffer: true}
+	tests := []struct {
+		name       string
+		candidates []PixelFormatCandidate
+		want       []int // indexes, best first
+	}{
+		{"acceleration", []PixelFormatCandidate{
+			pixelFormat(1, pfdWindowGL|PFD_GENERIC_FORMAT, 24, 0, 24, 0),
+			pixelFormat(2, pfdWindowGL|PFD_GENERIC_FORMAT|PFD_GENERIC_ACCELERATED, 24, 0, 24, 0),
+			pixelFormat(3, pfdWindowGL, 32, 8, 32, 8),
+		}, []int{3, 2, 1}},
+		{"overshoot", []PixelFormatCandidate{
+			pixelFormat(1, pfdWindowGL, 32, 8, 24, 0), // 8 color and 8 alpha bits over
+			pixelFormat(2, pfdWindowGL, 24, 0, 32, 8), // only ancillary bits over
+			pixelFormat(3, pfdWindowGL, 32, 0, 24, 0), // 8 color bits over
+			pixelFormat(4, pfdWindowGL|PFD_STEREO, 24, 0, 24, 0),
+			pixelFormat(5, pfdWindowGL, 24, 0, 24, 0),  // exact
+			pixelFormat(6, pfdWindowGL, 16, 0, 24, 0),  // rejected
+			pixelFormat(7, pfdWindowGL, 24, 0, 24, 16), // 16 stencil bits over
+		}, []int{5, 2, 7, 3, 1, 4, 6}},
+		{"ties", []PixelFormatCandidate{
+			pixelFormat(9, pfdWindowGL, 16, 0, 24, 0),
+			pixelFormat(8, pfdWindowGL, 32, 0, 24, 0),
+			pixelFormat(4, pfdWindowGL, 16, 0, 16, 0),
+			pixelFormat(6, pfdWindowGL, 32, 0, 24, 0),
+		}, []int{6, 8, 4, 9}},
+	}
+	for _, tt := range tests {
+		var got []int
+		for _, s := range RankPixelFormats(spec, tt.candidates) {
+			got = append(got, s.Candidate.Index)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ranked %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Multisampling overshoot counts after color but before the
+	// ancillary buffers.
+	msaa := PixelFormatSpec{ColorBits: 24, Samples: 4}
+	a := pixelFormat(1, PFD_SUPPORT_OPENGL|PFD_DRAW_TO_WINDOW, 24, 0, 24, 0)
+	a.Samples = 8
+	b := pixelFormat(2, PFD_SUPPORT_OPENGL|PFD_DRAW_TO_WINDOW, 32, 0, 0, 0)
+	b.Samples = 4
+	c := pixelFormat(3, PFD_SUPPORT_OPENGL|PFD_DRAW_TO_WINDOW, 24, 0, 0, 0)
+	c.Samples = 4
+	c.PFD.AccumBits, c.PFD.AuxBuffers = 64, 4
+	var got []int
+	for _, s := range RankPixelFormats(msaa, []PixelFormatCandidate{a, b, c}) {
+		got = append(got, s.Candidate.Index)
+	}
+	if want := []int{3, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("multisampling: ranked %v, want %v", got, want)
+	}
+}
+
+func TestScorePixelFormatNotes(t *testing.T) {
+	spec := PixelFormatSpec{ColorBits: 24, DepthBits: 24}
+	tests := []struct {
+		c    PixelFormatCandidate
+		want string
+	}{
+		{pixelFormat(1, PFD_SUPPORT_OPENGL|PFD_DRAW_TO_WINDOW, 24, 0, 24, 0),
+			"format 1: hardware accelerated (ICD); exact match"},
+		{pixelFormat(2, pfdWindowGL|PFD_GENERIC_FORMAT|PFD_GENERIC_ACCELERATED, 32, 8, 24, 0),
+			"format 2: partly accelerated (MCD); double buffered though not requested; " +
+				"32 color bits, 8 more than needed; 8 alpha bits, 8 more than needed"},
+		{pixelFormat(3, PFD_SUPPORT_OPENGL|PFD_DRAW_TO_WINDOW|PFD_GENERIC_FORMAT|PFD_STEREO, 24, 0, 24, 0),
+			"format 3: software renderer; stereo"},
+		{pixelFormat(4, PFD_DRAW_TO_WINDOW, 24, 0, 16, 0),
+			"format 4: rejected: no OpenGL support; 16 depth bits, need 24"},
+	}
+	for _, tt := range tests {
+		s := ScorePixelFormat(spec, tt.c)
+		if got := s.String(); got != tt.want {
+			t.Errorf("got  %q\nwant %q", got, tt.want)
+		}
+	}
+}
+
+func TestPickPixelFormat(t *testing.T) {
+	spec := PixelFormatSpec{ColorBits: 24, DepthBits: 24, DoubleBuffer: true}
+	if _, err := PickPixelFormat(spec, nil); err == nil || err.Error() != "no pixel formats to choose from" {
+		t.Errorf("no candidates: %v", err)
+	}
+
+	candidates := []PixelFormatCandidate{
+		pixelFormat(2, PFD_SUPPORT_OPENGL|PFD_DRAW_TO_WINDOW, 24, 0, 24, 0),
+		pixelFormat(1, pfdWindowGL, 24, 0, 16, 0),
+	}
+	_, err := PickPixelFormat(spec, candidates)
+	want := "no acceptable pixel format:\n" +
+		"format 1: rejected: 16 depth bits, need 24\n" +
+		"format 2: rejected: single buffered"
+	if err == nil || err.Error() != want {
+		t.Errorf("error is %v, want %q", err, want)
+	}
+
+	candidates = append(candidates, pixelFormat(3, pfdWindowGL, 32, 0, 24, 0))
+	s, err := PickPixelFormat(spec, candidates)
+	if err != nil || s.Candidate.Index != 3 {
+		t.Errorf("picked %v, %v", s.Candidate.Index, err)
+	}
+}
+
+func TestPixelFormatDescriptor(t *testing.T) {
+	pfd := PixelFormatSpec{ColorBits: 24, AlphaBits: 8, DepthBits: 24, StencilBits: 8, DoubleBuffer: true}.Descriptor()
+	if pfd.Size != 40 || pfd.Version != 1 || pfd.DwFlags != pfdWindowGL ||
+		pfd.ColorBits != 24 || pfd.AlphaBits != 8 || pfd.DepthBits != 24 || pfd.StencilBits != 8 {
+		t.Errorf("descriptor is %+v", pfd)
+	}
+	// A descriptor made from a spec meets it.
+	if s := ScorePixelFormat(PixelFormatSpec{DrawToBitmap: true}, PixelFormatCandidate{
+		PFD: PixelFormatSpec{DrawToBitmap: true}.Descriptor(),
+	}); !s.Acceptable() {
+		t.Errorf("bitmap descriptor rejected: %s", s.String())
+	}
+}
+
+func TestPFDFlagsString(t *testing.T) {
+	tests := []struct {
+		flags uint32
+		want  string
+	}{
+		{0, "0x0"},
+		{PFD_DOUBLEBUFFER, "PFD_DOUBLEBUFFER"},
+		{pfdWindowGL, "PFD_DOUBLEBUFFER|PFD_DRAW_TO_WINDOW|PFD_SUPPORT_OPENGL"},
+		{PFD_GENERIC_FORMAT | PFD_STEREO_DONTCARE, "PFD_GENERIC_FORMAT|PFD_STEREO_DONTCARE"},
+		{0x00010000, "0x10000"},
+		{PFD_SUPPORT_OPENGL | 0x00030000, "PFD_SUPPORT_OPENGL|0x30000"},
+	}
+	for _, tt := range tests {
+		if got := PFDFlagsString(tt.flags); got != tt.want {
+			t.Errorf("PFDFlagsString(%#x) = %q, want %q", tt.flags, got, tt.want)
+		}
+	}
+
+	pfd := pixelFormat(1, pfdWindowGL, 32, 8, 24, 8).PFD
+	pfd.RedBits, pfd.GreenBits, pfd.BlueBits = 8, 8, 8
+	if got := pfd.String(); !strings.HasPrefix(got, "RGBA color 32 (r8 g8 b8 a8) depth 24 stencil 8 accum 0 aux 0 PFD_DOUBLEBUFFER|") {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,494 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// This file holds the geometry behind the software renderers: affine
+// transforms, figure flattening, scanline filling and pen stroking. It
+// works in raster space, where pixel (x, y) covers [x, x+1) x [y, y+1) and
+// GDI's integer device coordinate x is the pixel center x+0.5. Like GDI it
+// does not antialias.
+
+// affine maps (x, y) to (a*x + c*y + e, b*x + d*y + f), with the fields
+// laid out as those of XFORM.
+type affine struct {
+	a, b, c, d, e, f float64
+}
+
+var identityAffine = affine{a: 1, d: 1}
+
+func affineFromXFORM(x XFORM) affine {
+	return affine{float64(x.EM11), float64(x.EM12), float64(x.EM21), float64(x.EM22), float64(x.EDx), float64(x.EDy)}
+}
+
+func translateAffine(dx, dy float64) affine {
+	return affine{a: 1, d: 1, e: dx, f: dy}
+}
+
+func scaleAffine(sx, sy float64) affine {
+	return affine{a: sx, d: sy}
+}
+
+func (m affine) apply(x, y float64) rasterPt {
+	return rasterPt{m.a*x + m.c*y + m.e, m.b*x + m.d*y + m.f}
+}
+
+// then returns the transform applying m and then n.
+func (m affine) then(n affine) affine {
+	return affine{
+		a: m.a*n.a + m.b*n.c,
+		b: m.a*n.b + m.b*n.d,
+		c: m.c*n.a + m.d*n.c,
+		d: m.c*n.b + m.d*n.d,
+		e: m.e*n.a + m.f*n.c + n.e,
+		f: m.e*n.b + m.f*n.d + n.f,
+	}
+}
+
+func (m affine) det() float64 {
+	return m.a*m.d - m.b*m.c
+}
+
+// invert returns the inverse of m, or false if m is singular.
+func (m affine) invert() (affine, bool) {
+	det := m.det()
+	if det == 0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return affine{}, false
+	}
+	a, b, c, d := m.d/det, -m.b/det, -m.c/det, m.a/det
+	return affine{a, b, c, d, -(m.e*a + m.f*c), -(m.e*b + m.f*d)}, true
+}
+
+// scale returns the factor by which m scales lengths on average.
+func (m affine) scale() float64 {
+	return math.Sqrt(math.Abs(m.det()))
+}
+
+// axisAligned reports whether m maps rectangles to rectangles.
+func (m affine) axisAligned() bool {
+	return m.b == 0 && m.c == 0
+}
+
+// rasterPt is a point in raster space.
+type rasterPt struct {
+	X, Y float64
+}
+
+func (p rasterPt) add(q rasterPt) rasterPt {
+	return rasterPt{p.X + q.X, p.Y + q.Y}
+}
+
+func (p rasterPt) sub(q rasterPt) rasterPt {
+	return rasterPt{p.X - q.X, p.Y - q.Y}
+}
+
+func (p rasterPt) mul(k float64) rasterPt {
+	return rasterPt{p.X * k, p.Y * k}
+}
+
+func (p rasterPt) dot(q rasterPt) float64 {
+	return p.X*q.X + p.Y*q.Y
+}
+
+func (p rasterPt) cross(q rasterPt) float64 {
+	return p.X*q.Y - p.Y*q.X
+}
+
+func (p rasterPt) length() float64 {
+	return math.Hypot(p.X, p.Y)
+}
+
+func (p rasterPt) finite() bool {
+	return !math.IsNaN(p.X+p.Y) && !math.IsInf(p.X+p.Y, 0)
+}
+
+func (p rasterPt) pixel() (x, y int) {
+	return int(math.Floor(p.X)), int(math.Floor(p.Y))
+}
+
+func (p rasterPt) lerp(q rasterPt, t float64) rasterPt {
+	return p.add(q.sub(p).mul(t))
+}
+
+// rasterFigure is a connected run of line segments. Filling always closes
+// a figure; stroking closes it only if closed is set.
+type rasterFigure struct {
+	pts    []rasterPt
+	closed bool
+}
+
+// flattenBezier appends the points of the cubic Bézier curve from p0 to
+// p3, excluding p0.
+func flattenBezier(pts []rasterPt, p0, p1, p2, p3 rasterPt) []rasterPt {
+	l := p1.sub(p0).length() + p2.sub(p1).length() + p3.sub(p2).length()
+	n := int(math.Ceil(l / 2))
+	if n < 1 || math.IsNaN(l) {
+		n = 1
+	} else if n > 256 {
+		n = 256
+	}
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		a, b, c := p0.lerp(p1, t), p1.lerp(p2, t), p2.lerp(p3, t)
+		d, e := a.lerp(b, t), b.lerp(c, t)
+		pts = append(pts, d.lerp(e, t))
+	}
+	return pts
+}
+
+// arcSegments returns the number of segments used to flatten an arc of
+// sweep radians on an ellipse of the given raster radii.
+func arcSegments(rx, ry, sweep float64) int {
+	r := math.Max(math.Abs(rx), math.Abs(ry))
+	n := int(math.Ceil(math.Abs(sweep) * math.Sqrt(r) * 2))
+	if n < 4 || math.IsNaN(r) {
+		n = 4
+	} else if n > 1024 {
+		n = 1024
+	}
+	return n
+}
+
+// ellipseArc appends the points of the arc of the ellipse centered on c
+// with radii rx and ry, from angle start sweeping by sweep radians, mapped
+// through m. Angles are measured as for an x-right, y-down raster.
+func ellipseArc(pts []rasterPt, m affine, c rasterPt, rx, ry, start, sweep float64) []rasterPt {
+	n := arcSegments(rx*m.scale(), ry*m.scale(), sweep)
+	for i := 0; i <= n; i++ {
+		t := start + sweep*float64(i)/float64(n)
+		pts = append(pts, m.apply(c.X+rx*math.Cos(t), c.Y+ry*math.Sin(t)))
+	}
+	return pts
+}
+
+type rasterEdge struct {
+	x0, y0, x1, y1 float64
+	dir            int
+}
+
+// fillFigures calls span for each run [x0, x1) of pixels on row y whose
+// centers lie inside figs, under the even-odd rule or, with winding set,
+// the nonzero rule. A center on a left or top edge is inside and one on a
+// right or bottom edge outside, so adjacent shapes do not overlap. Spans
+// are limited to clip.
+func fillFigures(figs []rasterFigure, winding bool, clip image.Rectangle, span func(y, x0, x1 int)) {
+	var edges []rasterEdge
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, f := range figs {
+		n := len(f.pts)
+		for i := 0; i < n; i++ {
+			p, q := f.pts[i], f.pts[(i+1)%n]
+			if !p.finite() || !q.finite() || p.Y == q.Y {
+				continue
+			}
+			e := rasterEdge{p.X, p.Y, q.X, q.Y, 1}
+			if p.Y > q.Y {
+				e = rasterEdge{q.X, q.Y, p.X, p.Y, -1}
+			}
+			edges = append(edges, e)
+			minY, maxY = math.Min(minY, e.y0), math.Max(maxY, e.y1)
+		}
+	}
+	if len(edges) == 0 {
+		return
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].y0 < edges[j].y0 })
+
+	y0 := clip.Min.Y
+	if top := math.Ceil(minY - 0.5); top > float64(y0) {
+		y0 = int(top)
+	}
+	y1 := clip.Max.Y
+	if bottom := math.Ceil(maxY - 0.5); bottom < float64(y1) {
+		y1 = int(bottom)
+	}
+
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	var active []*rasterEdge
+	var xs []crossing
+	next := 0
+	for y := y0; y < y1; y++ {
+		yc := float64(y) + 0.5
+		for next < len(edges) && edges[next].y0 <= yc {
+			active = append(active, &edges[next])
+			next++
+		}
+		xs = xs[:0]
+		kept := active[:0]
+		for _, e := range active {
+			if e.y1 <= yc {
+				continue
+			}
+			kept = append(kept, e)
+			if e.y0 <= yc {
+				xs = append(xs, crossing{e.x0 + (yc-e.y0)*(e.x1-e.x0)/(e.y1-e.y0), e.dir})
+			}
+		}
+		active = kept
+		sort.Slice(xs, func(i, j int) bool { return xs[i].x < xs[j].x })
+
+		count := 0
+		var start float64
+		for _, c := range xs {
+			was := count
+			if winding {
+				count += c.dir
+			} else {
+				count ^= 1
+			}
+			switch {
+			case was == 0 && count != 0:
+				start = c.x
+			case was != 0 && count == 0:
+				emitSpan(y, start, c.x, clip, span)
+			}
+		}
+	}
+}
+
+// emitSpan calls span for the pixels whose centers lie in [xa, xb).
+func emitSpan(y int, xa, xb float64, clip image.Rectangle, span func(y, x0, x1 int)) {
+	x0, x1 := clip.Min.X, clip.Max.X
+	if a := math.Ceil(xa - 0.5); a > float64(x0) {
+		x0 = int(a)
+	}
+	if b := math.Ceil(xb - 0.5); b < float64(x1) {
+		x1 = int(b)
+	}
+	if x0 < x1 {
+		span(y, x0, x1)
+	}
+}
+
+// thinLine calls plot for the pixels of the Bresenham line from pixel
+// (x0, y0) to pixel (x1, y1), leaving out the last one as LineTo does.
+// Lines are cut to limit pixels, so that absurd coordinates do not hang.
+func thinLine(x0, y0, x1, y1 int, plot func(x, y int)) {
+	dx, dy := x1-x0, y1-y0
+	sx, sy := 1, 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+	const limit = 1 << 16
+	n := dx
+	if dy > n {
+		n = dy
+	}
+	if n > limit {
+		n = limit
+	}
+	err := dx - dy
+	for i := 0; i < n; i++ {
+		plot(x0, y0)
+		e2 := 2 * err
+		if e2 > -dy {
+			err -= dy
+			x0 += sx
+		}
+		if e2 < dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// dashFigures splits figs by a dash pattern of alternating on and off
+// lengths. It returns the on and the off pieces.
+func dashFigures(figs []rasterFigure, pattern []float64) (on, off []rasterFigure) {
+	var total float64
+	for _, l := range pattern {
+		total += l
+	}
+	if len(pattern) == 0 || !(total > 0) {
+		return figs, nil
+	}
+	for _, f := range figs {
+		pts := f.pts
+		if f.closed && len(pts) > 0 {
+			pts = append(pts[:len(pts):len(pts)], pts[0])
+		}
+		i, left := 0, pattern[0]
+		cur := rasterFigure{}
+		if len(pts) > 0 {
+			cur.pts = []rasterPt{pts[0]}
+		}
+		flush := func() {
+			if len(cur.pts) > 1 {
+				if i%2 == 0 {
+					on = append(on, cur)
+				} else {
+					off = append(off, cur)
+				}
+			}
+		}
+		for k := 1; k < len(pts); k++ {
+			p, q := pts[k-1], pts[k]
+			seg := q.sub(p).length()
+			pos := 0.0
+			for seg-pos > left && len(on)+len(off) < 1<<16 {
+				pos += left
+				m := p.lerp(q, pos/seg)
+				cur.pts = append(cur.pts, m)
+				flush()
+				cur = rasterFigure{pts: []rasterPt{m}}
+				i = (i + 1) % len(pattern)
+				left = pattern[i]
+			}
+			left -= seg - pos
+			cur.pts = append(cur.pts, q)
+		}
+		flush()
+	}
+	return on, off
+}
+
+// rasterPen describes how strokeFigures outlines a figure.
+type rasterPen struct {
+	width      float64
+	endCap     uint32 // PS_ENDCAP_*
+	join       uint32 // PS_JOIN_*
+	miterLimit float64
+}
+
+// strokeFigures returns polygons covering figs stroked with pen, to be
+// filled with the nonzero rule. Every polygon is wound the same way so
+// that overlaps add up.
+func strokeFigures(figs []rasterFigure, pen rasterPen) []rasterFigure {
+	hw := pen.width / 2
+	var out []rasterFigure
+	add := func(pts ...rasterPt) {
+		out = append(out, orient(rasterFigure{pts: pts, closed: true}))
+	}
+	dot := func(c rasterPt) {
+		out = append(out, orient(rasterFigure{pts: circle(c, hw), closed: true}))
+	}
+
+	for _, f := range figs {
+		pts := dedupe(f.pts)
+		if f.closed && len(pts) > 2 && pts[0] == pts[len(pts)-1] {
+			pts = pts[:len(pts)-1]
+		}
+		if len(pts) == 0 || !pts[0].finite() {
+			continue
+		}
+		if len(pts) == 1 {
+			if pen.endCap == PS_ENDCAP_ROUND {
+				dot(pts[0])
+			} else if pen.endCap == PS_ENDCAP_SQUARE {
+				p := pts[0]
+				add(p.add(rasterPt{-hw, -hw}), p.add(rasterPt{hw, -hw}), p.add(rasterPt{hw, hw}), p.add(rasterPt{-hw, hw}))
+			}
+			continue
+		}
+
+		closed := f.closed && len(pts) > 2
+		nseg := len(pts) - 1
+		if closed {
+			nseg++
+		}
+		for i := 0; i < nseg; i++ {
+			p, q := pts[i], pts[(i+1)%len(pts)]
+			d := q.sub(p).mul(1 / q.sub(p).length())
+			n := rasterPt{-d.Y, d.X}.mul(hw)
+			if !closed && pen.endCap == PS_ENDCAP_SQUARE {
+				if i == 0 {
+					p = p.sub(d.mul(hw))
+				}
+				if i == nseg-1 {
+					q = q.add(d.mul(hw))
+				}
+			}
+			add(p.add(n), q.add(n), q.sub(n), p.sub(n))
+		}
+
+		// Joins at the inner vertices, and at the first for closed figures.
+		first, last := 1, len(pts)-1
+		if closed {
+			first, last = 0, len(pts)
+		}
+		for i := first; i < last; i++ {
+			prev := pts[(i-1+len(pts))%len(pts)]
+			p, next := pts[i], pts[(i+1)%len(pts)]
+			join(p, p.sub(prev), next.sub(p), hw, pen, add, dot)
+		}
+		if !closed && pen.endCap == PS_ENDCAP_ROUND {
+			dot(pts[0])
+			dot(pts[len(pts)-1])
+		}
+	}
+	return out
+}
+
+// join adds the join at vertex p between segments with directions da and
+// db.
+func join(p, da, db rasterPt, hw float64, pen rasterPen, add func(...rasterPt), dot func(rasterPt)) {
+	if pen.join == PS_JOIN_ROUND {
+		dot(p)
+		return
+	}
+	ua, ub := da.mul(1/da.length()), db.mul(1/db.length())
+	na, nb := rasterPt{-ua.Y, ua.X}, rasterPt{-ub.Y, ub.X}
+	side := 1.0
+	if ua.cross(ub) > 0 {
+		side = -1
+	}
+	a, b := p.add(na.mul(side*hw)), p.add(nb.mul(side*hw))
+	if pen.join == PS_JOIN_MITER {
+		sum := na.add(nb)
+		if l := sum.length(); l > 0 && 2/l <= pen.miterLimit {
+			m := p.add(sum.mul(side * hw / (1 + na.dot(nb))))
+			add(p, a, m, b)
+			return
+		}
+	}
+	add(p, a, b)
+}
+
+// circle returns a polygon approximating the circle of radius r around c.
+func circle(c rasterPt, r float64) []rasterPt {
+	n := arcSegments(r, r, 2*math.Pi)
+	pts := make([]rasterPt, n)
+	for i := range pts {
+		t := 2 * math.Pi * float64(i) / float64(n)
+		pts[i] = rasterPt{c.X + r*math.Cos(t), c.Y + r*math.Sin(t)}
+	}
+	return pts
+}
+
+// orient reverses f if its signed area is negative.
+func orient(f rasterFigure) rasterFigure {
+	var area float64
+	for i, p := range f.pts {
+		area += p.cross(f.pts[(i+1)%len(f.pts)])
+	}
+	if area < 0 {
+		for i, j := 0, len(f.pts)-1; i < j; i, j = i+1, j-1 {
+			f.pts[i], f.pts[j] = f.pts[j], f.pts[i]
+		}
+	}
+	return f
+}
+
+// dedupe drops consecutive repeated points.
+func dedupe(pts []rasterPt) []rasterPt {
+	out := make([]rasterPt, 0, len(pts))
+	for i, p := range pts {
+		if i == 0 || p != pts[i-1] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
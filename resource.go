@@ -0,0 +1,677 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrNoResources is returned when a PE or COFF file has no resource section.
+var ErrNoResources = errors.New("file has no resource section")
+
+// ResourceID identifies a resource type, name or language. Like the
+// MAKEINTRESOURCE/string duality of the Win32 API it is either an integer
+// ordinal or a string name; Name is used when it is not empty.
+type ResourceID struct {
+	ID   uint16
+	Name string
+}
+
+// IntResourceID returns the ResourceID for an integer ordinal.
+func IntResourceID(id uint16) ResourceID {
+	return ResourceID{ID: id}
+}
+
+// NamedResourceID returns the ResourceID for a string name.
+func NamedResourceID(name string) ResourceID {
+	return ResourceID{Name: name}
+}
+
+// IsInt reports whether id is an integer ordinal.
+func (id ResourceID) IsInt() bool {
+	return id.Name == ""
+}
+
+// String formats id the way resource compilers do: "#14" for ordinals and
+// the plain name otherwise.
+func (id ResourceID) String() string {
+	if id.IsInt() {
+		return fmt.Sprintf("#%d", id.ID)
+	}
+	return id.Name
+}
+
+func (id ResourceID) less(other ResourceID) bool {
+	// Named entries sort before ordinals, as in the on-disk directory.
+	if id.IsInt() != other.IsInt() {
+		return !id.IsInt()
+	}
+	if id.IsInt() {
+		return id.ID < other.ID
+	}
+	return strings.ToUpper(id.Name) < strings.ToUpper(other.Name)
+}
+
+// Resource is one leaf of the resource directory tree.
+type Resource struct {
+	Type     ResourceID
+	Name     ResourceID
+	Lang     uint16
+	CodePage uint32
+	Data     []byte
+}
+
+// ResourceTable holds every resource of a module, flattened from the
+// type/name/language directory tree in directory order.
+type ResourceTable struct {
+	Resources []*Resource
+}
+
+// http://msdn.microsoft.com/en-us/library/ms809762.aspx
+type imageResourceDirectory struct {
+	Characteristics      uint32
+	TimeDateStamp        uint32
+	MajorVersion         uint16
+	MinorVersion         uint16
+	NumberOfNamedEntries uint16
+	NumberOfIdEntries    uint16
+}
+
+type imageResourceDirectoryEntry struct {
+	Name         uint32
+	OffsetToData uint32
+}
+
+type imageResourceDataEntry struct {
+	OffsetToData uint32
+	Size         uint32
+	CodePage     uint32
+	Reserved     uint32
+}
+
+const (
+	imageResourceNameIsString    = 0x80000000
+	imageResourceDataIsDirectory = 0x80000000
+)
+
+// OpenResourceTable opens the named .exe, .dll or COFF object file and reads
+// its resource directory.
+func OpenResourceTable(name string) (*ResourceTable, error) {
+	f, err := pe.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return NewResourceTable(f)
+}
+
+// NewResourceTable reads the resource directory of an opened PE image. COFF
+// objects such as .syso files, which have no optional header, are read from
+// their .rsrc section with section-relative data offsets.
+func NewResourceTable(f *pe.File) (*ResourceTable, error) {
+	var dir pe.DataDirectory
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if oh.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_RESOURCE {
+			dir = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_RESOURCE]
+		}
+	case *pe.OptionalHeader64:
+		if oh.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_RESOURCE {
+			dir = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_RESOURCE]
+		}
+	default:
+		s := f.Section(".rsrc")
+		if s == nil {
+			return nil, ErrNoResources
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, err
+		}
+		return ParseResourceSection(data, 0)
+	}
+
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return nil, ErrNoResources
+	}
+	for _, s := range f.Sections {
+		if dir.VirtualAddress < s.VirtualAddress || dir.VirtualAddress >= s.VirtualAddress+s.VirtualSize {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, err
+		}
+		start := dir.VirtualAddress - s.VirtualAddress
+		if start >= uint32(len(data)) {
+			return nil, errors.New("resource directory lies outside section data")
+		}
+		return ParseResourceSection(data[start:], dir.VirtualAddress)
+	}
+	return nil, ErrNoResources
+}
+
+// ParseResourceSection parses a raw resource directory. data must start with
+// the root IMAGE_RESOURCE_DIRECTORY and rva is the address it is loaded at;
+// data entry offsets are RVAs and are rebased against it.
+func ParseResourceSection(data []byte, rva uint32) (*ResourceTable, error) {
+	p := resourceParser{data: data, rva: rva}
+	t := new(ResourceTable)
+
+	types, err := p.directory(0)
+	if err != nil {
+		return nil, err
+	}
+	for _, te := range types {
+		if te.OffsetToData&imageResourceDataIsDirectory == 0 {
+			return nil, errors.New("resource type entry does not point to a directory")
+		}
+		typ, err := p.entryID(te)
+		if err != nil {
+			return nil, err
+		}
+		names, err := p.directory(te.OffsetToData &^ imageResourceDataIsDirectory)
+		if err != nil {
+			return nil, err
+		}
+		for _, ne := range names {
+			if ne.OffsetToData&imageResourceDataIsDirectory == 0 {
+				return nil, errors.New("resource name entry does not point to a directory")
+			}
+			name, err := p.entryID(ne)
+			if err != nil {
+				return nil, err
+			}
+			langs, err := p.directory(ne.OffsetToData &^ imageResourceDataIsDirectory)
+			if err != nil {
+				return nil, err
+			}
+			for _, le := range langs {
+				if le.OffsetToData&imageResourceDataIsDirectory != 0 {
+					return nil, errors.New("resource language entry points to a directory")
+				}
+				r, err := p.leaf(le.OffsetToData)
+				if err != nil {
+					return nil, err
+				}
+				r.Type = typ
+				r.Name = name
+				r.Lang = uint16(le.Name)
+				t.Resources = append(t.Resources, r)
+			}
+		}
+	}
+
+	return t, nil
+}
+
+type resourceParser struct {
+	data []byte
+	rva  uint32
+}
+
+func (p *resourceParser) directory(off uint32) ([]imageResourceDirectoryEntry, error) {
+	var dir imageResourceDirectory
+	if err := p.read(off, &dir); err != nil {
+		return nil, err
+	}
+	n := uint32(dir.NumberOfNamedEntries) + uint32(dir.NumberOfIdEntries)
+	entries := make([]imageResourceDirectoryEntry, n)
+	if err := p.read(off+16, entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (p *resourceParser) entryID(e imageResourceDirectoryEntry) (ResourceID, error) {
+	if e.Name&imageResourceNameIsString == 0 {
+		return IntResourceID(uint16(e.Name)), nil
+	}
+	off := e.Name &^ imageResourceNameIsString
+	var n uint16
+	if err := p.read(off, &n); err != nil {
+		return ResourceID{}, err
+	}
+	s := make([]uint16, n)
+	if err := p.read(off+2, s); err != nil {
+		return ResourceID{}, err
+	}
+	return NamedResourceID(string(utf16.Decode(s))), nil
+}
+
+func (p *resourceParser) leaf(off uint32) (*Resource, error) {
+	var de imageResourceDataEntry
+	if err := p.read(off, &de); err != nil {
+		return nil, err
+	}
+	start := uint64(de.OffsetToData) - uint64(p.rva)
+	end := start + uint64(de.Size)
+	if de.OffsetToData < p.rva || end > uint64(len(p.data)) {
+		return nil, fmt.Errorf("resource data at RVA %#x lies outside the resource section", de.OffsetToData)
+	}
+	return &Resource{
+		CodePage: de.CodePage,
+		Data:     p.data[start:end],
+	}, nil
+}
+
+func (p *resourceParser) read(off uint32, v interface{}) error {
+	if uint64(off)+uint64(binary.Size(v)) > uint64(len(p.data)) {
+		return fmt.Errorf("resource directory is truncated at offset %#x", off)
+	}
+	return binary.Read(bytes.NewReader(p.data[off:]), binary.LittleEndian, v)
+}
+
+// Types returns the distinct resource types in the table.
+func (t *ResourceTable) Types() []ResourceID {
+	var types []ResourceID
+	seen := make(map[ResourceID]bool)
+	for _, r := range t.Resources {
+		if !seen[r.Type] {
+			seen[r.Type] = true
+			types = append(types, r.Type)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].less(types[j]) })
+	return types
+}
+
+// OfType returns every resource of the given type.
+func (t *ResourceTable) OfType(typ ResourceID) []*Resource {
+	var rs []*Resource
+	for _, r := range t.Resources {
+		if r.Type == typ {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// Find returns the resource with the given type and name. Like FindResourceEx
+// it prefers an exact language match, then the neutral language, then
+// whichever language comes first.
+func (t *ResourceTable) Find(typ, name ResourceID, lang uint16) (*Resource, bool) {
+	var neutral, first *Resource
+	for _, r := range t.Resources {
+		if r.Type != typ || !sameResourceName(r.Name, name) {
+			continue
+		}
+		if r.Lang == lang {
+			return r, true
+		}
+		if r.Lang == 0 && neutral == nil {
+			neutral = r
+		}
+		if first == nil {
+			first = r
+		}
+	}
+	if neutral != nil {
+		return neutral, true
+	}
+	return first, first != nil
+}
+
+// Resource names are case-insensitive; rc.exe stores them upper-cased.
+func sameResourceName(a, b ResourceID) bool {
+	if a.IsInt() || b.IsInt() {
+		return a == b
+	}
+	return strings.EqualFold(a.Name, b.Name)
+}
+
+// LoadString returns the RT_STRING entry with the given id, mirroring the
+// LoadString function.
+func (t *ResourceTable) LoadString(id uint16, lang uint16) (string, bool) {
+	r, ok := t.Find(IntResourceID(RT_STRING), IntResourceID(id/16+1), lang)
+	if !ok {
+		return "", false
+	}
+	strs, err := ParseStringBlock(r.Name.ID, r.Data)
+	if err != nil {
+		return "", false
+	}
+	s, ok := strs[id]
+	return s, ok
+}
+
+// ParseStringBlock decodes an RT_STRING resource. Each block holds sixteen
+// length-prefixed UTF-16 strings; block n holds string IDs (n-1)*16 through
+// (n-1)*16+15, so blocks run from 1 to 4096. Empty slots are omitted from
+// the result.
+func ParseStringBlock(block uint16, data []byte) (map[uint16]string, error) {
+	if block == 0 || block > 4096 {
+		return nil, fmt.Errorf("string block ID %d is not between 1 and 4096", block)
+	}
+	strs := make(map[uint16]string)
+	r := resReader{data: data}
+	for i := uint16(0); i < 16; i++ {
+		n := r.u16()
+		s := r.utf16(int(n))
+		if r.err != nil {
+			return nil, r.err
+		}
+		if n != 0 {
+			strs[(block-1)*16+i] = s
+		}
+	}
+	return strs, nil
+}
+
+// http://msdn.microsoft.com/en-us/library/ms997538.aspx
+type GRPICONDIR struct {
+	Reserved uint16
+	Type     uint16
+	Count    uint16
+}
+
+// GRPICONDIRENTRY is the packed, 14 byte directory entry of an RT_GROUP_ICON
+// resource. For RT_GROUP_CURSOR resources Width and Height hold the cursor
+// size as a single WORD each and ColorCount/Reserved are unused; use
+// ParseGroupCursor for those.
+type GRPICONDIRENTRY struct {
+	Width      byte
+	Height     byte
+	ColorCount byte
+	Reserved   byte
+	Planes     uint16
+	BitCount   uint16
+	BytesInRes uint32
+	ID         uint16
+}
+
+// GroupIcon is a decoded RT_GROUP_ICON or RT_GROUP_CURSOR resource. Each
+// entry refers to an RT_ICON or RT_CURSOR resource by ID.
+type GroupIcon struct {
+	Cursor  bool
+	Entries []GroupIconEntry
+}
+
+// GroupIconEntry describes one image of a group. Width and Height are in
+// pixels, with the 0 = 256 convention of the on-disk format already resolved.
+// Cursor groups store the height of bitmap cursors doubled, counting both
+// masks, and it is returned as stored.
+type GroupIconEntry struct {
+	Width, Height int
+	ColorCount    int
+	Planes        uint16
+	BitCount      uint16
+	BytesInRes    uint32
+	ID            uint16
+}
+
+// ParseGroupIcon decodes an RT_GROUP_ICON or RT_GROUP_CURSOR resource.
+func ParseGroupIcon(data []byte) (*GroupIcon, error) {
+	var hdr GRPICONDIR
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &hdr); err != nil {
+		return nil, errors.New("group icon header is truncated")
+	}
+	if hdr.Reserved != 0 || (hdr.Type != 1 && hdr.Type != 2) {
+		return nil, errors.New("not a group icon or cursor resource")
+	}
+	if len(data) < 6+14*int(hdr.Count) {
+		return nil, errors.New("group icon directory is truncated")
+	}
+
+	g := &GroupIcon{Cursor: hdr.Type == 2}
+	r := resReader{data: data, pos: 6}
+	for i := 0; i < int(hdr.Count); i++ {
+		var e GroupIconEntry
+		if g.Cursor {
+			e.Width = int(r.u16())
+			e.Height = int(r.u16())
+		} else {
+			e.Width = int(r.u8())
+			e.Height = int(r.u8())
+			e.ColorCount = int(r.u8())
+			r.u8()
+			if e.Width == 0 {
+				e.Width = 256
+			}
+			if e.Height == 0 {
+				e.Height = 256
+			}
+		}
+		e.Planes = r.u16()
+		e.BitCount = r.u16()
+		e.BytesInRes = r.u32()
+		e.ID = r.u16()
+		g.Entries = append(g.Entries, e)
+	}
+	return g, r.err
+}
+
+// IconImage describes an RT_ICON or RT_CURSOR resource: either a PNG stream
+// or a BITMAPINFOHEADER followed by the XOR and AND masks. For cursors the
+// leading hotspot is split off into HotspotX and HotspotY.
+type IconImage struct {
+	PNG                bool
+	Width, Height      int
+	BitCount           uint16
+	Header             BITMAPINFOHEADER
+	HotspotX, HotspotY uint16
+	Data               []byte
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// ParseIconImage decodes the header of an RT_ICON resource, or of an
+// RT_CURSOR resource when cursor is true.
+func ParseIconImage(data []byte, cursor bool) (*IconImage, error) {
+	img := new(IconImage)
+	if cursor {
+		if len(data) < 4 {
+			return nil, errors.New("cursor hotspot is truncated")
+		}
+		img.HotspotX = binary.LittleEndian.Uint16(data)
+		img.HotspotY = binary.LittleEndian.Uint16(data[2:])
+		data = data[4:]
+	}
+	img.Data = data
+
+	if bytes.HasPrefix(data, pngSignature) {
+		// The IHDR chunk always comes first.
+		if len(data) < 33 || string(data[12:16]) != "IHDR" {
+			return nil, errors.New("PNG icon has no IHDR chunk")
+		}
+		img.PNG = true
+		img.Width = int(binary.BigEndian.Uint32(data[16:]))
+		img.Height = int(binary.BigEndian.Uint32(data[20:]))
+		img.BitCount = uint16(data[24]) * pngChannels(data[25])
+		return img, nil
+	}
+
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &img.Header); err != nil {
+		return nil, errors.New("icon BITMAPINFOHEADER is truncated")
+	}
+	if img.Header.BiSize < 40 {
+		return nil, fmt.Errorf("icon header size %d is too small", img.Header.BiSize)
+	}
+	img.Width = int(img.Header.BiWidth)
+	// The height covers both the XOR and the AND mask.
+	img.Height = int(img.Header.BiHeight) / 2
+	img.BitCount = img.Header.BiBitCount
+	return img, nil
+}
+
+func pngChannels(colorType byte) uint16 {
+	switch colorType {
+	case 2:
+		return 3
+	case 4:
+		return 2
+	case 6:
+		return 4
+	}
+	return 1
+}
+
+// DecodeManifest returns the text of an RT_MANIFEST resource. Manifests are
+// normally UTF-8; a UTF-16 byte order mark is honored and any BOM stripped.
+func DecodeManifest(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:])
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		r := resReader{data: data, pos: 2}
+		return r.utf16((len(data) - 2) / 2)
+	}
+	return string(data)
+}
+
+// resReader reads the little-endian, WORD-aligned structures that make up
+// resource data. The first error sticks and later reads return zero values.
+type resReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *resReader) need(n int) bool {
+	if r.err != nil {
+		return false
+	}
+	// pos comes from file offsets, which may not fit an int on 32-bit
+	// platforms; compare so that neither side can overflow.
+	if n < 0 || r.pos < 0 || r.pos > len(r.data) || n > len(r.data)-r.pos {
+		r.err = fmt.Errorf("resource data is truncated at offset %#x", r.pos)
+		return false
+	}
+	return true
+}
+
+func (r *resReader) u8() byte {
+	if !r.need(1) {
+		return 0
+	}
+	r.pos++
+	return r.data[r.pos-1]
+}
+
+func (r *resReader) u16() uint16 {
+	if !r.need(2) {
+		return 0
+	}
+	r.pos += 2
+	return binary.LittleEndian.Uint16(r.data[r.pos-2:])
+}
+
+func (r *resReader) u32() uint32 {
+	if !r.need(4) {
+		return 0
+	}
+	r.pos += 4
+	return binary.LittleEndian.Uint32(r.data[r.pos-4:])
+}
+
+func (r *resReader) bytes(n int) []byte {
+	if !r.need(n) {
+		return nil
+	}
+	r.pos += n
+	return r.data[r.pos-n : r.pos]
+}
+
+// utf16 reads n UTF-16 code units.
+func (r *resReader) utf16(n int) string {
+	if n < 0 || !r.need(2*n) {
+		if r.err == nil {
+			r.err = fmt.Errorf("resource data is truncated at offset %#x", r.pos)
+		}
+		return ""
+	}
+	s := make([]uint16, n)
+	for i := range s {
+		s[i] = binary.LittleEndian.Uint16(r.data[r.pos:])
+		r.pos += 2
+	}
+	return string(utf16.Decode(s))
+}
+
+// sz reads a NUL-terminated UTF-16 string.
+func (r *resReader) sz() string {
+	var s []uint16
+	for {
+		c := r.u16()
+		if r.err != nil || c == 0 {
+			return string(utf16.Decode(s))
+		}
+		s = append(s, c)
+	}
+}
+
+// szOrOrd reads a sz_Or_Ord field: 0x0000 for none, 0xFFFF followed by an
+// ordinal, or a NUL-terminated string.
+func (r *resReader) szOrOrd() ResourceID {
+	if !r.need(2) {
+		return ResourceID{}
+	}
+	switch binary.LittleEndian.Uint16(r.data[r.pos:]) {
+	case 0:
+		r.pos += 2
+		return ResourceID{}
+	case 0xFFFF:
+		r.pos += 2
+		return IntResourceID(r.u16())
+	}
+	return NamedResourceID(r.sz())
+}
+
+// align skips padding up to the next multiple of n bytes.
+func (r *resReader) align(n int) {
+	if pad := (n - r.pos%n) % n; pad != 0 && r.pos+pad <= len(r.data) {
+		r.pos += pad
+	}
+}
+
+// resWriter is the encoding counterpart of resReader.
+type resWriter struct {
+	b bytes.Buffer
+}
+
+func (w *resWriter) u8(v byte) {
+	w.b.WriteByte(v)
+}
+
+func (w *resWriter) u16(v uint16) {
+	binary.Write(&w.b, binary.LittleEndian, v)
+}
+
+func (w *resWriter) u32(v uint32) {
+	binary.Write(&w.b, binary.LittleEndian, v)
+}
+
+// sz writes s as a NUL-terminated UTF-16 string.
+func (w *resWriter) sz(s string) {
+	w.b.Write(utf16Bytes(s, true))
+}
+
+// szOrOrd writes a sz_Or_Ord field; the zero ResourceID is written as 0x0000.
+func (w *resWriter) szOrOrd(id ResourceID) {
+	switch {
+	case id == ResourceID{}:
+		w.u16(0)
+	case id.IsInt():
+		w.u16(0xFFFF)
+		w.u16(id.ID)
+	default:
+		w.sz(id.Name)
+	}
+}
+
+// align pads with zeros up to the next multiple of n bytes.
+func (w *resWriter) align(n int) {
+	for w.b.Len()%n != 0 {
+		w.b.WriteByte(0)
+	}
+}
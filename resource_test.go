@@ -0,0 +1,52 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+// stringBlock lays out an RT_STRING resource with s in the given slots.
+func stringBlock(s map[int]string) []byte {
+	var w resWriter
+	for i := 0; i < 16; i++ {
+		u := utf16.Encode([]rune(s[i]))
+		w.u16(uint16(len(u)))
+		for _, c := range u {
+			w.u16(c)
+		}
+	}
+	return w.b.Bytes()
+}
+
+func TestParseStringBlock(t *testing.T) {
+	data := stringBlock(map[int]string{0: "first", 3: "héllo", 15: "last"})
+	tests := []struct {
+		block uint16
+		want  map[uint16]string
+	}{
+		{1, map[uint16]string{0: "first", 3: "héllo", 15: "last"}},
+		{2, map[uint16]string{16: "first", 19: "héllo", 31: "last"}},
+		{4096, map[uint16]string{65520: "first", 65523: "héllo", 65535: "last"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseStringBlock(tt.block, data)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseStringBlock(%d) = %v, %v; want %v", tt.block, got, err, tt.want)
+		}
+	}
+
+	// Block IDs outside 1-4096 would wrap the string IDs around.
+	for _, block := range []uint16{0, 4097, 0xFFFF} {
+		if got, err := ParseStringBlock(block, data); err == nil {
+			t.Errorf("ParseStringBlock(%d) = %v", block, got)
+		}
+	}
+	if _, err := ParseStringBlock(1, data[:len(data)-2]); err == nil {
+		t.Error("truncated block parsed")
+	}
+}
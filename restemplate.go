@@ -0,0 +1,307 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"errors"
+)
+
+// DialogTemplate is a decoded RT_DIALOG resource. Both the classic
+// DLGTEMPLATE and the extended DLGTEMPLATEEX layouts are supported; the
+// fields that only exist in the extended layout are zero otherwise.
+//
+// Menu and Class are zero ResourceIDs when absent. The font fields are only
+// meaningful when Style has DS_SETFONT.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/ms645394.aspx
+// http://msdn.microsoft.com/en-us/library/windows/desktop/ms645398.aspx
+type DialogTemplate struct {
+	Extended     bool
+	HelpID       uint32
+	Style        uint32
+	ExStyle      uint32
+	X, Y, Cx, Cy int16
+	Menu         ResourceID
+	Class        ResourceID
+	Title        string
+	PointSize    uint16
+	Weight       uint16
+	Italic       bool
+	CharSet      byte
+	FaceName     string
+	Items        []DialogItem
+}
+
+// DialogItem is one control of a DialogTemplate. Class is either one of the
+// predefined ordinals (0x0080 button, 0x0081 edit, 0x0082 static,
+// 0x0083 list box, 0x0084 scroll bar, 0x0085 combo box) or a class name.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/ms644997.aspx
+// http://msdn.microsoft.com/en-us/library/windows/desktop/ms645389.aspx
+type DialogItem struct {
+	HelpID       uint32
+	Style        uint32
+	ExStyle      uint32
+	X, Y, Cx, Cy int16
+	ID           uint32
+	Class        ResourceID
+	Title        ResourceID
+	CreationData []byte
+}
+
+// ParseDialogTemplate decodes an RT_DIALOG resource.
+func ParseDialogTemplate(data []byte) (*DialogTemplate, error) {
+	r := resReader{data: data}
+	d := new(DialogTemplate)
+
+	var count uint16
+	if len(data) >= 4 && data[0] == 1 && data[1] == 0 && data[2] == 0xFF && data[3] == 0xFF {
+		d.Extended = true
+		r.pos = 4
+		d.HelpID = r.u32()
+		d.ExStyle = r.u32()
+		d.Style = r.u32()
+	} else {
+		d.Style = r.u32()
+		d.ExStyle = r.u32()
+	}
+	count = r.u16()
+	d.X = int16(r.u16())
+	d.Y = int16(r.u16())
+	d.Cx = int16(r.u16())
+	d.Cy = int16(r.u16())
+	d.Menu = r.szOrOrd()
+	d.Class = r.szOrOrd()
+	d.Title = r.sz()
+	if d.Style&DS_SETFONT != 0 {
+		d.PointSize = r.u16()
+		if d.Extended {
+			d.Weight = r.u16()
+			d.Italic = r.u8() != 0
+			d.CharSet = r.u8()
+		}
+		d.FaceName = r.sz()
+	}
+
+	for i := 0; i < int(count) && r.err == nil; i++ {
+		var it DialogItem
+		r.align(4)
+		if d.Extended {
+			it.HelpID = r.u32()
+			it.ExStyle = r.u32()
+			it.Style = r.u32()
+		} else {
+			it.Style = r.u32()
+			it.ExStyle = r.u32()
+		}
+		it.X = int16(r.u16())
+		it.Y = int16(r.u16())
+		it.Cx = int16(r.u16())
+		it.Cy = int16(r.u16())
+		if d.Extended {
+			it.ID = r.u32()
+		} else {
+			it.ID = uint32(r.u16())
+		}
+		it.Class = r.szOrOrd()
+		it.Title = r.szOrOrd()
+		if n := int(r.u16()); n > 0 {
+			if !d.Extended {
+				// The classic layout counts the size word itself.
+				n -= 2
+			}
+			it.CreationData = append([]byte(nil), r.bytes(n)...)
+		}
+		d.Items = append(d.Items, it)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return d, nil
+}
+
+// MenuTemplate is a decoded RT_MENU resource in either the classic
+// MENUITEMTEMPLATE or the extended MENUEX_TEMPLATE_ITEM layout.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/ms647567.aspx
+// http://msdn.microsoft.com/en-us/library/windows/desktop/ms647561.aspx
+type MenuTemplate struct {
+	Extended bool
+	HelpID   uint32
+	Items    []MenuItem
+}
+
+// MenuItem is one entry of a MenuTemplate. In the classic layout Flags holds
+// the MF_* option flags (without MF_POPUP and MF_END) and an all-zero item
+// is a separator. In the extended layout Type and State hold the MFT_* and
+// MFS_* values and HelpID applies to popups.
+type MenuItem struct {
+	Popup  bool
+	Flags  uint16
+	Type   uint32
+	State  uint32
+	ID     uint32
+	HelpID uint32
+	Text   string
+	Items  []MenuItem
+}
+
+const (
+	menuExPopup = 0x01
+	menuExEnd   = 0x80
+
+	maxMenuDepth = 32
+)
+
+// ParseMenuTemplate decodes an RT_MENU resource.
+func ParseMenuTemplate(data []byte) (*MenuTemplate, error) {
+	r := resReader{data: data}
+	m := new(MenuTemplate)
+
+	version := r.u16()
+	offset := r.u16()
+	switch version {
+	case 0:
+		r.pos += int(offset)
+		m.Items = parseMenuItems(&r, 0)
+	case 1:
+		m.Extended = true
+		m.HelpID = r.u32()
+		r.pos = 4 + int(offset)
+		m.Items = parseMenuExItems(&r, 0)
+	default:
+		return nil, errors.New("unknown menu template version")
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return m, nil
+}
+
+func parseMenuItems(r *resReader, depth int) []MenuItem {
+	if depth > maxMenuDepth {
+		r.err = errors.New("menu template is nested too deeply")
+		return nil
+	}
+	var items []MenuItem
+	for r.err == nil {
+		flags := r.u16()
+		it := MenuItem{
+			Popup: flags&MF_POPUP != 0,
+			Flags: flags &^ (MF_POPUP | MF_END),
+		}
+		if !it.Popup {
+			it.ID = uint32(r.u16())
+		}
+		it.Text = r.sz()
+		if it.Popup {
+			it.Items = parseMenuItems(r, depth+1)
+		}
+		items = append(items, it)
+		if flags&MF_END != 0 {
+			break
+		}
+	}
+	return items
+}
+
+func parseMenuExItems(r *resReader, depth int) []MenuItem {
+	if depth > maxMenuDepth {
+		r.err = errors.New("menu template is nested too deeply")
+		return nil
+	}
+	var items []MenuItem
+	for r.err == nil {
+		r.align(4)
+		it := MenuItem{
+			Type:  r.u32(),
+			State: r.u32(),
+			ID:    r.u32(),
+		}
+		flags := r.u16()
+		it.Popup = flags&menuExPopup != 0
+		it.Text = r.sz()
+		if it.Popup {
+			r.align(4)
+			it.HelpID = r.u32()
+			it.Items = parseMenuExItems(r, depth+1)
+		}
+		items = append(items, it)
+		if flags&menuExEnd != 0 {
+			break
+		}
+	}
+	return items
+}
+
+// Bytes encodes d as an RT_DIALOG resource, using the DLGTEMPLATEEX layout
+// when d.Extended is set.
+func (d *DialogTemplate) Bytes() []byte {
+	var w resWriter
+	if d.Extended {
+		w.u16(1)
+		w.u16(0xFFFF)
+		w.u32(d.HelpID)
+		w.u32(d.ExStyle)
+		w.u32(d.Style)
+	} else {
+		w.u32(d.Style)
+		w.u32(d.ExStyle)
+	}
+	w.u16(uint16(len(d.Items)))
+	w.u16(uint16(d.X))
+	w.u16(uint16(d.Y))
+	w.u16(uint16(d.Cx))
+	w.u16(uint16(d.Cy))
+	w.szOrOrd(d.Menu)
+	w.szOrOrd(d.Class)
+	w.sz(d.Title)
+	if d.Style&DS_SETFONT != 0 {
+		w.u16(d.PointSize)
+		if d.Extended {
+			w.u16(d.Weight)
+			if d.Italic {
+				w.u8(1)
+			} else {
+				w.u8(0)
+			}
+			w.u8(d.CharSet)
+		}
+		w.sz(d.FaceName)
+	}
+
+	for _, it := range d.Items {
+		w.align(4)
+		if d.Extended {
+			w.u32(it.HelpID)
+			w.u32(it.ExStyle)
+			w.u32(it.Style)
+		} else {
+			w.u32(it.Style)
+			w.u32(it.ExStyle)
+		}
+		w.u16(uint16(it.X))
+		w.u16(uint16(it.Y))
+		w.u16(uint16(it.Cx))
+		w.u16(uint16(it.Cy))
+		if d.Extended {
+			w.u32(it.ID)
+		} else {
+			w.u16(uint16(it.ID))
+		}
+		w.szOrOrd(it.Class)
+		w.szOrOrd(it.Title)
+		switch {
+		case len(it.CreationData) == 0:
+			w.u16(0)
+		case d.Extended:
+			w.u16(uint16(len(it.CreationData)))
+		default:
+			w.u16(uint16(len(it.CreationData) + 2))
+		}
+		w.b.Write(it.CreationData)
+	}
+	return w.b.Bytes()
+}
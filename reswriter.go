@@ -0,0 +1,393 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Add appends a resource. Resources may be added in any order; the writers
+// sort them into directory order.
+func (t *ResourceTable) Add(typ, name ResourceID, lang uint16, data []byte) *Resource {
+	r := &Resource{Type: typ, Name: name, Lang: lang, Data: data}
+	t.Resources = append(t.Resources, r)
+	return r
+}
+
+// nextID returns the lowest unused ordinal name of the given type.
+func (t *ResourceTable) nextID(typ uint16) uint16 {
+	var max uint16
+	for _, r := range t.Resources {
+		if r.Type == IntResourceID(typ) && r.Name.IsInt() && r.Name.ID > max {
+			max = r.Name.ID
+		}
+	}
+	return max + 1
+}
+
+// AddIcon adds the images of f as RT_ICON (or RT_CURSOR) resources with
+// fresh ordinals and an RT_GROUP_ICON (or RT_GROUP_CURSOR) resource called
+// name that refers to them. The group with the lowest ordinal is the one
+// Explorer shows for an executable.
+func (t *ResourceTable) AddIcon(name ResourceID, lang uint16, f *IconFile) {
+	imgType, groupType, dirType := uint16(RT_ICON), uint16(RT_GROUP_ICON), uint16(1)
+	if f.Cursor {
+		imgType, groupType, dirType = RT_CURSOR, RT_GROUP_CURSOR, 2
+	}
+
+	var w resWriter
+	w.u16(0)
+	w.u16(dirType)
+	w.u16(uint16(len(f.Entries)))
+	for _, e := range f.Entries {
+		id := t.nextID(imgType)
+		data := e.Data
+		planes, bitCount := e.Planes, e.BitCount
+		if img, err := ParseIconImage(e.Data, false); err == nil {
+			planes, bitCount = 1, img.BitCount
+		}
+		if f.Cursor {
+			var hs resWriter
+			hs.u16(e.HotspotX)
+			hs.u16(e.HotspotY)
+			data = append(hs.b.Bytes(), e.Data...)
+			// CURSORDIR heights count both masks of bitmap cursors.
+			height := e.Height
+			if !e.IsPNG() {
+				height *= 2
+			}
+			w.u16(uint16(e.Width))
+			w.u16(uint16(height))
+		} else {
+			w.u8(byte(e.Width))
+			w.u8(byte(e.Height))
+			w.u8(byte(e.ColorCount))
+			w.u8(0)
+		}
+		w.u16(planes)
+		w.u16(bitCount)
+		w.u32(uint32(len(data)))
+		w.u16(id)
+		t.Add(IntResourceID(imgType), IntResourceID(id), lang, data)
+	}
+	t.Add(IntResourceID(groupType), name, lang, w.b.Bytes())
+}
+
+// AddVersionInfo adds vi as the RT_VERSION resource #1.
+func (t *ResourceTable) AddVersionInfo(lang uint16, vi *VersionInfo) {
+	t.Add(IntResourceID(RT_VERSION), IntResourceID(1), lang, vi.Bytes())
+}
+
+// AddManifest adds an application manifest as RT_MANIFEST
+// CREATEPROCESS_MANIFEST_RESOURCE_ID.
+func (t *ResourceTable) AddManifest(lang uint16, manifest []byte) {
+	t.Add(IntResourceID(RT_MANIFEST), IntResourceID(CREATEPROCESS_MANIFEST_RESOURCE_ID), lang, manifest)
+}
+
+// AddStrings adds a string table, grouped into the sixteen-string RT_STRING
+// blocks that LoadString reads.
+func (t *ResourceTable) AddStrings(lang uint16, strs map[uint16]string) {
+	blocks := make(map[uint16]*[16]string)
+	for id, s := range strs {
+		b := blocks[id/16+1]
+		if b == nil {
+			b = new([16]string)
+			blocks[id/16+1] = b
+		}
+		b[id%16] = s
+	}
+
+	ids := make([]int, 0, len(blocks))
+	for id := range blocks {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		var w resWriter
+		for _, s := range blocks[uint16(id)] {
+			u := utf16Bytes(s, false)
+			w.u16(uint16(len(u) / 2))
+			w.b.Write(u)
+		}
+		t.Add(IntResourceID(RT_STRING), IntResourceID(uint16(id)), lang, w.b.Bytes())
+	}
+}
+
+// AddDialog adds d as an RT_DIALOG resource.
+func (t *ResourceTable) AddDialog(name ResourceID, lang uint16, d *DialogTemplate) {
+	t.Add(IntResourceID(RT_DIALOG), name, lang, d.Bytes())
+}
+
+// sorted returns the resources in directory order: by type, name and
+// language, named entries before ordinals at each level. Names that differ
+// only in case are equal, as they are to the loader.
+func (t *ResourceTable) sorted() []*Resource {
+	rs := append([]*Resource(nil), t.Resources...)
+	sort.SliceStable(rs, func(i, j int) bool {
+		a, b := rs[i], rs[j]
+		switch {
+		case a.Type.less(b.Type):
+			return true
+		case b.Type.less(a.Type):
+			return false
+		case a.Name.less(b.Name):
+			return true
+		case b.Name.less(a.Name):
+			return false
+		}
+		return a.Lang < b.Lang
+	})
+	return rs
+}
+
+// SectionBytes lays out the resource directory tree as it appears in a .rsrc
+// section loaded at rva. It also returns the section offsets of the data
+// entry OffsetToData fields, which need relocations in an object file.
+func (t *ResourceTable) SectionBytes(rva uint32) ([]byte, []uint32) {
+	rs := t.sorted()
+
+	type nameGroup struct {
+		name  ResourceID
+		langs []*Resource
+	}
+	type typeGroup struct {
+		typ   ResourceID
+		names []*nameGroup
+	}
+	// rs is sorted, so an entry starts a new group exactly when it sorts
+	// after the last one; a group keeps its first spelling of the name.
+	var types []*typeGroup
+	for _, r := range rs {
+		if len(types) == 0 || types[len(types)-1].typ.less(r.Type) {
+			types = append(types, &typeGroup{typ: r.Type})
+		}
+		tg := types[len(types)-1]
+		if len(tg.names) == 0 || tg.names[len(tg.names)-1].name.less(r.Name) {
+			tg.names = append(tg.names, &nameGroup{name: r.Name})
+		}
+		ng := tg.names[len(tg.names)-1]
+		ng.langs = append(ng.langs, r)
+	}
+
+	// Directories come first, then data entries, name strings and data.
+	dirSize := func(n int) uint32 { return uint32(16 + 8*n) }
+	off := dirSize(len(types))
+	typeOff := make([]uint32, len(types))
+	for i, tg := range types {
+		typeOff[i] = off
+		off += dirSize(len(tg.names))
+	}
+	nameOff := make(map[*nameGroup]uint32)
+	for _, tg := range types {
+		for _, ng := range tg.names {
+			nameOff[ng] = off
+			off += dirSize(len(ng.langs))
+		}
+	}
+	entryOff := off
+	off += uint32(16 * len(rs))
+
+	strOff := make(map[string]uint32)
+	var strs resWriter
+	addStr := func(id ResourceID) {
+		if id.IsInt() {
+			return
+		}
+		if _, ok := strOff[id.Name]; ok {
+			return
+		}
+		strOff[id.Name] = off + uint32(strs.b.Len())
+		u := utf16Bytes(id.Name, false)
+		strs.u16(uint16(len(u) / 2))
+		strs.b.Write(u)
+	}
+	for _, tg := range types {
+		addStr(tg.typ)
+		for _, ng := range tg.names {
+			addStr(ng.name)
+		}
+	}
+	strs.align(8)
+	off += uint32(strs.b.Len())
+
+	var w resWriter
+	dir := func(ids []ResourceID) {
+		var named uint16
+		for _, id := range ids {
+			if !id.IsInt() {
+				named++
+			}
+		}
+		binary.Write(&w.b, binary.LittleEndian, imageResourceDirectory{
+			MajorVersion:         4,
+			NumberOfNamedEntries: named,
+			NumberOfIdEntries:    uint16(len(ids)) - named,
+		})
+	}
+	entryName := func(id ResourceID) uint32 {
+		if id.IsInt() {
+			return uint32(id.ID)
+		}
+		return strOff[id.Name] | imageResourceNameIsString
+	}
+
+	ids := make([]ResourceID, len(types))
+	for i, tg := range types {
+		ids[i] = tg.typ
+	}
+	dir(ids)
+	for i, tg := range types {
+		w.u32(entryName(tg.typ))
+		w.u32(typeOff[i] | imageResourceDataIsDirectory)
+	}
+	for _, tg := range types {
+		ids = ids[:0]
+		for _, ng := range tg.names {
+			ids = append(ids, ng.name)
+		}
+		dir(ids)
+		for _, ng := range tg.names {
+			w.u32(entryName(ng.name))
+			w.u32(nameOff[ng] | imageResourceDataIsDirectory)
+		}
+	}
+	leaf := entryOff
+	for _, tg := range types {
+		for _, ng := range tg.names {
+			binary.Write(&w.b, binary.LittleEndian, imageResourceDirectory{
+				MajorVersion:      4,
+				NumberOfIdEntries: uint16(len(ng.langs)),
+			})
+			for _, r := range ng.langs {
+				w.u32(uint32(r.Lang))
+				w.u32(leaf)
+				leaf += 16
+			}
+		}
+	}
+
+	var relocs []uint32
+	dataOff := off
+	for _, r := range rs {
+		relocs = append(relocs, uint32(w.b.Len()))
+		binary.Write(&w.b, binary.LittleEndian, imageResourceDataEntry{
+			OffsetToData: rva + dataOff,
+			Size:         uint32(len(r.Data)),
+			CodePage:     r.CodePage,
+		})
+		dataOff += uint32(len(r.Data)+7) &^ 7
+	}
+	w.b.Write(strs.b.Bytes())
+	for _, r := range rs {
+		w.b.Write(r.Data)
+		w.align(8)
+	}
+	return w.b.Bytes(), relocs
+}
+
+// WriteSyso writes t as a COFF object file holding a single .rsrc section,
+// the format the Go linker picks up from *.syso files in a package
+// directory. goarch is "386", "amd64" or "arm64".
+func (t *ResourceTable) WriteSyso(w io.Writer, goarch string) error {
+	var machine, relocType uint16
+	switch goarch {
+	case "386":
+		machine, relocType = pe.IMAGE_FILE_MACHINE_I386, 0x0007 // IMAGE_REL_I386_DIR32NB
+	case "amd64":
+		machine, relocType = pe.IMAGE_FILE_MACHINE_AMD64, 0x0003 // IMAGE_REL_AMD64_ADDR32NB
+	case "arm64":
+		machine, relocType = pe.IMAGE_FILE_MACHINE_ARM64, 0x0002 // IMAGE_REL_ARM64_ADDR32NB
+	default:
+		return fmt.Errorf("unsupported architecture %q", goarch)
+	}
+
+	// The data entries hold section-relative offsets and are relocated
+	// against the .rsrc section symbol when linked.
+	data, relocs := t.SectionBytes(0)
+	const headersSize = 20 + 40
+	relocOff := headersSize + len(data)
+	symOff := relocOff + 10*len(relocs)
+
+	var b resWriter
+	binary.Write(&b.b, binary.LittleEndian, pe.FileHeader{
+		Machine:              machine,
+		NumberOfSections:     1,
+		PointerToSymbolTable: uint32(symOff),
+		NumberOfSymbols:      1,
+	})
+	sh := pe.SectionHeader32{
+		SizeOfRawData:        uint32(len(data)),
+		PointerToRawData:     headersSize,
+		PointerToRelocations: uint32(relocOff),
+		NumberOfRelocations:  uint16(len(relocs)),
+		Characteristics:      pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_MEM_READ,
+	}
+	copy(sh.Name[:], ".rsrc")
+	binary.Write(&b.b, binary.LittleEndian, sh)
+	b.b.Write(data)
+	for _, off := range relocs {
+		binary.Write(&b.b, binary.LittleEndian, pe.Reloc{
+			VirtualAddress:   off,
+			SymbolTableIndex: 0,
+			Type:             relocType,
+		})
+	}
+	sym := pe.COFFSymbol{
+		SectionNumber: 1,
+		StorageClass:  3, // IMAGE_SYM_CLASS_STATIC
+	}
+	copy(sym.Name[:], ".rsrc")
+	binary.Write(&b.b, binary.LittleEndian, sym)
+	// An empty string table is just its own length.
+	b.u32(4)
+
+	_, err := w.Write(b.b.Bytes())
+	return err
+}
+
+// WriteRes writes t in the 32-bit .res format produced by rc.exe and
+// accepted by link.exe and windres.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/ms648027.aspx
+func (t *ResourceTable) WriteRes(w io.Writer) error {
+	var b resWriter
+	// The file starts with an empty resource that marks it as 32-bit.
+	t.writeResEntry(&b, &Resource{}, 0)
+	for _, r := range t.sorted() {
+		t.writeResEntry(&b, r, 0x1030) // MOVEABLE | PURE | DISCARDABLE
+	}
+	_, err := w.Write(b.b.Bytes())
+	return err
+}
+
+func (t *ResourceTable) writeResEntry(b *resWriter, r *Resource, memoryFlags uint16) {
+	var hdr resWriter
+	resID := func(id ResourceID) {
+		if id.IsInt() {
+			hdr.u16(0xFFFF)
+			hdr.u16(id.ID)
+		} else {
+			hdr.sz(id.Name)
+		}
+	}
+	resID(r.Type)
+	resID(r.Name)
+	hdr.align(4)
+	hdr.u32(0) // DataVersion
+	hdr.u16(memoryFlags)
+	hdr.u16(r.Lang)
+	hdr.u32(0) // Version
+	hdr.u32(0) // Characteristics
+
+	b.u32(uint32(len(r.Data)))
+	b.u32(uint32(8 + hdr.b.Len()))
+	b.b.Write(hdr.b.Bytes())
+	b.b.Write(r.Data)
+	b.align(4)
+}
@@ -0,0 +1,272 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"debug/pe"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testDialog = DialogTemplate{
+	Style:     DS_SETFONT | DS_MODALFRAME | WS_POPUP | WS_CAPTION | WS_SYSMENU,
+	X:         10,
+	Y:         20,
+	Cx:        200,
+	Cy:        100,
+	Title:     "About",
+	PointSize: 9,
+	FaceName:  "MS Shell Dlg",
+	Items: []DialogItem{
+		{Style: WS_CHILD | WS_VISIBLE | BS_DEFPUSHBUTTON, X: 140, Y: 80, Cx: 50, Cy: 14, ID: IDOK,
+			Class: IntResourceID(0x0080), Title: NamedResourceID("OK"), CreationData: []byte{1, 2, 3}},
+		{Style: WS_CHILD | WS_VISIBLE, X: 10, Y: 10, Cx: 120, Cy: 60, ID: 100,
+			Class: NamedResourceID("SysListView32"), Title: NamedResourceID("")},
+		{Style: WS_CHILD | WS_VISIBLE, X: 10, Y: 80, Cx: 16, Cy: 16, ID: 101,
+			Class: IntResourceID(0x0082), Title: IntResourceID(1)},
+	},
+}
+
+// testResources returns a table with an icon, version information, a
+// manifest, strings in two blocks, a classic and an extended dialog and a
+// custom resource with named type and name.
+func testResources(t *testing.T) (*ResourceTable, *IconFile, *VersionInfo) {
+	data, err := os.ReadFile(filepath.Join("testdata", "icon", "test.ico"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	icon, err := DecodeIconFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vi := &VersionInfo{Fixed: NewFixedFileInfo([4]uint16{1, 2, 3, 4}, [4]uint16{1, 2, 0, 0})}
+	vi.SetString(0x0409, 1200, "ProductName", "Test")
+	vi.SetString(0x0409, 1200, "FileDescription", "Résumé")
+
+	rt := new(ResourceTable)
+	rt.AddIcon(IntResourceID(1), 0x0409, icon)
+	rt.AddVersionInfo(0x0409, vi)
+	rt.AddManifest(0x0409, []byte("<assembly/>"))
+	rt.AddStrings(0x0409, map[uint16]string{1: "one", 15: "fifteen", 16: "sixteen", 200: "two hundred"})
+	rt.AddDialog(NamedResourceID("ABOUT"), 0x0409, &testDialog)
+	ex := testDialog
+	ex.Extended, ex.HelpID, ex.Weight, ex.Italic, ex.CharSet = true, 7, FW_BOLD, true, 1
+	rt.AddDialog(IntResourceID(100), 0x0409, &ex)
+	rt.Add(NamedResourceID("CUSTOM"), NamedResourceID("BLOB"), 0, []byte{9, 8, 7})
+	return rt, icon, vi
+}
+
+// checkTestResources checks that rt, read back from the output of a
+// writer, holds what testResources put in.
+func checkTestResources(t *testing.T, name string, rt *ResourceTable, icon *IconFile, vi *VersionInfo) {
+	t.Helper()
+	r, ok := rt.Find(IntResourceID(RT_GROUP_ICON), IntResourceID(1), 0x0409)
+	if !ok {
+		t.Fatalf("%s: no RT_GROUP_ICON #1", name)
+	}
+	g, err := ParseGroupIcon(r.Data)
+	if err != nil || len(g.Entries) != len(icon.Entries) {
+		t.Fatalf("%s: group icon %+v, %v", name, g, err)
+	}
+	for i, e := range g.Entries {
+		r, ok := rt.Find(IntResourceID(RT_ICON), IntResourceID(e.ID), 0x0409)
+		if !ok || !bytes.Equal(r.Data, icon.Entries[i].Data) || int(e.BytesInRes) != len(r.Data) {
+			t.Errorf("%s: RT_ICON #%d does not hold icon image %d", name, e.ID, i)
+		}
+		if e.Width != icon.Entries[i].Width || e.Height != icon.Entries[i].Height {
+			t.Errorf("%s: group entry %d is %dx%d", name, i, e.Width, e.Height)
+		}
+	}
+
+	r, ok = rt.Find(IntResourceID(RT_VERSION), IntResourceID(1), 0)
+	if !ok {
+		t.Fatalf("%s: no RT_VERSION", name)
+	}
+	got, err := ParseVersionInfo(r.Data)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if *got.Fixed != *vi.Fixed || !reflect.DeepEqual(got.StringTables, vi.StringTables) ||
+		!reflect.DeepEqual(got.Translations, vi.Translations) {
+		t.Errorf("%s: version info is %+v, want %+v", name, got, vi)
+	}
+
+	r, ok = rt.Find(IntResourceID(RT_MANIFEST), IntResourceID(CREATEPROCESS_MANIFEST_RESOURCE_ID), 0)
+	if !ok || DecodeManifest(r.Data) != "<assembly/>" {
+		t.Errorf("%s: manifest is missing or wrong", name)
+	}
+
+	for id, want := range map[uint16]string{1: "one", 15: "fifteen", 16: "sixteen", 200: "two hundred"} {
+		if s, ok := rt.LoadString(id, 0x0409); !ok || s != want {
+			t.Errorf("%s: string %d is %q, want %q", name, id, s, want)
+		}
+	}
+	if s, ok := rt.LoadString(2, 0x0409); ok {
+		t.Errorf("%s: string 2 is %q", name, s)
+	}
+
+	r, ok = rt.Find(IntResourceID(RT_DIALOG), NamedResourceID("about"), 0x0409)
+	if !ok {
+		t.Fatalf("%s: no ABOUT dialog", name)
+	}
+	d, err := ParseDialogTemplate(r.Data)
+	if err != nil || !reflect.DeepEqual(*d, testDialog) {
+		t.Errorf("%s: dialog is %+v, %v; want %+v", name, d, err, testDialog)
+	}
+	r, ok = rt.Find(IntResourceID(RT_DIALOG), IntResourceID(100), 0x0409)
+	if !ok {
+		t.Fatalf("%s: no dialog #100", name)
+	}
+	d, err = ParseDialogTemplate(r.Data)
+	if err != nil || !d.Extended || d.HelpID != 7 || d.Weight != FW_BOLD || !d.Italic || d.CharSet != 1 ||
+		!reflect.DeepEqual(d.Items, testDialog.Items) {
+		t.Errorf("%s: extended dialog is %+v, %v", name, d, err)
+	}
+
+	r, ok = rt.Find(NamedResourceID("CUSTOM"), NamedResourceID("blob"), 0x0409)
+	if !ok || !bytes.Equal(r.Data, []byte{9, 8, 7}) {
+		t.Errorf("%s: custom resource is missing or wrong", name)
+	}
+}
+
+func TestWriteSyso(t *testing.T) {
+	rt, icon, vi := testResources(t)
+	for _, tt := range []struct {
+		goarch  string
+		machine uint16
+	}{
+		{"386", pe.IMAGE_FILE_MACHINE_I386},
+		{"amd64", pe.IMAGE_FILE_MACHINE_AMD64},
+		{"arm64", pe.IMAGE_FILE_MACHINE_ARM64},
+	} {
+		var b bytes.Buffer
+		if err := rt.WriteSyso(&b, tt.goarch); err != nil {
+			t.Fatal(err)
+		}
+		f, err := pe.NewFile(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.goarch, err)
+		}
+		if f.Machine != tt.machine || len(f.Sections) != 1 {
+			t.Errorf("%s: machine %#x with %d sections", tt.goarch, f.Machine, len(f.Sections))
+		}
+		// Every data entry is relocated.
+		if n, want := len(f.Sections[0].Relocs), len(rt.Resources); n != want {
+			t.Errorf("%s: %d relocations for %d resources", tt.goarch, n, want)
+		}
+		got, err := NewResourceTable(f)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.goarch, err)
+		}
+		checkTestResources(t, tt.goarch, got, icon, vi)
+	}
+	if err := rt.WriteSyso(new(bytes.Buffer), "mips"); err == nil {
+		t.Error("WriteSyso accepted mips")
+	}
+
+	// SectionBytes at a nonzero RVA, as in a linked image.
+	data, _ := rt.SectionBytes(0x3000)
+	got, err := ParseResourceSection(data, 0x3000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestResources(t, "section at 0x3000", got, icon, vi)
+}
+
+// parseRes reads a 32-bit .res file.
+func parseRes(t *testing.T, data []byte) *ResourceTable {
+	t.Helper()
+	rt := new(ResourceTable)
+	r := resReader{data: data}
+	for first := true; r.pos < len(data); first = false {
+		start := r.pos
+		dataSize, headerSize := r.u32(), r.u32()
+		typ, name := r.szOrOrd(), r.szOrOrd()
+		r.align(4)
+		r.u32() // DataVersion
+		flags, lang := r.u16(), r.u16()
+		r.u32() // Version
+		r.u32() // Characteristics
+		if r.err != nil || r.pos-start != int(headerSize) {
+			t.Fatalf("resource header at %#x is %d bytes, says %d (%v)", start, r.pos-start, headerSize, r.err)
+		}
+		body := r.bytes(int(dataSize))
+		r.align(4)
+		if r.err != nil {
+			t.Fatalf("resource at %#x: %v", start, r.err)
+		}
+		if first {
+			// The empty resource that marks a 32-bit file.
+			if dataSize != 0 || typ != IntResourceID(0) || name != IntResourceID(0) {
+				t.Fatalf(".res file starts with %v %v of %d bytes", typ, name, dataSize)
+			}
+			continue
+		}
+		if flags != 0x1030 {
+			t.Errorf("%v %v has memory flags %#x", typ, name, flags)
+		}
+		rt.Add(typ, name, lang, body)
+	}
+	return rt
+}
+
+func TestWriteRes(t *testing.T) {
+	rt, icon, vi := testResources(t)
+	var b bytes.Buffer
+	if err := rt.WriteRes(&b); err != nil {
+		t.Fatal(err)
+	}
+	got := parseRes(t, b.Bytes())
+	if len(got.Resources) != len(rt.Resources) {
+		t.Errorf(".res file holds %d resources, want %d", len(got.Resources), len(rt.Resources))
+	}
+	checkTestResources(t, ".res", got, icon, vi)
+}
+
+func TestSectionBytesNameCase(t *testing.T) {
+	rt := new(ResourceTable)
+	rt.Add(IntResourceID(RT_RCDATA), NamedResourceID("Foo"), 0x0409, []byte{1})
+	rt.Add(IntResourceID(RT_RCDATA), IntResourceID(5), 0, []byte{2})
+	rt.Add(IntResourceID(RT_RCDATA), NamedResourceID("BAR"), 0, []byte{3})
+	rt.Add(IntResourceID(RT_RCDATA), NamedResourceID("FOO"), 0x0407, []byte{4})
+	rt.Add(NamedResourceID("custom"), NamedResourceID("x"), 0, []byte{5})
+	rt.Add(IntResourceID(RT_RCDATA), NamedResourceID("foo"), 0x040C, []byte{6})
+	rt.Add(NamedResourceID("CUSTOM"), NamedResourceID("X"), 1, []byte{7})
+
+	data, _ := rt.SectionBytes(0)
+	got, err := ParseResourceSection(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Names that differ only in case share a directory entry, spelled as
+	// in its lowest language.
+	type leaf struct {
+		typ, name ResourceID
+		lang      uint16
+		data      byte
+	}
+	want := []leaf{
+		{NamedResourceID("custom"), NamedResourceID("x"), 0, 5},
+		{NamedResourceID("custom"), NamedResourceID("x"), 1, 7},
+		{IntResourceID(RT_RCDATA), NamedResourceID("BAR"), 0, 3},
+		{IntResourceID(RT_RCDATA), NamedResourceID("FOO"), 0x0407, 4},
+		{IntResourceID(RT_RCDATA), NamedResourceID("FOO"), 0x0409, 1},
+		{IntResourceID(RT_RCDATA), NamedResourceID("FOO"), 0x040C, 6},
+		{IntResourceID(RT_RCDATA), IntResourceID(5), 0, 2},
+	}
+	var leaves []leaf
+	for _, r := range got.Resources {
+		leaves = append(leaves, leaf{r.Type, r.Name, r.Lang, r.Data[0]})
+	}
+	if !reflect.DeepEqual(leaves, want) {
+		t.Errorf("directory holds\n%v\nwant\n%v", leaves, want)
+	}
+	if r, ok := got.Find(IntResourceID(RT_RCDATA), NamedResourceID("foo"), 0x040C); !ok || r.Data[0] != 6 {
+		t.Errorf("foo in French is %+v, %v", r, ok)
+	}
+}
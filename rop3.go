@@ -0,0 +1,223 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"encoding/binary"
+	"image"
+)
+
+// A ternary raster operation code, as passed to BitBlt, carries its
+// truth table in bits 16 to 23: bit p<<2|s<<1|d of that index byte is
+// the result for pattern, source and destination bits p, s and d. The low
+// word only encodes how GDI once compiled the operation and is ignored
+// here.
+
+// rop3Minterms lists, for each bit of the index byte, the pattern, source
+// and destination polarities it stands for.
+var rop3Minterms = [8][3]bool{
+	{false, false, false},
+	{false, false, true},
+	{false, true, false},
+	{false, true, true},
+	{true, false, false},
+	{true, false, true},
+	{true, true, false},
+	{true, true, true},
+}
+
+// EvalROP3 applies the ternary raster operation rop to every bit of
+// pattern p, source s and destination d. Any packing of the operands
+// works, since each bit is combined independently.
+func EvalROP3(rop, p, s, d uint32) uint32 {
+	return uint32(evalROP3(uint8(rop>>16), uint64(p), uint64(s), uint64(d)))
+}
+
+// evalROP3 ORs together the minterms selected by the index byte.
+func evalROP3(index uint8, p, s, d uint64) uint64 {
+	switch index {
+	case 0x00:
+		return 0
+	case 0xFF:
+		return ^uint64(0)
+	case 0xCC:
+		return s
+	case 0xF0:
+		return p
+	case 0xAA:
+		return d
+	}
+	var r uint64
+	for i, m := range rop3Minterms {
+		if index&(1<<uint(i)) == 0 {
+			continue
+		}
+		t := ^uint64(0)
+		if m[0] {
+			t &= p
+		} else {
+			t &^= p
+		}
+		if m[1] {
+			t &= s
+		} else {
+			t &^= s
+		}
+		if m[2] {
+			t &= d
+		} else {
+			t &^= d
+		}
+		r |= t
+	}
+	return r
+}
+
+// ROP3Operands reports which operands the result of rop depends on.
+func ROP3Operands(rop uint32) (pattern, source, destination bool) {
+	index := uint8(rop >> 16)
+	pattern = index>>4&0x0F != index&0x0F
+	source = index>>2&0x33 != index&0x33
+	destination = index>>1&0x55 != index&0x55
+	return
+}
+
+// ROP3Bytes sets dst[i] to the result of rop on pat[i], src[i] and
+// dst[i]. src and pat must be at least as long as dst, or nil to read as
+// zeros.
+func ROP3Bytes(rop uint32, dst, src, pat []byte) {
+	index := uint8(rop >> 16)
+	word := func(b []byte, i int) uint64 {
+		if b == nil {
+			return 0
+		}
+		return binary.LittleEndian.Uint64(b[i:])
+	}
+	n := len(dst) &^ 7
+	for i := 0; i < n; i += 8 {
+		r := evalROP3(index, word(pat, i), word(src, i), word(dst, i))
+		binary.LittleEndian.PutUint64(dst[i:], r)
+	}
+	for i := n; i < len(dst); i++ {
+		var p, s uint64
+		if pat != nil {
+			p = uint64(pat[i])
+		}
+		if src != nil {
+			s = uint64(src[i])
+		}
+		dst[i] = uint8(evalROP3(index, p, s, uint64(dst[i])))
+	}
+}
+
+// ROP3Image applies rop to the pixels of dst in r, as BitBlt does on a
+// 32 bpp bitmap: all four channels are combined. Source pixels are read
+// from src starting at sp, and the pattern is tiled from pat with its
+// origin at dst pixel pp. src and pat may be nil if rop does not use
+// them. dst and src may overlap.
+func ROP3Image(dst *image.RGBA, r image.Rectangle, src *image.RGBA, sp image.Point, pat *image.RGBA, pp image.Point, rop uint32) {
+	usePat, useSrc, _ := ROP3Operands(rop)
+	if !useSrc {
+		src = nil
+	}
+	if !usePat {
+		pat = nil
+	}
+	if pat != nil && pat.Rect.Empty() {
+		pat = nil
+	}
+	if src != nil {
+		// Clip to the destination and the source, keeping r and sp in
+		// step.
+		clipped := r.Intersect(dst.Rect)
+		sp = sp.Add(clipped.Min.Sub(r.Min))
+		r = clipped
+		sr := r.Sub(r.Min).Add(sp).Intersect(src.Rect)
+		r = sr.Sub(sp).Add(r.Min)
+		sp = sr.Min
+	} else {
+		r = r.Intersect(dst.Rect)
+	}
+	if r.Empty() {
+		return
+	}
+
+	w := r.Dx() * 4
+	var srcRow, patRow []byte
+	if src != nil {
+		srcRow = make([]byte, w)
+	}
+	if pat != nil {
+		patRow = make([]byte, w)
+	}
+	dy, step := 0, 1
+	if src == dst && sp.Y < r.Min.Y {
+		// Work upwards so that source rows are read before being written.
+		dy, step = r.Dy()-1, -1
+	}
+	for ; dy >= 0 && dy < r.Dy(); dy += step {
+		y := r.Min.Y + dy
+		if src != nil {
+			i := src.PixOffset(sp.X, sp.Y+dy)
+			copy(srcRow, src.Pix[i:i+w])
+		}
+		if pat != nil {
+			pr := pat.Rect
+			py := pr.Min.Y + mod(y-pp.Y, pr.Dy())
+			for x := 0; x < r.Dx(); x++ {
+				px := pr.Min.X + mod(r.Min.X+x-pp.X, pr.Dx())
+				i := pat.PixOffset(px, py)
+				copy(patRow[4*x:4*x+4], pat.Pix[i:i+4])
+			}
+		}
+		i := dst.PixOffset(r.Min.X, y)
+		ROP3Bytes(rop, dst.Pix[i:i+w], srcRow, patRow)
+	}
+}
+
+// ROP3Expression returns the operation rop performs in the reverse Polish
+// notation of the Windows documentation: P, S and D are the pattern,
+// source and destination, and a, o, x and n are AND, OR, XOR and NOT. For
+// example, PATINVERT is "DPx".
+func ROP3Expression(rop uint32) string {
+	return rop3Expressions[uint8(rop>>16)]
+}
+
+// rop3Expressions is the table of all ternary raster operations by index
+// byte, as documented for Windows.
+var rop3Expressions = [256]string{
+	"0", "DPSoon", "DPSona", "PSon", "SDPona", "DPon", "PDSxnon", "PDSaon",
+	"SDPnaa", "PDSxon", "DPna", "PSDnaon", "SPna", "PDSnaon", "PDSonon", "Pn",
+	"PDSona", "DSon", "SDPxnon", "SDPaon", "DPSxnon", "DPSaon", "PSDPSanaxx", "SSPxDSxaxn",
+	"SPxPDxa", "SDPSanaxn", "PDSPaox", "SDPSxaxn", "PSDPaox", "DSPDxaxn", "PDSox", "PDSoan",
+	"DPSnaa", "SDPxon", "DSna", "SPDnaon", "SPxDSxa", "PDSPanaxn", "SDPSaox", "SDPSxnox",
+	"DPSxa", "PSDPSaoxxn", "DPSana", "SSPxPDxaxn", "SPDSoax", "PSDnox", "PSDPxox", "PSDnoan",
+	"PSna", "SDPnaon", "SDPSoox", "Sn", "SPDSaox", "SPDSxnox", "SDPox", "SDPoan",
+	"PSDPoax", "SPDnox", "SPDSxox", "SPDnoan", "PSx", "SPDSonox", "SPDSnaox", "PSan",
+	"PSDnaa", "DPSxon", "SDxPDxa", "SPDSanaxn", "SDna", "DPSnaon", "DSPDaox", "PSDPxaxn",
+	"SDPxa", "PDSPDaoxxn", "DPSDoax", "PDSnox", "SDPana", "SSPxDSxoxn", "PDSPxox", "PDSnoan",
+	"PDna", "DSPnaon", "DPSDaox", "SPDSxaxn", "DPSonon", "Dn", "DPSox", "DPSoan",
+	"PDSPoax", "DPSnox", "DPx", "DPSDonox", "DPSDxox", "DPSnoan", "DPSDnaox", "DPan",
+	"PDSxa", "DSPDSaoxxn", "DSPDoax", "SDPnox", "SDPSoax", "DSPnox", "DSx", "SDPSonox",
+	"DSPDSonoxxn", "PDSxxn", "DPSax", "PSDPSoaxxn", "SDPax", "PDSPDoaxxn", "SDPSnoax", "PDSxnan",
+	"PDSana", "SSDxPDxaxn", "SDPSxox", "SDPnoan", "DSPDxox", "DSPnoan", "SDPSnaox", "DSan",
+	"PDSax", "DSPDSoaxxn", "DPSDnoax", "SDPxnan", "SPDSnoax", "DPSxnan", "SPxDSxo", "DPSaan",
+	"DPSaa", "SPxDSxon", "DPSxna", "SPDSnoaxn", "SDPxna", "PDSPnoaxn", "DSPDSoaxx", "PDSaxn",
+	"DSa", "SDPSnaoxn", "DSPnoa", "DSPDxoxn", "SDPnoa", "SDPSxoxn", "SSDxPDxax", "PDSanan",
+	"PDSxna", "SDPSnoaxn", "DPSDPoaxx", "SPDaxn", "PSDPSoaxx", "DPSaxn", "DPSxx", "PSDPSonoxx",
+	"SDPSonoxn", "DSxn", "DPSnax", "SDPSoaxn", "SPDnax", "DSPDoaxn", "DSPDSaoxx", "PDSxan",
+	"DPa", "PDSPnaoxn", "DPSnoa", "DPSDxoxn", "PDSPonoxn", "PDxn", "DSPnax", "PDSPoaxn",
+	"DPSoa", "DPSoxn", "D", "DPSono", "SPDSxax", "DPSDaoxn", "DSPnao", "DPno",
+	"PDSnoa", "PDSPxoxn", "SSPxDSxox", "SDPanan", "PSDnax", "DPSDoaxn", "DPSDPaoxx", "SDPxan",
+	"PSDPxax", "DSPDaoxn", "DPSnao", "DSno", "SPDSanax", "SDxPDxan", "DPSxo", "DPSano",
+	"PSa", "SPDSnaoxn", "SPDSonoxn", "PSxn", "SPDnoa", "SPDSxoxn", "SDPnax", "PSDPoaxn",
+	"SDPoa", "SPDoxn", "DPSDxax", "SPDSaoxn", "S", "SDPono", "SDPnao", "SPno",
+	"PSDnoa", "PSDPxoxn", "PDSnax", "SPDSoaxn", "SSPxPDxax", "DPSanan", "PSDPSaoxx", "DPSxan",
+	"PDSPxax", "SDPSaoxn", "DPSDanax", "SPxDSxan", "SPDnao", "SDno", "SDPxo", "SDPano",
+	"PDSoa", "PDSoxn", "DSPDxax", "PSDPaoxn", "SDPSxax", "PDSPaoxn", "SDPSanax", "SPxPDxan",
+	"SSPxDSxax", "DSPDSanaxxn", "DPSao", "DPSxno", "SDPao", "SDPxno", "DSo", "SDPnoo",
+	"P", "PDSono", "PDSnao", "PSno", "PSDnao", "PDno", "PDSxo", "PDSano",
+	"PDSao", "PDSxno", "DPo", "DPSnoo", "PSo", "PSDnoo", "DPSoo", "1",
+}
@@ -0,0 +1,217 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// evalRPN evaluates a raster operation in the notation of
+// rop3Expressions on all eight bits of p, s and d.
+func evalRPN(t *testing.T, expr string, p, s, d uint8) uint8 {
+	switch expr {
+	case "0":
+		return 0
+	case "1":
+		return 0xFF
+	}
+	var stack []uint8
+	for _, c := range expr {
+		switch c {
+		case 'P':
+			stack = append(stack, p)
+		case 'S':
+			stack = append(stack, s)
+		case 'D':
+			stack = append(stack, d)
+		case 'n':
+			if len(stack) < 1 {
+				t.Fatalf("%q: stack underflow", expr)
+			}
+			stack[len(stack)-1] = ^stack[len(stack)-1]
+		case 'a', 'o', 'x':
+			if len(stack) < 2 {
+				t.Fatalf("%q: stack underflow", expr)
+			}
+			a, b := stack[len(stack)-2], stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			switch c {
+			case 'a':
+				stack[len(stack)-1] = a & b
+			case 'o':
+				stack[len(stack)-1] = a | b
+			case 'x':
+				stack[len(stack)-1] = a ^ b
+			}
+		default:
+			t.Fatalf("%q: unknown operator %q", expr, c)
+		}
+	}
+	if len(stack) != 1 {
+		t.Fatalf("%q leaves %d values on the stack", expr, len(stack))
+	}
+	return stack[0]
+}
+
+// The index byte of a raster operation is its result for P = 0xF0,
+// S = 0xCC and D = 0xAA.
+func TestROP3Expressions(t *testing.T) {
+	for i, expr := range rop3Expressions {
+		if got := evalRPN(t, expr, 0xF0, 0xCC, 0xAA); got != uint8(i) {
+			t.Errorf("rop3Expressions[%#02x] = %q evaluates to %#02x", i, expr, got)
+		}
+		rop := uint32(i) << 16
+		if got := EvalROP3(rop, 0xF0, 0xCC, 0xAA) & 0xFF; got != uint32(i) {
+			t.Errorf("EvalROP3(%#08x, 0xF0, 0xCC, 0xAA) = %#x", rop, got)
+		}
+		if got := EvalROP3(rop, 0xF0F0F0F0, 0xCCCCCCCC, 0xAAAAAAAA); got != uint32(i)*0x01010101 {
+			t.Errorf("EvalROP3(%#08x) on words = %#x", rop, got)
+		}
+	}
+
+	named := []struct {
+		rop  uint32
+		expr string
+	}{
+		{BLACKNESS, "0"},
+		{NOTSRCERASE, "DSon"},
+		{NOTSRCCOPY, "Sn"},
+		{SRCERASE, "SDna"},
+		{DSTINVERT, "Dn"},
+		{PATINVERT, "DPx"},
+		{SRCINVERT, "DSx"},
+		{SRCAND, "DSa"},
+		{MERGEPAINT, "DSno"},
+		{MERGECOPY, "PSa"},
+		{SRCCOPY, "S"},
+		{SRCPAINT, "DSo"},
+		{PATCOPY, "P"},
+		{PATPAINT, "DPSnoo"},
+		{WHITENESS, "1"},
+	}
+	for _, n := range named {
+		if got := ROP3Expression(n.rop); got != n.expr {
+			t.Errorf("ROP3Expression(%#08x) = %q, want %q", n.rop, got, n.expr)
+		}
+	}
+}
+
+func TestROP3Operands(t *testing.T) {
+	for i, expr := range rop3Expressions {
+		p, s, d := ROP3Operands(uint32(i) << 16)
+		// An operand is used if flipping it changes the result.
+		usesP := evalRPN(t, expr, 0xF0, 0xCC, 0xAA) != evalRPN(t, expr, 0x0F, 0xCC, 0xAA)
+		usesS := evalRPN(t, expr, 0xF0, 0xCC, 0xAA) != evalRPN(t, expr, 0xF0, 0x33, 0xAA)
+		usesD := evalRPN(t, expr, 0xF0, 0xCC, 0xAA) != evalRPN(t, expr, 0xF0, 0xCC, 0x55)
+		if p != usesP || s != usesS || d != usesD {
+			t.Errorf("ROP3Operands(%#02x) = %v, %v, %v, want %v, %v, %v (%s)",
+				i, p, s, d, usesP, usesS, usesD, expr)
+		}
+	}
+}
+
+func TestROP3Bytes(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 256; i++ {
+		rop := uint32(i) << 16
+		// Lengths around the 8 byte word size.
+		for _, n := range []int{0, 1, 7, 8, 9, 17} {
+			dst, src, pat := make([]byte, n), make([]byte, n), make([]byte, n)
+			rng.Read(dst)
+			rng.Read(src)
+			rng.Read(pat)
+			want := make([]byte, n)
+			wantNil := make([]byte, n)
+			for j := range want {
+				want[j] = byte(EvalROP3(rop, uint32(pat[j]), uint32(src[j]), uint32(dst[j])))
+				wantNil[j] = byte(EvalROP3(rop, 0, 0, uint32(dst[j])))
+			}
+			got := append([]byte(nil), dst...)
+			ROP3Bytes(rop, got, src, pat)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("ROP3Bytes(%#08x) on %d bytes = %x, want %x", rop, n, got, want)
+			}
+			got = append(got[:0], dst...)
+			ROP3Bytes(rop, got, nil, nil)
+			if !bytes.Equal(got, wantNil) {
+				t.Fatalf("ROP3Bytes(%#08x) on %d bytes without operands = %x, want %x", rop, n, got, wantNil)
+			}
+		}
+	}
+}
+
+// ROP3Image within one image gives the same result as from a copy of it,
+// whichever way the source and destination overlap.
+func TestROP3ImageOverlap(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	newImage := func() *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 13, 11))
+		rng.Read(img.Pix)
+		return img
+	}
+	pat := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	rng.Read(pat.Pix)
+	r := image.Rect(3, 3, 10, 8)
+	for _, sp := range []image.Point{
+		{3, 3},         // in place
+		{2, 3}, {4, 3}, // left and right by one pixel
+		{3, 2}, {3, 4}, // up and down by one row
+		{1, 1}, {5, 5}, {5, 1}, // diagonally
+		{-2, -2}, {8, 7}, // clipped by the source
+	} {
+		for _, rop := range []uint32{SRCCOPY, SRCINVERT, NOTSRCCOPY, 0x00B80000, 0x00E20000} {
+			img := newImage()
+			ref := image.NewRGBA(img.Rect)
+			copy(ref.Pix, img.Pix)
+			src := image.NewRGBA(img.Rect)
+			copy(src.Pix, img.Pix)
+
+			ROP3Image(img, r, img, sp, pat, image.Pt(1, 0), rop)
+			ROP3Image(ref, r, src, sp, pat, image.Pt(1, 0), rop)
+			if !bytes.Equal(img.Pix, ref.Pix) {
+				t.Errorf("ROP3Image(%#08x) from %v within the image differs from a copy", rop, sp)
+			}
+		}
+	}
+}
+
+func TestROP3ImageClip(t *testing.T) {
+	dst := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := range src.Pix {
+		src.Pix[i] = 0xFF
+	}
+	// Clipping r to dst moves sp with it: dst (0, 0) reads src (1, 1),
+	// and nothing else lines up with a source pixel.
+	ROP3Image(dst, image.Rect(-1, -1, 4, 4), src, image.Pt(0, 0), nil, image.Point{}, SRCCOPY)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := uint8(0)
+			if x == 0 && y == 0 {
+				want = 0xFF
+			}
+			if got := dst.RGBAAt(x, y).R; got != want {
+				t.Errorf("pixel (%d, %d) = %#x, want %#x", x, y, got, want)
+			}
+		}
+	}
+
+	// PATCOPY ignores src, and tiles the pattern from pp.
+	pat := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	pat.Pix[0] = 0xFF
+	ROP3Image(dst, dst.Rect, nil, image.Point{}, pat, image.Pt(1, 0), PATCOPY)
+	for x := 0; x < 4; x++ {
+		want := uint8(0)
+		if x%2 == 1 {
+			want = 0xFF
+		}
+		if got := dst.RGBAAt(x, 2).R; got != want {
+			t.Errorf("PATCOPY pixel (%d, 2) = %#x, want %#x", x, got, want)
+		}
+	}
+}
@@ -0,0 +1,506 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// softDC paints onto an image.RGBA with the pen, brush, background and
+// raster operation semantics of a GDI device context. It works in raster
+// space (see raster.go); mapping logical coordinates is up to its users.
+// Painted pixels are made opaque.
+type softDC struct {
+	dst       *image.RGBA
+	clip      []byte // one byte per pixel of dst.Rect; nil means no clipping
+	pen       softPen
+	brush     softBrush
+	textColor COLORREF
+	bkColor   COLORREF
+	bkMode    int
+	rop2      int
+	fillMode  int
+	brushOrg  image.Point
+}
+
+// softPen is a pen resolved to raster units.
+type softPen struct {
+	style      uint32 // PS_* style, type, end cap and join
+	width      float64
+	color      COLORREF
+	userStyle  []float64
+	miterLimit float64
+}
+
+// softBrush is a brush. Pattern brushes tile pattern from the brush
+// origin; mono pattern brushes paint 0 bits in the text color and 1 bits
+// in the background color.
+type softBrush struct {
+	style   uint32 // BS_*
+	color   COLORREF
+	hatch   uint32
+	pattern image.Image
+	mono    bool
+}
+
+// GDI's cosmetic pen dash patterns, in pixels.
+var softPenDashes = map[uint32][]float64{
+	PS_DASH:       {18, 6},
+	PS_DOT:        {3, 3},
+	PS_DASHDOT:    {9, 6, 3, 6},
+	PS_DASHDOTDOT: {9, 3, 3, 3, 3, 3},
+	PS_ALTERNATE:  {1, 1},
+}
+
+// createPenStyle returns the full style of a pen made by CreatePen with
+// the given width: wide pens are geometric, solid and round.
+func createPenStyle(style uint32, width float64) uint32 {
+	style &= PS_STYLE_MASK
+	if width > 1 {
+		if style != PS_NULL && style != PS_INSIDEFRAME {
+			style = PS_SOLID
+		}
+		style |= PS_GEOMETRIC | PS_ENDCAP_ROUND | PS_JOIN_ROUND
+	}
+	return style
+}
+
+// newSoftDC returns a DC with the defaults of a new GDI DC: a black
+// one-pixel pen, a white brush, black text on an opaque white background
+// and the ALTERNATE fill mode.
+func newSoftDC(dst *image.RGBA) *softDC {
+	return &softDC{
+		dst:       dst,
+		pen:       softPen{style: PS_SOLID, miterLimit: 10},
+		brush:     softBrush{style: BS_SOLID, color: 0xFFFFFF},
+		textColor: 0,
+		bkColor:   0xFFFFFF,
+		bkMode:    OPAQUE,
+		rop2:      R2_COPYPEN,
+		fillMode:  ALTERNATE,
+	}
+}
+
+// clone returns a copy of dc with its own clip mask.
+func (dc *softDC) clone() softDC {
+	c := *dc
+	if dc.clip != nil {
+		c.clip = append([]byte(nil), dc.clip...)
+	}
+	return c
+}
+
+func colorrefRGB(c COLORREF) (r, g, b uint8) {
+	return uint8(c), uint8(c >> 8), uint8(c >> 16)
+}
+
+func rgbCOLORREF(r, g, b uint8) COLORREF {
+	return COLORREF(r) | COLORREF(g)<<8 | COLORREF(b)<<16
+}
+
+// rop2 combines pen and destination as the binary raster operation code.
+// The bits of code-1 are the results for the four combinations of a pen
+// and a destination bit.
+func rop2(code int, p, d uint8) uint8 {
+	t := code - 1
+	var r uint8
+	if t&1 != 0 {
+		r |= ^p & ^d
+	}
+	if t&2 != 0 {
+		r |= ^p & d
+	}
+	if t&4 != 0 {
+		r |= p & ^d
+	}
+	if t&8 != 0 {
+		r |= p & d
+	}
+	return r
+}
+
+func (dc *softDC) clipped(x, y int) bool {
+	if !(image.Point{x, y}).In(dc.dst.Rect) {
+		return true
+	}
+	if dc.clip == nil {
+		return false
+	}
+	r := dc.dst.Rect
+	return dc.clip[(y-r.Min.Y)*r.Dx()+x-r.Min.X] == 0
+}
+
+// put paints pixel (x, y) with c through the binary raster operation and
+// the clip region.
+func (dc *softDC) put(x, y int, c COLORREF) {
+	if dc.clipped(x, y) {
+		return
+	}
+	i := dc.dst.PixOffset(x, y)
+	px := dc.dst.Pix[i : i+4 : i+4]
+	r, g, b := colorrefRGB(c)
+	if dc.rop2 != R2_COPYPEN {
+		r, g, b = rop2(dc.rop2, r, px[0]), rop2(dc.rop2, g, px[1]), rop2(dc.rop2, b, px[2])
+	}
+	px[0], px[1], px[2], px[3] = r, g, b, 0xFF
+}
+
+// get returns the color of pixel (x, y).
+func (dc *softDC) get(x, y int) COLORREF {
+	i := dc.dst.PixOffset(x, y)
+	return rgbCOLORREF(dc.dst.Pix[i], dc.dst.Pix[i+1], dc.dst.Pix[i+2])
+}
+
+// hatchBit reports whether pixel (x, y) of an 8x8 HS_* pattern is set.
+func hatchBit(hatch uint32, x, y int) bool {
+	switch hatch {
+	case HS_HORIZONTAL:
+		return y == 3
+	case HS_VERTICAL:
+		return x == 3
+	case HS_FDIAGONAL:
+		return x == y
+	case HS_BDIAGONAL:
+		return x == 7-y
+	case HS_CROSS:
+		return x == 3 || y == 3
+	case HS_DIAGCROSS:
+		return x == y || x == 7-y
+	}
+	return false
+}
+
+// brushAt returns the brush color at pixel (x, y), or false where the
+// brush leaves the destination alone.
+func (dc *softDC) brushAt(x, y int) (COLORREF, bool) {
+	b := &dc.brush
+	switch b.style {
+	case BS_SOLID:
+		return b.color, true
+	case BS_HATCHED:
+		if hatchBit(b.hatch, (x-dc.brushOrg.X)&7, (y-dc.brushOrg.Y)&7) {
+			return b.color, true
+		}
+		return dc.bkColor, dc.bkMode == OPAQUE
+	case BS_PATTERN, BS_DIBPATTERN, BS_DIBPATTERNPT:
+		if b.pattern == nil {
+			return 0, false
+		}
+		r := b.pattern.Bounds()
+		if r.Empty() {
+			return 0, false
+		}
+		px := r.Min.X + mod(x-dc.brushOrg.X, r.Dx())
+		py := r.Min.Y + mod(y-dc.brushOrg.Y, r.Dy())
+		if p, ok := b.pattern.(*image.Paletted); ok && b.mono {
+			if p.ColorIndexAt(px, py) == 0 {
+				return dc.textColor, true
+			}
+			return dc.bkColor, true
+		}
+		cr, cg, cb, _ := rawRGBA(b.pattern, px, py)
+		return rgbCOLORREF(cr, cg, cb), true
+	}
+	return 0, false
+}
+
+func mod(a, n int) int {
+	a %= n
+	if a < 0 {
+		a += n
+	}
+	return a
+}
+
+// rawRGBA returns the stored channels of a pixel, without applying its
+// alpha, as GDI copies the bytes of 32 bpp bitmaps.
+func rawRGBA(img image.Image, x, y int) (r, g, b, a uint8) {
+	switch m := img.(type) {
+	case *image.NRGBA:
+		i := m.PixOffset(x, y)
+		return m.Pix[i], m.Pix[i+1], m.Pix[i+2], m.Pix[i+3]
+	case *image.RGBA:
+		i := m.PixOffset(x, y)
+		return m.Pix[i], m.Pix[i+1], m.Pix[i+2], m.Pix[i+3]
+	}
+	c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+	return c.R, c.G, c.B, c.A
+}
+
+// fill paints figs with the brush in the current fill mode.
+func (dc *softDC) fill(figs []rasterFigure) {
+	if dc.brush.style == BS_NULL {
+		return
+	}
+	fillFigures(figs, dc.fillMode == WINDING, dc.dst.Rect, func(y, x0, x1 int) {
+		for x := x0; x < x1; x++ {
+			if c, ok := dc.brushAt(x, y); ok {
+				dc.put(x, y, c)
+			}
+		}
+	})
+}
+
+// fillColor paints figs with c under the nonzero rule.
+func (dc *softDC) fillColor(figs []rasterFigure, c COLORREF) {
+	fillFigures(figs, true, dc.dst.Rect, func(y, x0, x1 int) {
+		for x := x0; x < x1; x++ {
+			dc.put(x, y, c)
+		}
+	})
+}
+
+// thin reports whether the pen draws one-pixel lines.
+func (dc *softDC) thin() bool {
+	return dc.pen.style&PS_TYPE_MASK == PS_COSMETIC || dc.pen.width < 1.5
+}
+
+// stroke outlines figs with the pen.
+func (dc *softDC) stroke(figs []rasterFigure) {
+	p := &dc.pen
+	style := p.style & PS_STYLE_MASK
+	if style == PS_NULL {
+		return
+	}
+
+	// Styled pens: cosmetic ones use fixed pixel patterns, geometric ones
+	// patterns scaled by the width. Wide cosmetic-style pens from
+	// CreatePen are always solid.
+	var dashes []float64
+	geometric := p.style&PS_TYPE_MASK == PS_GEOMETRIC
+	switch {
+	case style == PS_USERSTYLE:
+		dashes = p.userStyle
+	case softPenDashes[style] != nil && geometric:
+		for _, d := range softPenDashes[style] {
+			dashes = append(dashes, d*math.Max(p.width, 1)/3)
+		}
+	case softPenDashes[style] != nil && dc.thin():
+		dashes = softPenDashes[style]
+	}
+	on, off := dashFigures(figs, dashes)
+	dc.strokeColor(on, p.color)
+	if dc.bkMode == OPAQUE && len(off) > 0 && !geometric {
+		dc.strokeColor(off, dc.bkColor)
+	}
+}
+
+func (dc *softDC) strokeColor(figs []rasterFigure, c COLORREF) {
+	if !dc.thin() {
+		pen := rasterPen{
+			width:      dc.pen.width,
+			endCap:     dc.pen.style & PS_ENDCAP_MASK,
+			join:       dc.pen.style & PS_JOIN_MASK,
+			miterLimit: dc.pen.miterLimit,
+		}
+		dc.fillColor(strokeFigures(figs, pen), c)
+		return
+	}
+	plot := func(x, y int) { dc.put(x, y, c) }
+	r := dc.dst.Rect
+	line := func(x0, y0, x1, y1 int) {
+		// Skip segments entirely to one side of the image.
+		if x0 < r.Min.X && x1 < r.Min.X || x0 >= r.Max.X && x1 >= r.Max.X ||
+			y0 < r.Min.Y && y1 < r.Min.Y || y0 >= r.Max.Y && y1 >= r.Max.Y {
+			return
+		}
+		thinLine(x0, y0, x1, y1, plot)
+	}
+	for _, f := range figs {
+		if len(f.pts) == 0 || !f.pts[0].finite() {
+			continue
+		}
+		x0, y0 := f.pts[0].pixel()
+		for _, p := range f.pts[1:] {
+			if !p.finite() {
+				break
+			}
+			x1, y1 := p.pixel()
+			line(x0, y0, x1, y1)
+			x0, y0 = x1, y1
+		}
+		if f.closed {
+			x1, y1 := f.pts[0].pixel()
+			line(x0, y0, x1, y1)
+		}
+	}
+}
+
+// maskFigures returns a clip mask of the pixels inside figs.
+func (dc *softDC) maskFigures(figs []rasterFigure, winding bool) []byte {
+	r := dc.dst.Rect
+	mask := make([]byte, r.Dx()*r.Dy())
+	fillFigures(figs, winding, r, func(y, x0, x1 int) {
+		row := mask[(y-r.Min.Y)*r.Dx():]
+		for x := x0; x < x1; x++ {
+			row[x-r.Min.X] = 1
+		}
+	})
+	return mask
+}
+
+// combineClip combines the clip region with mask by an RGN_* mode. A nil
+// mask stands for the whole image.
+func (dc *softDC) combineClip(mask []byte, mode int) {
+	n := dc.dst.Rect.Dx() * dc.dst.Rect.Dy()
+	full := func(m []byte) []byte {
+		if m != nil {
+			return m
+		}
+		m = make([]byte, n)
+		for i := range m {
+			m[i] = 1
+		}
+		return m
+	}
+	if mode == RGN_COPY {
+		dc.clip = mask
+		return
+	}
+	cur := full(dc.clip)
+	mask = full(mask)
+	out := make([]byte, n)
+	for i := range out {
+		a, b := cur[i], mask[i]
+		switch mode {
+		case RGN_AND:
+			out[i] = a & b
+		case RGN_OR:
+			out[i] = a | b
+		case RGN_XOR:
+			out[i] = a ^ b
+		case RGN_DIFF:
+			out[i] = a &^ b
+		}
+	}
+	dc.clip = out
+}
+
+// offsetClip moves the clip region by (dx, dy) pixels.
+func (dc *softDC) offsetClip(dx, dy int) {
+	if dc.clip == nil {
+		return
+	}
+	r := dc.dst.Rect
+	w, h := r.Dx(), r.Dy()
+	out := make([]byte, len(dc.clip))
+	for y := 0; y < h; y++ {
+		sy := y - dy
+		if sy < 0 || sy >= h {
+			continue
+		}
+		for x := 0; x < w; x++ {
+			if sx := x - dx; sx >= 0 && sx < w {
+				out[y*w+x] = dc.clip[sy*w+sx]
+			}
+		}
+	}
+	dc.clip = out
+}
+
+// blit implements BitBlt, StretchBlt and their relatives with nearest
+// neighbour sampling. toSrc maps raster space to source pixel space and
+// srcRect bounds the source pixels that may be read; box bounds the
+// destination pixels to visit. src is nil for blits without a source.
+func (dc *softDC) blit(box image.Rectangle, toSrc affine, src image.Image, srcRect image.Rectangle, rop uint32) {
+	if src != nil {
+		srcRect = srcRect.Intersect(src.Bounds())
+	}
+	dc.eachPixel(box, toSrc, srcRect, src != nil, func(x, y, sx, sy int) {
+		var s COLORREF
+		if src != nil {
+			r, g, b, _ := rawRGBA(src, sx, sy)
+			s = rgbCOLORREF(r, g, b)
+		}
+		p, _ := dc.brushAt(x, y)
+		r, g, b := colorrefRGB(COLORREF(EvalROP3(rop, uint32(p), uint32(s), uint32(dc.get(x, y)))))
+		dc.putRaw(x, y, r, g, b, 0xFF)
+	})
+}
+
+// alphaBlend implements AlphaBlend: src holds premultiplied colors if
+// srcAlpha is set, and constant scales the whole source.
+func (dc *softDC) alphaBlend(box image.Rectangle, toSrc affine, src image.Image, srcRect image.Rectangle, constant uint8, srcAlpha bool) {
+	srcRect = srcRect.Intersect(src.Bounds())
+	k := uint32(constant)
+	dc.eachPixel(box, toSrc, srcRect, true, func(x, y, sx, sy int) {
+		r, g, b, a := rawRGBA(src, sx, sy)
+		if !srcAlpha {
+			a = 0xFF
+		}
+		sr, sg, sb := uint32(r)*k/255, uint32(g)*k/255, uint32(b)*k/255
+		sa := uint32(a) * k / 255
+		i := dc.dst.PixOffset(x, y)
+		px := dc.dst.Pix[i : i+4 : i+4]
+		blend := func(s uint32, d uint8) uint8 {
+			v := s + uint32(d)*(255-sa)/255
+			if v > 255 {
+				v = 255
+			}
+			return uint8(v)
+		}
+		dc.putRaw(x, y, blend(sr, px[0]), blend(sg, px[1]), blend(sb, px[2]), blend(sa, px[3]))
+	})
+}
+
+// transparentBlt implements TransparentBlt: source pixels of color key are
+// skipped and the rest copied.
+func (dc *softDC) transparentBlt(box image.Rectangle, toSrc affine, src image.Image, srcRect image.Rectangle, key COLORREF) {
+	srcRect = srcRect.Intersect(src.Bounds())
+	dc.eachPixel(box, toSrc, srcRect, true, func(x, y, sx, sy int) {
+		r, g, b, _ := rawRGBA(src, sx, sy)
+		if rgbCOLORREF(r, g, b) != key {
+			dc.putRaw(x, y, r, g, b, 0xFF)
+		}
+	})
+}
+
+// eachPixel calls fn for the unclipped destination pixels in box whose
+// centers map into srcRect, or for all of them if checkSrc is false.
+func (dc *softDC) eachPixel(box image.Rectangle, toSrc affine, srcRect image.Rectangle, checkSrc bool, fn func(x, y, sx, sy int)) {
+	box = box.Intersect(dc.dst.Rect)
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		for x := box.Min.X; x < box.Max.X; x++ {
+			if dc.clipped(x, y) {
+				continue
+			}
+			s := toSrc.apply(float64(x)+0.5, float64(y)+0.5)
+			if !s.finite() {
+				continue
+			}
+			sx, sy := s.pixel()
+			if checkSrc && !(image.Point{sx, sy}).In(srcRect) {
+				continue
+			}
+			fn(x, y, sx, sy)
+		}
+	}
+}
+
+// putRaw stores a pixel unchanged; the caller has checked the clip.
+func (dc *softDC) putRaw(x, y int, r, g, b, a uint8) {
+	i := dc.dst.PixOffset(x, y)
+	px := dc.dst.Pix[i : i+4 : i+4]
+	px[0], px[1], px[2], px[3] = r, g, b, a
+}
+
+// boundsOf returns the pixels touched by the bounding box of pts.
+func boundsOf(pts ...rasterPt) image.Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range pts {
+		minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	clamp := func(v float64) int {
+		return int(math.Max(-1<<30, math.Min(1<<30, v)))
+	}
+	if math.IsNaN(minX + minY + maxX + maxY) {
+		return image.Rectangle{}
+	}
+	return image.Rect(clamp(math.Floor(minX)), clamp(math.Floor(minY)), clamp(math.Ceil(maxX)), clamp(math.Ceil(maxY)))
+}
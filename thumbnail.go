@@ -0,0 +1,162 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"encoding/binary"
+)
+
+// dwmThumbnailPropertiesSize is the size of DWM_THUMBNAIL_PROPERTIES,
+// which dwmapi.h packs to 1 byte, unlike the Go struct.
+const dwmThumbnailPropertiesSize = 45
+
+// marshal writes p in the packed layout to b, which must be
+// dwmThumbnailPropertiesSize bytes long.
+func (p *DWM_THUMBNAIL_PROPERTIES) marshal(b []byte) {
+	le := binary.LittleEndian
+	le.PutUint32(b, p.dwFlags)
+	for i, v := range []int32{
+		p.rcDestination.Left, p.rcDestination.Top, p.rcDestination.Right, p.rcDestination.Bottom,
+		p.rcSource.Left, p.rcSource.Top, p.rcSource.Right, p.rcSource.Bottom,
+	} {
+		le.PutUint32(b[4+4*i:], uint32(v))
+	}
+	b[36] = p.opacity
+	le.PutUint32(b[37:], uint32(p.fVisible))
+	le.PutUint32(b[41:], uint32(p.fSourceClientAreaOnly))
+}
+
+// ThumbnailProperties holds the properties of a DWM thumbnail and tracks
+// which of them changed since they were last applied, so that the
+// DWM_TNP_* flags follow from the setters:
+//
+//	t.SetDestination(r).SetOpacity(255).SetVisible(true)
+//	err := t.Update()
+type ThumbnailProperties struct {
+	props   DWM_THUMBNAIL_PROPERTIES
+	dirty   uint32 // DWM_TNP_* flags of the fields to send
+	applied uint32 // DWM_TNP_* flags of the fields sent at least once
+}
+
+func (p *ThumbnailProperties) mark(flag uint32, changed bool) *ThumbnailProperties {
+	if changed || p.applied&flag == 0 {
+		p.dirty |= flag
+	}
+	return p
+}
+
+// SetDestination sets where the thumbnail is drawn, in client
+// coordinates of the destination window.
+func (p *ThumbnailProperties) SetDestination(r RECT) *ThumbnailProperties {
+	changed := p.props.rcDestination != r
+	p.props.rcDestination = r
+	return p.mark(DWM_TNP_RECTDESTINATION, changed)
+}
+
+// SetSource sets the part of the source window to show.
+func (p *ThumbnailProperties) SetSource(r RECT) *ThumbnailProperties {
+	changed := p.props.rcSource != r
+	p.props.rcSource = r
+	return p.mark(DWM_TNP_RECTSOURCE, changed)
+}
+
+// SetOpacity sets the opacity, from 0 for transparent to 255.
+func (p *ThumbnailProperties) SetOpacity(opacity byte) *ThumbnailProperties {
+	changed := p.props.opacity != opacity
+	p.props.opacity = opacity
+	return p.mark(DWM_TNP_OPACITY, changed)
+}
+
+func (p *ThumbnailProperties) SetVisible(visible bool) *ThumbnailProperties {
+	v := BOOL(FALSE)
+	if visible {
+		v = TRUE
+	}
+	changed := p.props.fVisible != v
+	p.props.fVisible = v
+	return p.mark(DWM_TNP_VISIBLE, changed)
+}
+
+// SetSourceClientAreaOnly shows only the client area of the source
+// window.
+func (p *ThumbnailProperties) SetSourceClientAreaOnly(clientOnly bool) *ThumbnailProperties {
+	v := BOOL(FALSE)
+	if clientOnly {
+		v = TRUE
+	}
+	changed := p.props.fSourceClientAreaOnly != v
+	p.props.fSourceClientAreaOnly = v
+	return p.mark(DWM_TNP_SOURCECLIENTAREAONLY, changed)
+}
+
+func (p *ThumbnailProperties) Destination() RECT {
+	return p.props.rcDestination
+}
+
+func (p *ThumbnailProperties) Source() RECT {
+	return p.props.rcSource
+}
+
+func (p *ThumbnailProperties) Opacity() byte {
+	return p.props.opacity
+}
+
+func (p *ThumbnailProperties) Visible() bool {
+	return p.props.fVisible != 0
+}
+
+func (p *ThumbnailProperties) SourceClientAreaOnly() bool {
+	return p.props.fSourceClientAreaOnly != 0
+}
+
+// Dirty returns the DWM_TNP_* flags of the properties not yet applied.
+func (p *ThumbnailProperties) Dirty() uint32 {
+	return p.dirty
+}
+
+// pending returns the properties to pass to DwmUpdateThumbnailProperties,
+// with dwFlags set to the changed fields.
+func (p *ThumbnailProperties) pending() DWM_THUMBNAIL_PROPERTIES {
+	props := p.props
+	props.dwFlags = p.dirty
+	return props
+}
+
+// markApplied marks the pending properties as sent.
+func (p *ThumbnailProperties) markApplied() {
+	p.applied |= p.dirty
+	p.dirty = 0
+}
+
+// FitThumbnailRect returns the largest rectangle with the aspect ratio of
+// source that fits in bounds, centered in it. Unless upscale is true, a
+// source smaller than bounds keeps its size. An empty source or bounds
+// gives an empty rectangle at the top left of bounds.
+func FitThumbnailRect(source SIZE, bounds RECT, upscale bool) RECT {
+	bw, bh := int64(bounds.Right-bounds.Left), int64(bounds.Bottom-bounds.Top)
+	sw, sh := int64(source.CX), int64(source.CY)
+	if sw <= 0 || sh <= 0 || bw <= 0 || bh <= 0 {
+		return RECT{bounds.Left, bounds.Top, bounds.Left, bounds.Top}
+	}
+	var w, h int64
+	switch {
+	case !upscale && sw <= bw && sh <= bh:
+		w, h = sw, sh
+	case sw*bh <= sh*bw:
+		// Limited by the height.
+		w, h = (sw*bh+sh/2)/sh, bh
+	default:
+		w, h = bw, (sh*bw+sw/2)/sw
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	left := int64(bounds.Left) + (bw-w)/2
+	top := int64(bounds.Top) + (bh-h)/2
+	return RECT{int32(left), int32(top), int32(left + w), int32(top + h)}
+}
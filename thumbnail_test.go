@@ -0,0 +1,129 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+func TestThumbnailPropertiesDirty(t *testing.T) {
+	var p ThumbnailProperties
+	if p.Dirty() != 0 {
+		t.Fatalf("new properties are dirty: %#x", p.Dirty())
+	}
+	if q := p.SetDestination(RECT{1, 2, 3, 4}).SetOpacity(255); q != &p {
+		t.Error("setters do not return their receiver")
+	}
+	if got, want := p.Dirty(), uint32(DWM_TNP_RECTDESTINATION|DWM_TNP_OPACITY); got != want {
+		t.Errorf("Dirty() = %#x, want %#x", got, want)
+	}
+
+	// A property is sent the first time it is set, even to its zero value.
+	p.SetVisible(false).SetSourceClientAreaOnly(false).SetSource(RECT{})
+	want := uint32(DWM_TNP_RECTDESTINATION | DWM_TNP_OPACITY | DWM_TNP_VISIBLE |
+		DWM_TNP_SOURCECLIENTAREAONLY | DWM_TNP_RECTSOURCE)
+	if got := p.Dirty(); got != want {
+		t.Errorf("Dirty() = %#x, want %#x", got, want)
+	}
+	pending := p.pending()
+	if pending.dwFlags != want || pending.rcDestination != (RECT{1, 2, 3, 4}) || pending.opacity != 255 {
+		t.Errorf("pending() = %+v", pending)
+	}
+
+	p.markApplied()
+	if p.Dirty() != 0 {
+		t.Errorf("Dirty() after markApplied = %#x", p.Dirty())
+	}
+	// Setting a property to the value already applied changes nothing.
+	p.SetDestination(RECT{1, 2, 3, 4}).SetOpacity(255).SetVisible(false).SetSource(RECT{})
+	if p.Dirty() != 0 {
+		t.Errorf("unchanged properties are dirty: %#x", p.Dirty())
+	}
+	p.SetVisible(true).SetOpacity(128)
+	want = DWM_TNP_VISIBLE | DWM_TNP_OPACITY
+	if got := p.Dirty(); got != want {
+		t.Errorf("Dirty() = %#x, want %#x", got, want)
+	}
+	if pending := p.pending(); pending.dwFlags != want || pending.fVisible != TRUE || pending.opacity != 128 {
+		t.Errorf("pending() = %+v", pending)
+	}
+	// Changing a property back before it is applied keeps it dirty.
+	p.SetOpacity(255)
+	if p.Dirty()&DWM_TNP_OPACITY == 0 {
+		t.Error("opacity changed back is not dirty")
+	}
+	p.markApplied()
+
+	if p.Destination() != (RECT{1, 2, 3, 4}) || p.Source() != (RECT{}) || p.Opacity() != 255 ||
+		!p.Visible() || p.SourceClientAreaOnly() {
+		t.Errorf("getters return %v, %v, %d, %v, %v",
+			p.Destination(), p.Source(), p.Opacity(), p.Visible(), p.SourceClientAreaOnly())
+	}
+}
+
+// DWM_THUMBNAIL_PROPERTIES is packed to 1 byte: the BOOLs after the
+// opacity byte are not aligned.
+func TestThumbnailPropertiesMarshal(t *testing.T) {
+	props := DWM_THUMBNAIL_PROPERTIES{
+		dwFlags:               0x1F,
+		rcDestination:         RECT{1, 2, 0x300, -1},
+		rcSource:              RECT{-2, 0, 5, 6},
+		opacity:               0x80,
+		fVisible:              TRUE,
+		fSourceClientAreaOnly: FALSE,
+	}
+	want := "1f000000" +
+		"01000000" + "02000000" + "00030000" + "ffffffff" +
+		"feffffff" + "00000000" + "05000000" + "06000000" +
+		"80" + "01000000" + "00000000"
+	b := make([]byte, dwmThumbnailPropertiesSize)
+	props.marshal(b)
+	if got := hex.EncodeToString(b); got != want {
+		t.Errorf("marshal:\n got %s\nwant %s", got, want)
+	}
+	if dwmThumbnailPropertiesSize != 45 {
+		t.Errorf("dwmThumbnailPropertiesSize = %d, want 45", dwmThumbnailPropertiesSize)
+	}
+}
+
+func TestFitThumbnailRect(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  SIZE
+		bounds  RECT
+		upscale bool
+		want    RECT
+	}{
+		{"letterbox", SIZE{1600, 900}, RECT{0, 0, 400, 400}, false, RECT{0, 87, 400, 312}},
+		{"pillarbox", SIZE{900, 1600}, RECT{0, 0, 400, 400}, false, RECT{87, 0, 312, 400}},
+		{"same aspect", SIZE{800, 600}, RECT{10, 10, 410, 310}, false, RECT{10, 10, 410, 310}},
+		{"no upscale", SIZE{100, 50}, RECT{10, 20, 410, 420}, false, RECT{160, 195, 260, 245}},
+		{"upscale", SIZE{100, 50}, RECT{10, 20, 410, 420}, true, RECT{10, 120, 410, 320}},
+		{"too wide without upscale", SIZE{800, 100}, RECT{0, 0, 400, 400}, false, RECT{0, 175, 400, 225}},
+		{"too tall without upscale", SIZE{100, 800}, RECT{0, 0, 400, 400}, false, RECT{175, 0, 225, 400}},
+		{"negative bounds", SIZE{2, 1}, RECT{-100, -100, -60, -60}, true, RECT{-100, -90, -60, -70}},
+
+		// Sizes are rounded to the nearest pixel, halves up.
+		{"round down", SIZE{3, 2}, RECT{0, 0, 5, 5}, true, RECT{0, 1, 5, 4}},
+		{"round half up", SIZE{2, 1}, RECT{0, 0, 5, 5}, true, RECT{0, 1, 5, 4}},
+		{"round up", SIZE{3, 1}, RECT{0, 0, 5, 5}, true, RECT{0, 1, 5, 3}},
+		{"at least a pixel", SIZE{1000, 1}, RECT{0, 0, 10, 10}, false, RECT{0, 4, 10, 5}},
+		{"huge source", SIZE{math.MaxInt32, math.MaxInt32 / 2}, RECT{0, 0, 1000, 1000}, false, RECT{0, 250, 1000, 750}},
+
+		// Empty sources and bounds.
+		{"empty source", SIZE{0, 10}, RECT{5, 6, 100, 100}, true, RECT{5, 6, 5, 6}},
+		{"negative source", SIZE{10, -10}, RECT{5, 6, 100, 100}, true, RECT{5, 6, 5, 6}},
+		{"empty bounds", SIZE{10, 10}, RECT{5, 6, 5, 100}, true, RECT{5, 6, 5, 6}},
+		{"inverted bounds", SIZE{10, 10}, RECT{100, 100, 5, 6}, true, RECT{100, 100, 100, 100}},
+	}
+	for _, tt := range tests {
+		if got := FitThumbnailRect(tt.source, tt.bounds, tt.upscale); got != tt.want {
+			t.Errorf("%s: FitThumbnailRect(%v, %v, %v) = %v, want %v",
+				tt.name, tt.source, tt.bounds, tt.upscale, got, tt.want)
+		}
+	}
+}
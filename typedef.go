@@ -417,6 +417,102 @@ type BITMAPINFO struct {
 	BmiColors *RGBQUAD
 }
 
+// BITMAPFILEHEADER is 14 bytes on disk; encoding/binary reads and writes it
+// without the padding Go adds after BfType.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd183374.aspx
+type BITMAPFILEHEADER struct {
+	BfType      uint16
+	BfSize      uint32
+	BfReserved1 uint16
+	BfReserved2 uint16
+	BfOffBits   uint32
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd183372.aspx
+type BITMAPCOREHEADER struct {
+	BcSize     uint32
+	BcWidth    uint16
+	BcHeight   uint16
+	BcPlanes   uint16
+	BcBitCount uint16
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd162939.aspx
+type RGBTRIPLE struct {
+	RgbtBlue  byte
+	RgbtGreen byte
+	RgbtRed   byte
+}
+
+// CIEXYZ coordinates are FXPT2DOT30 fixed-point values.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd371828.aspx
+type CIEXYZ struct {
+	CiexyzX int32
+	CiexyzY int32
+	CiexyzZ int32
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd371833.aspx
+type CIEXYZTRIPLE struct {
+	CiexyzRed   CIEXYZ
+	CiexyzGreen CIEXYZ
+	CiexyzBlue  CIEXYZ
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd183380.aspx
+type BITMAPV4HEADER struct {
+	BV4Size          uint32
+	BV4Width         int32
+	BV4Height        int32
+	BV4Planes        uint16
+	BV4BitCount      uint16
+	BV4V4Compression uint32
+	BV4SizeImage     uint32
+	BV4XPelsPerMeter int32
+	BV4YPelsPerMeter int32
+	BV4ClrUsed       uint32
+	BV4ClrImportant  uint32
+	BV4RedMask       uint32
+	BV4GreenMask     uint32
+	BV4BlueMask      uint32
+	BV4AlphaMask     uint32
+	BV4CSType        uint32
+	BV4Endpoints     CIEXYZTRIPLE
+	BV4GammaRed      uint32
+	BV4GammaGreen    uint32
+	BV4GammaBlue     uint32
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd183381.aspx
+type BITMAPV5HEADER struct {
+	BV5Size          uint32
+	BV5Width         int32
+	BV5Height        int32
+	BV5Planes        uint16
+	BV5BitCount      uint16
+	BV5Compression   uint32
+	BV5SizeImage     uint32
+	BV5XPelsPerMeter int32
+	BV5YPelsPerMeter int32
+	BV5ClrUsed       uint32
+	BV5ClrImportant  uint32
+	BV5RedMask       uint32
+	BV5GreenMask     uint32
+	BV5BlueMask      uint32
+	BV5AlphaMask     uint32
+	BV5CSType        uint32
+	BV5Endpoints     CIEXYZTRIPLE
+	BV5GammaRed      uint32
+	BV5GammaGreen    uint32
+	BV5GammaBlue     uint32
+	BV5Intent        uint32
+	BV5ProfileData   uint32
+	BV5ProfileSize   uint32
+	BV5Reserved      uint32
+}
+
 // http://msdn.microsoft.com/en-us/library/windows/desktop/dd183371.aspx
 type BITMAP struct {
 	BmType       int32
@@ -437,6 +533,51 @@ type DIBSECTION struct {
 	DsOffset    uint32
 }
 
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd162606.aspx
+type EMR struct {
+	IType uint32
+	NSize uint32
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd162808.aspx
+type POINTS struct {
+	X, Y int16
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd145228.aspx
+type XFORM struct {
+	EM11 float32
+	EM12 float32
+	EM21 float32
+	EM22 float32
+	EDx  float32
+	EDy  float32
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd145041.aspx
+type LOGPEN struct {
+	LopnStyle uint32
+	LopnWidth POINT
+	LopnColor COLORREF
+}
+
+// LOGBRUSH32 is the fixed-size LOGBRUSH stored in metafiles.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd145035.aspx
+type LOGBRUSH32 struct {
+	LbStyle uint32
+	LbColor COLORREF
+	LbHatch uint32
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd162769.aspx
+type PALETTEENTRY struct {
+	PeRed   byte
+	PeGreen byte
+	PeBlue  byte
+	PeFlags byte
+}
+
 // http://msdn.microsoft.com/en-us/library/windows/desktop/dd162607.aspx
 type ENHMETAHEADER struct {
 	IType          uint32
@@ -460,6 +601,23 @@ type ENHMETAHEADER struct {
 	SzlMicrometers SIZE
 }
 
+// http://msdn.microsoft.com/en-us/library/windows/desktop/ms646997.aspx
+type VS_FIXEDFILEINFO struct {
+	DwSignature        uint32
+	DwStrucVersion     uint32
+	DwFileVersionMS    uint32
+	DwFileVersionLS    uint32
+	DwProductVersionMS uint32
+	DwProductVersionLS uint32
+	DwFileFlagsMask    uint32
+	DwFileFlags        uint32
+	DwFileOS           uint32
+	DwFileType         uint32
+	DwFileSubtype      uint32
+	DwFileDateMS       uint32
+	DwFileDateLS       uint32
+}
+
 // http://msdn.microsoft.com/en-us/library/windows/desktop/dd145106.aspx
 type SIZE struct {
 	CX, CY int32
@@ -489,6 +647,35 @@ type TEXTMETRIC struct {
 	TmCharSet          byte
 }
 
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd162627.aspx
+type ENUMLOGFONTEX struct {
+	LogFont  LOGFONT
+	FullName [LF_FULLFACESIZE]uint16
+	Style    [LF_FACESIZE]uint16
+	Script   [LF_FACESIZE]uint16
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd162741.aspx
+type NEWTEXTMETRIC struct {
+	TEXTMETRIC
+	NtmFlags      uint32
+	NtmSizeEM     uint32
+	NtmCellHeight uint32
+	NtmAvgWidth   uint32
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd162742.aspx
+type NEWTEXTMETRICEX struct {
+	NtmTm      NEWTEXTMETRIC
+	NtmFontSig FONTSIGNATURE
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/dd144918.aspx
+type FONTSIGNATURE struct {
+	FsUsb [4]uint32
+	FsCsb [2]uint32
+}
+
 // http://msdn.microsoft.com/en-us/library/windows/desktop/dd183574.aspx
 type DOCINFO struct {
 	CbSize       int32
@@ -615,6 +802,40 @@ type GdiplusStartupOutput struct {
 	NotificationUnhook uintptr
 }
 
+// https://docs.microsoft.com/en-us/windows/win32/api/gdiplustypes/nl-gdiplustypes-pointf
+type PointF struct {
+	X, Y float32
+}
+
+// https://docs.microsoft.com/en-us/windows/win32/api/gdiplustypes/nl-gdiplustypes-rectf
+type RectF struct {
+	X, Y, Width, Height float32
+}
+
+// https://docs.microsoft.com/en-us/windows/win32/api/gdiplusimaging/nl-gdiplusimaging-encoderparameter
+type EncoderParameter struct {
+	Guid           GUID
+	NumberOfValues uint32
+	Type           uint32
+	Value          uintptr
+}
+
+// https://docs.microsoft.com/en-us/windows/win32/api/gdiplusimaging/nl-gdiplusimaging-encoderparameters
+// Count parameters follow in memory; Parameter only declares the first.
+type EncoderParameters struct {
+	Count     uint32
+	Parameter [1]EncoderParameter
+}
+
+// http://msdn.microsoft.com/en-us/library/windows/desktop/ms648052.aspx
+type ICONINFO struct {
+	FIcon    BOOL
+	XHotspot uint32
+	YHotspot uint32
+	HbmMask  HBITMAP
+	HbmColor HBITMAP
+}
+
 // http://msdn.microsoft.com/en-us/library/windows/desktop/dd162768.aspx
 type PAINTSTRUCT struct {
 	Hdc         HDC
@@ -656,6 +877,19 @@ type SERVICE_STATUS struct {
 	DwWaitHint                uint32
 }
 
+// http://msdn.microsoft.com/en-us/library/windows/desktop/aa374149.aspx
+type ACTCTX struct {
+	CbSize                 uint32
+	DwFlags                uint32
+	LpSource               *uint16
+	WProcessorArchitecture uint16
+	WLangId                uint16
+	LpAssemblyDirectory    *uint16
+	LpResourceName         *uint16
+	LpApplicationName      *uint16
+	HModule                HMODULE
+}
+
 // http://msdn.microsoft.com/en-us/library/windows/desktop/ms684225.aspx
 type MODULEENTRY32 struct {
 	Size         uint32
@@ -2,6 +2,8 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+//go:build windows
+
 package w32
 
 import (
@@ -9,6 +11,7 @@ import (
 	"C"
 	"errors"
 	"fmt"
+	"image"
 	"syscall"
 	"unsafe"
 )
@@ -103,6 +106,8 @@ var (
 	procSetCursorPos                  = moduser32.NewProc("SetCursorPos")
 	procSetCursor                     = moduser32.NewProc("SetCursor")
 	procCreateIcon                    = moduser32.NewProc("CreateIcon")
+	procCreateIconIndirect            = moduser32.NewProc("CreateIconIndirect")
+	procGetIconInfo                   = moduser32.NewProc("GetIconInfo")
 	procDestroyIcon                   = moduser32.NewProc("DestroyIcon")
 	procMonitorFromPoint              = moduser32.NewProc("MonitorFromPoint")
 	procMonitorFromRect               = moduser32.NewProc("MonitorFromRect")
@@ -113,6 +118,8 @@ var (
 	procChangeDisplaySettingsEx       = moduser32.NewProc("ChangeDisplaySettingsExW")
 	procSendInput                     = moduser32.NewProc("SendInput")
 	procFindWindow                    = moduser32.NewProc("FindWindowW")
+	procGetSysColor                   = moduser32.NewProc("GetSysColor")
+	procGetSysColorBrush              = moduser32.NewProc("GetSysColorBrush")
 )
 
 // Windows
@@ -153,7 +160,6 @@ var (
 // TODO: GetParent
 // TODO: GetProcessDefaultLayout
 // TODO: GetShellWindow
-// TODO: GetSysColor
 // TODO: GetTitleBarInfo
 // TODO: GetTopWindow
 // TODO: GetWindow
@@ -697,13 +703,11 @@ func WaitMessage() bool {
 // TODO: CopyIcon
 // TODO: CreateIconFromResource
 // TODO: CreateIconFromResourceEx
-// TODO: CreateIconIndirect
 // TODO: DrawIconEx
 // TODO: DuplicateIcon
 // TODO: ExtractAssociatedIcon
 // TODO: ExtractIcon
 // TODO: ExtractIconEx
-// TODO: GetIconInfo
 // TODO: GetIconInfoEx
 // TODO: LookupIconIdFromDirectory
 // TODO: LookupIconIdFromDirectoryEx
@@ -722,16 +726,67 @@ func CreateIcon(instance HINSTANCE, nWidth, nHeight int, cPlanes, cBitsPerPixel
 		uintptr(unsafe.Pointer(ANDbits)),
 		uintptr(unsafe.Pointer(XORbits)),
 	)
+	trackHandle(HandleKindIcon, "CreateIcon", ret)
+	return HICON(ret)
+}
+
+// CreateIconIndirect creates an icon or cursor from an ICONINFO structure.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/ms648062
+func CreateIconIndirect(iconInfo *ICONINFO) HICON {
+	ret, _, _ := procCreateIconIndirect.Call(
+		uintptr(unsafe.Pointer(iconInfo)),
+	)
+	trackHandle(HandleKindIcon, "CreateIconIndirect", ret)
 	return HICON(ret)
 }
 
+// CreateIconFromImage creates an icon from img through CreateIcon, using the
+// bit planes computed by IconBitPlanes. The icon must be destroyed with
+// DestroyIcon. It returns 0 if img is empty.
+func CreateIconFromImage(img image.Image) HICON {
+	b := img.Bounds()
+	if b.Empty() {
+		return 0
+	}
+	andBits, xorBits := IconBitPlanes(img)
+	return CreateIcon(0, b.Dx(), b.Dy(), 1, 32, &andBits[0], &xorBits[0])
+}
+
+// CreateCursorFromImage creates a cursor with the given hotspot from img
+// through CreateIconIndirect. The cursor must be destroyed with DestroyIcon.
+// It returns 0 if img is empty.
+func CreateCursorFromImage(img image.Image, hotspotX, hotspotY int) HCURSOR {
+	b := img.Bounds()
+	if b.Empty() {
+		return 0
+	}
+	andBits, xorBits := IconBitPlanes(img)
+	mask := CreateBitmap(b.Dx(), b.Dy(), 1, 1, &andBits[0])
+	defer DeleteObject(HGDIOBJ(mask))
+	color := CreateBitmap(b.Dx(), b.Dy(), 1, 32, &xorBits[0])
+	defer DeleteObject(HGDIOBJ(color))
+
+	return HCURSOR(CreateIconIndirect(&ICONINFO{
+		FIcon:    FALSE,
+		XHotspot: uint32(hotspotX),
+		YHotspot: uint32(hotspotY),
+		HbmMask:  mask,
+		HbmColor: color,
+	}))
+}
+
 // DestroyIcon destroys an icon and frees any memory the icon occupied.
 //
 // http://msdn.microsoft.com/en-us/library/windows/desktop/ms648063
 func DestroyIcon(icon HICON) bool {
+	r := untrackHandle("DestroyIcon", uintptr(icon))
 	ret, _, _ := procDestroyIcon.Call(
 		uintptr(icon),
 	)
+	if ret == 0 {
+		restoreHandle(r)
+	}
 	return ret != 0
 }
 
@@ -748,6 +803,18 @@ func DrawIcon(hDC HDC, x, y int, hIcon HICON) bool {
 	return ret != 0
 }
 
+// GetIconInfo retrieves information about the specified icon or cursor. The caller must delete
+// the HbmMask and HbmColor bitmaps it returns.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/ms648070
+func GetIconInfo(icon HICON, iconInfo *ICONINFO) bool {
+	ret, _, _ := procGetIconInfo.Call(
+		uintptr(icon),
+		uintptr(unsafe.Pointer(iconInfo)),
+	)
+	return ret != 0
+}
+
 // LoadIcon loads the specified icon resource from the executable (.exe) file associated with an
 // application instance.
 //
@@ -1277,6 +1344,8 @@ func GetDC(hwnd HWND) HDC {
 	ret, _, _ := procGetDC.Call(
 		uintptr(hwnd))
 
+	trackHandle(HandleKindWindowDC, "GetDC", ret)
+
 	return HDC(ret)
 }
 
@@ -1286,10 +1355,15 @@ func GetDC(hwnd HWND) HDC {
 //
 // http://msdn.microsoft.com/en-us/library/windows/desktop/dd162920
 func ReleaseDC(hwnd HWND, hDC HDC) bool {
+	r := untrackHandle("ReleaseDC", uintptr(hDC))
 	ret, _, _ := procReleaseDC.Call(
 		uintptr(hwnd),
 		uintptr(hDC))
 
+	if ret == 0 {
+		restoreHandle(r)
+	}
+
 	return ret != 0
 }
 
@@ -1431,3 +1505,21 @@ func ToAscii(uVirtKey, uScanCode uint, lpKeyState *byte, lpChar *uint16, uFlags
 		uintptr(uFlags))
 	return int(ret)
 }
+
+// GetSysColor returns the current color of a display element. See
+// DefaultSysColor for the values of a default Windows installation.
+func GetSysColor(nIndex int) COLORREF {
+	ret, _, _ := procGetSysColor.Call(
+		uintptr(nIndex))
+
+	return COLORREF(ret)
+}
+
+// GetSysColorBrush returns a cached brush for a display element. It must
+// not be deleted.
+func GetSysColorBrush(nIndex int) HBRUSH {
+	ret, _, _ := procGetSysColorBrush.Call(
+		uintptr(nIndex))
+
+	return HBRUSH(ret)
+}
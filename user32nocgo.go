@@ -0,0 +1,93 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package w32
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// user32.go needs cgo. The user32 functions that other files call are
+// loaded again here, so that those files also build with cgo disabled.
+var (
+	moduser32NoCgo = syscall.NewLazyDLL("user32.dll")
+
+	procCreateWindowExNoCgo = moduser32NoCgo.NewProc("CreateWindowExW")
+	procDestroyWindowNoCgo  = moduser32NoCgo.NewProc("DestroyWindow")
+	procFillRectNoCgo       = moduser32NoCgo.NewProc("FillRect")
+	procGetDCNoCgo          = moduser32NoCgo.NewProc("GetDC")
+	procGetWindowRectNoCgo  = moduser32NoCgo.NewProc("GetWindowRect")
+	procReleaseDCNoCgo      = moduser32NoCgo.NewProc("ReleaseDC")
+	procSetWindowPosNoCgo   = moduser32NoCgo.NewProc("SetWindowPos")
+)
+
+func createWindowEx(exStyle uint, className, windowName *uint16,
+	style uint, x, y, width, height int, parent HWND, menu HMENU,
+	instance HINSTANCE, param unsafe.Pointer) HWND {
+	ret, _, _ := procCreateWindowExNoCgo.Call(
+		uintptr(exStyle),
+		uintptr(unsafe.Pointer(className)),
+		uintptr(unsafe.Pointer(windowName)),
+		uintptr(style),
+		uintptr(x),
+		uintptr(y),
+		uintptr(width),
+		uintptr(height),
+		uintptr(parent),
+		uintptr(menu),
+		uintptr(instance),
+		uintptr(param))
+	return HWND(ret)
+}
+
+func destroyWindow(hwnd HWND) bool {
+	ret, _, _ := procDestroyWindowNoCgo.Call(
+		uintptr(hwnd))
+	return ret != 0
+}
+
+func fillRect(hDC HDC, lprc *RECT, hbr HBRUSH) bool {
+	ret, _, _ := procFillRectNoCgo.Call(
+		uintptr(hDC),
+		uintptr(unsafe.Pointer(lprc)),
+		uintptr(hbr))
+	return ret != 0
+}
+
+// getDC and releaseDC are not seen by the handle tracker.
+func getDC(hwnd HWND) HDC {
+	ret, _, _ := procGetDCNoCgo.Call(
+		uintptr(hwnd))
+	return HDC(ret)
+}
+
+func releaseDC(hwnd HWND, hDC HDC) bool {
+	ret, _, _ := procReleaseDCNoCgo.Call(
+		uintptr(hwnd),
+		uintptr(hDC))
+	return ret != 0
+}
+
+func getWindowRect(hwnd HWND) (RECT, bool) {
+	var rect RECT
+	ret, _, _ := procGetWindowRectNoCgo.Call(
+		uintptr(hwnd),
+		uintptr(unsafe.Pointer(&rect)))
+	return rect, ret != 0
+}
+
+func setWindowPos(hwnd, hWndInsertAfter HWND, x, y, cx, cy int, uFlags uint) bool {
+	ret, _, _ := procSetWindowPosNoCgo.Call(
+		uintptr(hwnd),
+		uintptr(hWndInsertAfter),
+		uintptr(x),
+		uintptr(y),
+		uintptr(cx),
+		uintptr(cy),
+		uintptr(uFlags))
+	return ret != 0
+}
@@ -11,3 +11,32 @@ var (
 	IID_IConnectionPointContainer = &GUID{0xB196B284, 0xBAB4, 0x101A, [8]byte{0xB6, 0x9C, 0x00, 0xAA, 0x00, 0x34, 0x1D, 0x07}}
 	IID_IConnectionPoint          = &GUID{0xB196B286, 0xBAB4, 0x101A, [8]byte{0xB6, 0x9C, 0x00, 0xAA, 0x00, 0x34, 0x1D, 0x07}}
 )
+
+// GDI+ image formats, as in ImageCodecInfo.FormatID
+var (
+	ImageFormatUndefined = &GUID{0xB96B3CA9, 0x0728, 0x11D3, [8]byte{0x9D, 0x7B, 0x00, 0x00, 0xF8, 0x1E, 0xF3, 0x2E}}
+	ImageFormatMemoryBMP = &GUID{0xB96B3CAA, 0x0728, 0x11D3, [8]byte{0x9D, 0x7B, 0x00, 0x00, 0xF8, 0x1E, 0xF3, 0x2E}}
+	ImageFormatBMP       = &GUID{0xB96B3CAB, 0x0728, 0x11D3, [8]byte{0x9D, 0x7B, 0x00, 0x00, 0xF8, 0x1E, 0xF3, 0x2E}}
+	ImageFormatEMF       = &GUID{0xB96B3CAC, 0x0728, 0x11D3, [8]byte{0x9D, 0x7B, 0x00, 0x00, 0xF8, 0x1E, 0xF3, 0x2E}}
+	ImageFormatWMF       = &GUID{0xB96B3CAD, 0x0728, 0x11D3, [8]byte{0x9D, 0x7B, 0x00, 0x00, 0xF8, 0x1E, 0xF3, 0x2E}}
+	ImageFormatJPEG      = &GUID{0xB96B3CAE, 0x0728, 0x11D3, [8]byte{0x9D, 0x7B, 0x00, 0x00, 0xF8, 0x1E, 0xF3, 0x2E}}
+	ImageFormatPNG       = &GUID{0xB96B3CAF, 0x0728, 0x11D3, [8]byte{0x9D, 0x7B, 0x00, 0x00, 0xF8, 0x1E, 0xF3, 0x2E}}
+	ImageFormatGIF       = &GUID{0xB96B3CB0, 0x0728, 0x11D3, [8]byte{0x9D, 0x7B, 0x00, 0x00, 0xF8, 0x1E, 0xF3, 0x2E}}
+	ImageFormatTIFF      = &GUID{0xB96B3CB1, 0x0728, 0x11D3, [8]byte{0x9D, 0x7B, 0x00, 0x00, 0xF8, 0x1E, 0xF3, 0x2E}}
+	ImageFormatEXIF      = &GUID{0xB96B3CB2, 0x0728, 0x11D3, [8]byte{0x9D, 0x7B, 0x00, 0x00, 0xF8, 0x1E, 0xF3, 0x2E}}
+	ImageFormatIcon      = &GUID{0xB96B3CB5, 0x0728, 0x11D3, [8]byte{0x9D, 0x7B, 0x00, 0x00, 0xF8, 0x1E, 0xF3, 0x2E}}
+)
+
+// GDI+ encoder parameter categories, as in EncoderParameter.Guid
+var (
+	EncoderCompression      = &GUID{0xE09D739D, 0xCCD4, 0x44EE, [8]byte{0x8E, 0xBA, 0x3F, 0xBF, 0x8B, 0xE4, 0xFC, 0x58}}
+	EncoderColorDepth       = &GUID{0x66087055, 0xAD66, 0x4C7C, [8]byte{0x9A, 0x18, 0x38, 0xA2, 0x31, 0x0B, 0x83, 0x37}}
+	EncoderScanMethod       = &GUID{0x3A4E2661, 0x3109, 0x4E56, [8]byte{0x85, 0x36, 0x42, 0xC1, 0x56, 0xE7, 0xDC, 0xFA}}
+	EncoderVersion          = &GUID{0x24D18C76, 0x814A, 0x41A4, [8]byte{0xBF, 0x53, 0x1C, 0x21, 0x9C, 0xCC, 0xF7, 0x97}}
+	EncoderRenderMethod     = &GUID{0x6D42C53A, 0x229A, 0x4825, [8]byte{0x8B, 0xB7, 0x5C, 0x99, 0xE2, 0xB9, 0xA8, 0xB8}}
+	EncoderQuality          = &GUID{0x1D5BE4B5, 0xFA4A, 0x452D, [8]byte{0x9C, 0xDD, 0x5D, 0xB3, 0x51, 0x05, 0xE7, 0xEB}}
+	EncoderTransformation   = &GUID{0x8D0EB2D1, 0xA58E, 0x4EA8, [8]byte{0xAA, 0x14, 0x10, 0x80, 0x74, 0xB7, 0xB6, 0xF9}}
+	EncoderLuminanceTable   = &GUID{0xEDB33BCE, 0x0266, 0x4A77, [8]byte{0xB9, 0x04, 0x27, 0x21, 0x60, 0x99, 0xE7, 0x17}}
+	EncoderChrominanceTable = &GUID{0xF2E455DC, 0x09B3, 0x4316, [8]byte{0x82, 0x60, 0x67, 0x6A, 0xDA, 0x32, 0x48, 0x1C}}
+	EncoderSaveFlag         = &GUID{0x292266FC, 0xAC40, 0x47BF, [8]byte{0x8C, 0xFC, 0xA8, 0x5B, 0x89, 0xA6, 0x55, 0xDE}}
+)
@@ -0,0 +1,123 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package w32
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modversion = syscall.NewLazyDLL("version.dll")
+
+	procGetFileVersionInfoSize = modversion.NewProc("GetFileVersionInfoSizeW")
+	procGetFileVersionInfo     = modversion.NewProc("GetFileVersionInfoW")
+	procVerQueryValue          = modversion.NewProc("VerQueryValueW")
+)
+
+func GetFileVersionInfoSize(filename string) (uint32, error) {
+	var handle uint32
+	ret, _, _ := procGetFileVersionInfoSize.Call(
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(filename))),
+		uintptr(unsafe.Pointer(&handle)))
+
+	if ret == 0 {
+		return 0, syscall.GetLastError()
+	}
+
+	return uint32(ret), nil
+}
+
+// GetFileVersionInfo returns the raw version-information block of a file,
+// suitable for VerQueryValue or ParseVersionInfo.
+func GetFileVersionInfo(filename string) ([]byte, error) {
+	size, err := GetFileVersionInfoSize(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	block := make([]byte, size)
+	ret, _, _ := procGetFileVersionInfo.Call(
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(filename))),
+		0,
+		uintptr(size),
+		uintptr(unsafe.Pointer(&block[0])))
+
+	if ret == 0 {
+		return nil, syscall.GetLastError()
+	}
+
+	return block, nil
+}
+
+// VerQueryValue looks up subBlock in a block returned by GetFileVersionInfo.
+// The returned length is in bytes for binary values and in characters for
+// strings. It reports false for a block that is empty or shorter than the
+// length in its header, which VerQueryValueW would read past.
+func VerQueryValue(block []byte, subBlock string) (unsafe.Pointer, uint32, bool) {
+	if len(block) < 2 || int(block[0])|int(block[1])<<8 > len(block) {
+		return nil, 0, false
+	}
+	var value unsafe.Pointer
+	var length uint32
+	ret, _, _ := procVerQueryValue.Call(
+		uintptr(unsafe.Pointer(&block[0])),
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(subBlock))),
+		uintptr(unsafe.Pointer(&value)),
+		uintptr(unsafe.Pointer(&length)))
+
+	return value, length, ret != 0 && value != nil
+}
+
+// VerQueryFixedFileInfo returns the root VS_FIXEDFILEINFO of block.
+func VerQueryFixedFileInfo(block []byte) (*VS_FIXEDFILEINFO, bool) {
+	p, n, ok := VerQueryValue(block, `\`)
+	if !ok || uintptr(n) < unsafe.Sizeof(VS_FIXEDFILEINFO{}) {
+		return nil, false
+	}
+
+	ffi := *(*VS_FIXEDFILEINFO)(p)
+	return &ffi, true
+}
+
+// VerQueryTranslations returns the language/code page pairs of
+// \VarFileInfo\Translation.
+func VerQueryTranslations(block []byte) []VersionTranslation {
+	p, n, ok := VerQueryValue(block, `\VarFileInfo\Translation`)
+	if !ok {
+		return nil
+	}
+
+	translations := make([]VersionTranslation, n/4)
+	for i := range translations {
+		translations[i] = *(*VersionTranslation)(unsafe.Pointer(uintptr(p) + uintptr(4*i)))
+	}
+	return translations
+}
+
+// VerQueryString returns the \StringFileInfo value of key for the given
+// language and code page.
+func VerQueryString(block []byte, lang, codePage uint16, key string) (string, bool) {
+	p, _, ok := VerQueryValue(block, fmt.Sprintf(`\StringFileInfo\%04x%04x\%s`, lang, codePage, key))
+	if !ok {
+		return "", false
+	}
+
+	return UTF16PtrToString((*uint16)(p)), true
+}
+
+// LoadFileVersionInfo reads the version resource of a file into the same
+// VersionInfo that ParseVersionInfo and VersionInfo.Bytes work with.
+func LoadFileVersionInfo(filename string) (*VersionInfo, error) {
+	block, err := GetFileVersionInfo(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseVersionInfo(block)
+}
@@ -0,0 +1,51 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package w32
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func TestVerQueryValue(t *testing.T) {
+	block := readVersionBlock(t)
+	want := testVersionInfo()
+
+	ffi, ok := VerQueryFixedFileInfo(block)
+	if !ok || *ffi != *want.Fixed {
+		t.Errorf("fixed file info is %+v, %v", ffi, ok)
+	}
+	if tr := VerQueryTranslations(block); !reflect.DeepEqual(tr, want.Translations) {
+		t.Errorf("translations are %+v, want %+v", tr, want.Translations)
+	}
+	for _, st := range want.StringTables {
+		for _, s := range st.Strings {
+			if v, ok := VerQueryString(block, st.Lang, st.CodePage, s.Key); !ok || v != s.Value {
+				t.Errorf("%04x%04x %s is %q, %v; want %q", st.Lang, st.CodePage, s.Key, v, ok, s.Value)
+			}
+		}
+	}
+	if v, ok := VerQueryString(block, 0x0407, 1200, "ProductName"); ok {
+		t.Errorf("German ProductName is %q", v)
+	}
+	if v, ok := VerQueryString(block, 0x040C, 1200, "FileDescription"); ok {
+		t.Errorf("French FileDescription is %q", v)
+	}
+
+	// Values point into the block.
+	p, n, ok := VerQueryValue(block, `\VarFileInfo\Translation`)
+	if !ok || n != 8 || uintptr(p)-uintptr(unsafe.Pointer(&block[0])) != uintptr(len(block)-8) {
+		t.Errorf("translation value is %d bytes at %p in a block at %p", n, p, &block[0])
+	}
+
+	for _, b := range [][]byte{nil, block[:1], block[:len(block)-1]} {
+		if _, _, ok := VerQueryValue(b, `\`); ok {
+			t.Errorf("VerQueryValue accepted a %d byte block", len(b))
+		}
+	}
+}
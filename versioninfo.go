@@ -0,0 +1,354 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// VersionInfo is a decoded VS_VERSIONINFO resource: the fixed file info, the
+// StringFileInfo tables and the VarFileInfo translations.
+//
+// http://msdn.microsoft.com/en-us/library/windows/desktop/ms647001.aspx
+type VersionInfo struct {
+	Fixed        *VS_FIXEDFILEINFO
+	StringTables []VersionStringTable
+	Translations []VersionTranslation
+}
+
+// VersionStringTable is the StringTable for one language and code page, such
+// as 0x0409/1200 for the "040904b0" table.
+type VersionStringTable struct {
+	Lang     uint16
+	CodePage uint16
+	Strings  []VersionString
+}
+
+// VersionString is one key/value pair of a StringTable, e.g.
+// "FileDescription".
+type VersionString struct {
+	Key   string
+	Value string
+}
+
+// VersionTranslation is one language/code page pair of the VarFileInfo
+// Translation value.
+type VersionTranslation struct {
+	Lang     uint16
+	CodePage uint16
+}
+
+// versionNode is the generic length/value-length/type/key header shared by
+// every block of a VS_VERSIONINFO resource.
+type versionNode struct {
+	Key      string
+	Text     bool
+	Value    []byte
+	Children []*versionNode
+}
+
+const maxVersionDepth = 8
+
+// ParseVersionInfo decodes an RT_VERSION resource.
+func ParseVersionInfo(data []byte) (*VersionInfo, error) {
+	root, _, err := parseVersionNode(data, 0, len(data), 0)
+	if err != nil {
+		return nil, err
+	}
+	if root.Key != "VS_VERSION_INFO" {
+		return nil, fmt.Errorf("unexpected version resource key %q", root.Key)
+	}
+
+	vi := new(VersionInfo)
+	if len(root.Value) >= binary.Size(VS_FIXEDFILEINFO{}) {
+		ffi := new(VS_FIXEDFILEINFO)
+		binary.Read(bytes.NewReader(root.Value), binary.LittleEndian, ffi)
+		if ffi.DwSignature != VS_FFI_SIGNATURE {
+			return nil, fmt.Errorf("bad VS_FIXEDFILEINFO signature %#x", ffi.DwSignature)
+		}
+		vi.Fixed = ffi
+	}
+
+	for _, c := range root.Children {
+		switch c.Key {
+		case "StringFileInfo":
+			for _, tn := range c.Children {
+				lc, err := strconv.ParseUint(tn.Key, 16, 32)
+				if err != nil || len(tn.Key) != 8 {
+					return nil, fmt.Errorf("bad StringTable key %q", tn.Key)
+				}
+				st := VersionStringTable{
+					Lang:     uint16(lc >> 16),
+					CodePage: uint16(lc),
+				}
+				for _, sn := range tn.Children {
+					st.Strings = append(st.Strings, VersionString{sn.Key, versionText(sn.Value)})
+				}
+				vi.StringTables = append(vi.StringTables, st)
+			}
+		case "VarFileInfo":
+			for _, vn := range c.Children {
+				if vn.Key != "Translation" {
+					continue
+				}
+				for i := 0; i+4 <= len(vn.Value); i += 4 {
+					vi.Translations = append(vi.Translations, VersionTranslation{
+						Lang:     binary.LittleEndian.Uint16(vn.Value[i:]),
+						CodePage: binary.LittleEndian.Uint16(vn.Value[i+2:]),
+					})
+				}
+			}
+		}
+	}
+	return vi, nil
+}
+
+// parseVersionNode parses the block at off, which must end before end, and
+// returns it together with the offset just past it.
+func parseVersionNode(data []byte, off, end, depth int) (*versionNode, int, error) {
+	if depth > maxVersionDepth {
+		return nil, 0, errors.New("version resource is nested too deeply")
+	}
+	r := resReader{data: data[:end], pos: off}
+	length := int(r.u16())
+	if r.err != nil || length < 6 || off+length > end {
+		return nil, 0, fmt.Errorf("bad version block length at offset %#x", off)
+	}
+	nodeEnd := off + length
+	r.data = data[:nodeEnd]
+
+	valueLen := int(r.u16())
+	n := &versionNode{Text: r.u16() == 1}
+	n.Key = r.sz()
+	r.align(4)
+	if n.Text {
+		valueLen *= 2
+	}
+	if valueLen > nodeEnd-r.pos {
+		// Some linkers store text lengths in bytes rather than WORDs.
+		valueLen = nodeEnd - r.pos
+	}
+	n.Value = r.bytes(valueLen)
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+
+	for {
+		r.align(4)
+		if r.pos >= nodeEnd {
+			break
+		}
+		c, next, err := parseVersionNode(data, r.pos, nodeEnd, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		n.Children = append(n.Children, c)
+		r.pos = next
+	}
+	return n, nodeEnd, nil
+}
+
+func versionText(b []byte) string {
+	r := resReader{data: b}
+	return strings.TrimRight(r.utf16(len(b)/2), "\x00")
+}
+
+// StringValue returns the value of key from the first StringTable that has
+// it, the way VerQueryValue is usually combined with the first translation.
+func (vi *VersionInfo) StringValue(key string) (string, bool) {
+	for _, st := range vi.StringTables {
+		for _, s := range st.Strings {
+			if s.Key == key {
+				return s.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// SetString sets key in the StringTable for lang and codePage, adding the
+// table and its VarFileInfo translation when they do not exist yet.
+func (vi *VersionInfo) SetString(lang, codePage uint16, key, value string) {
+	var st *VersionStringTable
+	for i := range vi.StringTables {
+		if vi.StringTables[i].Lang == lang && vi.StringTables[i].CodePage == codePage {
+			st = &vi.StringTables[i]
+			break
+		}
+	}
+	if st == nil {
+		vi.StringTables = append(vi.StringTables, VersionStringTable{Lang: lang, CodePage: codePage})
+		st = &vi.StringTables[len(vi.StringTables)-1]
+	}
+	for i := range st.Strings {
+		if st.Strings[i].Key == key {
+			st.Strings[i].Value = value
+			return
+		}
+	}
+	st.Strings = append(st.Strings, VersionString{key, value})
+
+	for _, t := range vi.Translations {
+		if t.Lang == lang && t.CodePage == codePage {
+			return
+		}
+	}
+	vi.Translations = append(vi.Translations, VersionTranslation{lang, codePage})
+}
+
+// NewFixedFileInfo returns a VS_FIXEDFILEINFO for a Win32 application with
+// the given file and product versions.
+func NewFixedFileInfo(fileVersion, productVersion [4]uint16) *VS_FIXEDFILEINFO {
+	ffi := &VS_FIXEDFILEINFO{
+		DwSignature:     VS_FFI_SIGNATURE,
+		DwStrucVersion:  VS_FFI_STRUCVERSION,
+		DwFileFlagsMask: VS_FFI_FILEFLAGSMASK,
+		DwFileOS:        VOS_NT_WINDOWS32,
+		DwFileType:      VFT_APP,
+	}
+	ffi.SetFileVersion(fileVersion)
+	ffi.SetProductVersion(productVersion)
+	return ffi
+}
+
+// FileVersion returns the file version as major, minor, patch and build.
+func (ffi *VS_FIXEDFILEINFO) FileVersion() [4]uint16 {
+	return unpackVersion(ffi.DwFileVersionMS, ffi.DwFileVersionLS)
+}
+
+// ProductVersion returns the product version as major, minor, patch and
+// build.
+func (ffi *VS_FIXEDFILEINFO) ProductVersion() [4]uint16 {
+	return unpackVersion(ffi.DwProductVersionMS, ffi.DwProductVersionLS)
+}
+
+// SetFileVersion sets DwFileVersionMS and DwFileVersionLS.
+func (ffi *VS_FIXEDFILEINFO) SetFileVersion(v [4]uint16) {
+	ffi.DwFileVersionMS, ffi.DwFileVersionLS = packVersion(v)
+}
+
+// SetProductVersion sets DwProductVersionMS and DwProductVersionLS.
+func (ffi *VS_FIXEDFILEINFO) SetProductVersion(v [4]uint16) {
+	ffi.DwProductVersionMS, ffi.DwProductVersionLS = packVersion(v)
+}
+
+func packVersion(v [4]uint16) (ms, ls uint32) {
+	return uint32(v[0])<<16 | uint32(v[1]), uint32(v[2])<<16 | uint32(v[3])
+}
+
+func unpackVersion(ms, ls uint32) [4]uint16 {
+	return [4]uint16{uint16(ms >> 16), uint16(ms), uint16(ls >> 16), uint16(ls)}
+}
+
+// ParseVersionString parses a "1.2.3.4" style version. Missing trailing
+// components are zero.
+func ParseVersionString(s string) ([4]uint16, error) {
+	var v [4]uint16
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) > 4 {
+		return v, fmt.Errorf("version %q has more than four components", s)
+	}
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return v, fmt.Errorf("bad version %q: %v", s, err)
+		}
+		v[i] = uint16(n)
+	}
+	return v, nil
+}
+
+// Bytes encodes vi as an RT_VERSION resource with the WORD lengths and
+// DWORD padding that the version APIs expect. A nil Fixed is written as an
+// empty value.
+func (vi *VersionInfo) Bytes() []byte {
+	var fixed []byte
+	if vi.Fixed != nil {
+		var b bytes.Buffer
+		binary.Write(&b, binary.LittleEndian, vi.Fixed)
+		fixed = b.Bytes()
+	}
+
+	root := &versionNode{Key: "VS_VERSION_INFO", Value: fixed}
+	if len(vi.StringTables) > 0 {
+		sfi := &versionNode{Key: "StringFileInfo", Text: true}
+		for _, st := range vi.StringTables {
+			tn := &versionNode{
+				Key:  fmt.Sprintf("%04x%04x", st.Lang, st.CodePage),
+				Text: true,
+			}
+			for _, s := range st.Strings {
+				tn.Children = append(tn.Children, &versionNode{
+					Key:   s.Key,
+					Text:  true,
+					Value: utf16Bytes(s.Value, true),
+				})
+			}
+			sfi.Children = append(sfi.Children, tn)
+		}
+		root.Children = append(root.Children, sfi)
+	}
+	if len(vi.Translations) > 0 {
+		var b bytes.Buffer
+		for _, t := range vi.Translations {
+			binary.Write(&b, binary.LittleEndian, t)
+		}
+		root.Children = append(root.Children, &versionNode{
+			Key:      "VarFileInfo",
+			Text:     true,
+			Children: []*versionNode{{Key: "Translation", Value: b.Bytes()}},
+		})
+	}
+
+	var b bytes.Buffer
+	root.write(&b)
+	return b.Bytes()
+}
+
+// write appends the node at the current, DWORD-aligned end of b. The node's
+// own length excludes any trailing padding.
+func (n *versionNode) write(b *bytes.Buffer) {
+	start := b.Len()
+	valueLen := len(n.Value)
+	var typ uint16
+	if n.Text {
+		valueLen /= 2
+		typ = 1
+	}
+	binary.Write(b, binary.LittleEndian, [3]uint16{0, uint16(valueLen), typ})
+	b.Write(utf16Bytes(n.Key, true))
+	padTo4(b)
+	b.Write(n.Value)
+	for _, c := range n.Children {
+		padTo4(b)
+		c.write(b)
+	}
+	binary.LittleEndian.PutUint16(b.Bytes()[start:], uint16(b.Len()-start))
+}
+
+func padTo4(b *bytes.Buffer) {
+	for b.Len()%4 != 0 {
+		b.WriteByte(0)
+	}
+}
+
+// utf16Bytes encodes s as little-endian UTF-16, optionally NUL-terminated.
+func utf16Bytes(s string, nul bool) []byte {
+	u := utf16.Encode([]rune(s))
+	if nul {
+		u = append(u, 0)
+	}
+	b := make([]byte, 2*len(u))
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return b
+}
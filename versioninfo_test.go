@@ -0,0 +1,100 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readVersionBlock reads testdata/version/app.bin, a VS_VERSIONINFO laid out
+// by hand the way rc.exe does: file version 1.2.3.4, an English and a German
+// StringTable and both translations.
+func readVersionBlock(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "version", "app.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func testVersionInfo() *VersionInfo {
+	vi := &VersionInfo{Fixed: NewFixedFileInfo([4]uint16{1, 2, 3, 4}, [4]uint16{1, 2, 0, 0})}
+	vi.SetString(0x0409, 1200, "CompanyName", "The W32 Authors")
+	vi.SetString(0x0409, 1200, "FileDescription", "Résumé")
+	vi.SetString(0x0409, 1200, "ProductName", "w32")
+	vi.SetString(0x0407, 1200, "FileDescription", "Lebenslauf")
+	return vi
+}
+
+func TestVersionInfoGolden(t *testing.T) {
+	data := readVersionBlock(t)
+	want := testVersionInfo()
+
+	got, err := ParseVersionInfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Fixed == nil || *got.Fixed != *want.Fixed {
+		t.Errorf("fixed file info is %+v, want %+v", got.Fixed, want.Fixed)
+	}
+	if !reflect.DeepEqual(got.StringTables, want.StringTables) {
+		t.Errorf("string tables are %+v, want %+v", got.StringTables, want.StringTables)
+	}
+	if !reflect.DeepEqual(got.Translations, want.Translations) {
+		t.Errorf("translations are %+v, want %+v", got.Translations, want.Translations)
+	}
+	if v, ok := got.StringValue("FileDescription"); !ok || v != "Résumé" {
+		t.Errorf("FileDescription is %q, %v", v, ok)
+	}
+	if v, ok := got.StringValue("LegalCopyright"); ok {
+		t.Errorf("LegalCopyright is %q", v)
+	}
+
+	if b := want.Bytes(); !bytes.Equal(b, data) {
+		t.Errorf("Bytes() gave\n%x\nwant\n%x", b, data)
+	}
+	if b := got.Bytes(); !bytes.Equal(b, data) {
+		t.Error("parsing and encoding the block changed it")
+	}
+
+	// The values where VerQueryValue finds them.
+	le := binary.LittleEndian
+	if le.Uint16(data) != uint16(len(data)) || le.Uint32(data[0x28:]) != VS_FFI_SIGNATURE {
+		t.Errorf("root is %d bytes, signature %#x", le.Uint16(data), le.Uint32(data[0x28:]))
+	}
+	if tr := data[len(data)-8:]; le.Uint16(tr) != 0x0409 || le.Uint16(tr[2:]) != 1200 || le.Uint16(tr[4:]) != 0x0407 {
+		t.Errorf("translation value is %x", tr)
+	}
+}
+
+func TestParseVersionInfoErrors(t *testing.T) {
+	data := readVersionBlock(t)
+	patch := func(off int, v uint32) []byte {
+		b := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint32(b[off:], v)
+		return b
+	}
+	for _, tt := range []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"empty", nil, "bad version block length at offset 0x0"},
+		{"truncated", data[:len(data)-1], "bad version block length at offset 0x0"},
+		{"bad child length", patch(0x5C, 0x10000), "bad version block length at offset 0x5c"},
+		{"bad key", patch(6, 'X'|'S'<<16), `unexpected version resource key "XS_VERSION_INFO"`},
+		{"bad signature", patch(0x28, 0), "bad VS_FIXEDFILEINFO signature 0x0"},
+	} {
+		if _, err := ParseVersionInfo(tt.data); err == nil || err.Error() != tt.err {
+			t.Errorf("%s: error is %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
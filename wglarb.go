@@ -0,0 +1,219 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"strings"
+)
+
+// WGLContextAttribs describes the OpenGL context to request from
+// wglCreateContextAttribsARB. A zero value asks for the driver's default,
+// a legacy context of the highest version it can provide.
+type WGLContextAttribs struct {
+	Major, Minor int
+	// Profile is WGL_CONTEXT_CORE_PROFILE_BIT_ARB,
+	// WGL_CONTEXT_COMPATIBILITY_PROFILE_BIT_ARB or 0 for the default, the
+	// core profile. Profiles only exist from OpenGL 3.2 on.
+	Profile           int32
+	Debug             bool
+	ForwardCompatible bool // remove deprecated features, from OpenGL 3.0 on
+}
+
+// CoreContext returns the attributes of a core profile context of the
+// given version.
+func CoreContext(major, minor int) WGLContextAttribs {
+	return WGLContextAttribs{Major: major, Minor: minor, Profile: WGL_CONTEXT_CORE_PROFILE_BIT_ARB}
+}
+
+// CompatibilityContext returns the attributes of a compatibility profile
+// context of the given version.
+func CompatibilityContext(major, minor int) WGLContextAttribs {
+	return WGLContextAttribs{Major: major, Minor: minor, Profile: WGL_CONTEXT_COMPATIBILITY_PROFILE_BIT_ARB}
+}
+
+// List returns the zero terminated attribute list for
+// wglCreateContextAttribsARB. Attributes at their default are left out.
+func (a WGLContextAttribs) List() []int32 {
+	var l []int32
+	if a.Major != 0 {
+		l = append(l,
+			WGL_CONTEXT_MAJOR_VERSION_ARB, int32(a.Major),
+			WGL_CONTEXT_MINOR_VERSION_ARB, int32(a.Minor))
+	}
+	var flags int32
+	if a.Debug {
+		flags |= WGL_CONTEXT_DEBUG_BIT_ARB
+	}
+	if a.ForwardCompatible {
+		flags |= WGL_CONTEXT_FORWARD_COMPATIBLE_BIT_ARB
+	}
+	if flags != 0 {
+		l = append(l, WGL_CONTEXT_FLAGS_ARB, flags)
+	}
+	if a.Profile != 0 {
+		l = append(l, WGL_CONTEXT_PROFILE_MASK_ARB, a.Profile)
+	}
+	return append(l, 0)
+}
+
+// WGLAttribs returns the zero terminated attribute list for
+// wglChoosePixelFormatARB requesting an accelerated RGBA format that meets
+// spec. Samples and SRGB need WGL_ARB_multisample and
+// WGL_ARB_framebuffer_sRGB.
+func (spec PixelFormatSpec) WGLAttribs() []int32 {
+	l := []int32{
+		WGL_SUPPORT_OPENGL_ARB, TRUE,
+		WGL_ACCELERATION_ARB, WGL_FULL_ACCELERATION_ARB,
+		WGL_PIXEL_TYPE_ARB, WGL_TYPE_RGBA_ARB,
+	}
+	if spec.DrawToBitmap {
+		l = append(l, WGL_DRAW_TO_BITMAP_ARB, TRUE)
+	} else {
+		l = append(l, WGL_DRAW_TO_WINDOW_ARB, TRUE)
+	}
+	if spec.DoubleBuffer {
+		l = append(l, WGL_DOUBLE_BUFFER_ARB, TRUE)
+	}
+	bits := []struct {
+		attrib int32
+		n      int
+	}{
+		{WGL_COLOR_BITS_ARB, spec.ColorBits},
+		{WGL_ALPHA_BITS_ARB, spec.AlphaBits},
+		{WGL_DEPTH_BITS_ARB, spec.DepthBits},
+		{WGL_STENCIL_BITS_ARB, spec.StencilBits},
+	}
+	for _, b := range bits {
+		if b.n != 0 {
+			l = append(l, b.attrib, int32(b.n))
+		}
+	}
+	if spec.Samples > 0 {
+		l = append(l, WGL_SAMPLE_BUFFERS_ARB, TRUE, WGL_SAMPLES_ARB, int32(spec.Samples))
+	}
+	if spec.SRGB {
+		l = append(l, WGL_FRAMEBUFFER_SRGB_CAPABLE_ARB, TRUE)
+	}
+	return append(l, 0)
+}
+
+// wglPixelFormatQuery returns the attributes to read through
+// wglGetPixelFormatAttribivARB to fill a PixelFormatCandidate. The driver
+// fails the whole query on attributes of extensions it lacks, so those
+// are only asked for when ext has them.
+func wglPixelFormatQuery(ext WGLExtensions) []int32 {
+	q := []int32{
+		WGL_DRAW_TO_WINDOW_ARB, WGL_DRAW_TO_BITMAP_ARB, WGL_ACCELERATION_ARB,
+		WGL_SUPPORT_GDI_ARB, WGL_SUPPORT_OPENGL_ARB, WGL_DOUBLE_BUFFER_ARB,
+		WGL_STEREO_ARB, WGL_PIXEL_TYPE_ARB, WGL_COLOR_BITS_ARB,
+		WGL_RED_BITS_ARB, WGL_GREEN_BITS_ARB, WGL_BLUE_BITS_ARB,
+		WGL_ALPHA_BITS_ARB, WGL_ACCUM_BITS_ARB, WGL_DEPTH_BITS_ARB,
+		WGL_STENCIL_BITS_ARB, WGL_AUX_BUFFERS_ARB, WGL_SWAP_METHOD_ARB,
+	}
+	if ext.Has("WGL_ARB_multisample") {
+		q = append(q, WGL_SAMPLES_ARB)
+	}
+	if ext.Has("WGL_ARB_framebuffer_sRGB") || ext.Has("WGL_EXT_framebuffer_sRGB") {
+		q = append(q, WGL_FRAMEBUFFER_SRGB_CAPABLE_ARB)
+	}
+	return q
+}
+
+// wglPixelFormatCandidate builds the candidate for format index from the
+// values wglGetPixelFormatAttribivARB returned for attribs, translating
+// them to the PIXELFORMATDESCRIPTOR flags ScorePixelFormat looks at.
+func wglPixelFormatCandidate(index int, attribs, values []int32) PixelFormatCandidate {
+	c := PixelFormatCandidate{Index: index}
+	pfd := &c.PFD
+	pfd.Version = 1
+	pfd.ILayerType = PFD_MAIN_PLANE
+	flag := func(v int32, f uint32) {
+		if v != 0 {
+			pfd.DwFlags |= f
+		}
+	}
+	for i, a := range attribs {
+		if i >= len(values) {
+			break
+		}
+		v := values[i]
+		switch a {
+		case WGL_DRAW_TO_WINDOW_ARB:
+			flag(v, PFD_DRAW_TO_WINDOW)
+		case WGL_DRAW_TO_BITMAP_ARB:
+			flag(v, PFD_DRAW_TO_BITMAP)
+		case WGL_SUPPORT_GDI_ARB:
+			flag(v, PFD_SUPPORT_GDI)
+		case WGL_SUPPORT_OPENGL_ARB:
+			flag(v, PFD_SUPPORT_OPENGL)
+		case WGL_DOUBLE_BUFFER_ARB:
+			flag(v, PFD_DOUBLEBUFFER)
+		case WGL_STEREO_ARB:
+			flag(v, PFD_STEREO)
+		case WGL_ACCELERATION_ARB:
+			switch v {
+			case WGL_NO_ACCELERATION_ARB:
+				pfd.DwFlags |= PFD_GENERIC_FORMAT
+			case WGL_GENERIC_ACCELERATION_ARB:
+				pfd.DwFlags |= PFD_GENERIC_FORMAT | PFD_GENERIC_ACCELERATED
+			}
+		case WGL_SWAP_METHOD_ARB:
+			switch v {
+			case WGL_SWAP_EXCHANGE_ARB:
+				pfd.DwFlags |= PFD_SWAP_EXCHANGE
+			case WGL_SWAP_COPY_ARB:
+				pfd.DwFlags |= PFD_SWAP_COPY
+			}
+		case WGL_PIXEL_TYPE_ARB:
+			if v == WGL_TYPE_COLORINDEX_ARB {
+				pfd.IPixelType = PFD_TYPE_COLORINDEX
+			} else {
+				pfd.IPixelType = PFD_TYPE_RGBA
+			}
+		case WGL_COLOR_BITS_ARB:
+			pfd.ColorBits = byte(v)
+		case WGL_RED_BITS_ARB:
+			pfd.RedBits = byte(v)
+		case WGL_GREEN_BITS_ARB:
+			pfd.GreenBits = byte(v)
+		case WGL_BLUE_BITS_ARB:
+			pfd.BlueBits = byte(v)
+		case WGL_ALPHA_BITS_ARB:
+			pfd.AlphaBits = byte(v)
+		case WGL_ACCUM_BITS_ARB:
+			pfd.AccumBits = byte(v)
+		case WGL_DEPTH_BITS_ARB:
+			pfd.DepthBits = byte(v)
+		case WGL_STENCIL_BITS_ARB:
+			pfd.StencilBits = byte(v)
+		case WGL_AUX_BUFFERS_ARB:
+			pfd.AuxBuffers = byte(v)
+		case WGL_SAMPLES_ARB:
+			c.Samples = int(v)
+		case WGL_FRAMEBUFFER_SRGB_CAPABLE_ARB:
+			c.SRGB = v != 0
+		}
+	}
+	return c
+}
+
+// WGLExtensions is the set of extensions named in a WGL or GL extensions
+// string.
+type WGLExtensions map[string]bool
+
+// ParseWGLExtensions splits the space separated string returned by
+// wglGetExtensionsStringARB.
+func ParseWGLExtensions(s string) WGLExtensions {
+	ext := make(WGLExtensions)
+	for _, name := range strings.Fields(s) {
+		ext[name] = true
+	}
+	return ext
+}
+
+// Has reports whether the extension name is present.
+func (ext WGLExtensions) Has(name string) bool {
+	return ext[name]
+}
@@ -0,0 +1,202 @@
+// Copyright 2010-2012 The W32 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package w32
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWGLContextAttribsList(t *testing.T) {
+	tests := []struct {
+		name  string
+		attrs WGLContextAttribs
+		want  []int32
+	}{
+		{"default", WGLContextAttribs{}, []int32{0}},
+		{"legacy version", WGLContextAttribs{Major: 2, Minor: 1}, []int32{
+			WGL_CONTEXT_MAJOR_VERSION_ARB, 2, WGL_CONTEXT_MINOR_VERSION_ARB, 1, 0,
+		}},
+		{"core", CoreContext(3, 3), []int32{
+			WGL_CONTEXT_MAJOR_VERSION_ARB, 3, WGL_CONTEXT_MINOR_VERSION_ARB, 3,
+			WGL_CONTEXT_PROFILE_MASK_ARB, WGL_CONTEXT_CORE_PROFILE_BIT_ARB, 0,
+		}},
+		{"compatibility", CompatibilityContext(4, 6), []int32{
+			WGL_CONTEXT_MAJOR_VERSION_ARB, 4, WGL_CONTEXT_MINOR_VERSION_ARB, 6,
+			WGL_CONTEXT_PROFILE_MASK_ARB, WGL_CONTEXT_COMPATIBILITY_PROFILE_BIT_ARB, 0,
+		}},
+		{"debug", WGLContextAttribs{Major: 4, Minor: 5, Profile: WGL_CONTEXT_CORE_PROFILE_BIT_ARB, Debug: true}, []int32{
+			WGL_CONTEXT_MAJOR_VERSION_ARB, 4, WGL_CONTEXT_MINOR_VERSION_ARB, 5,
+			WGL_CONTEXT_FLAGS_ARB, WGL_CONTEXT_DEBUG_BIT_ARB,
+			WGL_CONTEXT_PROFILE_MASK_ARB, WGL_CONTEXT_CORE_PROFILE_BIT_ARB, 0,
+		}},
+		{"debug forward compatible", WGLContextAttribs{Major: 3, Debug: true, ForwardCompatible: true}, []int32{
+			WGL_CONTEXT_MAJOR_VERSION_ARB, 3, WGL_CONTEXT_MINOR_VERSION_ARB, 0,
+			WGL_CONTEXT_FLAGS_ARB, WGL_CONTEXT_DEBUG_BIT_ARB | WGL_CONTEXT_FORWARD_COMPATIBLE_BIT_ARB, 0,
+		}},
+		{"flags only", WGLContextAttribs{ForwardCompatible: true}, []int32{
+			WGL_CONTEXT_FLAGS_ARB, WGL_CONTEXT_FORWARD_COMPATIBLE_BIT_ARB, 0,
+		}},
+	}
+	for _, tt := range tests {
+		if got := tt.attrs.List(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: List() = %#x, want %#x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPixelFormatSpecWGLAttribs(t *testing.T) {
+	base := []int32{
+		WGL_SUPPORT_OPENGL_ARB, TRUE,
+		WGL_ACCELERATION_ARB, WGL_FULL_ACCELERATION_ARB,
+		WGL_PIXEL_TYPE_ARB, WGL_TYPE_RGBA_ARB,
+	}
+	with := func(l ...int32) []int32 {
+		return append(append(append([]int32(nil), base...), l...), 0)
+	}
+	tests := []struct {
+		name string
+		spec PixelFormatSpec
+		want []int32
+	}{
+		{"zero", PixelFormatSpec{}, with(WGL_DRAW_TO_WINDOW_ARB, TRUE)},
+		{"bitmap", PixelFormatSpec{DrawToBitmap: true}, with(WGL_DRAW_TO_BITMAP_ARB, TRUE)},
+		{"typical", PixelFormatSpec{ColorBits: 24, AlphaBits: 8, DepthBits: 24, StencilBits: 8, DoubleBuffer: true}, with(
+			WGL_DRAW_TO_WINDOW_ARB, TRUE,
+			WGL_DOUBLE_BUFFER_ARB, TRUE,
+			WGL_COLOR_BITS_ARB, 24,
+			WGL_ALPHA_BITS_ARB, 8,
+			WGL_DEPTH_BITS_ARB, 24,
+			WGL_STENCIL_BITS_ARB, 8,
+		)},
+		{"multisample sRGB", PixelFormatSpec{ColorBits: 32, Samples: 4, SRGB: true}, with(
+			WGL_DRAW_TO_WINDOW_ARB, TRUE,
+			WGL_COLOR_BITS_ARB, 32,
+			WGL_SAMPLE_BUFFERS_ARB, TRUE, WGL_SAMPLES_ARB, 4,
+			WGL_FRAMEBUFFER_SRGB_CAPABLE_ARB, TRUE,
+		)},
+	}
+	for _, tt := range tests {
+		if got := tt.spec.WGLAttribs(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: WGLAttribs() = %#x, want %#x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWGLPixelFormatQuery(t *testing.T) {
+	plain := wglPixelFormatQuery(nil)
+	for _, a := range plain {
+		if a == WGL_SAMPLES_ARB || a == WGL_FRAMEBUFFER_SRGB_CAPABLE_ARB {
+			t.Errorf("query without extensions asks for %#x", a)
+		}
+	}
+	ext := ParseWGLExtensions("WGL_ARB_multisample WGL_EXT_framebuffer_sRGB")
+	full := wglPixelFormatQuery(ext)
+	want := append(append([]int32(nil), plain...), WGL_SAMPLES_ARB, WGL_FRAMEBUFFER_SRGB_CAPABLE_ARB)
+	if !reflect.DeepEqual(full, want) {
+		t.Errorf("query with extensions = %#x, want %#x", full, want)
+	}
+}
+
+func TestWGLPixelFormatCandidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		attribs []int32
+		values  []int32
+		want    PixelFormatCandidate
+	}{
+		{
+			"full acceleration",
+			[]int32{
+				WGL_DRAW_TO_WINDOW_ARB, WGL_SUPPORT_OPENGL_ARB, WGL_DOUBLE_BUFFER_ARB,
+				WGL_ACCELERATION_ARB, WGL_SWAP_METHOD_ARB, WGL_PIXEL_TYPE_ARB,
+				WGL_COLOR_BITS_ARB, WGL_RED_BITS_ARB, WGL_GREEN_BITS_ARB, WGL_BLUE_BITS_ARB,
+				WGL_ALPHA_BITS_ARB, WGL_DEPTH_BITS_ARB, WGL_STENCIL_BITS_ARB,
+				WGL_SAMPLES_ARB, WGL_FRAMEBUFFER_SRGB_CAPABLE_ARB,
+			},
+			[]int32{
+				TRUE, TRUE, TRUE,
+				WGL_FULL_ACCELERATION_ARB, WGL_SWAP_EXCHANGE_ARB, WGL_TYPE_RGBA_ARB,
+				24, 8, 8, 8,
+				8, 24, 8,
+				4, TRUE,
+			},
+			PixelFormatCandidate{Index: 3, Samples: 4, SRGB: true, PFD: PIXELFORMATDESCRIPTOR{
+				Version:    1,
+				DwFlags:    PFD_DRAW_TO_WINDOW | PFD_SUPPORT_OPENGL | PFD_DOUBLEBUFFER | PFD_SWAP_EXCHANGE,
+				IPixelType: PFD_TYPE_RGBA,
+				ColorBits:  24, RedBits: 8, GreenBits: 8, BlueBits: 8,
+				AlphaBits: 8, DepthBits: 24, StencilBits: 8,
+				ILayerType: PFD_MAIN_PLANE,
+			}},
+		},
+		{
+			"generic acceleration",
+			[]int32{WGL_ACCELERATION_ARB, WGL_SWAP_METHOD_ARB, WGL_DRAW_TO_BITMAP_ARB, WGL_SUPPORT_GDI_ARB},
+			[]int32{WGL_GENERIC_ACCELERATION_ARB, WGL_SWAP_COPY_ARB, TRUE, TRUE},
+			PixelFormatCandidate{Index: 1, PFD: PIXELFORMATDESCRIPTOR{
+				Version:    1,
+				DwFlags:    PFD_GENERIC_FORMAT | PFD_GENERIC_ACCELERATED | PFD_SWAP_COPY | PFD_DRAW_TO_BITMAP | PFD_SUPPORT_GDI,
+				ILayerType: PFD_MAIN_PLANE,
+			}},
+		},
+		{
+			"software color index",
+			[]int32{WGL_ACCELERATION_ARB, WGL_PIXEL_TYPE_ARB, WGL_STEREO_ARB, WGL_ACCUM_BITS_ARB, WGL_AUX_BUFFERS_ARB},
+			[]int32{WGL_NO_ACCELERATION_ARB, WGL_TYPE_COLORINDEX_ARB, TRUE, 64, 2},
+			PixelFormatCandidate{Index: 2, PFD: PIXELFORMATDESCRIPTOR{
+				Version:    1,
+				DwFlags:    PFD_GENERIC_FORMAT | PFD_STEREO,
+				IPixelType: PFD_TYPE_COLORINDEX,
+				AccumBits:  64, AuxBuffers: 2,
+				ILayerType: PFD_MAIN_PLANE,
+			}},
+		},
+		{
+			"false flags and short values",
+			[]int32{WGL_DRAW_TO_WINDOW_ARB, WGL_DOUBLE_BUFFER_ARB, WGL_DEPTH_BITS_ARB},
+			[]int32{FALSE, FALSE},
+			PixelFormatCandidate{Index: 5, PFD: PIXELFORMATDESCRIPTOR{Version: 1, ILayerType: PFD_MAIN_PLANE}},
+		},
+	}
+	for _, tt := range tests {
+		got := wglPixelFormatCandidate(tt.want.Index, tt.attribs, tt.values)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseWGLExtensions(t *testing.T) {
+	tests := []struct {
+		in   string
+		want WGLExtensions
+	}{
+		{"", WGLExtensions{}},
+		{"   ", WGLExtensions{}},
+		{"WGL_ARB_pixel_format", WGLExtensions{"WGL_ARB_pixel_format": true}},
+		{" WGL_ARB_multisample  WGL_EXT_swap_control\tWGL_ARB_create_context \n", WGLExtensions{
+			"WGL_ARB_multisample":    true,
+			"WGL_EXT_swap_control":   true,
+			"WGL_ARB_create_context": true,
+		}},
+		{"WGL_ARB_multisample WGL_ARB_multisample", WGLExtensions{"WGL_ARB_multisample": true}},
+	}
+	for _, tt := range tests {
+		if got := ParseWGLExtensions(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseWGLExtensions(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	ext := ParseWGLExtensions("WGL_ARB_create_context_profile")
+	if ext.Has("WGL_ARB_create_context") {
+		t.Error("Has matched a prefix of an extension name")
+	}
+	if !ext.Has("WGL_ARB_create_context_profile") {
+		t.Error("Has missed an extension")
+	}
+	if WGLExtensions(nil).Has("WGL_ARB_pixel_format") {
+		t.Error("nil set has an extension")
+	}
+}